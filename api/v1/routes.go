@@ -7,6 +7,7 @@ import (
         "dnd-combat/internal/auth"
         "dnd-combat/internal/character"
         "dnd-combat/internal/combat"
+        "dnd-combat/internal/dice"
         "dnd-combat/internal/game"
         "dnd-combat/pkg/database"
         "dnd-combat/pkg/dnd5e"
@@ -15,31 +16,33 @@ import (
 
 // SetupRoutes configures all API routes
 func SetupRoutes(r *gin.Engine, db *database.DB, srdClient *dnd5e.SRDClient, wsHub *websocket.Hub, cfg *config.Config) {
-        // Create dice roller and combat rules
-        diceRoller := dnd5e.NewDiceRoller()
-        combatRules := dnd5e.NewCombatRules(diceRoller)
-
         // Auth setup
         authRepo := auth.NewRepository(db)
         authService := auth.NewService(authRepo, cfg)
         authHandler := auth.NewHandler(authService)
         authMiddleware := auth.NewMiddleware(authService)
 
-        // Character setup
-        characterRepo := character.NewRepository(db)
-        characterService := character.NewService(characterRepo)
-        characterHandler := character.NewHandler(characterService)
+        // SRD data is shared by characters and combat
+        srdClientAdapter := dnd5e.NewSRDClientAdapter(srdClient)
 
         // Game setup
         gameRepo := game.NewRepository(db)
         gameService := game.NewService(gameRepo)
-        gameHandler := game.NewHandler(gameService)
+        gameHandler := game.NewHandler(gameService, wsHub)
+
+        // Character setup
+        characterRepo := character.NewRepository(db)
+        characterService := character.NewService(characterRepo, srdClientAdapter)
+        characterHandler := character.NewHandler(characterService, gameService, wsHub)
 
         // Combat setup
         combatRepo := combat.NewRepository(db)
-        combatService := combat.NewService(combatRepo, diceRoller, combatRules)
-        srdClientAdapter := dnd5e.NewSRDClientAdapter(srdClient)
-        combatHandler := combat.NewHandler(combatService, characterService, srdClientAdapter, wsHub)
+        reactionBroker := combat.NewReactionBroker(wsHub, cfg.ReactionTimeout)
+        combatService := combat.NewService(combatRepo, srdClientAdapter, reactionBroker, combat.NewHeuristicBrain())
+        combatHandler := combat.NewHandler(combatService, characterService, srdClientAdapter, wsHub, cfg.MonsterStepDelay)
+
+        // Dice setup
+        diceHandler := dice.NewHandler(combatService, gameService, wsHub)
 
         // Public routes (no auth required)
         publicRoutes := r.Group("/api/v1")
@@ -55,6 +58,7 @@ func SetupRoutes(r *gin.Engine, db *database.DB, srdClient *dnd5e.SRDClient, wsH
                 wsGroup := publicRoutes.Group("/ws")
                 {
                         wsGroup.GET("/combat/:id", authMiddleware.RequireAuth(), combatHandler.WebSocketHandler)
+                        wsGroup.GET("/game/:id", authMiddleware.RequireAuth(), gameHandler.WebSocketHandler)
                 }
         }
 
@@ -68,6 +72,18 @@ func SetupRoutes(r *gin.Engine, db *database.DB, srdClient *dnd5e.SRDClient, wsH
                         characterGroup.POST("", characterHandler.Create)
                         characterGroup.GET("/:id", characterHandler.Get)
                         characterGroup.GET("", characterHandler.List)
+                        characterGroup.POST("/checks", characterHandler.GroupCheck)
+                        characterGroup.POST("/saves", characterHandler.GroupSave)
+                        characterGroup.POST("/ability-rolls", characterHandler.RollAbilityScores)
+                        characterGroup.PUT("/:id", characterHandler.Update)
+                        characterGroup.PATCH("/:id", characterHandler.Patch)
+                        characterGroup.DELETE("/:id", characterHandler.Delete)
+                        characterGroup.POST("/:id/level-up", characterHandler.LevelUp)
+                        characterGroup.POST("/:id/short-rest", characterHandler.ShortRest)
+                        characterGroup.POST("/:id/long-rest", characterHandler.LongRest)
+                        characterGroup.POST("/:id/check", characterHandler.Check)
+                        characterGroup.POST("/:id/save", characterHandler.Save)
+                        characterGroup.PUT("/:id/inventory", characterHandler.UpdateInventory)
                 }
 
                 // Game routes
@@ -84,8 +100,27 @@ func SetupRoutes(r *gin.Engine, db *database.DB, srdClient *dnd5e.SRDClient, wsH
                 {
                         combatGroup.POST("", combatHandler.InitiateCombat)
                         combatGroup.GET("/:id", combatHandler.GetCombat)
+                        combatGroup.GET("/:id/rolls", combatHandler.GetRolls)
                         combatGroup.POST("/:id/action", combatHandler.PerformAction)
                         combatGroup.POST("/:id/end-turn", combatHandler.EndTurn)
+                        combatGroup.POST("/:id/reactions/:reaction_id", combatHandler.RespondToReaction)
+                        combatGroup.PUT("/:id/automation", combatHandler.SetAutomation)
+                        combatGroup.POST("/:id/initiative", combatHandler.EnterInitiative)
+                        combatGroup.POST("/:id/initiative/:actor_id/review", combatHandler.ReviewInitiative)
+                        combatGroup.PUT("/:id/initiative", combatHandler.ReorderInitiative)
+                        combatGroup.POST("/:id/delay", combatHandler.DelayTurn)
+                        combatGroup.POST("/:id/combatants", combatHandler.AddCombatant)
+                        combatGroup.DELETE("/:id/combatants/:combatant_id", combatHandler.RemoveCombatant)
+                        combatGroup.POST("/:id/combatants/:combatant_id/flee", combatHandler.FleeCombat)
+                        combatGroup.PATCH("/:id/override/combatants/:combatant_id", combatHandler.OverrideCombatant)
+                        combatGroup.POST("/:id/override/turn", combatHandler.OverrideTurn)
+                        combatGroup.PUT("/:id/override/status", combatHandler.OverrideStatus)
+                }
+
+                // Dice routes
+                diceGroup := protectedRoutes.Group("/dice")
+                {
+                        diceGroup.POST("/roll", diceHandler.Roll)
                 }
         }
 
@@ -4,6 +4,8 @@ import (
 	"errors"
 	"os"
 	"path/filepath"
+	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -15,6 +17,8 @@ type Config struct {
 	Environment string
 	Port        string
 	SRDAPIBaseURL string
+	ReactionTimeout time.Duration
+	MonsterStepDelay time.Duration
 }
 
 // Load loads configuration from environment variables
@@ -55,6 +59,20 @@ func Load() (*Config, error) {
 	}
 	config.DBPath = dbPath
 
+	// How long a combatant's controller has to answer a reaction prompt
+	reactionTimeout, err := strconv.Atoi(getEnv("REACTION_TIMEOUT_SECONDS", "30"))
+	if err != nil || reactionTimeout < 0 {
+		return nil, errors.New("REACTION_TIMEOUT_SECONDS must be a non-negative number of seconds")
+	}
+	config.ReactionTimeout = time.Duration(reactionTimeout) * time.Second
+
+	// Pause between the steps of automated monster turns so players can follow them
+	monsterStepDelay, err := strconv.Atoi(getEnv("MONSTER_STEP_DELAY_MS", "1000"))
+	if err != nil || monsterStepDelay < 0 {
+		return nil, errors.New("MONSTER_STEP_DELAY_MS must be a non-negative number of milliseconds")
+	}
+	config.MonsterStepDelay = time.Duration(monsterStepDelay) * time.Millisecond
+
 	return config, nil
 }
 
@@ -0,0 +1,253 @@
+package character
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"dnd-combat/internal/models"
+	"dnd-combat/pkg/dnd5e"
+)
+
+// Ways a new character's ability scores can be generated
+const (
+	PointBuy      = "point_buy"
+	StandardArray = "standard_array"
+	Rolled        = "rolled"
+)
+
+// PointBuyBudget is how many points a character can spend on ability scores with point buy
+const PointBuyBudget = 27
+
+// pointBuyCosts is what each ability score costs with point buy, which only buys scores
+// from 8 to 15
+var pointBuyCosts = map[int]int{8: 0, 9: 1, 10: 2, 11: 3, 12: 4, 13: 5, 14: 7, 15: 9}
+
+// standardArray is the set of scores the standard array assigns to the six abilities
+var standardArray = []int{15, 14, 13, 12, 10, 8}
+
+// NewCharacterOptions are the choices a player makes when creating a character besides
+// their race, class and base ability scores
+type NewCharacterOptions struct {
+	AbilityMethod       string   // PointBuy, StandardArray or Rolled
+	AbilityRollID       string   // Rolled scores the base ability scores were arranged from
+	AbilityBonusChoices []string // Abilities given the +1 bonuses a race like the half-elf chooses
+}
+
+// PrepareNew checks a new character against the SRD and fills in what their race and class
+// give them. The character's race, subrace and class must exist in the SRD, and their base
+// ability scores must have been generated with the chosen method. Racial ability bonuses,
+// speed and size are applied, and their hit points are their class's hit die plus their CON
+// modifier at 1st level, with the hit die's average rounded up plus CON for each level after.
+// The character isn't saved.
+func (s *Service) PrepareNew(character *models.Character, options NewCharacterOptions) error {
+	if err := s.checkAbilityMethod(character, options); err != nil {
+		return err
+	}
+
+	hitDie, err := s.srdClient.GetHitDie(srdIndex(character.Class))
+	if err != nil {
+		return fmt.Errorf("unknown class '%s': %w", character.Class, err)
+	}
+
+	race, subrace, err := s.SetRace(character)
+	if err != nil {
+		return err
+	}
+	if err := applyRacialBonuses(character, race, subrace, options.AbilityBonusChoices); err != nil {
+		return err
+	}
+
+	character.MaxHitPoints = startingHitPoints(hitDie, character.Level, models.GetAbilityModifier(character.Constitution))
+	character.HitPoints = character.MaxHitPoints
+	return nil
+}
+
+// SetRace checks that a character's race and subrace exist in the SRD and sets the speed
+// and size of their race. A race with subraces needs one of them. Ability bonuses aren't
+// applied, as the character's scores already include them.
+func (s *Service) SetRace(character *models.Character) (*models.Race, *models.Subrace, error) {
+	race, err := s.srdClient.GetRace(srdIndex(character.Race))
+	if err != nil {
+		return nil, nil, fmt.Errorf("unknown race '%s': %w", character.Race, err)
+	}
+
+	var subrace *models.Subrace
+	switch {
+	case character.Subrace != "":
+		subrace, err = s.srdClient.GetSubrace(srdIndex(character.Subrace))
+		if err != nil {
+			return nil, nil, fmt.Errorf("unknown subrace '%s': %w", character.Subrace, err)
+		}
+		if subrace.Race != race.Index {
+			return nil, nil, fmt.Errorf("%s is not a subrace of %s", subrace.Name, race.Name)
+		}
+		character.Subrace = subrace.Name
+	case len(race.Subraces) > 0:
+		return nil, nil, fmt.Errorf("a %s needs a subrace, one of %s", race.Name, strings.Join(race.Subraces, ", "))
+	}
+
+	character.Race = race.Name
+	character.RaceSpeed = race.Speed
+	character.Size = race.Size
+	character.DeriveStats()
+	return race, subrace, nil
+}
+
+// RollAbilityScores rolls a set of six ability scores for a user's next character, each
+// the highest three of 4d6. A user has one set at a time: until a character is created
+// from it, the same set is returned rather than a new one, so scores can't be rerolled.
+func (s *Service) RollAbilityScores(userID string) (*models.AbilityRoll, error) {
+	roll, err := s.repo.GetUnusedAbilityRoll(userID)
+	if err != nil || roll != nil {
+		return roll, err
+	}
+
+	expression, err := dnd5e.ParseDice("4d6kh3")
+	if err != nil {
+		return nil, err
+	}
+	roller := dnd5e.NewDiceRoller()
+	roller.Label("ability score", userID)
+	roll = &models.AbilityRoll{UserID: userID, CreatedAt: time.Now().UTC()}
+	for range models.Abilities {
+		roll.Scores = append(roll.Scores, roller.RollExpression(expression).Total)
+	}
+	roll.Rolls = roller.TakeRecords()
+
+	if err := s.repo.CreateAbilityRoll(roll); err != nil {
+		return nil, err
+	}
+	return roll, nil
+}
+
+// checkAbilityMethod checks that a new character's base ability scores are ones the
+// chosen method can generate
+func (s *Service) checkAbilityMethod(character *models.Character, options NewCharacterOptions) error {
+	scores := make([]int, 0, len(models.Abilities))
+	for _, ability := range models.Abilities {
+		scores = append(scores, *character.AbilityScore(ability))
+	}
+
+	switch options.AbilityMethod {
+	case PointBuy:
+		spent := 0
+		for i, score := range scores {
+			cost, ok := pointBuyCosts[score]
+			if !ok {
+				return fmt.Errorf("point buy scores must be from 8 to 15, %s is %d", strings.ToUpper(models.Abilities[i]), score)
+			}
+			spent += cost
+		}
+		if spent > PointBuyBudget {
+			return fmt.Errorf("ability scores cost %d points, more than the %d point buy allows", spent, PointBuyBudget)
+		}
+	case StandardArray:
+		if !sameScores(scores, standardArray) {
+			return fmt.Errorf("ability scores must be the standard array %v in any order", standardArray)
+		}
+	case Rolled:
+		if options.AbilityRollID == "" {
+			return errors.New("rolled ability scores need the ability roll they came from")
+		}
+		roll, err := s.repo.GetAbilityRoll(options.AbilityRollID)
+		if err != nil {
+			return fmt.Errorf("failed to retrieve ability roll: %w", err)
+		}
+		if roll == nil || roll.UserID != character.UserID {
+			return errors.New("ability roll not found")
+		}
+		if roll.Used {
+			return errors.New("ability roll has already been used")
+		}
+		if !sameScores(scores, roll.Scores) {
+			return fmt.Errorf("ability scores must be the rolled scores %v in any order", roll.Scores)
+		}
+	default:
+		return fmt.Errorf("unknown ability score method '%s', expected point_buy, standard_array or rolled", options.AbilityMethod)
+	}
+	return nil
+}
+
+// applyRacialBonuses adds the ability bonuses of a character's race and subrace, along with
+// the +1 bonuses the race lets them choose, to their scores, up to MaxAbilityScore
+func applyRacialBonuses(character *models.Character, race *models.Race, subrace *models.Subrace, choices []string) error {
+	if len(choices) != race.AbilityBonusChoices {
+		return fmt.Errorf("a %s chooses %d ability bonuses, got %d", race.Name, race.AbilityBonusChoices, len(choices))
+	}
+
+	bonuses := make(map[string]int)
+	for ability, bonus := range race.AbilityBonuses {
+		bonuses[ability] += bonus
+	}
+	if subrace != nil {
+		for ability, bonus := range subrace.AbilityBonuses {
+			bonuses[ability] += bonus
+		}
+	}
+	chosen := make([]string, 0, len(choices))
+	for _, choice := range choices {
+		ability := strings.ToLower(strings.TrimSpace(choice))
+		if !containsIndex(race.AbilityBonusOptions, ability) {
+			return fmt.Errorf("a %s can't choose a bonus to '%s', expected one of %s", race.Name, choice, strings.Join(race.AbilityBonusOptions, ", "))
+		}
+		if containsIndex(chosen, ability) {
+			return fmt.Errorf("'%s' is chosen more than once", ability)
+		}
+		chosen = append(chosen, ability)
+		bonuses[ability]++
+	}
+
+	for ability, bonus := range bonuses {
+		score := character.AbilityScore(ability)
+		if score == nil {
+			continue
+		}
+		*score += bonus
+		if *score > models.MaxAbilityScore {
+			*score = models.MaxAbilityScore
+		}
+	}
+	return nil
+}
+
+// startingHitPoints returns the hit point maximum of a new character of a level: the full
+// hit die at 1st level and its average rounded up after, plus the CON modifier at each
+// level, gaining at least 1 hit point per level
+func startingHitPoints(hitDie, level, constitution int) int {
+	hitPoints := 0
+	for l := 1; l <= level; l++ {
+		gained := hitDie/2 + 1 + constitution
+		if l == 1 {
+			gained = hitDie + constitution
+		}
+		if gained < 1 {
+			gained = 1
+		}
+		hitPoints += gained
+	}
+	return hitPoints
+}
+
+// sameScores checks if two sets of ability scores hold the same scores in any order
+func sameScores(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a, b = append([]int(nil), a...), append([]int(nil), b...)
+	sort.Ints(a)
+	sort.Ints(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// srdIndex turns a name like "Hill Dwarf" into an SRD index like "hill-dwarf"
+func srdIndex(name string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(name)), " ", "-")
+}
@@ -1,41 +1,146 @@
 package character
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 
+	"dnd-combat/internal/game"
 	"dnd-combat/internal/models"
+	"dnd-combat/pkg/websocket"
 )
 
 // Handler handles character-related HTTP requests
 type Handler struct {
-	service *Service
+	service     *Service
+	gameService *game.Service
+	wsHub       *websocket.Hub
 }
 
 // NewHandler creates a new character handler
-func NewHandler(service *Service) *Handler {
+func NewHandler(service *Service, gameService *game.Service, wsHub *websocket.Hub) *Handler {
 	return &Handler{
-		service: service,
+		service:     service,
+		gameService: gameService,
+		wsHub:       wsHub,
 	}
 }
 
-// CreateRequest represents the request body for character creation
+// CreateRequest represents the request body for character creation. Ability scores are
+// the base scores before racial bonuses, generated with ability_method; hit points are
+// worked out from the class.
 type CreateRequest struct {
-	Name         string `json:"name" binding:"required"`
-	Race         string `json:"race" binding:"required"`
-	Class        string `json:"class" binding:"required"`
-	Level        int    `json:"level" binding:"required,min=1,max=20"`
-	Strength     int    `json:"strength" binding:"required,min=3,max=20"`
-	Dexterity    int    `json:"dexterity" binding:"required,min=3,max=20"`
-	Constitution int    `json:"constitution" binding:"required,min=3,max=20"`
-	Intelligence int    `json:"intelligence" binding:"required,min=3,max=20"`
-	Wisdom       int    `json:"wisdom" binding:"required,min=3,max=20"`
-	Charisma     int    `json:"charisma" binding:"required,min=3,max=20"`
-	HitPoints    int    `json:"hit_points" binding:"required,min=1"`
-	ArmorClass   int    `json:"armor_class" binding:"required,min=1"`
-	Equipment    []string `json:"equipment"`
-	Spells       []string `json:"spells"`
+	Name                string                 `json:"name" binding:"required"`
+	Race                string                 `json:"race" binding:"required"`
+	Subrace             string                 `json:"subrace"` // Required for races with subraces
+	Class               string                 `json:"class" binding:"required"`
+	Level               int                    `json:"level" binding:"required,min=1,max=20"`
+	AbilityMethod       string                 `json:"ability_method" binding:"required,oneof=point_buy standard_array rolled"`
+	AbilityRollID       string                 `json:"ability_roll_id"`       // Rolled scores the ability scores are arranged from
+	AbilityBonusChoices []string               `json:"ability_bonus_choices"` // Abilities given the +1 bonuses a race like the half-elf chooses
+	Strength            int                    `json:"strength" binding:"required,min=3,max=18"`
+	Dexterity           int                    `json:"dexterity" binding:"required,min=3,max=18"`
+	Constitution        int                    `json:"constitution" binding:"required,min=3,max=18"`
+	Intelligence        int                    `json:"intelligence" binding:"required,min=3,max=18"`
+	Wisdom              int                    `json:"wisdom" binding:"required,min=3,max=18"`
+	Charisma            int                    `json:"charisma" binding:"required,min=3,max=18"`
+	Inventory           []models.InventoryItem `json:"inventory"`
+	Equipped            models.EquippedSlots   `json:"equipped"`
+	Spells              []string               `json:"spells"`
+	Skills              []string               `json:"skills"`    // SRD skill indexes like "stealth" or "sleight-of-hand"
+	Expertise           []string               `json:"expertise"` // Skills to add twice the proficiency bonus to
+	Feats               []string               `json:"feats"`     // Feat indexes like "alert"
+}
+
+// CreationFields are the character fields set by creation and levelling up. Update and
+// patch requests can repeat them, like a character returned by Get Character does, but
+// can't change them, so the creation rules can't be got around. Levels are gained with
+// LevelUp, which also raises the hit point maximum.
+type CreationFields struct {
+	Race         *string `json:"race"`
+	Subrace      *string `json:"subrace"`
+	Class        *string `json:"class"`
+	Level        *int    `json:"level"`
+	Strength     *int    `json:"strength"`
+	Dexterity    *int    `json:"dexterity"`
+	Constitution *int    `json:"constitution"`
+	Intelligence *int    `json:"intelligence"`
+	Wisdom       *int    `json:"wisdom"`
+	Charisma     *int    `json:"charisma"`
+	MaxHitPoints *int    `json:"max_hit_points"`
+}
+
+// UpdateRequest represents the request body for replacing the details of a character a
+// player can change after creating them. Hit points that are left out keep their value.
+type UpdateRequest struct {
+	CreationFields
+	Name      string                 `json:"name" binding:"required"`
+	HitPoints *int                   `json:"hit_points" binding:"omitempty,min=0"`
+	Inventory []models.InventoryItem `json:"inventory"`
+	Equipped  models.EquippedSlots   `json:"equipped"`
+	Spells    []string               `json:"spells"`
+	Skills    []string               `json:"skills"`
+	Expertise []string               `json:"expertise"`
+	Feats     []string               `json:"feats"`
+}
+
+// PatchRequest represents the request body for changing some of a character's fields;
+// fields that are left out keep their value
+type PatchRequest struct {
+	CreationFields
+	Name       *string                 `json:"name" binding:"omitempty,min=1"`
+	HitPoints  *int                    `json:"hit_points" binding:"omitempty,min=0"`
+	Exhaustion *int                    `json:"exhaustion" binding:"omitempty,min=0,max=6"`
+	Inventory  *[]models.InventoryItem `json:"inventory"`
+	Equipped   *models.EquippedSlots   `json:"equipped"`
+	Spells     *[]string               `json:"spells"`
+	Skills     *[]string               `json:"skills"`
+	Expertise  *[]string               `json:"expertise"`
+	Feats      *[]string               `json:"feats"`
+}
+
+// LevelUpRequest represents the request body for levelling up a character
+type LevelUpRequest struct {
+	HitPointsMethod string `json:"hit_points_method" binding:"omitempty,oneof=average roll"` // Defaults to average
+}
+
+// ShortRestRequest represents the request body for a short rest
+type ShortRestRequest struct {
+	HitDice int `json:"hit_dice" binding:"min=0"` // Hit dice to spend
+}
+
+// RollRequest represents the request body for an ability check or saving throw
+type RollRequest struct {
+	Ability      string `json:"ability" binding:"omitempty,oneof=str dex con int wis cha"` // Required for saves and checks without a skill
+	Skill        string `json:"skill"`                                                     // Skill of an ability check
+	DC           int    `json:"dc" binding:"min=0"`                                        // 0 rolls without a DC
+	Advantage    bool   `json:"advantage"`
+	Disadvantage bool   `json:"disadvantage"`
+	GameID       string `json:"game_id"` // Game room to share the roll with; its DM can roll for its players' characters
+}
+
+// GroupRollRequest represents the request body for the DM of a game calling for the same
+// ability check or saving throw from several characters
+type GroupRollRequest struct {
+	RollRequest
+	CharacterIDs []string `json:"character_ids" binding:"required,min=1"`
+}
+
+// RollsMessage is the ability checks or saving throws a user rolled for characters, as
+// broadcast to a game room as a character_rolls message
+type RollsMessage struct {
+	UserID  string        `json:"user_id"`
+	GameID  string        `json:"game_id"`
+	Results []*RollResult `json:"results"`
+}
+
+// InventoryRequest represents the request body for replacing a character's inventory
+type InventoryRequest struct {
+	Inventory []models.InventoryItem `json:"inventory"`
+	Equipped  models.EquippedSlots   `json:"equipped"`
 }
 
 // Create handles character creation
@@ -57,6 +162,7 @@ func (h *Handler) Create(c *gin.Context) {
 		UserID:       userID.(string),
 		Name:         req.Name,
 		Race:         req.Race,
+		Subrace:      req.Subrace,
 		Class:        req.Class,
 		Level:        req.Level,
 		Strength:     req.Strength,
@@ -65,14 +171,39 @@ func (h *Handler) Create(c *gin.Context) {
 		Intelligence: req.Intelligence,
 		Wisdom:       req.Wisdom,
 		Charisma:     req.Charisma,
-		HitPoints:    req.HitPoints,
-		MaxHitPoints: req.HitPoints,
-		ArmorClass:   req.ArmorClass,
-		Equipment:    req.Equipment,
 		Spells:       req.Spells,
+		Feats:        req.Feats,
+	}
+
+	// Race and class come from the SRD, which also gives the racial bonuses and hit points
+	options := NewCharacterOptions{
+		AbilityMethod:       req.AbilityMethod,
+		AbilityRollID:       req.AbilityRollID,
+		AbilityBonusChoices: req.AbilityBonusChoices,
+	}
+	if err := h.service.PrepareNew(character, options); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid character", "details": err.Error()})
+		return
+	}
+
+	// Armor class and encumbrance are derived from the inventory
+	if err := h.service.SetInventory(character, req.Inventory, req.Equipped); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid inventory", "details": err.Error()})
+		return
 	}
 
-	if err := h.service.Create(character); err != nil {
+	// Saving throw proficiencies come from the class
+	if err := h.service.SetProficiencies(character, req.Skills, req.Expertise); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid proficiencies", "details": err.Error()})
+		return
+	}
+
+	// Rolled scores are only claimed once everything else checks out
+	abilityRollID := ""
+	if req.AbilityMethod == Rolled {
+		abilityRollID = req.AbilityRollID
+	}
+	if err := h.service.Create(character, abilityRollID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create character"})
 		return
 	}
@@ -80,6 +211,25 @@ func (h *Handler) Create(c *gin.Context) {
 	c.JSON(http.StatusCreated, character)
 }
 
+// RollAbilityScores rolls the ability scores of the user's next character, or returns the
+// ones they rolled before and haven't used yet
+func (h *Handler) RollAbilityScores(c *gin.Context) {
+	// Get the user ID from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	roll, err := h.service.RollAbilityScores(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to roll ability scores"})
+		return
+	}
+
+	c.JSON(http.StatusOK, roll)
+}
+
 // Get retrieves a character by ID
 func (h *Handler) Get(c *gin.Context) {
 	id := c.Param("id")
@@ -132,3 +282,528 @@ func (h *Handler) List(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"characters": characters})
 }
+
+// Update replaces the details of a character that can change after creation
+func (h *Handler) Update(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Character ID is required"})
+		return
+	}
+
+	var req UpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+
+	character, ok := h.ownedCharacter(c, id)
+	if !ok {
+		return
+	}
+	if err := req.CreationFields.check(character); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+
+	character.Name = req.Name
+	if req.HitPoints != nil {
+		character.HitPoints = *req.HitPoints
+	}
+	character.Spells = req.Spells
+	character.Feats = req.Feats
+
+	h.save(c, character, req.Inventory, req.Equipped, req.Skills, req.Expertise)
+}
+
+// Patch changes the fields of a character given in the request
+func (h *Handler) Patch(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Character ID is required"})
+		return
+	}
+
+	var req PatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+
+	character, ok := h.ownedCharacter(c, id)
+	if !ok {
+		return
+	}
+	if err := req.CreationFields.check(character); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+
+	setString := func(field *string, value *string) {
+		if value != nil {
+			*field = *value
+		}
+	}
+	setInt := func(field *int, value *int) {
+		if value != nil {
+			*field = *value
+		}
+	}
+	setString(&character.Name, req.Name)
+	setInt(&character.HitPoints, req.HitPoints)
+	setInt(&character.Exhaustion, req.Exhaustion)
+	if req.Spells != nil {
+		character.Spells = *req.Spells
+	}
+	if req.Feats != nil {
+		character.Feats = *req.Feats
+	}
+
+	inventory, equipped := character.Inventory, character.Equipped
+	if req.Inventory != nil {
+		inventory = *req.Inventory
+	}
+	if req.Equipped != nil {
+		equipped = *req.Equipped
+	}
+	skills, expertise := character.Skills, character.Expertise
+	if req.Skills != nil {
+		skills = *req.Skills
+	}
+	if req.Expertise != nil {
+		expertise = *req.Expertise
+	}
+
+	h.save(c, character, inventory, equipped, skills, expertise)
+}
+
+// Delete deletes a character
+func (h *Handler) Delete(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Character ID is required"})
+		return
+	}
+
+	character, ok := h.ownedCharacter(c, id)
+	if !ok {
+		return
+	}
+
+	if err := h.service.Delete(character.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete character"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// LevelUp raises a character's level by one
+func (h *Handler) LevelUp(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Character ID is required"})
+		return
+	}
+
+	var req LevelUpRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+
+	character, ok := h.ownedCharacter(c, id)
+	if !ok {
+		return
+	}
+
+	result, err := h.service.LevelUp(character, req.HitPointsMethod)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to level up", "details": err.Error()})
+		return
+	}
+
+	if err := h.service.Update(character); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update character"})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// ShortRest spends a character's hit dice to regain hit points
+func (h *Handler) ShortRest(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Character ID is required"})
+		return
+	}
+
+	var req ShortRestRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+
+	character, ok := h.ownedCharacter(c, id)
+	if !ok {
+		return
+	}
+
+	result, err := h.service.ShortRest(character, req.HitDice)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to take a short rest", "details": err.Error()})
+		return
+	}
+
+	if err := h.service.Update(character); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update character"})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// LongRest restores a character's hit points, spell slots and hit dice
+func (h *Handler) LongRest(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Character ID is required"})
+		return
+	}
+
+	character, ok := h.ownedCharacter(c, id)
+	if !ok {
+		return
+	}
+
+	result, err := h.service.LongRest(character)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to take a long rest", "details": err.Error()})
+		return
+	}
+
+	if err := h.service.Update(character); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update character"})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// UpdateInventory replaces the inventory and equipped slots of a character
+func (h *Handler) UpdateInventory(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Character ID is required"})
+		return
+	}
+
+	var req InventoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+
+	character, ok := h.ownedCharacter(c, id)
+	if !ok {
+		return
+	}
+
+	if err := h.service.SetInventory(character, req.Inventory, req.Equipped); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid inventory", "details": err.Error()})
+		return
+	}
+
+	if err := h.service.Update(character); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update character"})
+		return
+	}
+
+	c.JSON(http.StatusOK, character)
+}
+
+// Check makes a character roll an ability check
+func (h *Handler) Check(c *gin.Context) {
+	h.rollOne(c, "check")
+}
+
+// Save makes a character roll a saving throw
+func (h *Handler) Save(c *gin.Context) {
+	h.rollOne(c, "save")
+}
+
+// GroupCheck makes several characters of a game roll the same ability check
+func (h *Handler) GroupCheck(c *gin.Context) {
+	h.rollGroup(c, "check")
+}
+
+// GroupSave makes several characters of a game roll the same saving throw
+func (h *Handler) GroupSave(c *gin.Context) {
+	h.rollGroup(c, "save")
+}
+
+// rollOne makes a character roll an ability check or saving throw. The owner of the
+// character can roll it, and so can the DM of a game the owner plays in.
+func (h *Handler) rollOne(c *gin.Context, rollType string) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Character ID is required"})
+		return
+	}
+
+	var req RollRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+
+	// Get the user ID from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	character, err := h.service.GetByID(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve character"})
+		return
+	}
+
+	if character == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Character not found"})
+		return
+	}
+
+	var session *models.Game
+	if req.GameID != "" {
+		var ok bool
+		if session, ok = h.getGame(c, req.GameID); !ok {
+			return
+		}
+		if !h.gameService.IsUserInGame(session, userID.(string)) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You don't have access to this game session"})
+			return
+		}
+	}
+
+	// Only the owner, or the DM of a game the owner plays in, can roll for a character
+	if character.UserID != userID.(string) && !isDMOf(session, userID.(string), character) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You don't have permission to roll for this character"})
+		return
+	}
+
+	result, err := h.roll(rollType, character, req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to roll", "details": err.Error()})
+		return
+	}
+
+	h.broadcastRolls(userID.(string), req.GameID, []*RollResult{result})
+	c.JSON(http.StatusOK, result)
+}
+
+// rollGroup makes several characters roll the same ability check or saving throw. Only
+// the DM of a game can call for it, and only from characters of the game's players.
+func (h *Handler) rollGroup(c *gin.Context, rollType string) {
+	var req GroupRollRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+	if req.GameID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A game ID is required to roll for several characters"})
+		return
+	}
+
+	// Get the user ID from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	session, ok := h.getGame(c, req.GameID)
+	if !ok {
+		return
+	}
+	if session.DMUserID != userID.(string) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the DM can call for rolls from several characters"})
+		return
+	}
+
+	characters, err := h.service.GetMultiple(req.CharacterIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve characters"})
+		return
+	}
+	if len(characters) != len(req.CharacterIDs) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "One or more characters not found"})
+		return
+	}
+
+	results := make([]*RollResult, 0, len(characters))
+	for _, character := range characters {
+		if !isDMOf(session, userID.(string), character) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Character " + character.Name + " doesn't belong to a player of this game"})
+			return
+		}
+
+		result, err := h.roll(rollType, character, req.RollRequest)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to roll", "details": err.Error()})
+			return
+		}
+		results = append(results, result)
+	}
+
+	h.broadcastRolls(userID.(string), req.GameID, results)
+	c.JSON(http.StatusOK, gin.H{"results": results})
+}
+
+// roll makes a character roll an ability check or saving throw
+func (h *Handler) roll(rollType string, character *models.Character, req RollRequest) (*RollResult, error) {
+	if rollType == "save" {
+		return h.service.SavingThrow(character, req.Ability, req.DC, req.Advantage, req.Disadvantage)
+	}
+	return h.service.AbilityCheck(character, req.Ability, req.Skill, req.DC, req.Advantage, req.Disadvantage)
+}
+
+// broadcastRolls shares rolls with everyone in a game room
+func (h *Handler) broadcastRolls(userID, gameID string, results []*RollResult) {
+	if gameID == "" {
+		return
+	}
+	h.wsHub.BroadcastToRoom(gameID, websocket.Message{
+		Type: "character_rolls",
+		Data: RollsMessage{UserID: userID, GameID: gameID, Results: results},
+	})
+}
+
+// getGame retrieves a game session. When the game can't be retrieved it writes the error
+// response and returns false.
+func (h *Handler) getGame(c *gin.Context, id string) (*models.Game, bool) {
+	session, err := h.gameService.GetByID(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve game session"})
+		return nil, false
+	}
+	if session == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Game session not found"})
+		return nil, false
+	}
+	return session, true
+}
+
+// isDMOf checks if a user is the DM of a game the owner of a character plays in
+func isDMOf(session *models.Game, userID string, character *models.Character) bool {
+	if session == nil || session.DMUserID != userID {
+		return false
+	}
+	for _, playerID := range session.PlayerIDs {
+		if playerID == character.UserID {
+			return true
+		}
+	}
+	return false
+}
+
+// save validates a character's hit points, inventory and proficiencies and saves them
+func (h *Handler) save(c *gin.Context, character *models.Character, inventory []models.InventoryItem, equipped models.EquippedSlots, skills, expertise []string) {
+	if err := checkHitPoints(character); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+
+	if err := h.service.SetInventory(character, inventory, equipped); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid inventory", "details": err.Error()})
+		return
+	}
+
+	if err := h.service.SetProficiencies(character, skills, expertise); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid proficiencies", "details": err.Error()})
+		return
+	}
+
+	if err := h.service.Update(character); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update character"})
+		return
+	}
+
+	c.JSON(http.StatusOK, character)
+}
+
+// ownedCharacter retrieves a character the authenticated user owns. When the character
+// can't be retrieved it writes the error response and returns false.
+func (h *Handler) ownedCharacter(c *gin.Context, id string) (*models.Character, bool) {
+	// Get the user ID from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return nil, false
+	}
+
+	character, err := h.service.GetByID(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve character"})
+		return nil, false
+	}
+
+	if character == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Character not found"})
+		return nil, false
+	}
+
+	// Check if the character belongs to the authenticated user
+	if character.UserID != userID.(string) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You don't have permission to modify this character"})
+		return nil, false
+	}
+
+	return character, true
+}
+
+// check returns an error if a field is given with another value than the character's
+func (f CreationFields) check(character *models.Character) error {
+	changed := func(given *string, current string) bool {
+		return given != nil && srdIndex(*given) != srdIndex(current)
+	}
+	switch {
+	case changed(f.Race, character.Race), changed(f.Subrace, character.Subrace):
+		return errors.New("race and subrace can't be changed after creation")
+	case changed(f.Class, character.Class):
+		return errors.New("class can't be changed after creation")
+	case f.Level != nil && *f.Level != character.Level:
+		return errors.New("level can't be set, level the character up instead")
+	case f.MaxHitPoints != nil && *f.MaxHitPoints != character.MaxHitPoints:
+		return errors.New("the hit point maximum can't be set, it grows as the character levels up")
+	}
+
+	scores := []struct {
+		given   *int
+		current int
+	}{
+		{f.Strength, character.Strength},
+		{f.Dexterity, character.Dexterity},
+		{f.Constitution, character.Constitution},
+		{f.Intelligence, character.Intelligence},
+		{f.Wisdom, character.Wisdom},
+		{f.Charisma, character.Charisma},
+	}
+	for i, score := range scores {
+		if score.given != nil && *score.given != score.current {
+			return fmt.Errorf("ability scores can't be changed after creation (%s)", models.Abilities[i])
+		}
+	}
+	return nil
+}
+
+// checkHitPoints checks that a character's hit points don't exceed their maximum, less any
+// reduction of it that lasts until a long rest
+func checkHitPoints(character *models.Character) error {
+	if maxHP := character.MaxHitPoints - character.MaxHPReduction; character.HitPoints > maxHP {
+		return fmt.Errorf("hit points (%d) can't exceed the hit point maximum (%d)", character.HitPoints, maxHP)
+	}
+	return nil
+}
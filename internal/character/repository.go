@@ -24,8 +24,12 @@ func NewRepository(db *database.DB) *Repository {
 
 // Create stores a new character in the database
 func (r *Repository) Create(character *models.Character) error {
-        // Convert equipment to JSON
-        equipmentJSON, err := json.Marshal(character.Equipment)
+        // Convert inventory and equipped slots to JSON
+        inventoryJSON, err := json.Marshal(inventoryOrEmpty(character.Inventory))
+        if err != nil {
+                return err
+        }
+        equippedJSON, err := json.Marshal(character.Equipped)
         if err != nil {
                 return err
         }
@@ -36,18 +40,54 @@ func (r *Repository) Create(character *models.Character) error {
                 return err
         }
 
+        // Convert spell slots to JSON
+        spellSlotsJSON, err := json.Marshal(spellSlotsOrEmpty(character.SpellSlots))
+        if err != nil {
+                return err
+        }
+
+        // Convert proficiencies to JSON
+        savingThrowsJSON, err := json.Marshal(stringsOrEmpty(character.SavingThrows))
+        if err != nil {
+                return err
+        }
+        skillsJSON, err := json.Marshal(stringsOrEmpty(character.Skills))
+        if err != nil {
+                return err
+        }
+        expertiseJSON, err := json.Marshal(stringsOrEmpty(character.Expertise))
+        if err != nil {
+                return err
+        }
+
+        // Convert feats to JSON
+        featsJSON, err := json.Marshal(stringsOrEmpty(character.Feats))
+        if err != nil {
+                return err
+        }
+
+        // Convert limited class feature uses to JSON
+        featureUsesJSON, err := json.Marshal(featureUsesOrEmpty(character.FeatureUses))
+        if err != nil {
+                return err
+        }
+
         query := `
                 INSERT INTO characters (
                         user_id, name, race, class, level, 
                         strength, dexterity, constitution, intelligence, wisdom, charisma,
                         hit_points, max_hit_points, armor_class, equipment_json, spells_json,
-                        created_at, updated_at
+                        spell_slots_json, equipped_json, max_hp_reduction, hit_dice_used, exhaustion,
+                        saving_throws_json, skills_json, expertise_json, subrace, size, race_speed,
+                        feature_uses_json, feats_json, created_at, updated_at
                 )
                 VALUES (
                         ?, ?, ?, ?, ?, 
                         ?, ?, ?, ?, ?, ?,
                         ?, ?, ?, ?, ?,
-                        CURRENT_TIMESTAMP, CURRENT_TIMESTAMP
+                        ?, ?, ?, ?, ?,
+                        ?, ?, ?, ?, ?, ?,
+                        ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP
                 )
                 RETURNING id
         `
@@ -68,8 +108,21 @@ func (r *Repository) Create(character *models.Character) error {
                 character.HitPoints,
                 character.MaxHitPoints,
                 character.ArmorClass,
-                string(equipmentJSON),
+                string(inventoryJSON),
                 string(spellsJSON),
+                string(spellSlotsJSON),
+                string(equippedJSON),
+                character.MaxHPReduction,
+                character.HitDiceUsed,
+                character.Exhaustion,
+                string(savingThrowsJSON),
+                string(skillsJSON),
+                string(expertiseJSON),
+                character.Subrace,
+                character.Size,
+                character.RaceSpeed,
+                string(featureUsesJSON),
+                string(featsJSON),
         ).Scan(&character.ID)
 
         return err
@@ -78,41 +131,13 @@ func (r *Repository) Create(character *models.Character) error {
 // GetByID retrieves a character by ID
 func (r *Repository) GetByID(id string) (*models.Character, error) {
         query := `
-                SELECT 
-                        id, user_id, name, race, class, level,
-                        strength, dexterity, constitution, intelligence, wisdom, charisma,
-                        hit_points, max_hit_points, armor_class, equipment_json, spells_json,
-                        created_at, updated_at
+                SELECT ` + characterColumns + `
                 FROM characters
                 WHERE id = ?
                 LIMIT 1
         `
         
-        character := &models.Character{}
-        var equipmentJSON, spellsJSON string
-
-        err := r.db.QueryRow(query, id).Scan(
-                &character.ID,
-                &character.UserID,
-                &character.Name,
-                &character.Race,
-                &character.Class,
-                &character.Level,
-                &character.Strength,
-                &character.Dexterity,
-                &character.Constitution,
-                &character.Intelligence,
-                &character.Wisdom,
-                &character.Charisma,
-                &character.HitPoints,
-                &character.MaxHitPoints,
-                &character.ArmorClass,
-                &equipmentJSON,
-                &spellsJSON,
-                &character.CreatedAt,
-                &character.UpdatedAt,
-        )
-
+        character, err := scanCharacter(r.db.QueryRow(query, id))
         if err != nil {
                 if errors.Is(err, sql.ErrNoRows) {
                         return nil, nil
@@ -120,31 +145,13 @@ func (r *Repository) GetByID(id string) (*models.Character, error) {
                 return nil, err
         }
 
-        // Parse equipment JSON
-        if equipmentJSON != "" {
-                if err := json.Unmarshal([]byte(equipmentJSON), &character.Equipment); err != nil {
-                        return nil, err
-                }
-        }
-
-        // Parse spells JSON
-        if spellsJSON != "" {
-                if err := json.Unmarshal([]byte(spellsJSON), &character.Spells); err != nil {
-                        return nil, err
-                }
-        }
-
         return character, nil
 }
 
 // GetByUserID retrieves all characters for a user
 func (r *Repository) GetByUserID(userID string) ([]*models.Character, error) {
         query := `
-                SELECT 
-                        id, user_id, name, race, class, level,
-                        strength, dexterity, constitution, intelligence, wisdom, charisma,
-                        hit_points, max_hit_points, armor_class, equipment_json, spells_json,
-                        created_at, updated_at
+                SELECT ` + characterColumns + `
                 FROM characters
                 WHERE user_id = ?
                 ORDER BY name
@@ -159,49 +166,11 @@ func (r *Repository) GetByUserID(userID string) ([]*models.Character, error) {
         var characters []*models.Character
 
         for rows.Next() {
-                character := &models.Character{}
-                var equipmentJSON, spellsJSON string
-
-                err := rows.Scan(
-                        &character.ID,
-                        &character.UserID,
-                        &character.Name,
-                        &character.Race,
-                        &character.Class,
-                        &character.Level,
-                        &character.Strength,
-                        &character.Dexterity,
-                        &character.Constitution,
-                        &character.Intelligence,
-                        &character.Wisdom,
-                        &character.Charisma,
-                        &character.HitPoints,
-                        &character.MaxHitPoints,
-                        &character.ArmorClass,
-                        &equipmentJSON,
-                        &spellsJSON,
-                        &character.CreatedAt,
-                        &character.UpdatedAt,
-                )
-
+                character, err := scanCharacter(rows)
                 if err != nil {
                         return nil, err
                 }
 
-                // Parse equipment JSON
-                if equipmentJSON != "" {
-                        if err := json.Unmarshal([]byte(equipmentJSON), &character.Equipment); err != nil {
-                                return nil, err
-                        }
-                }
-
-                // Parse spells JSON
-                if spellsJSON != "" {
-                        if err := json.Unmarshal([]byte(spellsJSON), &character.Spells); err != nil {
-                                return nil, err
-                        }
-                }
-
                 characters = append(characters, character)
         }
 
@@ -228,11 +197,7 @@ func (r *Repository) GetMultiple(ids []string) ([]*models.Character, error) {
         }
 
         query := `
-                SELECT 
-                        id, user_id, name, race, class, level,
-                        strength, dexterity, constitution, intelligence, wisdom, charisma,
-                        hit_points, max_hit_points, armor_class, equipment_json, spells_json,
-                        created_at, updated_at
+                SELECT ` + characterColumns + `
                 FROM characters
                 WHERE id IN (` + placeholders[0] + strings.Repeat(", ?", len(placeholders)-1) + `)
         `
@@ -246,49 +211,11 @@ func (r *Repository) GetMultiple(ids []string) ([]*models.Character, error) {
         var characters []*models.Character
 
         for rows.Next() {
-                character := &models.Character{}
-                var equipmentJSON, spellsJSON string
-
-                err := rows.Scan(
-                        &character.ID,
-                        &character.UserID,
-                        &character.Name,
-                        &character.Race,
-                        &character.Class,
-                        &character.Level,
-                        &character.Strength,
-                        &character.Dexterity,
-                        &character.Constitution,
-                        &character.Intelligence,
-                        &character.Wisdom,
-                        &character.Charisma,
-                        &character.HitPoints,
-                        &character.MaxHitPoints,
-                        &character.ArmorClass,
-                        &equipmentJSON,
-                        &spellsJSON,
-                        &character.CreatedAt,
-                        &character.UpdatedAt,
-                )
-
+                character, err := scanCharacter(rows)
                 if err != nil {
                         return nil, err
                 }
 
-                // Parse equipment JSON
-                if equipmentJSON != "" {
-                        if err := json.Unmarshal([]byte(equipmentJSON), &character.Equipment); err != nil {
-                                return nil, err
-                        }
-                }
-
-                // Parse spells JSON
-                if spellsJSON != "" {
-                        if err := json.Unmarshal([]byte(spellsJSON), &character.Spells); err != nil {
-                                return nil, err
-                        }
-                }
-
                 characters = append(characters, character)
         }
 
@@ -301,8 +228,12 @@ func (r *Repository) GetMultiple(ids []string) ([]*models.Character, error) {
 
 // Update updates a character in the database
 func (r *Repository) Update(character *models.Character) error {
-        // Convert equipment to JSON
-        equipmentJSON, err := json.Marshal(character.Equipment)
+        // Convert inventory and equipped slots to JSON
+        inventoryJSON, err := json.Marshal(inventoryOrEmpty(character.Inventory))
+        if err != nil {
+                return err
+        }
+        equippedJSON, err := json.Marshal(character.Equipped)
         if err != nil {
                 return err
         }
@@ -313,6 +244,38 @@ func (r *Repository) Update(character *models.Character) error {
                 return err
         }
 
+        // Convert spell slots to JSON
+        spellSlotsJSON, err := json.Marshal(spellSlotsOrEmpty(character.SpellSlots))
+        if err != nil {
+                return err
+        }
+
+        // Convert proficiencies to JSON
+        savingThrowsJSON, err := json.Marshal(stringsOrEmpty(character.SavingThrows))
+        if err != nil {
+                return err
+        }
+        skillsJSON, err := json.Marshal(stringsOrEmpty(character.Skills))
+        if err != nil {
+                return err
+        }
+        expertiseJSON, err := json.Marshal(stringsOrEmpty(character.Expertise))
+        if err != nil {
+                return err
+        }
+
+        // Convert feats to JSON
+        featsJSON, err := json.Marshal(stringsOrEmpty(character.Feats))
+        if err != nil {
+                return err
+        }
+
+        // Convert limited class feature uses to JSON
+        featureUsesJSON, err := json.Marshal(featureUsesOrEmpty(character.FeatureUses))
+        if err != nil {
+                return err
+        }
+
         query := `
                 UPDATE characters
                 SET
@@ -331,6 +294,19 @@ func (r *Repository) Update(character *models.Character) error {
                         armor_class = ?,
                         equipment_json = ?,
                         spells_json = ?,
+                        spell_slots_json = ?,
+                        equipped_json = ?,
+                        max_hp_reduction = ?,
+                        hit_dice_used = ?,
+                        exhaustion = ?,
+                        saving_throws_json = ?,
+                        skills_json = ?,
+                        expertise_json = ?,
+                        subrace = ?,
+                        size = ?,
+                        race_speed = ?,
+                        feature_uses_json = ?,
+                        feats_json = ?,
                         updated_at = CURRENT_TIMESTAMP
                 WHERE id = ?
         `
@@ -350,8 +326,21 @@ func (r *Repository) Update(character *models.Character) error {
                 character.HitPoints,
                 character.MaxHitPoints,
                 character.ArmorClass,
-                string(equipmentJSON),
+                string(inventoryJSON),
                 string(spellsJSON),
+                string(spellSlotsJSON),
+                string(equippedJSON),
+                character.MaxHPReduction,
+                character.HitDiceUsed,
+                character.Exhaustion,
+                string(savingThrowsJSON),
+                string(skillsJSON),
+                string(expertiseJSON),
+                character.Subrace,
+                character.Size,
+                character.RaceSpeed,
+                string(featureUsesJSON),
+                string(featsJSON),
                 character.ID,
         )
 
@@ -370,3 +359,298 @@ func (r *Repository) Update(character *models.Character) error {
 
         return nil
 }
+
+// Delete removes a character from the database
+func (r *Repository) Delete(id string) error {
+        result, err := r.db.Exec("DELETE FROM characters WHERE id = ?", id)
+        if err != nil {
+                return err
+        }
+
+        rows, err := result.RowsAffected()
+        if err != nil {
+                return err
+        }
+
+        if rows == 0 {
+                return errors.New("character not found")
+        }
+
+        return nil
+}
+
+// CreateAbilityRoll stores a new set of rolled ability scores
+func (r *Repository) CreateAbilityRoll(roll *models.AbilityRoll) error {
+        scoresJSON, err := json.Marshal(roll.Scores)
+        if err != nil {
+                return err
+        }
+        rollsJSON, err := json.Marshal(roll.Rolls)
+        if err != nil {
+                return err
+        }
+
+        query := `
+                INSERT INTO ability_rolls (user_id, scores_json, rolls_json, used, created_at)
+                VALUES (?, ?, ?, 0, ?)
+                RETURNING id
+        `
+
+        return r.db.QueryRow(query, roll.UserID, string(scoresJSON), string(rollsJSON), roll.CreatedAt).Scan(&roll.ID)
+}
+
+// GetAbilityRoll retrieves a set of rolled ability scores by ID
+func (r *Repository) GetAbilityRoll(id string) (*models.AbilityRoll, error) {
+        query := `
+                SELECT ` + abilityRollColumns + `
+                FROM ability_rolls
+                WHERE id = ?
+                LIMIT 1
+        `
+
+        roll, err := scanAbilityRoll(r.db.QueryRow(query, id))
+        if err != nil {
+                if errors.Is(err, sql.ErrNoRows) {
+                        return nil, nil
+                }
+                return nil, err
+        }
+
+        return roll, nil
+}
+
+// GetUnusedAbilityRoll retrieves the latest set of rolled ability scores a user hasn't
+// created a character from yet
+func (r *Repository) GetUnusedAbilityRoll(userID string) (*models.AbilityRoll, error) {
+        query := `
+                SELECT ` + abilityRollColumns + `
+                FROM ability_rolls
+                WHERE user_id = ? AND used = 0
+                ORDER BY created_at DESC
+                LIMIT 1
+        `
+
+        roll, err := scanAbilityRoll(r.db.QueryRow(query, userID))
+        if err != nil {
+                if errors.Is(err, sql.ErrNoRows) {
+                        return nil, nil
+                }
+                return nil, err
+        }
+
+        return roll, nil
+}
+
+// UseAbilityRoll marks a user's set of rolled ability scores as used. It fails when the
+// set was already used, so that each set makes only one character.
+func (r *Repository) UseAbilityRoll(id, userID string) error {
+        result, err := r.db.Exec("UPDATE ability_rolls SET used = 1 WHERE id = ? AND user_id = ? AND used = 0", id, userID)
+        if err != nil {
+                return err
+        }
+
+        rows, err := result.RowsAffected()
+        if err != nil {
+                return err
+        }
+
+        if rows == 0 {
+                return errors.New("ability roll not found or already used")
+        }
+
+        return nil
+}
+
+// ReleaseAbilityRoll makes a set of rolled ability scores usable again after the character
+// created from it couldn't be saved
+func (r *Repository) ReleaseAbilityRoll(id string) error {
+        _, err := r.db.Exec("UPDATE ability_rolls SET used = 0 WHERE id = ?", id)
+        return err
+}
+
+// abilityRollColumns lists the columns scanAbilityRoll reads, in order
+const abilityRollColumns = `id, user_id, scores_json, rolls_json, used, created_at`
+
+// scanAbilityRoll reads a set of rolled ability scores selected with abilityRollColumns
+func scanAbilityRoll(row rowScanner) (*models.AbilityRoll, error) {
+        roll := &models.AbilityRoll{}
+        var scoresJSON, rollsJSON string
+
+        if err := row.Scan(&roll.ID, &roll.UserID, &scoresJSON, &rollsJSON, &roll.Used, &roll.CreatedAt); err != nil {
+                return nil, err
+        }
+
+        if err := json.Unmarshal([]byte(scoresJSON), &roll.Scores); err != nil {
+                return nil, err
+        }
+        if err := json.Unmarshal([]byte(rollsJSON), &roll.Rolls); err != nil {
+                return nil, err
+        }
+
+        return roll, nil
+}
+
+// characterColumns lists the columns scanCharacter reads, in order
+const characterColumns = `
+                        id, user_id, name, race, class, level,
+                        strength, dexterity, constitution, intelligence, wisdom, charisma,
+                        hit_points, max_hit_points, armor_class, equipment_json, spells_json,
+                        spell_slots_json, equipped_json, max_hp_reduction, hit_dice_used, exhaustion,
+                        saving_throws_json, skills_json, expertise_json, subrace, size, race_speed,
+                        feature_uses_json, feats_json, created_at, updated_at`
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+        Scan(dest ...interface{}) error
+}
+
+// scanCharacter reads a character selected with characterColumns
+func scanCharacter(row rowScanner) (*models.Character, error) {
+        character := &models.Character{}
+        var inventoryJSON, spellsJSON, spellSlotsJSON, equippedJSON string
+        var savingThrowsJSON, skillsJSON, expertiseJSON, featureUsesJSON, featsJSON string
+
+        err := row.Scan(
+                &character.ID,
+                &character.UserID,
+                &character.Name,
+                &character.Race,
+                &character.Class,
+                &character.Level,
+                &character.Strength,
+                &character.Dexterity,
+                &character.Constitution,
+                &character.Intelligence,
+                &character.Wisdom,
+                &character.Charisma,
+                &character.HitPoints,
+                &character.MaxHitPoints,
+                &character.ArmorClass,
+                &inventoryJSON,
+                &spellsJSON,
+                &spellSlotsJSON,
+                &equippedJSON,
+                &character.MaxHPReduction,
+                &character.HitDiceUsed,
+                &character.Exhaustion,
+                &savingThrowsJSON,
+                &skillsJSON,
+                &expertiseJSON,
+                &character.Subrace,
+                &character.Size,
+                &character.RaceSpeed,
+                &featureUsesJSON,
+                &featsJSON,
+                &character.CreatedAt,
+                &character.UpdatedAt,
+        )
+        if err != nil {
+                return nil, err
+        }
+
+        // Parse inventory JSON
+        if inventoryJSON != "" {
+                inventory, err := parseInventory(inventoryJSON)
+                if err != nil {
+                        return nil, err
+                }
+                character.Inventory = inventory
+        }
+
+        // Parse equipped slots JSON
+        if equippedJSON != "" {
+                if err := json.Unmarshal([]byte(equippedJSON), &character.Equipped); err != nil {
+                        return nil, err
+                }
+        }
+
+        // Parse spells JSON
+        if spellsJSON != "" {
+                if err := json.Unmarshal([]byte(spellsJSON), &character.Spells); err != nil {
+                        return nil, err
+                }
+        }
+
+        // Parse spell slots JSON
+        if spellSlotsJSON != "" {
+                if err := json.Unmarshal([]byte(spellSlotsJSON), &character.SpellSlots); err != nil {
+                        return nil, err
+                }
+        }
+
+        // Parse proficiencies and feats JSON
+        for _, list := range []struct {
+                data   string
+                target *[]string
+        }{
+                {savingThrowsJSON, &character.SavingThrows},
+                {skillsJSON, &character.Skills},
+                {expertiseJSON, &character.Expertise},
+                {featsJSON, &character.Feats},
+        } {
+                if err := json.Unmarshal([]byte(list.data), list.target); err != nil {
+                        return nil, err
+                }
+        }
+
+        // Parse limited class feature uses JSON
+        if err := json.Unmarshal([]byte(featureUsesJSON), &character.FeatureUses); err != nil {
+                return nil, err
+        }
+
+        // Armor class, speed and encumbrance aren't stored
+        character.DeriveStats()
+
+        return character, nil
+}
+
+// parseInventory reads the inventory stored in equipment_json, which holds a plain list of
+// item names for characters created before inventories had quantities and slots
+func parseInventory(data string) ([]models.InventoryItem, error) {
+        var inventory []models.InventoryItem
+        if err := json.Unmarshal([]byte(data), &inventory); err == nil {
+                return inventory, nil
+        }
+
+        var names []string
+        if err := json.Unmarshal([]byte(data), &names); err != nil {
+                return nil, err
+        }
+        inventory = make([]models.InventoryItem, 0, len(names))
+        for _, name := range names {
+                inventory = append(inventory, models.InventoryItem{Index: name, Name: name, Quantity: 1})
+        }
+        return inventory, nil
+}
+
+// inventoryOrEmpty stores characters without items as an empty list rather than null
+func inventoryOrEmpty(inventory []models.InventoryItem) []models.InventoryItem {
+        if inventory == nil {
+                return []models.InventoryItem{}
+        }
+        return inventory
+}
+
+// stringsOrEmpty stores empty lists of indexes as an empty list rather than null
+func stringsOrEmpty(list []string) []string {
+        if list == nil {
+                return []string{}
+        }
+        return list
+}
+
+// featureUsesOrEmpty stores characters without limited class features as an empty list rather than null
+func featureUsesOrEmpty(uses []models.FeatureUse) []models.FeatureUse {
+        if uses == nil {
+                return []models.FeatureUse{}
+        }
+        return uses
+}
+
+// spellSlotsOrEmpty stores characters without spell slots as an empty list rather than null
+func spellSlotsOrEmpty(slots []models.SpellSlot) []models.SpellSlot {
+        if slots == nil {
+                return []models.SpellSlot{}
+        }
+        return slots
+}
@@ -1,24 +1,94 @@
 package character
 
 import (
+	"errors"
+	"fmt"
+	"strings"
+
 	"dnd-combat/internal/models"
+	"dnd-combat/pkg/dnd5e"
 )
 
+// MaxLevel is the highest level a character can reach
+const MaxLevel = 20
+
+// SRDClient looks up the races, equipment and class tables characters are built from
+type SRDClient interface {
+	GetRace(index string) (*models.Race, error)
+	GetSubrace(index string) (*models.Subrace, error)
+	GetEquipment(index string) (*models.Equipment, error)
+	GetClassSavingThrows(class string) ([]string, error)
+	GetHitDie(class string) (int, error)
+	GetSpellSlots(class string, level int) ([]models.SpellSlot, error)
+}
+
 // Service handles character business logic
 type Service struct {
-	repo *Repository
+	repo      *Repository
+	srdClient SRDClient
 }
 
 // NewService creates a new character service
-func NewService(repo *Repository) *Service {
+func NewService(repo *Repository, srdClient SRDClient) *Service {
 	return &Service{
-		repo: repo,
+		repo:      repo,
+		srdClient: srdClient,
 	}
 }
 
-// Create creates a new character
-func (s *Service) Create(character *models.Character) error {
-	return s.repo.Create(character)
+// LevelUpResult is a character after gaining a level and the hit points they gained
+type LevelUpResult struct {
+	Character       *models.Character   `json:"character"`
+	HitPointsGained int                 `json:"hit_points_gained"`
+	Rolls           []models.RollRecord `json:"rolls,omitempty"`
+}
+
+// RestResult is a character after a rest and what the rest restored
+type RestResult struct {
+	Character           *models.Character   `json:"character"`
+	HitPointsRegained   int                 `json:"hit_points_regained"`
+	HitDiceSpent        int                 `json:"hit_dice_spent,omitempty"`
+	HitDiceRegained     int                 `json:"hit_dice_regained,omitempty"`
+	ExhaustionRemoved   int                 `json:"exhaustion_removed,omitempty"`
+	SpellSlotsRestored  int                 `json:"spell_slots_restored,omitempty"`
+	FeatureUsesRestored int                 `json:"feature_uses_restored,omitempty"`
+	Rolls               []models.RollRecord `json:"rolls,omitempty"`
+}
+
+// RollResult is an ability check or saving throw made by a character
+type RollResult struct {
+	CharacterID  string              `json:"character_id"`
+	Name         string              `json:"name"`
+	Type         string              `json:"type"` // "check" or "save"
+	Ability      string              `json:"ability"`
+	Skill        string              `json:"skill,omitempty"`
+	Modifier     int                 `json:"modifier"`
+	Proficient   bool                `json:"proficient"`
+	Expertise    bool                `json:"expertise,omitempty"`
+	Total        int                 `json:"total"`
+	DC           int                 `json:"dc,omitempty"`
+	Success      *bool               `json:"success,omitempty"` // Only set when a DC is given
+	Advantage    []string            `json:"advantage,omitempty"`
+	Disadvantage []string            `json:"disadvantage,omitempty"`
+	Rolls        []models.RollRecord `json:"rolls"`
+}
+
+// Create creates a new character. When their ability scores were rolled, the roll is
+// used up so it can't make another character.
+func (s *Service) Create(character *models.Character, abilityRollID string) error {
+	if abilityRollID != "" {
+		if err := s.repo.UseAbilityRoll(abilityRollID, character.UserID); err != nil {
+			return err
+		}
+	}
+	character.DeriveStats()
+	if err := s.repo.Create(character); err != nil {
+		if abilityRollID != "" {
+			s.repo.ReleaseAbilityRoll(abilityRollID)
+		}
+		return err
+	}
+	return nil
 }
 
 // GetByID retrieves a character by ID
@@ -38,5 +108,474 @@ func (s *Service) GetMultiple(ids []string) ([]*models.Character, error) {
 
 // Update updates a character
 func (s *Service) Update(character *models.Character) error {
+	character.DeriveStats()
 	return s.repo.Update(character)
 }
+
+// Delete deletes a character
+func (s *Service) Delete(id string) error {
+	return s.repo.Delete(id)
+}
+
+// LevelUp raises a character's level by one. They gain hit points from their class's hit
+// die, either its average rounded up or a roll, plus their CON modifier (at least 1), along
+// with another hit die, the proficiency bonus of the new level and its spell slots.
+// The character isn't saved.
+func (s *Service) LevelUp(character *models.Character, method string) (*LevelUpResult, error) {
+	if character.Level >= MaxLevel {
+		return nil, fmt.Errorf("%s is already level %d", character.Name, MaxLevel)
+	}
+
+	hitDie, err := s.srdClient.GetHitDie(character.Class)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up the hit die of %s: %w", character.Class, err)
+	}
+
+	result := &LevelUpResult{Character: character}
+	var gained int
+	switch method {
+	case "", "average":
+		gained = hitDie/2 + 1
+	case "roll":
+		roller := dnd5e.NewDiceRoller()
+		roller.Label("level up hit points", character.ID)
+		gained = roller.Roll(1, hitDie)
+		result.Rolls = roller.TakeRecords()
+	default:
+		return nil, fmt.Errorf("unknown hit point method '%s', expected average or roll", method)
+	}
+	gained += models.GetAbilityModifier(character.Constitution)
+	if gained < 1 {
+		gained = 1
+	}
+
+	slots, err := s.srdClient.GetSpellSlots(character.Class, character.Level+1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up the spell slots of %s: %w", character.Class, err)
+	}
+	for i := range slots {
+		if existing := character.GetSpellSlot(slots[i].Level); existing != nil {
+			slots[i].Used = existing.Used
+		}
+	}
+
+	character.Level++
+	character.MaxHitPoints += gained
+	character.HitPoints += gained
+	character.SpellSlots = slots
+	character.DeriveStats()
+
+	result.HitPointsGained = gained
+	return result, nil
+}
+
+// restoreFeatureUses restores the uses of a character's class features that recover on a
+// rest, returning how many uses were restored. A long rest also restores the features
+// that recover on a short rest.
+func restoreFeatureUses(character *models.Character, rest string) int {
+	restored := 0
+	for i := range character.FeatureUses {
+		use := &character.FeatureUses[i]
+		if rest == models.LongRest || use.Recovery == models.ShortRest {
+			restored += use.Used
+			use.Used = 0
+		}
+	}
+	return restored
+}
+
+// ShortRest spends hit dice to regain hit points. Each die is rolled and adds the
+// character's CON modifier, regaining at least 0 hit points. Class features that recover
+// on a short rest, like Action Surge, regain their uses. The character isn't saved.
+func (s *Service) ShortRest(character *models.Character, hitDice int) (*RestResult, error) {
+	if hitDice < 0 {
+		return nil, errors.New("the number of hit dice to spend can't be negative")
+	}
+	if hitDice > character.HitDiceLeft() {
+		return nil, fmt.Errorf("%s has %d hit dice left", character.Name, character.HitDiceLeft())
+	}
+
+	result := &RestResult{Character: character, HitDiceSpent: hitDice}
+	result.FeatureUsesRestored = restoreFeatureUses(character, models.ShortRest)
+	if hitDice == 0 {
+		return result, nil
+	}
+
+	hitDie, err := s.srdClient.GetHitDie(character.Class)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up the hit die of %s: %w", character.Class, err)
+	}
+
+	roller := dnd5e.NewDiceRoller()
+	roller.Label("hit dice", character.ID)
+	constitution := models.GetAbilityModifier(character.Constitution)
+	healing := 0
+	for i := 0; i < hitDice; i++ {
+		roll := roller.Roll(1, hitDie) + constitution
+		roller.AddModifier(constitution)
+		if roll > 0 {
+			healing += roll
+		}
+	}
+	result.Rolls = roller.TakeRecords()
+
+	before := character.HitPoints
+	character.HitPoints += healing
+	if maxHP := character.MaxHitPoints - character.MaxHPReduction; character.HitPoints > maxHP {
+		character.HitPoints = maxHP
+	}
+	character.HitDiceUsed += hitDice
+
+	result.HitPointsRegained = character.HitPoints - before
+	return result, nil
+}
+
+// LongRest restores a character's hit points, hit point maximum, spell slots and class feature uses, regains
+// half their hit dice (at least one) and removes a level of exhaustion. A character needs
+// at least 1 hit point to benefit from a long rest. The character isn't saved.
+func (s *Service) LongRest(character *models.Character) (*RestResult, error) {
+	if character.HitPoints < 1 {
+		return nil, fmt.Errorf("%s needs at least 1 hit point to benefit from a long rest", character.Name)
+	}
+
+	result := &RestResult{Character: character}
+
+	character.MaxHPReduction = 0
+	result.HitPointsRegained = character.MaxHitPoints - character.HitPoints
+	character.HitPoints = character.MaxHitPoints
+
+	regained := character.Level / 2
+	if regained < 1 {
+		regained = 1
+	}
+	if regained > character.HitDiceUsed {
+		regained = character.HitDiceUsed
+	}
+	character.HitDiceUsed -= regained
+	result.HitDiceRegained = regained
+
+	for i := range character.SpellSlots {
+		result.SpellSlotsRestored += character.SpellSlots[i].Used
+		character.SpellSlots[i].Used = 0
+	}
+	result.FeatureUsesRestored = restoreFeatureUses(character, models.LongRest)
+
+	if character.Exhaustion > 0 {
+		character.Exhaustion--
+		result.ExhaustionRemoved = 1
+	}
+
+	return result, nil
+}
+
+// SetProficiencies sets a character's saving throw proficiencies from their class in the
+// SRD and checks that their skill proficiencies and expertise are SRD skills. Expertise
+// can only be in skills the character is proficient in.
+func (s *Service) SetProficiencies(character *models.Character, skills, expertise []string) error {
+	savingThrows, err := s.srdClient.GetClassSavingThrows(character.Class)
+	if err != nil {
+		return fmt.Errorf("failed to look up the saving throws of %s: %w", character.Class, err)
+	}
+
+	skills, err = skillIndexes(skills)
+	if err != nil {
+		return err
+	}
+	expertise, err = skillIndexes(expertise)
+	if err != nil {
+		return err
+	}
+	for _, skill := range expertise {
+		if !containsIndex(skills, skill) {
+			return fmt.Errorf("expertise in '%s' needs proficiency in it", skill)
+		}
+	}
+
+	character.SavingThrows = savingThrows
+	character.Skills = skills
+	character.Expertise = expertise
+	return nil
+}
+
+// skillIndexes turns skill names like "Sleight of Hand" into SRD skill indexes, rejecting
+// unknown skills and duplicates
+func skillIndexes(names []string) ([]string, error) {
+	skills := make([]string, 0, len(names))
+	for _, name := range names {
+		skill := strings.ReplaceAll(strings.ToLower(strings.TrimSpace(name)), " ", "-")
+		if _, ok := models.SkillAbilities[skill]; !ok {
+			return nil, fmt.Errorf("unknown skill '%s'", name)
+		}
+		if containsIndex(skills, skill) {
+			return nil, fmt.Errorf("'%s' is listed more than once", skill)
+		}
+		skills = append(skills, skill)
+	}
+	return skills, nil
+}
+
+// containsIndex checks if a list of SRD indexes contains one
+func containsIndex(indexes []string, index string) bool {
+	for _, i := range indexes {
+		if i == index {
+			return true
+		}
+	}
+	return false
+}
+
+// AbilityCheck makes a character roll an ability check, optionally with a skill. The check
+// uses the skill's ability unless another ability is given, as in a Strength (Intimidation)
+// check. A DC of 0 rolls without one.
+func (s *Service) AbilityCheck(character *models.Character, ability, skill string, dc int, advantage, disadvantage bool) (*RollResult, error) {
+	if skill != "" {
+		skills, err := skillIndexes([]string{skill})
+		if err != nil {
+			return nil, err
+		}
+		skill = skills[0]
+		if ability == "" {
+			ability = models.SkillAbilities[skill]
+		}
+	}
+	if !isAbility(ability) {
+		return nil, fmt.Errorf("unknown ability '%s'", ability)
+	}
+
+	result := &RollResult{
+		CharacterID: character.ID,
+		Name:        character.Name,
+		Type:        "check",
+		Ability:     ability,
+		Skill:       skill,
+		Modifier:    character.AbilityModifier(ability),
+		DC:          dc,
+	}
+	proficiencyBonus := 0
+	if skill != "" {
+		result.Expertise = containsIndex(character.Expertise, skill)
+		result.Proficient = result.Expertise || containsIndex(character.Skills, skill)
+		proficiencyBonus = models.GetProficiencyBonus(character.Level)
+		if result.Expertise {
+			proficiencyBonus *= 2
+		}
+	}
+
+	result.addMode(advantage, disadvantage)
+	if character.Exhaustion >= 1 {
+		result.Disadvantage = append(result.Disadvantage, character.Name+" is exhausted")
+	}
+	if skill == "stealth" {
+		if armor := character.WornArmor(); armor != nil && armor.StealthDisadvantage {
+			result.Disadvantage = append(result.Disadvantage, character.Name+"'s armor is noisy")
+		}
+	}
+	result.addEncumbrance(character)
+
+	roller := dnd5e.NewDiceRoller()
+	roller.Label(checkPurpose(ability, skill), character.ID)
+	hasAdvantage, hasDisadvantage := result.mode()
+	result.Total = roller.RollAbilityCheck(result.Modifier, proficiencyBonus, result.Proficient, hasAdvantage, hasDisadvantage)
+	if result.Proficient {
+		result.Modifier += proficiencyBonus
+	}
+	result.Rolls = roller.TakeRecords()
+	result.judge()
+	return result, nil
+}
+
+// SavingThrow makes a character roll a saving throw, adding their proficiency bonus if
+// their class is proficient in it. A DC of 0 rolls without one.
+func (s *Service) SavingThrow(character *models.Character, ability string, dc int, advantage, disadvantage bool) (*RollResult, error) {
+	if !isAbility(ability) {
+		return nil, fmt.Errorf("unknown ability '%s'", ability)
+	}
+
+	result := &RollResult{
+		CharacterID: character.ID,
+		Name:        character.Name,
+		Type:        "save",
+		Ability:     ability,
+		Modifier:    character.SavingThrowBonus(ability),
+		Proficient:  character.IsProficientInSave(ability),
+		DC:          dc,
+	}
+
+	result.addMode(advantage, disadvantage)
+	if character.Exhaustion >= 3 {
+		result.Disadvantage = append(result.Disadvantage, character.Name+" is exhausted")
+	}
+	result.addEncumbrance(character)
+
+	roller := dnd5e.NewDiceRoller()
+	roller.Label(strings.ToUpper(ability)+" saving throw", character.ID)
+	hasAdvantage, hasDisadvantage := result.mode()
+	result.Total = roller.RollSavingThrowTotal(result.Modifier, hasAdvantage, hasDisadvantage)
+	result.Rolls = roller.TakeRecords()
+	result.judge()
+	return result, nil
+}
+
+// isAbility checks if an ability is one of the SRD ability indexes
+func isAbility(ability string) bool {
+	for _, a := range models.Abilities {
+		if a == ability {
+			return true
+		}
+	}
+	return false
+}
+
+// checkPurpose labels the roll of an ability check, like "DEX (stealth) check"
+func checkPurpose(ability, skill string) string {
+	if skill == "" {
+		return strings.ToUpper(ability) + " check"
+	}
+	return fmt.Sprintf("%s (%s) check", strings.ToUpper(ability), skill)
+}
+
+// addMode records advantage or disadvantage asked for by whoever called for the roll
+func (r *RollResult) addMode(advantage, disadvantage bool) {
+	if advantage {
+		r.Advantage = append(r.Advantage, "requested")
+	}
+	if disadvantage {
+		r.Disadvantage = append(r.Disadvantage, "requested")
+	}
+}
+
+// addEncumbrance gives heavily encumbered characters disadvantage on Strength, Dexterity
+// and Constitution rolls
+func (r *RollResult) addEncumbrance(character *models.Character) {
+	switch r.Ability {
+	case "str", "dex", "con":
+		if character.Encumbrance == models.HeavilyEncumbered || character.Encumbrance == models.OverCapacity {
+			r.Disadvantage = append(r.Disadvantage, character.Name+" is heavily encumbered")
+		}
+	}
+}
+
+// mode applies the 5e rule that any advantage and any disadvantage cancel out
+func (r *RollResult) mode() (advantage bool, disadvantage bool) {
+	advantage, disadvantage = len(r.Advantage) > 0, len(r.Disadvantage) > 0
+	if advantage && disadvantage {
+		return false, false
+	}
+	return advantage, disadvantage
+}
+
+// judge sets whether the roll succeeded when it was made against a DC
+func (r *RollResult) judge() {
+	if r.DC > 0 {
+		success := r.Total >= r.DC
+		r.Success = &success
+	}
+}
+
+// SetInventory replaces a character's inventory and equipped slots. Each item is filled in
+// from the SRD equipment catalog, and the slots must hold items of the right kind.
+func (s *Service) SetInventory(character *models.Character, inventory []models.InventoryItem, equipped models.EquippedSlots) error {
+	items := make([]models.InventoryItem, 0, len(inventory))
+	attuned := 0
+	for _, item := range inventory {
+		index := strings.ToLower(strings.TrimSpace(item.Index))
+		if index == "" {
+			return errors.New("every inventory item needs an index")
+		}
+		for _, existing := range items {
+			if existing.Index == index {
+				return fmt.Errorf("'%s' is listed more than once", index)
+			}
+		}
+
+		equipment, err := s.srdClient.GetEquipment(index)
+		if err != nil {
+			return fmt.Errorf("failed to look up item '%s': %w", index, err)
+		}
+
+		quantity := item.Quantity
+		if quantity < 1 {
+			quantity = 1
+		}
+		if item.Attuned {
+			attuned++
+		}
+		items = append(items, models.InventoryItem{
+			Index:    equipment.Index,
+			Name:     equipment.Name,
+			Category: equipment.Category,
+			Quantity: quantity,
+			Weight:   equipment.Weight,
+			Equipped: item.Equipped,
+			Attuned:  item.Attuned,
+			Armor:    equipment.Armor,
+		})
+	}
+	if attuned > models.MaxAttunedItems {
+		return fmt.Errorf("a character can be attuned to at most %d items", models.MaxAttunedItems)
+	}
+
+	character.Inventory = items
+	character.Equipped = equipped
+	if err := validateSlots(character); err != nil {
+		return err
+	}
+
+	// Everything in a slot is equipped
+	for _, index := range []string{equipped.MainHand, equipped.OffHand, equipped.Armor, equipped.Shield} {
+		if item := character.GetItem(index); item != nil {
+			item.Equipped = true
+		}
+	}
+
+	character.DeriveStats()
+	return nil
+}
+
+// validateSlots checks that a character's slots hold items from their inventory that fit them
+func validateSlots(character *models.Character) error {
+	slots := character.Equipped
+	slot := func(name, index string) (*models.InventoryItem, error) {
+		if index == "" {
+			return nil, nil
+		}
+		item := character.GetItem(index)
+		if item == nil {
+			return nil, fmt.Errorf("%s item '%s' is not in the inventory", name, index)
+		}
+		return item, nil
+	}
+
+	mainHand, err := slot("main hand", slots.MainHand)
+	if err != nil {
+		return err
+	}
+	offHand, err := slot("off hand", slots.OffHand)
+	if err != nil {
+		return err
+	}
+	armor, err := slot("armor", slots.Armor)
+	if err != nil {
+		return err
+	}
+	shield, err := slot("shield", slots.Shield)
+	if err != nil {
+		return err
+	}
+
+	if armor != nil && (armor.Armor == nil || armor.Armor.Category == "shield") {
+		return fmt.Errorf("'%s' is not armor", armor.Index)
+	}
+	if shield != nil && (shield.Armor == nil || shield.Armor.Category != "shield") {
+		return fmt.Errorf("'%s' is not a shield", shield.Index)
+	}
+	if mainHand != nil && offHand != nil && mainHand.Index == offHand.Index && mainHand.Quantity < 2 {
+		return fmt.Errorf("only one '%s' to hold in both hands", mainHand.Index)
+	}
+
+	// A shield takes up the off hand
+	if shield != nil && offHand != nil {
+		return fmt.Errorf("the off hand can't hold '%s' and a shield", offHand.Index)
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package combat
+
+import (
+        "fmt"
+        "strings"
+
+        "dnd-combat/internal/models"
+)
+
+// abilityNames maps the SRD ability indexes to display names
+var abilityNames = map[string]string{
+        "str": "Strength",
+        "dex": "Dexterity",
+        "con": "Constitution",
+        "int": "Intelligence",
+        "wis": "Wisdom",
+        "cha": "Charisma",
+}
+
+// normalizeAbility converts names like "Dexterity" or "DEX" to the SRD index "dex"
+func normalizeAbility(ability string) string {
+        ability = strings.ToLower(strings.TrimSpace(ability))
+        if len(ability) > 3 {
+                ability = ability[:3]
+        }
+        return ability
+}
+
+// abilityModifier returns a combatant's modifier for an ability
+func abilityModifier(combatant *models.Combatant, ability string) int {
+        switch stats := combatant.Stats.(type) {
+        case *models.Character:
+                switch normalizeAbility(ability) {
+                case "str":
+                        return models.GetAbilityModifier(stats.Strength)
+                case "dex":
+                        return models.GetAbilityModifier(stats.Dexterity)
+                case "con":
+                        return models.GetAbilityModifier(stats.Constitution)
+                case "int":
+                        return models.GetAbilityModifier(stats.Intelligence)
+                case "wis":
+                        return models.GetAbilityModifier(stats.Wisdom)
+                case "cha":
+                        return models.GetAbilityModifier(stats.Charisma)
+                }
+        case *models.Monster:
+                switch normalizeAbility(ability) {
+                case "str":
+                        return stats.StrengthMod
+                case "dex":
+                        return stats.DexterityMod
+                case "con":
+                        return stats.ConMod
+                case "int":
+                        return stats.IntMod
+                case "wis":
+                        return stats.WisdomMod
+                case "cha":
+                        return stats.CharismaMod
+                }
+        }
+        return 0
+}
+
+// proficiencyBonus returns a combatant's proficiency bonus from their level or challenge rating
+func proficiencyBonus(combatant *models.Combatant) int {
+        level := 1
+        switch stats := combatant.Stats.(type) {
+        case *models.Character:
+                level = stats.Level
+        case *models.Monster:
+                level = int(stats.ChallengeRating)
+        }
+
+        if level < 1 {
+                level = 1
+        }
+        return models.GetProficiencyBonus(level)
+}
+
+// savingThrowBonus returns the modifier a combatant adds to saving throws of an ability:
+// their ability modifier, plus their proficiency bonus if they're proficient in the save
+func savingThrowBonus(combatant *models.Combatant, ability string) int {
+        ability = normalizeAbility(ability)
+        switch stats := combatant.Stats.(type) {
+        case *models.Character:
+                return stats.SavingThrowBonus(ability)
+        case *models.Monster:
+                if bonus, ok := stats.SavingThrows[ability]; ok {
+                        return bonus
+                }
+        }
+        return abilityModifier(combatant, ability)
+}
+
+// skillBonus returns the modifier a combatant adds to checks of a skill like "stealth",
+// including their proficiency bonus if they're proficient in it
+func skillBonus(combatant *models.Combatant, skill string) int {
+        switch stats := combatant.Stats.(type) {
+        case *models.Character:
+                return stats.SkillBonus(skill)
+        case *models.Monster:
+                if bonus, ok := stats.Skills[skill]; ok {
+                        return bonus
+                }
+        }
+        return abilityModifier(combatant, models.SkillAbilities[skill])
+}
+
+// rollSavingThrow makes a combatant roll a saving throw against a DC, applying
+// the effects of their conditions. It returns whether the save succeeded.
+func (s *Service) rollSavingThrow(combatant *models.Combatant, ability string, dc int) (bool, string) {
+        save := s.saveModifiers(combatant, ability)
+        abilityName := abilityNames[normalizeAbility(ability)]
+
+        if save.AutoFail {
+                return false, fmt.Sprintf("%s automatically fails the %s saving throw", combatant.Name, abilityName)
+        }
+
+        s.diceRoller.Label(abilityName+" saving throw", combatant.ID)
+        if s.diceRoller.RollSavingThrow(save.AbilityMod, dc, save.HasAdvantage, save.HasDisadvantage) {
+                return true, fmt.Sprintf("%s succeeds on a DC %d %s saving throw", combatant.Name, dc, abilityName)
+        }
+        return false, fmt.Sprintf("%s fails a DC %d %s saving throw", combatant.Name, dc, abilityName)
+}
@@ -0,0 +1,183 @@
+package combat
+
+import (
+        "fmt"
+        "strings"
+
+        "dnd-combat/internal/models"
+)
+
+// rollMode collects every source of advantage and disadvantage on a d20 roll
+type rollMode struct {
+        Advantage    []string
+        Disadvantage []string
+}
+
+// addAdvantage records a source of advantage
+func (m *rollMode) addAdvantage(format string, args ...interface{}) {
+        m.Advantage = append(m.Advantage, fmt.Sprintf(format, args...))
+}
+
+// addDisadvantage records a source of disadvantage
+func (m *rollMode) addDisadvantage(format string, args ...interface{}) {
+        m.Disadvantage = append(m.Disadvantage, fmt.Sprintf(format, args...))
+}
+
+// resolve applies the 5e cancellation rule: any source of advantage and any source of
+// disadvantage cancel out, no matter how many of each there are
+func (m rollMode) resolve() (advantage bool, disadvantage bool) {
+        advantage = len(m.Advantage) > 0
+        disadvantage = len(m.Disadvantage) > 0
+        if advantage && disadvantage {
+                return false, false
+        }
+        return advantage, disadvantage
+}
+
+// describe explains how the roll was made, or returns "" for a straight roll
+func (m rollMode) describe() string {
+        advantage, disadvantage := m.resolve()
+        switch {
+        case advantage:
+                return fmt.Sprintf(" [advantage: %s]", strings.Join(m.Advantage, ", "))
+        case disadvantage:
+                return fmt.Sprintf(" [disadvantage: %s]", strings.Join(m.Disadvantage, ", "))
+        case len(m.Advantage) > 0:
+                return " [advantage and disadvantage cancel out]"
+        }
+        return ""
+}
+
+// record lists the sources of the roll on an action result
+func (m rollMode) record(result *models.ActionResult) {
+        result.Advantage = append(result.Advantage, m.Advantage...)
+        result.Disadvantage = append(result.Disadvantage, m.Disadvantage...)
+}
+
+// rollD20 rolls a d20 with advantage, disadvantage or neither
+func (s *Service) rollD20(mode rollMode) int {
+        advantage, disadvantage := mode.resolve()
+        switch {
+        case advantage:
+                return s.diceRoller.RollWithAdvantage()
+        case disadvantage:
+                return s.diceRoller.RollWithDisadvantage()
+        }
+        return s.diceRoller.Roll(1, 20)
+}
+
+// attackRollMode collects the advantage and disadvantage on an attack roll and
+// reports whether a hit is automatically a critical hit
+func (s *Service) attackRollMode(combat *models.Combat, attacker, target *models.Combatant, ranged bool, normalRange int) (rollMode, bool) {
+        var mode rollMode
+        distance := distanceFeet(attacker.Position, target.Position)
+
+        // Conditions of the attacker and the target
+        autoCritical := attackConditionEffects(&mode, attacker, target, distance)
+
+        // Unseen attackers and targets
+        if attacker.HasCondition("hidden") {
+                mode.addAdvantage("%s is hidden", attacker.Name)
+        }
+        if target.HasCondition("hidden") {
+                mode.addDisadvantage("%s is hidden", target.Name)
+        }
+
+        // The Dodge action only works while the target can move and act
+        if target.HasCondition("dodge") && !isIncapacitated(target) && target.Economy.Speed > 0 {
+                mode.addDisadvantage("%s is dodging", target.Name)
+        }
+
+        // The Help action
+        if attacker.HasCondition("helped") {
+                mode.addAdvantage("%s is being helped", attacker.Name)
+        }
+
+        if heavilyEncumbered(attacker) {
+                mode.addDisadvantage("%s is heavily encumbered", attacker.Name)
+        }
+
+        if ranged {
+                // Ranged attacks with a hostile creature within 5 feet
+                for i := range combat.Participants {
+                        other := &combat.Participants[i]
+                        if other.HP > 0 && isHostile(other, attacker) && !isIncapacitated(other) &&
+                                distanceFeet(attacker.Position, other.Position) <= 5 {
+                                mode.addDisadvantage("%s is within 5 feet of %s", other.Name, attacker.Name)
+                                break
+                        }
+                }
+
+                // Attacks beyond normal range
+                if distance > normalRange {
+                        mode.addDisadvantage("%s is at long range", target.Name)
+                }
+        }
+
+        return mode, autoCritical
+}
+
+// checkRollMode collects the advantage and disadvantage on an ability check
+func checkRollMode(combatant *models.Combatant) rollMode {
+        var mode rollMode
+
+        if hasCondition(combatant, "poisoned") {
+                mode.addDisadvantage("%s is poisoned", combatant.Name)
+        }
+        if hasCondition(combatant, "frightened") {
+                mode.addDisadvantage("%s is frightened", combatant.Name)
+        }
+        if exhaustionLevel(combatant) >= 1 {
+                mode.addDisadvantage("%s is exhausted", combatant.Name)
+        }
+        if combatant.HasCondition("helped") {
+                mode.addAdvantage("%s is being helped", combatant.Name)
+        }
+
+        return mode
+}
+
+// saveRollMode collects the advantage and disadvantage on a saving throw and whether
+// the save fails automatically
+func saveRollMode(combatant *models.Combatant, ability string) (rollMode, bool) {
+        var mode rollMode
+        autoFail := false
+        ability = normalizeAbility(ability)
+
+        // Strength and Dexterity saves automatically fail while paralyzed, petrified, stunned or unconscious
+        if ability == "str" || ability == "dex" {
+                for _, name := range []string{"paralyzed", "petrified", "stunned", "unconscious"} {
+                        if hasCondition(combatant, name) {
+                                autoFail = true
+                        }
+                }
+        }
+
+        if ability == "dex" && hasCondition(combatant, "restrained") {
+                mode.addDisadvantage("%s is restrained", combatant.Name)
+        }
+        if ability == "dex" && combatant.HasCondition("dodge") && !isIncapacitated(combatant) {
+                mode.addAdvantage("%s is dodging", combatant.Name)
+        }
+        if ability == "str" && isRaging(combatant) {
+                mode.addAdvantage("%s is raging", combatant.Name)
+        }
+        if exhaustionLevel(combatant) >= 3 {
+                mode.addDisadvantage("%s is exhausted", combatant.Name)
+        }
+        if (ability == "str" || ability == "dex" || ability == "con") && heavilyEncumbered(combatant) {
+                mode.addDisadvantage("%s is heavily encumbered", combatant.Name)
+        }
+
+        return mode, autoFail
+}
+
+// heavilyEncumbered checks if a character carries so much that their attacks and Strength,
+// Dexterity and Constitution saves are made with disadvantage
+func heavilyEncumbered(combatant *models.Combatant) bool {
+        char, ok := combatant.Stats.(*models.Character)
+        if !ok {
+                return false
+        }
+        return char.Encumbrance == models.HeavilyEncumbered || char.Encumbrance == models.OverCapacity
+}
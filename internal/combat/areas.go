@@ -0,0 +1,244 @@
+package combat
+
+import (
+        "errors"
+        "fmt"
+        "math"
+        "strings"
+
+        "dnd-combat/internal/models"
+)
+
+// maxAreaSize is the largest radius, length or side in feet an area can have, a little more
+// than any SRD spell's area. Every cell within it is checked when the area is placed.
+const maxAreaSize = 400
+
+// areaDirections maps compass directions to grid steps, with north towards y = 0
+var areaDirections = map[string][2]int{
+        "n":  {0, -1},
+        "ne": {1, -1},
+        "e":  {1, 0},
+        "se": {1, 1},
+        "s":  {0, 1},
+        "sw": {-1, 1},
+        "w":  {-1, 0},
+        "nw": {-1, -1},
+}
+
+// spellArea returns the area of effect a spell is cast with, taking the shape and size from
+// the spell's SRD data when it has an area there. Areas of spells with a range of Self start
+// at the caster. It returns nil when the action has no area.
+func spellArea(action *models.CombatAction, actor *models.Combatant, spell *models.Spell) *models.AreaTemplate {
+        if action.Area == nil {
+                return nil
+        }
+
+        area := *action.Area
+        area.Shape = strings.ToLower(area.Shape)
+        area.Direction = strings.ToLower(area.Direction)
+        if spell.AreaOfEffect != nil {
+                area.Shape = spell.AreaOfEffect.Type
+                area.Size = spell.AreaOfEffect.Size
+        }
+
+        if rangeFeet, limited := spellRangeFeet(spell.Range); limited && rangeFeet == 0 {
+                area.Origin = actor.Position
+        }
+        return &area
+}
+
+// validateSpellArea checks the shape, size, direction and placement of a spell's area. A spell
+// with an area in the SRD can only be cast with the shape and size given there.
+func validateSpellArea(combat *models.Combat, action *models.CombatAction, actor *models.Combatant, spell *models.Spell, area *models.AreaTemplate) error {
+        if spell.AreaOfEffect != nil {
+                if err := checkAreaOfEffect(spell.Name, action.Area, spell.AreaOfEffect); err != nil {
+                        return err
+                }
+        }
+
+        rangeFeet, limited := spellRangeFeet(spell.Range)
+        return validateArea(combat, actor, spell.Name, area, rangeFeet, limited)
+}
+
+// checkAreaOfEffect checks that the shape and size requested for an area, which can be left
+// out, are those of the area of effect the spell or monster action has
+func checkAreaOfEffect(name string, requested *models.AreaTemplate, aoe *models.AreaOfEffect) error {
+        if shape := requested.Shape; shape != "" && !strings.EqualFold(shape, aoe.Type) {
+                return fmt.Errorf("%s has a %s area, not a %s", name, aoe.Type, shape)
+        }
+        if size := requested.Size; size != 0 && size != aoe.Size {
+                return fmt.Errorf("%s has a %d-foot %s, not a %d-foot one", name, aoe.Size, aoe.Type, size)
+        }
+        return nil
+}
+
+// validateArea checks the shape, size, direction and placement of the area of a spell or
+// monster action, whose origin must be within rangeFeet of the actor when the range is limited
+func validateArea(combat *models.Combat, actor *models.Combatant, name string, area *models.AreaTemplate, rangeFeet int, limited bool) error {
+        switch area.Shape {
+        case "sphere", "cylinder":
+        case "cube":
+                // A cube centred on the actor would catch them in it
+                if limited && rangeFeet == 0 && area.Direction == "" {
+                        return fmt.Errorf("%s starts at %s, so its cube needs a direction", name, actor.Name)
+                }
+        case "cone", "line":
+                if area.Direction == "" {
+                        return fmt.Errorf("a %s needs a direction", area.Shape)
+                }
+        case "":
+                return fmt.Errorf("%s has no area of effect, give the area's shape", name)
+        default:
+                return fmt.Errorf("unknown area shape '%s'", area.Shape)
+        }
+
+        if area.Direction != "" {
+                if _, ok := areaDirections[area.Direction]; !ok {
+                        return fmt.Errorf("unknown direction '%s', use one of n, ne, e, se, s, sw, w or nw", area.Direction)
+                }
+        }
+
+        if area.Size <= 0 {
+                return errors.New("area size must be positive")
+        }
+        if area.Size > maxAreaSize {
+                return fmt.Errorf("area size can't be more than %d feet", maxAreaSize)
+        }
+
+        if !inBounds(combat.Battlefield, area.Origin) {
+                return errors.New("area origin is outside the battlefield")
+        }
+
+        if limited {
+                if distance := distanceFeet(actor.Position, area.Origin); distance > rangeFeet {
+                        return fmt.Errorf("area origin is out of range of %s (distance: %d ft, range: %d ft)",
+                                name, distance, rangeFeet)
+                }
+        }
+
+        return nil
+}
+
+// areaCells returns the battlefield cells covered by an area template. Cones, lines and
+// cubes with a direction extend away from their origin; spheres and cylinders spread from
+// it in every direction. Cells blocked by obstacles are not affected.
+func areaCells(battlefield models.Battlefield, area *models.AreaTemplate) [][2]int {
+        length := area.Size / 5
+        direction := areaDirections[area.Direction]
+
+        var cells [][2]int
+        for dy := -length; dy <= length; dy++ {
+                for dx := -length; dx <= length; dx++ {
+                        if !inTemplate(area.Shape, direction, length, dx, dy) {
+                                continue
+                        }
+
+                        cell := [2]int{area.Origin[0] + dx, area.Origin[1] + dy}
+                        if !inBounds(battlefield, cell) || battlefield.Obstacles[fmt.Sprintf("%d,%d", cell[0], cell[1])] {
+                                continue
+                        }
+                        cells = append(cells, cell)
+                }
+        }
+        return cells
+}
+
+// inTemplate reports whether the cell at an offset from the origin is covered by a shape
+// of the given length in cells
+func inTemplate(shape string, direction [2]int, length, dx, dy int) bool {
+        switch shape {
+        case "sphere", "cylinder":
+                return distanceFeet([2]int{0, 0}, [2]int{dx, dy}) <= length*5
+
+        case "cube":
+                return inCubeSide(direction[0], length, dx) && inCubeSide(direction[1], length, dy)
+
+        case "line":
+                // A 5-foot wide line, one cell per step in its direction
+                for step := 1; step <= length; step++ {
+                        if dx == direction[0]*step && dy == direction[1]*step {
+                                return true
+                        }
+                }
+                return false
+
+        case "cone":
+                // A cone is as wide as it is long at any distance from its origin
+                if distanceFeet([2]int{0, 0}, [2]int{dx, dy}) > length*5 {
+                        return false
+                }
+                norm := math.Hypot(float64(direction[0]), float64(direction[1]))
+                along := (float64(dx*direction[0]) + float64(dy*direction[1])) / norm
+                across := math.Abs(float64(dx*direction[1])-float64(dy*direction[0])) / norm
+                return along > 0 && across <= along/2+1e-9
+        }
+        return false
+}
+
+// inCubeSide reports whether an offset along one axis lies within a cube's side. The cube
+// extends away from the origin along the axes of its direction and is centred on the
+// origin along the others, so a cube without a direction is centred on its origin.
+func inCubeSide(step, length, offset int) bool {
+        switch {
+        case step > 0:
+                return offset >= 1 && offset <= length
+        case step < 0:
+                return offset <= -1 && offset >= -length
+        }
+        return offset >= -(length-1)/2 && offset <= length/2
+}
+
+// combatantsInArea returns every combatant, friend or foe, standing in one of the cells
+func (s *Service) combatantsInArea(combat *models.Combat, cells [][2]int) []*models.Combatant {
+        covered := make(map[[2]int]bool, len(cells))
+        for _, cell := range cells {
+                covered[cell] = true
+        }
+
+        var combatants []*models.Combatant
+        for i := range combat.Participants {
+                combatant := &combat.Participants[i]
+                if combatant.DeathSaves.Dead || (combatant.HP <= 0 && combatant.Type != "character") {
+                        continue
+                }
+                if covered[combatant.Position] {
+                        combatants = append(combatants, combatant)
+                }
+        }
+        return combatants
+}
+
+// addSpellZone marks the cells of a concentration spell's area on the battlefield until
+// the caster's concentration ends
+func addSpellZone(combat *models.Combat, actor *models.Combatant, spell *models.Spell, cells [][2]int) {
+        combat.Battlefield.Zones = append(combat.Battlefield.Zones, models.Zone{
+                SourceID:    actor.ID,
+                SourceSpell: spell.Index,
+                Cells:       cells,
+                Terrain:     spell.Terrain,
+        })
+}
+
+// terrainAt returns the terrain of a cell. Terrain created by spell zones covers the
+// battlefield's own terrain, which is "normal" on most cells.
+func terrainAt(battlefield models.Battlefield, pos [2]int) string {
+        for _, zone := range battlefield.Zones {
+                if zone.Terrain == "" {
+                        continue
+                }
+                for _, cell := range zone.Cells {
+                        if cell == pos {
+                                return zone.Terrain
+                        }
+                }
+        }
+        return battlefield.Terrain[fmt.Sprintf("%d,%d", pos[0], pos[1])]
+}
+
+// inBounds reports whether a cell lies on the battlefield. Battlefields without a size are unbounded.
+func inBounds(battlefield models.Battlefield, pos [2]int) bool {
+        if battlefield.Width <= 0 || battlefield.Height <= 0 {
+                return true
+        }
+        return pos[0] >= 0 && pos[0] < battlefield.Width && pos[1] >= 0 && pos[1] < battlefield.Height
+}
@@ -0,0 +1,160 @@
+package combat
+
+import (
+        "errors"
+        "log"
+
+        "dnd-combat/internal/models"
+)
+
+// maxMonsterSteps limits the actions a monster brain can take on one turn
+const maxMonsterSteps = 10
+
+// MonsterStep is one step of an automated monster turn: an action the monster took, or
+// the end of its turn with the events that started the next one
+type MonsterStep struct {
+        Combat     *models.Combat         `json:"combat"`
+        Action     *models.CombatAction   `json:"action,omitempty"`
+        Result     *models.ActionResult   `json:"result,omitempty"`
+        TurnEvents []*models.CombatAction `json:"turn_events,omitempty"`
+}
+
+// isAutomated reports whether a combatant's turns are played by the monster brain
+func isAutomated(combat *models.Combat, combatant *models.Combatant) bool {
+        return combatant.Type == "monster" && (combat.AutoMonsters || combatant.AutoPlay)
+}
+
+// IsAutomatedTurn reports whether the current turn belongs to an automated monster
+func (s *Service) IsAutomatedTurn(combat *models.Combat) bool {
+        item := currentInitiative(combat)
+        if item == nil || combat.Status != "active" {
+                return false
+        }
+        actor := s.getCombatant(combat, item.ID)
+        return actor != nil && isAutomated(combat, actor)
+}
+
+// IsPlayingMonsterTurns reports whether automated monster turns are being played in a combat
+func (s *Service) IsPlayingMonsterTurns(combatID string) bool {
+        s.autoPlay.mu.Lock()
+        defer s.autoPlay.mu.Unlock()
+        return s.autoPlay.combats[combatID]
+}
+
+// SetAutomation turns automated monster turns on or off for the whole combat when
+// autoMonsters is given, and for the individual monsters in monsters
+func (s *Service) SetAutomation(combat *models.Combat, autoMonsters *bool, monsters map[string]bool) error {
+        for id := range monsters {
+                combatant := s.getCombatant(combat, id)
+                if combatant == nil {
+                        return errors.New("combatant not found in combat")
+                }
+                if combatant.Type != "monster" {
+                        return errors.New("only monsters can be played automatically")
+                }
+        }
+
+        if autoMonsters != nil {
+                combat.AutoMonsters = *autoMonsters
+        }
+        for id, autoPlay := range monsters {
+                s.getCombatant(combat, id).AutoPlay = autoPlay
+        }
+
+        return s.repo.Update(combat)
+}
+
+// PlayMonsterTurns plays the turns of automated monsters, starting with the current turn,
+// until it is the turn of a combatant the players or DM control or the combat ends.
+// onStep is called after every action and every turn change. Only one caller plays a
+// combat's monster turns at a time; other calls return at once.
+func (s *Service) PlayMonsterTurns(combatID string, onStep func(step MonsterStep)) error {
+        s.autoPlay.mu.Lock()
+        if s.autoPlay.combats[combatID] {
+                s.autoPlay.mu.Unlock()
+                return nil
+        }
+        s.autoPlay.combats[combatID] = true
+        s.autoPlay.mu.Unlock()
+
+        defer func() {
+                s.autoPlay.mu.Lock()
+                delete(s.autoPlay.combats, combatID)
+                s.autoPlay.mu.Unlock()
+        }()
+
+        // Every step is taken under the combat's lock on the combat as it was last saved, and
+        // the lock is given up while onStep shows it to the players
+        played := &monsterTurn{}
+        for {
+                step, err := s.nextMonsterStep(combatID, played)
+                if err != nil || step == nil {
+                        return err
+                }
+                onStep(*step)
+        }
+}
+
+// monsterTurn counts the actions taken on the automated monster turn being played
+type monsterTurn struct {
+        round   int
+        index   int
+        actions int
+}
+
+// nextMonsterStep loads a combat under its lock and takes the next step of the automated
+// monster whose turn it is: the action its brain chooses, or the end of its turn once it has
+// nothing left to do. It returns nil when it isn't an automated monster's turn.
+func (s *Service) nextMonsterStep(combatID string, played *monsterTurn) (*MonsterStep, error) {
+        // A background player can wait out a reaction prompt
+        if err := s.locks.lock(combatID, true); err != nil {
+                return nil, err
+        }
+        defer s.locks.unlock(combatID)
+
+        combat, err := s.repo.GetByID(combatID)
+        if err != nil {
+                return nil, err
+        }
+        if combat == nil {
+                return nil, errors.New("combat not found")
+        }
+        if !s.IsAutomatedTurn(combat) {
+                return nil, nil
+        }
+        actor := s.getCombatant(combat, currentInitiative(combat).ID)
+
+        if played.round != combat.RoundNumber || played.index != combat.CurrentTurnIndex {
+                *played = monsterTurn{round: combat.RoundNumber, index: combat.CurrentTurnIndex}
+        }
+        if played.actions < maxMonsterSteps {
+                if action := s.brain.NextAction(combat, actor); action != nil {
+                        played.actions++
+                        action.CombatID = combat.ID
+                        action.ActorID = actor.ID
+                        result, err := s.ExecuteAction(combat, action)
+                        if err == nil {
+                                return &MonsterStep{Combat: combat, Action: action, Result: result}, nil
+                        }
+
+                        // The brain chose something the rules don't allow, so the monster's turn
+                        // ends. The failed action may have changed the combat before it failed,
+                        // so the turn ends on the combat as it was saved.
+                        log.Printf("Monster %s in combat %s tried a %s %q that was rejected: %v",
+                                actor.ID, combat.ID, action.Type, action.WeaponName, err)
+                        combat, err = s.repo.GetByID(combatID)
+                        if err != nil {
+                                return nil, err
+                        }
+                        if combat == nil {
+                                return nil, errors.New("combat not found")
+                        }
+                }
+        }
+
+        events, err := s.EndTurn(combat)
+        if err != nil {
+                return nil, err
+        }
+        return &MonsterStep{Combat: combat, TurnEvents: events}, nil
+}
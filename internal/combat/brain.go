@@ -0,0 +1,401 @@
+package combat
+
+import (
+        "dnd-combat/internal/models"
+)
+
+// MonsterBrain chooses the actions of monsters whose turns are played automatically
+type MonsterBrain interface {
+        // NextAction returns the next action the monster takes on its turn, or nil to end
+        // the turn. The combat must not be modified.
+        NextAction(combat *models.Combat, actor *models.Combatant) *models.CombatAction
+}
+
+// Limits of the default monster brain
+const (
+        badlyHurtDivisor = 4  // Monsters at or below a quarter of their HP dodge
+        saveActionReach  = 30 // Feet within which saving throw actions without an area are aimed
+)
+
+// compassDirections are the directions the brain tries to aim cones and lines in, in order
+var compassDirections = []string{"n", "ne", "e", "se", "s", "sw", "w", "nw"}
+
+// HeuristicBrain is the default MonsterBrain. A monster closes in on the nearest visible
+// enemy, attacks the weakest enemy in reach with its most damaging action, and takes the
+// Dodge action when badly hurt.
+type HeuristicBrain struct{}
+
+// NewHeuristicBrain creates the default monster brain
+func NewHeuristicBrain() *HeuristicBrain {
+        return &HeuristicBrain{}
+}
+
+// monsterAttackOption is an action the brain could attack with, with its expected damage
+type monsterAttackOption struct {
+        action *models.MonsterAction
+        reach  int
+        damage float64
+}
+
+// NextAction moves the monster until its best attack reaches an enemy and then attacks,
+// ending the turn once the monster has used its action or can't do anything useful
+func (b *HeuristicBrain) NextAction(combat *models.Combat, actor *models.Combatant) *models.CombatAction {
+        // The lair's turn and monsters that can't act end at once
+        if item := currentInitiative(combat); item == nil || item.Lair {
+                return nil
+        }
+        monster, ok := actor.Stats.(*models.Monster)
+        if !ok || actor.HP <= 0 || isIncapacitated(actor) {
+                return nil
+        }
+
+        enemies := visibleEnemies(combat, actor)
+        if len(enemies) == 0 {
+                return nil
+        }
+
+        if actor.Economy.Actions <= 0 {
+                return nil
+        }
+
+        if actor.HP*badlyHurtDivisor <= hitPointMaximum(actor) {
+                return &models.CombatAction{Type: "dodge"}
+        }
+
+        options := attackOptions(actor, monster)
+        if len(options) == 0 {
+                return nil
+        }
+
+        // Close in on the nearest enemy until the most damaging attack can reach someone
+        best := options[0]
+        for _, option := range options[1:] {
+                if option.damage > best.damage {
+                        best = option
+                }
+        }
+        if !enemyInReach(actor, enemies, best.reach) && !hasCondition(actor, "frightened") {
+                if path := pathTowards(combat, actor, nearestEnemy(actor, enemies).Position, best.reach); len(path) > 0 {
+                        return &models.CombatAction{Type: "move", MovementPath: path}
+                }
+        }
+
+        return chooseAttack(combat, actor, options, enemies)
+}
+
+// enemyInReach reports whether any enemy is within reach feet of the actor
+func enemyInReach(actor *models.Combatant, enemies []*models.Combatant, reach int) bool {
+        for _, enemy := range enemies {
+                if distanceFeet(actor.Position, enemy.Position) <= reach {
+                        return true
+                }
+        }
+        return false
+}
+
+// visibleEnemies returns the conscious combatants on the other side that aren't invisible
+func visibleEnemies(combat *models.Combat, actor *models.Combatant) []*models.Combatant {
+        var enemies []*models.Combatant
+        for i := range combat.Participants {
+                other := &combat.Participants[i]
+                if other.Type == actor.Type || other.HP <= 0 || hasCondition(other, "invisible") {
+                        continue
+                }
+                enemies = append(enemies, other)
+        }
+        return enemies
+}
+
+// nearestEnemy returns the closest enemy, preferring the weakest of equally close ones
+func nearestEnemy(actor *models.Combatant, enemies []*models.Combatant) *models.Combatant {
+        nearest := enemies[0]
+        for _, enemy := range enemies[1:] {
+                distance := distanceFeet(actor.Position, enemy.Position)
+                nearestDistance := distanceFeet(actor.Position, nearest.Position)
+                if distance < nearestDistance || (distance == nearestDistance && enemy.HP < nearest.HP) {
+                        nearest = enemy
+                }
+        }
+        return nearest
+}
+
+// attackOptions lists the actions a monster can attack with right now
+func attackOptions(actor *models.Combatant, monster *models.Monster) []monsterAttackOption {
+        var options []monsterAttackOption
+        for i := range monster.Actions {
+                monsterAction := &monster.Actions[i]
+                if usesLeft(actor, monsterAction) <= 0 {
+                        continue
+                }
+
+                switch {
+                case len(monsterAction.Multiattack) > 0:
+                        option := monsterAttackOption{action: monsterAction}
+                        for _, name := range expandMultiattack(monsterAction) {
+                                attack := findMonsterAction(actor, "attack", name)
+                                if attack == nil {
+                                        option.damage = 0
+                                        break
+                                }
+                                if reach := monsterActionReach(attack); option.reach == 0 || reach < option.reach {
+                                        option.reach = reach
+                                }
+                                option.damage += averageDamage(monsterDamageDice(attack))
+                        }
+                        if option.damage > 0 {
+                                options = append(options, option)
+                        }
+
+                case isSaveAction(monsterAction):
+                        if damage := averageDamage(monsterDamageDice(monsterAction)); damage > 0 {
+                                options = append(options, monsterAttackOption{action: monsterAction, reach: saveActionRange(monsterAction), damage: damage})
+                        }
+
+                case isAttackAction(monsterAction):
+                        options = append(options, monsterAttackOption{
+                                action: monsterAction,
+                                reach:  monsterActionReach(monsterAction),
+                                damage: averageDamage(monsterDamageDice(monsterAction)),
+                        })
+                }
+        }
+        return options
+}
+
+// chooseAttack picks the option that deals the most damage to enemies in reach. Attacks go
+// to the weakest enemy in reach. Saving throw actions with an area are aimed to catch as
+// many more enemies than allies as they can; those without one catch every enemy in reach.
+func chooseAttack(combat *models.Combat, actor *models.Combatant, options []monsterAttackOption, enemies []*models.Combatant) *models.CombatAction {
+        var best *models.CombatAction
+        bestDamage := 0.0
+        for _, option := range options {
+                if isSaveAction(option.action) && option.action.Area != nil {
+                        area, hits := aimArea(combat, actor, option.action, enemies)
+                        if damage := option.damage * float64(hits); area != nil && damage > bestDamage {
+                                bestDamage = damage
+                                best = &models.CombatAction{
+                                        Type:       "attack",
+                                        WeaponName: option.action.Name,
+                                        Area:       area,
+                                }
+                        }
+                        continue
+                }
+
+                var targets []*models.Combatant
+                for _, enemy := range enemies {
+                        if distanceFeet(actor.Position, enemy.Position) <= option.reach && !charmedBy(actor, enemy) {
+                                targets = append(targets, enemy)
+                        }
+                }
+                if len(targets) == 0 {
+                        continue
+                }
+
+                damage := option.damage
+                targetIDs := []string{weakestEnemy(targets).ID}
+                if isSaveAction(option.action) {
+                        damage *= float64(len(targets))
+                        targetIDs = targetIDs[:0]
+                        for _, target := range targets {
+                                targetIDs = append(targetIDs, target.ID)
+                        }
+                }
+
+                if damage > bestDamage {
+                        bestDamage = damage
+                        best = &models.CombatAction{
+                                Type:       "attack",
+                                WeaponName: option.action.Name,
+                                TargetIDs:  targetIDs,
+                        }
+                }
+        }
+        return best
+}
+
+// saveActionRange returns how far away a saving throw action can catch an enemy: the range
+// its area can be placed within, or the length of an area that starts at the monster
+func saveActionRange(monsterAction *models.MonsterAction) int {
+        switch {
+        case monsterAction.Area == nil:
+                return saveActionReach
+        case monsterAction.AreaRange > 0:
+                return monsterAction.AreaRange
+        }
+        return monsterAction.Area.Size
+}
+
+// aimArea places the area of a saving throw action where it catches the most enemies, less
+// the allies it catches. Areas that start at the monster are turned in each direction and
+// the others are centred on each enemy within range. It returns nil when no placement is
+// worth it, and otherwise the area and how many more enemies than allies it catches.
+func aimArea(combat *models.Combat, actor *models.Combatant, monsterAction *models.MonsterAction, enemies []*models.Combatant) (*models.AreaTemplate, int) {
+        aoe := monsterAction.Area
+        var placements []models.AreaTemplate
+        switch {
+        case monsterAction.AreaRange > 0:
+                for _, enemy := range enemies {
+                        if distanceFeet(actor.Position, enemy.Position) <= monsterAction.AreaRange {
+                                placements = append(placements, models.AreaTemplate{Shape: aoe.Type, Size: aoe.Size, Origin: enemy.Position})
+                        }
+                }
+        case aoe.Type == "cone" || aoe.Type == "line" || aoe.Type == "cube":
+                for _, direction := range compassDirections {
+                        placements = append(placements, models.AreaTemplate{Shape: aoe.Type, Size: aoe.Size, Origin: actor.Position, Direction: direction})
+                }
+        default:
+                placements = append(placements, models.AreaTemplate{Shape: aoe.Type, Size: aoe.Size, Origin: actor.Position})
+        }
+
+        var best *models.AreaTemplate
+        bestHits := 0
+        for i := range placements {
+                if hits := areaHits(combat, actor, &placements[i], enemies); hits > bestHits {
+                        best, bestHits = &placements[i], hits
+                }
+        }
+        return best, bestHits
+}
+
+// areaHits counts the enemies an area catches, less the allies it catches. Enemies who
+// charmed the monster don't count, since it can't target them.
+func areaHits(combat *models.Combat, actor *models.Combatant, area *models.AreaTemplate, enemies []*models.Combatant) int {
+        covered := make(map[[2]int]bool)
+        for _, cell := range areaCells(combat.Battlefield, area) {
+                covered[cell] = true
+        }
+
+        hits := 0
+        for _, enemy := range enemies {
+                if covered[enemy.Position] && !charmedBy(actor, enemy) {
+                        hits++
+                }
+        }
+        for i := range combat.Participants {
+                ally := &combat.Participants[i]
+                if ally.ID != actor.ID && ally.Type == actor.Type && ally.HP > 0 && covered[ally.Position] {
+                        hits--
+                }
+        }
+        return hits
+}
+
+// weakestEnemy returns the enemy with the fewest hit points
+func weakestEnemy(enemies []*models.Combatant) *models.Combatant {
+        weakest := enemies[0]
+        for _, enemy := range enemies[1:] {
+                if enemy.HP < weakest.HP {
+                        weakest = enemy
+                }
+        }
+        return weakest
+}
+
+// charmedBy reports whether a combatant is charmed by another and so can't attack them
+func charmedBy(actor, other *models.Combatant) bool {
+        for _, condition := range actor.Conditions {
+                if condition.Name == "charmed" && condition.SourceID == other.ID {
+                        return true
+                }
+        }
+        return false
+}
+
+// monsterActionReach returns how far away in feet a monster action can hit without disadvantage
+func monsterActionReach(monsterAction *models.MonsterAction) int {
+        switch {
+        case monsterAction.Range > monsterAction.Reach:
+                return monsterAction.Range
+        case monsterAction.Reach > 0:
+                return monsterAction.Reach
+        }
+        return 5
+}
+
+// averageDamage returns the average total of damage dice
+func averageDamage(dice []models.DamageInfo) float64 {
+        total := 0.0
+        for _, info := range dice {
+                total += float64(info.DiceCount)*float64(info.DiceValue+1)/2 + float64(info.Bonus)
+        }
+        return total
+}
+
+// pathTowards finds the cheapest path, one orthogonal step at a time, to a cell within
+// reach of a goal, or as close to it as the battlefield allows. The path is cut short
+// where the actor runs out of movement.
+func pathTowards(combat *models.Combat, actor *models.Combatant, goal [2]int, reach int) [][2]int {
+        battlefield := combat.Battlefield
+        if battlefield.Width <= 0 || battlefield.Height <= 0 {
+                return nil
+        }
+
+        occupied := make(map[[2]int]bool, len(combat.Participants))
+        for _, other := range combat.Participants {
+                if other.ID != actor.ID {
+                        occupied[other.Position] = true
+                }
+        }
+
+        // Cheapest cost to reach each cell, exploring in order of cost
+        start := actor.Position
+        costs := map[[2]int]int{start: 0}
+        previous := make(map[[2]int][2]int)
+        frontier := map[int][][2]int{0: {start}}
+        for cost := 0; len(frontier) > 0; cost += 5 {
+                for _, cell := range frontier[cost] {
+                        if costs[cell] != cost {
+                                continue
+                        }
+                        for _, step := range [][2]int{{0, -1}, {1, 0}, {0, 1}, {-1, 0}} {
+                                next := [2]int{cell[0] + step[0], cell[1] + step[1]}
+                                if !inBounds(battlefield, next) || blocksMovement(battlefield, next) || occupied[next] {
+                                        continue
+                                }
+
+                                nextCost := cost + 5
+                                if terrainAt(battlefield, next) == "difficult" {
+                                        nextCost += 5
+                                }
+                                if known, ok := costs[next]; ok && known <= nextCost {
+                                        continue
+                                }
+                                costs[next] = nextCost
+                                previous[next] = cell
+                                frontier[nextCost] = append(frontier[nextCost], next)
+                        }
+                }
+                delete(frontier, cost)
+        }
+
+        // The cheapest cell within reach, or failing that the closest one to the goal
+        destination := start
+        for cell, cost := range costs {
+                distance, best := distanceFeet(cell, goal), distanceFeet(destination, goal)
+                inReach, bestInReach := distance <= reach, best <= reach
+                switch {
+                case inReach && !bestInReach,
+                        inReach && cost < costs[destination],
+                        !inReach && !bestInReach && (distance < best || (distance == best && cost < costs[destination])):
+                        destination = cell
+                }
+        }
+        if destination == start {
+                return nil
+        }
+
+        var path [][2]int
+        for cell := destination; cell != start; cell = previous[cell] {
+                path = append([][2]int{cell}, path...)
+        }
+
+        // Walk as far along the path as the remaining movement allows
+        budget := actor.Economy.MovementLeft - standUpCost(actor)
+        for i, cell := range path {
+                if costs[cell] > budget {
+                        return path[:i]
+                }
+        }
+        return path
+}
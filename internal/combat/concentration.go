@@ -0,0 +1,132 @@
+package combat
+
+import (
+        "fmt"
+
+        "dnd-combat/internal/models"
+)
+
+// startConcentration makes the caster concentrate on a spell they have just cast. Any spell
+// they were concentrating on has already been stopped by stopConcentration.
+func startConcentration(caster *models.Combatant, spell *models.Spell, duration int) {
+        caster.Concentration = &models.Concentration{
+                SpellID:   spell.Index,
+                SpellName: spell.Name,
+                Duration:  duration,
+        }
+}
+
+// stopConcentration ends the concentration of a caster who casts another concentration spell,
+// before the new spell's effects are applied so the two can't be mixed up. It returns a
+// description of the concentration that ended, if any, and a function that brings it back
+// with its conditions and zones if the new spell fails.
+func (s *Service) stopConcentration(combat *models.Combat, caster *models.Combatant) (string, func()) {
+        concentration := caster.Concentration
+        if concentration == nil {
+                return "", func() {}
+        }
+
+        conditions := make([][]models.Condition, len(combat.Participants))
+        for i := range combat.Participants {
+                conditions[i] = append([]models.Condition(nil), combat.Participants[i].Conditions...)
+        }
+        zones := append([]models.Zone(nil), combat.Battlefield.Zones...)
+
+        description := s.endConcentration(combat, caster,
+                fmt.Sprintf("%s stops concentrating on %s.", caster.Name, concentration.SpellName))
+        return description, func() {
+                caster.Concentration = concentration
+                for i := range combat.Participants {
+                        combat.Participants[i].Conditions = conditions[i]
+                }
+                combat.Battlefield.Zones = zones
+        }
+}
+
+// endConcentration ends the caster's concentration and removes every condition and zone
+// tied to the spell from the battlefield. It returns the given reason followed by a
+// description of the effects that ended.
+func (s *Service) endConcentration(combat *models.Combat, caster *models.Combatant, reason string) string {
+        concentration := caster.Concentration
+        if concentration == nil {
+                return ""
+        }
+        caster.Concentration = nil
+
+        description := reason
+        for i := range combat.Participants {
+                participant := &combat.Participants[i]
+                remaining := make([]models.Condition, 0, len(participant.Conditions))
+                for _, condition := range participant.Conditions {
+                        if condition.SourceID == caster.ID && condition.SourceSpell == concentration.SpellID {
+                                description += fmt.Sprintf(" %s is no longer %s.", participant.Name, condition.Name)
+                                continue
+                        }
+                        remaining = append(remaining, condition)
+                }
+                participant.Conditions = remaining
+        }
+
+        zones := combat.Battlefield.Zones[:0]
+        for _, zone := range combat.Battlefield.Zones {
+                if zone.SourceID == caster.ID && zone.SourceSpell == concentration.SpellID {
+                        description += fmt.Sprintf(" The area of %s vanishes.", concentration.SpellName)
+                        continue
+                }
+                zones = append(zones, zone)
+        }
+        combat.Battlefield.Zones = zones
+
+        return description
+}
+
+// checkConcentration makes a concentrating combatant who took damage roll a Constitution
+// saving throw with a DC of 10 or half the damage, whichever is higher. Concentration also
+// ends when the combatant is incapacitated or drops to 0 HP.
+func (s *Service) checkConcentration(combat *models.Combat, target *models.Combatant, damage int) string {
+        if target.Concentration == nil || damage <= 0 {
+                return ""
+        }
+
+        spellName := target.Concentration.SpellName
+        if target.HP <= 0 || isIncapacitated(target) {
+                return s.endConcentration(combat, target,
+                        fmt.Sprintf("%s loses concentration on %s.", target.Name, spellName))
+        }
+
+        dc := damage / 2
+        if dc < 10 {
+                dc = 10
+        }
+
+        saved, description := s.rollSavingThrow(target, "con", dc)
+        if saved {
+                return fmt.Sprintf("%s to maintain concentration on %s.", description, spellName)
+        }
+        return s.endConcentration(combat, target,
+                fmt.Sprintf("%s and loses concentration on %s.", description, spellName))
+}
+
+// tickConcentration counts down the duration of the spell the combatant is concentrating on
+// at the start of their turn. Incapacitated combatants can't keep concentrating.
+func (s *Service) tickConcentration(combat *models.Combat, combatant *models.Combatant) string {
+        if combatant.Concentration == nil {
+                return ""
+        }
+
+        if isIncapacitated(combatant) {
+                return s.endConcentration(combat, combatant,
+                        fmt.Sprintf("%s is incapacitated and loses concentration on %s.", combatant.Name, combatant.Concentration.SpellName))
+        }
+
+        if combatant.Concentration.Duration <= 0 {
+                return ""
+        }
+
+        combatant.Concentration.Duration--
+        if combatant.Concentration.Duration > 0 {
+                return ""
+        }
+        return s.endConcentration(combat, combatant,
+                fmt.Sprintf("%s's %s ends.", combatant.Name, combatant.Concentration.SpellName))
+}
@@ -0,0 +1,246 @@
+package combat
+
+import (
+        "fmt"
+        "strings"
+
+        "dnd-combat/internal/models"
+        "dnd-combat/pkg/dnd5e"
+)
+
+// srdConditions are the conditions defined by the SRD
+var srdConditions = map[string]bool{
+        "blinded":       true,
+        "charmed":       true,
+        "deafened":      true,
+        "exhaustion":    true,
+        "frightened":    true,
+        "grappled":      true,
+        "incapacitated": true,
+        "invisible":     true,
+        "paralyzed":     true,
+        "petrified":     true,
+        "poisoned":      true,
+        "prone":         true,
+        "restrained":    true,
+        "stunned":       true,
+        "unconscious":   true,
+}
+
+// impliedConditions lists the conditions that include other conditions
+var impliedConditions = map[string][]string{
+        "paralyzed":   {"incapacitated"},
+        "petrified":   {"incapacitated"},
+        "stunned":     {"incapacitated"},
+        "unconscious": {"incapacitated", "prone"},
+}
+
+// hasCondition checks if a combatant is affected by a condition, directly or
+// through a condition that includes it
+func hasCondition(combatant *models.Combatant, name string) bool {
+        for _, condition := range combatant.Conditions {
+                if condition.Name == name {
+                        return true
+                }
+                for _, implied := range impliedConditions[condition.Name] {
+                        if implied == name {
+                                return true
+                        }
+                }
+        }
+        return false
+}
+
+// isIncapacitated checks if a combatant can't take actions or reactions
+func isIncapacitated(combatant *models.Combatant) bool {
+        return hasCondition(combatant, "incapacitated")
+}
+
+// exhaustionLevel returns a combatant's level of exhaustion
+func exhaustionLevel(combatant *models.Combatant) int {
+        if condition := combatant.GetCondition("exhaustion"); condition != nil {
+                return condition.Level
+        }
+        return 0
+}
+
+// effectiveSpeed applies conditions and exhaustion to a combatant's base speed
+func effectiveSpeed(combatant *models.Combatant, speed int) int {
+        for _, name := range []string{"grappled", "restrained", "paralyzed", "petrified", "stunned", "unconscious"} {
+                if hasCondition(combatant, name) {
+                        return 0
+                }
+        }
+
+        level := exhaustionLevel(combatant)
+        if level >= 5 {
+                return 0
+        }
+        if level >= 2 {
+                return speed / 2
+        }
+        return speed
+}
+
+// armorClass returns a combatant's AC including bonuses from conditions such as Shield
+func armorClass(combatant *models.Combatant) int {
+        ac := combatant.AC
+        for _, condition := range combatant.Conditions {
+                ac += condition.ACBonus
+        }
+        return ac
+}
+
+// immuneToCondition reports whether a monster's stat block makes it immune to a condition
+func immuneToCondition(combatant *models.Combatant, name string) bool {
+        monster, ok := combatant.Stats.(*models.Monster)
+        if !ok {
+                return false
+        }
+        for _, immunity := range monster.ConditionImmunities {
+                if strings.EqualFold(immunity, name) {
+                        return true
+                }
+        }
+        return false
+}
+
+// addCondition applies a condition to a combatant and returns a description of the change
+func (s *Service) addCondition(target *models.Combatant, condition models.Condition) string {
+        if immuneToCondition(target, condition.Name) {
+                return fmt.Sprintf("%s is immune to being %s", target.Name, condition.Name)
+        }
+
+        // Exhaustion stacks in levels instead of being applied twice
+        if condition.Name == "exhaustion" {
+                levels := condition.Level
+                if levels <= 0 {
+                        levels = 1
+                }
+
+                existing := target.GetCondition("exhaustion")
+                if existing == nil {
+                        condition.Level = levels
+                        target.Conditions = append(target.Conditions, condition)
+                        existing = &target.Conditions[len(target.Conditions)-1]
+                } else {
+                        existing.Level += levels
+                }
+
+                if existing.Level >= 6 {
+                        existing.Level = 6
+                        if target.Type == "character" {
+                                killCombatant(target)
+                        } else {
+                                target.HP = 0
+                        }
+                        return fmt.Sprintf("%s reaches exhaustion level 6 and dies!", target.Name)
+                }
+                return fmt.Sprintf("%s is now at exhaustion level %d", target.Name, existing.Level)
+        }
+
+        // Reapplying a condition from the same source refreshes it
+        for i, existing := range target.Conditions {
+                if existing.Name == condition.Name && existing.SourceID == condition.SourceID {
+                        target.Conditions[i] = condition
+                        return fmt.Sprintf("%s is %s", target.Name, condition.Name)
+                }
+        }
+
+        target.Conditions = append(target.Conditions, condition)
+        return fmt.Sprintf("%s is %s", target.Name, condition.Name)
+}
+
+// processConditions ticks durations and rolls saves for the conditions that are
+// processed at the start (or end) of the combatant's own turn
+func (s *Service) processConditions(combatant *models.Combatant, startOfTurn bool) []string {
+        var descriptions []string
+        remaining := make([]models.Condition, 0, len(combatant.Conditions))
+
+        for _, condition := range combatant.Conditions {
+                due := (startOfTurn && condition.StartOfTurn) || (!startOfTurn && condition.EndOfTurn)
+                if !due || (condition.Duration <= 0 && condition.SaveDC <= 0) {
+                        remaining = append(remaining, condition)
+                        continue
+                }
+
+                rulesCondition := &dnd5e.Condition{
+                        Name:        condition.Name,
+                        Duration:    condition.Duration,
+                        SaveDC:      condition.SaveDC,
+                        SaveType:    abilityNames[normalizeAbility(condition.SaveAbility)],
+                        EndOfTurn:   condition.EndOfTurn,
+                        StartOfTurn: condition.StartOfTurn,
+                }
+
+                s.diceRoller.Label(condition.Name+" saving throw", combatant.ID)
+                ended, description := s.combatRules.ProcessCondition(rulesCondition, combatant.Name,
+                        s.saveModifiers(combatant, condition.SaveAbility))
+
+                // Markers like Dodge expire silently; report SRD conditions and saves
+                if srdConditions[condition.Name] || condition.SaveDC > 0 {
+                        descriptions = append(descriptions, description)
+                }
+
+                if ended {
+                        continue
+                }
+
+                condition.Duration = rulesCondition.Duration
+                remaining = append(remaining, condition)
+        }
+
+        combatant.Conditions = remaining
+        return descriptions
+}
+
+// attackConditionEffects adds the advantage and disadvantage an attack gets from the
+// attacker's and target's conditions and reports whether a hit is automatically critical
+func attackConditionEffects(mode *rollMode, attacker, target *models.Combatant, distance int) bool {
+        // Attacker's conditions
+        for _, name := range []string{"blinded", "frightened", "poisoned", "prone", "restrained"} {
+                if hasCondition(attacker, name) {
+                        mode.addDisadvantage("%s is %s", attacker.Name, name)
+                }
+        }
+        if exhaustionLevel(attacker) >= 3 {
+                mode.addDisadvantage("%s is exhausted", attacker.Name)
+        }
+        if hasCondition(attacker, "invisible") {
+                mode.addAdvantage("%s is invisible", attacker.Name)
+        }
+
+        // Target's conditions
+        for _, name := range []string{"blinded", "paralyzed", "petrified", "restrained", "stunned", "unconscious"} {
+                if hasCondition(target, name) {
+                        mode.addAdvantage("%s is %s", target.Name, name)
+                }
+        }
+        if hasCondition(target, "invisible") {
+                mode.addDisadvantage("%s is invisible", target.Name)
+        }
+        if hasCondition(target, "prone") && !hasCondition(target, "unconscious") {
+                if distance <= 5 {
+                        mode.addAdvantage("%s is prone", target.Name)
+                } else {
+                        mode.addDisadvantage("%s is prone and out of reach", target.Name)
+                }
+        }
+
+        // Hits from within 5 feet against paralyzed or unconscious targets are critical
+        return distance <= 5 && (hasCondition(target, "paralyzed") || hasCondition(target, "unconscious"))
+}
+
+// saveModifiers works out how a combatant rolls a saving throw given their conditions and
+// saving throw proficiencies
+func (s *Service) saveModifiers(combatant *models.Combatant, ability string) dnd5e.SaveModifiers {
+        mode, autoFail := saveRollMode(combatant, ability)
+        advantage, disadvantage := mode.resolve()
+
+        return dnd5e.SaveModifiers{
+                AbilityMod:      savingThrowBonus(combatant, ability),
+                HasAdvantage:    advantage,
+                HasDisadvantage: disadvantage,
+                AutoFail:        autoFail,
+        }
+}
@@ -0,0 +1,295 @@
+package combat
+
+import (
+        "fmt"
+        "strings"
+
+        "dnd-combat/internal/models"
+)
+
+// applyDamage deals typed damage to a combatant, adjusting each part for the target's
+// immunities, resistances and vulnerabilities. Temporary hit points absorb the damage
+// first, and a concentrating target rolls to keep concentrating on their spell. It returns
+// the damage dealt and a description of the target's resulting state.
+func (s *Service) applyDamage(combat *models.Combat, target *models.Combatant, isCritical bool, parts ...models.TypedDamage) (int, string) {
+        damage := 0
+        var notes []string
+        for _, part := range parts {
+                amount, note := adjustDamage(target, part)
+                damage += amount
+                if note != "" {
+                        notes = append(notes, note)
+                }
+        }
+
+        // Temporary hit points are lost first
+        remaining := damage
+        if absorbed := min(target.TempHP, damage); absorbed > 0 {
+                target.TempHP -= absorbed
+                remaining -= absorbed
+                notes = append(notes, fmt.Sprintf("%s's temporary hit points absorb %d damage.", target.Name, absorbed))
+        }
+
+        description := s.takeDamage(target, remaining, isCritical)
+        if len(notes) > 0 {
+                description = strings.Join(notes, " ") + " " + description
+        }
+        if concentration := s.checkConcentration(combat, target, damage); concentration != "" {
+                description += " " + concentration
+        }
+        return damage, description
+}
+
+// adjustDamage applies the target's immunity, resistance and vulnerability to one type of
+// damage and returns the adjusted amount with a note when it changed
+func adjustDamage(target *models.Combatant, part models.TypedDamage) (int, string) {
+        if part.Amount <= 0 {
+                return 0, ""
+        }
+
+        var vulnerabilities, resistances, immunities []string
+        if monster, ok := target.Stats.(*models.Monster); ok {
+                vulnerabilities = monster.DamageVulnerabilities
+                resistances = monster.DamageResistances
+                immunities = monster.DamageImmunities
+        }
+
+        // Petrified creatures have resistance to all damage
+        if hasCondition(target, "petrified") {
+                resistances = append([]string{"all"}, resistances...)
+        }
+
+        // Raging barbarians resist weapon damage
+        if isRaging(target) {
+                resistances = append([]string{"bludgeoning, piercing, and slashing"}, resistances...)
+        }
+
+        if damageTraitApplies(immunities, part) {
+                return 0, fmt.Sprintf("%s is immune to %s damage.", target.Name, damageTypeName(part.Type))
+        }
+
+        amount := part.Amount
+        var effects []string
+        if damageTraitApplies(resistances, part) {
+                amount /= 2
+                effects = append(effects, "resistant")
+        }
+        if damageTraitApplies(vulnerabilities, part) {
+                amount *= 2
+                effects = append(effects, "vulnerable")
+        }
+        if len(effects) == 0 {
+                return amount, ""
+        }
+
+        return amount, fmt.Sprintf("%s is %s to %s damage (%d becomes %d).",
+                target.Name, strings.Join(effects, " and "), damageTypeName(part.Type), part.Amount, amount)
+}
+
+// damageTraitApplies reports whether a list of SRD damage resistances, immunities or
+// vulnerabilities such as "fire" or "bludgeoning, piercing, and slashing from nonmagical
+// attacks" covers a type of damage
+func damageTraitApplies(traits []string, part models.TypedDamage) bool {
+        for _, trait := range traits {
+                trait = strings.ToLower(trait)
+                if trait == "all" {
+                        return true
+                }
+                if part.Type == "" || !strings.Contains(trait, strings.ToLower(part.Type)) {
+                        continue
+                }
+                if part.Magical && strings.Contains(trait, "nonmagical") {
+                        continue
+                }
+                return true
+        }
+        return false
+}
+
+// damageTypeName returns a damage type for descriptions
+func damageTypeName(damageType string) string {
+        if damageType == "" {
+                return "untyped"
+        }
+        return damageType
+}
+
+// rollDamage rolls each type of damage an attack deals, rolling the dice twice on a critical hit
+func (s *Service) rollDamage(dice []models.DamageInfo, isCritical bool) []models.TypedDamage {
+        parts := make([]models.TypedDamage, 0, len(dice))
+        for _, info := range dice {
+                amount := s.diceRoller.Roll(info.DiceCount, info.DiceValue) + info.Bonus
+                // Flat damage, like an unarmed strike's, rolls no dice to add the bonus to
+                if info.DiceCount > 0 && info.DiceValue > 0 {
+                        s.diceRoller.AddModifier(info.Bonus)
+                }
+                if isCritical {
+                        amount += s.diceRoller.Roll(info.DiceCount, info.DiceValue)
+                }
+                if amount < 0 {
+                        amount = 0
+                }
+                parts = append(parts, models.TypedDamage{Type: info.Type, Amount: amount})
+        }
+        return parts
+}
+
+// describeDamage lists typed damage like "7 piercing and 3 poison"
+func describeDamage(parts []models.TypedDamage) string {
+        descriptions := make([]string, 0, len(parts))
+        for _, part := range parts {
+                descriptions = append(descriptions, fmt.Sprintf("%d %s", part.Amount, part.Type))
+        }
+        return strings.Join(descriptions, " and ")
+}
+
+// totalDamage adds up typed damage
+func totalDamage(parts []models.TypedDamage) int {
+        total := 0
+        for _, part := range parts {
+                total += part.Amount
+        }
+        return total
+}
+
+// takeDamage reduces a combatant's HP, applying the 5e rules for dropping to and taking
+// damage at 0 HP
+func (s *Service) takeDamage(target *models.Combatant, damage int, isCritical bool) string {
+        if damage <= 0 {
+                return hpStatus(target)
+        }
+
+        // Monsters are dead at 0 HP
+        if target.Type != "character" {
+                target.HP -= damage
+                if target.HP <= 0 {
+                        target.HP = 0
+                        return fmt.Sprintf("%s is defeated!", target.Name)
+                }
+                return hpStatus(target)
+        }
+
+        if target.DeathSaves.Dead {
+                return fmt.Sprintf("%s is already dead.", target.Name)
+        }
+
+        // Damage taken while already at 0 HP
+        if target.HP == 0 {
+                if damage >= hitPointMaximum(target) {
+                        killCombatant(target)
+                        return fmt.Sprintf("%s is killed outright by massive damage!", target.Name)
+                }
+
+                // A stable creature that takes damage starts dying again
+                target.DeathSaves.Stable = false
+                failures := 1
+                if isCritical {
+                        failures = 2
+                }
+                return addDeathSaveFailures(target, failures)
+        }
+
+        remaining := damage - target.HP
+        target.HP -= damage
+        if target.HP > 0 {
+                return hpStatus(target)
+        }
+
+        target.HP = 0
+
+        // Damage left over after dropping to 0 that equals max HP kills instantly
+        if remaining >= hitPointMaximum(target) {
+                killCombatant(target)
+                return fmt.Sprintf("%s is killed outright by massive damage!", target.Name)
+        }
+
+        target.DeathSaves = models.DeathSaves{}
+        if !target.HasCondition("unconscious") {
+                target.Conditions = append(target.Conditions, models.Condition{Name: "unconscious"})
+        }
+        return fmt.Sprintf("%s falls unconscious and is dying!", target.Name)
+}
+
+// applyHealing restores HP up to the combatant's hit point maximum, bringing a dying
+// character back to consciousness. It returns the amount of HP actually restored.
+func (s *Service) applyHealing(target *models.Combatant, healing int) int {
+        if healing <= 0 || target.DeathSaves.Dead {
+                return 0
+        }
+
+        oldHP := target.HP
+        target.HP += healing
+        if maximum := hitPointMaximum(target); target.HP > maximum {
+                target.HP = maximum
+        }
+
+        // Remove unconscious condition if healed from 0 HP
+        if oldHP == 0 && target.HP > 0 {
+                reviveCombatant(target)
+        }
+
+        return target.HP - oldHP
+}
+
+// grantTempHP gives a combatant temporary hit points. Temporary hit points don't stack,
+// so the combatant keeps whichever amount is higher.
+func grantTempHP(target *models.Combatant, amount int) string {
+        if amount <= target.TempHP {
+                return fmt.Sprintf("%s keeps their %d temporary hit points.", target.Name, target.TempHP)
+        }
+        target.TempHP = amount
+        return fmt.Sprintf("%s gains %d temporary hit points.", target.Name, amount)
+}
+
+// reduceMaxHP lowers a combatant's hit point maximum until they finish a long rest.
+// A creature whose maximum drops to 0 dies.
+func reduceMaxHP(target *models.Combatant, amount int) string {
+        if amount <= 0 || target.DeathSaves.Dead {
+                return ""
+        }
+
+        target.MaxHPReduction += amount
+        if target.MaxHPReduction >= target.MaxHP {
+                target.MaxHPReduction = target.MaxHP
+                if target.Type == "character" {
+                        killCombatant(target)
+                } else {
+                        target.HP = 0
+                }
+                return fmt.Sprintf("%s's hit point maximum is reduced to 0 and they die!", target.Name)
+        }
+
+        if maximum := hitPointMaximum(target); target.HP > maximum {
+                target.HP = maximum
+        }
+        return fmt.Sprintf("%s's hit point maximum is reduced by %d to %d.", target.Name, amount, hitPointMaximum(target))
+}
+
+// hitPointMaximum returns a combatant's maximum HP after reductions
+func hitPointMaximum(combatant *models.Combatant) int {
+        return combatant.MaxHP - combatant.MaxHPReduction
+}
+
+// hpStatus describes a combatant's current hit points
+func hpStatus(combatant *models.Combatant) string {
+        if combatant.TempHP > 0 {
+                return fmt.Sprintf("(HP: %d/%d, %d temporary)", combatant.HP, hitPointMaximum(combatant), combatant.TempHP)
+        }
+        return fmt.Sprintf("(HP: %d/%d)", combatant.HP, hitPointMaximum(combatant))
+}
+
+// killCombatant marks a character as dead
+func killCombatant(target *models.Combatant) {
+        target.HP = 0
+        target.DeathSaves.Dead = true
+        target.DeathSaves.Stable = false
+        if !target.HasCondition("unconscious") {
+                target.Conditions = append(target.Conditions, models.Condition{Name: "unconscious"})
+        }
+}
+
+// reviveCombatant clears the dying state of a character who regained HP
+func reviveCombatant(target *models.Combatant) {
+        target.DeathSaves = models.DeathSaves{}
+        target.RemoveCondition("unconscious")
+}
@@ -0,0 +1,113 @@
+package combat
+
+import (
+        "errors"
+        "fmt"
+
+        "dnd-combat/internal/models"
+)
+
+// isDying checks if a character is at 0 HP and still making death saving throws
+func isDying(combatant *models.Combatant) bool {
+        return combatant.Type == "character" && combatant.HP <= 0 &&
+                !combatant.DeathSaves.Stable && !combatant.DeathSaves.Dead
+}
+
+// rollDeathSave makes the death saving throw a dying character rolls at the start of their turn
+func (s *Service) rollDeathSave(combatant *models.Combatant) string {
+        s.diceRoller.Label("death saving throw", combatant.ID)
+        roll := s.diceRoller.Roll(1, 20)
+
+        switch {
+        case roll == 20:
+                // A natural 20 brings the character back with 1 HP
+                combatant.HP = 1
+                reviveCombatant(combatant)
+                return fmt.Sprintf("%s rolls a natural 20 on their death saving throw and regains 1 hit point!", combatant.Name)
+        case roll == 1:
+                return fmt.Sprintf("%s rolls a natural 1 on their death saving throw. %s", combatant.Name,
+                        addDeathSaveFailures(combatant, 2))
+        case roll >= 10:
+                combatant.DeathSaves.Successes++
+                if combatant.DeathSaves.Successes >= 3 {
+                        stabilize(combatant)
+                        return fmt.Sprintf("%s succeeds on their death saving throw (rolled %d) and is now stable!", combatant.Name, roll)
+                }
+                return fmt.Sprintf("%s succeeds on their death saving throw (rolled %d). (Successes: %d, Failures: %d)",
+                        combatant.Name, roll, combatant.DeathSaves.Successes, combatant.DeathSaves.Failures)
+        default:
+                return fmt.Sprintf("%s fails their death saving throw (rolled %d). %s", combatant.Name, roll,
+                        addDeathSaveFailures(combatant, 1))
+        }
+}
+
+// addDeathSaveFailures records failed death saves, killing the character at three
+func addDeathSaveFailures(combatant *models.Combatant, failures int) string {
+        combatant.DeathSaves.Failures += failures
+        if combatant.DeathSaves.Failures >= 3 {
+                combatant.DeathSaves.Failures = 3
+                killCombatant(combatant)
+                return fmt.Sprintf("%s has failed three death saving throws and dies!", combatant.Name)
+        }
+
+        if failures > 1 {
+                return fmt.Sprintf("%s suffers %d death save failures! (Successes: %d, Failures: %d)", combatant.Name,
+                        failures, combatant.DeathSaves.Successes, combatant.DeathSaves.Failures)
+        }
+        return fmt.Sprintf("%s suffers a death save failure! (Successes: %d, Failures: %d)", combatant.Name,
+                combatant.DeathSaves.Successes, combatant.DeathSaves.Failures)
+}
+
+// stabilize makes a dying character stable at 0 HP
+func stabilize(combatant *models.Combatant) {
+        combatant.DeathSaves.Stable = true
+        combatant.DeathSaves.Successes = 0
+        combatant.DeathSaves.Failures = 0
+}
+
+// validateStabilize checks if a stabilize action is valid
+func (s *Service) validateStabilize(combat *models.Combat, action *models.CombatAction, actor *models.Combatant) error {
+        if len(action.TargetIDs) == 0 {
+                return errors.New("stabilize requires a target")
+        }
+
+        target := s.getCombatant(combat, action.TargetIDs[0])
+        if !isDying(target) {
+                return errors.New("target is not dying")
+        }
+
+        // The Medicine check needs the actor to be within reach of the dying creature
+        if distanceFeet(actor.Position, target.Position) > 5 {
+                return errors.New("target is too far away to stabilize")
+        }
+
+        return nil
+}
+
+// processStabilize handles a DC 10 Wisdom (Medicine) check to stabilize a dying creature
+func (s *Service) processStabilize(combat *models.Combat, action *models.CombatAction, actor *models.Combatant) (*models.ActionResult, error) {
+        target := s.getCombatant(combat, action.TargetIDs[0])
+
+        mode := checkRollMode(actor)
+        s.diceRoller.Label("medicine check", actor.ID)
+        medicine := skillBonus(actor, "medicine")
+        check := s.rollD20(mode) + medicine
+        s.diceRoller.AddModifier(medicine)
+        actor.RemoveCondition("helped")
+
+        result := &models.ActionResult{}
+        mode.record(result)
+
+        if check < 10 {
+                result.Description = fmt.Sprintf("%s tries to stabilize %s but fails the Medicine check (rolled %d vs DC 10)%s",
+                        actor.Name, target.Name, check, mode.describe())
+                return result, nil
+        }
+
+        stabilize(target)
+        result.Success = true
+        result.Description = fmt.Sprintf("%s stabilizes %s with a successful Medicine check (rolled %d vs DC 10)%s",
+                actor.Name, target.Name, check, mode.describe())
+        result.TargetEffect = "stable"
+        return result, nil
+}
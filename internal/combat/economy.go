@@ -0,0 +1,321 @@
+package combat
+
+import (
+        "errors"
+        "fmt"
+
+        "dnd-combat/internal/models"
+)
+
+// Action economy resources
+const (
+        resourceAction      = "action"
+        resourceBonusAction = "bonus_action"
+        resourceReaction    = "reaction"
+        resourceMovement    = "movement"
+        resourceLegendary   = "legendary_action"
+        resourceLair        = "lair_action"
+        resourceFree        = "free" // Features like Action Surge that don't take an action
+)
+
+// actionResources maps each action type to the resource it uses up
+var actionResources = map[string]string{
+        "attack":           resourceAction,
+        "cast_spell":       resourceAction,
+        "dodge":            resourceAction,
+        "help":             resourceAction,
+        "hide":             resourceAction,
+        "disengage":        resourceAction,
+        "dash":             resourceAction,
+        "use_item":         resourceAction,
+        "stabilize":        resourceAction,
+        "grapple":          resourceAction,
+        "shove":            resourceAction,
+        "escape_grapple":   resourceAction,
+        "ready":            resourceAction,
+        "offhand_attack":   resourceBonusAction,
+        "move":             resourceMovement,
+        "legendary_action": resourceLegendary,
+        "lair_action":      resourceLair,
+}
+
+// startTurn prepares the combatant whose turn it now is and returns log entries
+// for anything that happened at the start of their turn
+func (s *Service) startTurn(combat *models.Combat) []*models.CombatAction {
+        if combat.CurrentTurnIndex < 0 || combat.CurrentTurnIndex >= len(combat.Initiative) {
+                return nil
+        }
+
+        item := combat.Initiative[combat.CurrentTurnIndex]
+        actor := s.getCombatant(combat, item.ID)
+        if actor == nil {
+                return nil
+        }
+
+        // On initiative count 20 the lair acts instead of the creature
+        if item.Lair {
+                actor.Economy.LairActions = 1
+                return []*models.CombatAction{{
+                        CombatID:          combat.ID,
+                        ActorID:           actor.ID,
+                        Type:              "lair",
+                        ResultDescription: fmt.Sprintf("Initiative count 20: %s can take a lair action.", item.Name),
+                }}
+        }
+
+        // Features that work once per turn, like Sneak Attack, can be used again on the new turn
+        for i := range combat.Participants {
+                combat.Participants[i].Economy.FeaturesUsed = nil
+        }
+
+        // A delayed turn goes on where it was put off, so nothing starts again
+        if item.Delayed {
+                combat.Initiative[combat.CurrentTurnIndex].Delayed = false
+                return []*models.CombatAction{s.turnEvent(combat, actor, "delay",
+                        fmt.Sprintf("%s takes their delayed turn.", actor.Name))}
+        }
+
+        var events []*models.CombatAction
+
+        // Conditions that end or are saved against at the start of the turn, like Shield
+        for _, description := range s.processConditions(actor, true) {
+                events = append(events, s.turnEvent(combat, actor, "condition", description))
+        }
+
+        // Concentration spells with a limited duration run out
+        if description := s.tickConcentration(combat, actor); description != "" {
+                events = append(events, s.turnEvent(combat, actor, "concentration", description))
+        }
+
+        // Spent abilities like a breath weapon may recharge
+        for _, description := range s.rollRecharges(actor) {
+                events = append(events, s.turnEvent(combat, actor, "recharge", description))
+        }
+
+        s.resetEconomy(actor)
+
+        // A readied action not taken by the start of the next turn is lost
+        actor.Readied = nil
+
+        // Dying characters roll a death saving throw
+        if isDying(actor) {
+                events = append(events, s.turnEvent(combat, actor, "death_save", s.rollDeathSave(actor)))
+        }
+
+        return events
+}
+
+// nextTurn ends the current turn and starts the next one in initiative order, starting a
+// new round after the last combatant's turn
+func (s *Service) nextTurn(combat *models.Combat) []*models.CombatAction {
+        // Conditions that end at the end of the current combatant's turn
+        events := s.endTurn(combat)
+
+        combat.CurrentTurnIndex++
+        if combat.CurrentTurnIndex >= len(combat.Initiative) {
+                combat.CurrentTurnIndex = 0
+                combat.RoundNumber++
+        }
+
+        // Reset the new active combatant's action economy and roll any saves
+        return append(events, s.startTurn(combat)...)
+}
+
+// endTurn processes the conditions that end or are saved against at the end of the
+// current combatant's turn and returns log entries for them
+func (s *Service) endTurn(combat *models.Combat) []*models.CombatAction {
+        if combat.CurrentTurnIndex < 0 || combat.CurrentTurnIndex >= len(combat.Initiative) {
+                return nil
+        }
+
+        item := combat.Initiative[combat.CurrentTurnIndex]
+        actor := s.getCombatant(combat, item.ID)
+        if actor == nil {
+                return nil
+        }
+
+        // An unused lair action is lost
+        if item.Lair {
+                actor.Economy.LairActions = 0
+                return nil
+        }
+
+        var events []*models.CombatAction
+        for _, description := range s.processConditions(actor, false) {
+                events = append(events, s.turnEvent(combat, actor, "condition", description))
+        }
+
+        return events
+}
+
+// turnEvent creates a log entry for something that happened at the start or end of a
+// turn, with the dice rolled for it
+func (s *Service) turnEvent(combat *models.Combat, actor *models.Combatant, eventType, description string) *models.CombatAction {
+        return &models.CombatAction{
+                CombatID:          combat.ID,
+                ActorID:           actor.ID,
+                Type:              eventType,
+                ResultDescription: description,
+                Rolls:             s.diceRoller.TakeRecords(),
+        }
+}
+
+// saveTurnEvents records start-of-turn events in the combat log. Dice rolled without an
+// event of their own go in the ledger with the last event, so no die is left out.
+func (s *Service) saveTurnEvents(combat *models.Combat, events []*models.CombatAction) error {
+        if rolls := s.diceRoller.TakeRecords(); len(rolls) > 0 {
+                if len(events) == 0 {
+                        events = append(events, &models.CombatAction{
+                                Type:              "rolls",
+                                ResultDescription: "Dice rolled between turns.",
+                        })
+                }
+                last := events[len(events)-1]
+                last.Rolls = append(last.Rolls, rolls...)
+        }
+
+        for _, event := range events {
+                event.CombatID = combat.ID
+                if err := s.repo.SaveAction(event); err != nil {
+                        return err
+                }
+        }
+        return nil
+}
+
+// resetEconomy restores a combatant's per-turn budget. Legendary creatures regain their
+// legendary actions at the start of their turn.
+func (s *Service) resetEconomy(combatant *models.Combatant) {
+        speed := effectiveSpeed(combatant, s.combatantSpeed(combatant))
+        combatant.Economy = models.ActionEconomy{
+                Actions:      1,
+                BonusActions: 1,
+                Reactions:    1,
+                MovementLeft: speed,
+                Speed:        speed,
+        }
+        if monster, ok := combatant.Stats.(*models.Monster); ok {
+                combatant.Economy.LegendaryActions = monster.LegendaryActionCount
+        }
+}
+
+// combatantSpeed returns a combatant's walking speed in feet
+func (s *Service) combatantSpeed(combatant *models.Combatant) int {
+        if monster, ok := combatant.Stats.(*models.Monster); ok {
+                return monster.Speed.Walk
+        }
+
+        // Characters are slowed by encumbrance and heavy armor
+        if char, ok := combatant.Stats.(*models.Character); ok {
+                char.DeriveStats()
+                return char.Speed
+        }
+        return models.BaseSpeed
+}
+
+// actionResource returns the resource an action uses up. Class features use the resource
+// of the feature, like a bonus action for Rage.
+func actionResource(action *models.CombatAction, actor *models.Combatant) string {
+        if action.Type == "use_feature" {
+                if feature, err := actionFeature(action, actor); err == nil && feature.Resource != "" {
+                        return feature.Resource
+                }
+        }
+        if resource, ok := actionResources[action.Type]; ok {
+                return resource
+        }
+        return resourceAction
+}
+
+// checkEconomy verifies that the actor still has the resource an action needs
+func (s *Service) checkEconomy(combat *models.Combat, action *models.CombatAction, actor *models.Combatant) error {
+        switch actionResource(action, actor) {
+        case resourceAction:
+                // Extra Attack lets the attacks of one Attack action be made one at a time
+                if takesAttack(action) && actor.Economy.AttacksLeft > 0 {
+                        break
+                }
+                if actor.Economy.Actions <= 0 {
+                        return errors.New("actor has already used their action this turn")
+                }
+        case resourceBonusAction:
+                if actor.Economy.BonusActions <= 0 {
+                        return errors.New("actor has already used their bonus action this turn")
+                }
+        case resourceReaction:
+                if actor.Economy.Reactions <= 0 {
+                        return errors.New("actor has already used their reaction this round")
+                }
+        case resourceLegendary:
+                if cost := legendaryActionCost(actor, action); cost > actor.Economy.LegendaryActions {
+                        return fmt.Errorf("actor has %d legendary actions left this round (cost: %d)",
+                                actor.Economy.LegendaryActions, cost)
+                }
+        case resourceLair:
+                if actor.Economy.LairActions <= 0 {
+                        return errors.New("the lair has already acted this round")
+                }
+        case resourceMovement:
+                cost := s.movementCost(combat, actor, action.MovementPath) + standUpCost(actor)
+                if cost > actor.Economy.MovementLeft {
+                        return fmt.Errorf("movement path exceeds remaining movement (cost: %d ft, remaining: %d ft)",
+                                cost, actor.Economy.MovementLeft)
+                }
+        }
+
+        return nil
+}
+
+// spendEconomy deducts the resource an action used from the actor's budget
+func (s *Service) spendEconomy(combat *models.Combat, action *models.CombatAction, actor *models.Combatant) {
+        switch actionResource(action, actor) {
+        case resourceAction:
+                if takesAttack(action) && actor.Economy.AttacksLeft > 0 {
+                        actor.Economy.AttacksLeft--
+                        break
+                }
+                actor.Economy.Actions--
+                actor.Economy.AttacksLeft = 0
+                if takesAttack(action) {
+                        actor.Economy.AttacksLeft = attacksPerAction(actor) - 1
+                }
+        case resourceBonusAction:
+                actor.Economy.BonusActions--
+        case resourceReaction:
+                actor.Economy.Reactions--
+        case resourceLegendary:
+                actor.Economy.LegendaryActions -= legendaryActionCost(actor, action)
+        case resourceLair:
+                actor.Economy.LairActions--
+        case resourceMovement:
+                actor.Economy.MovementLeft -= s.movementCost(combat, actor, action.MovementPath)
+                if actor.Economy.MovementLeft < 0 {
+                        actor.Economy.MovementLeft = 0
+                }
+        }
+}
+
+// standUpCost returns the movement a prone combatant spends to stand up
+func standUpCost(combatant *models.Combatant) int {
+        if !combatant.HasCondition("prone") {
+                return 0
+        }
+        return combatant.Economy.Speed / 2
+}
+
+// movementCost returns the cost in feet of a combatant walking a path, counting difficult
+// terrain twice, and doubled while they drag a creature they grapple
+func (s *Service) movementCost(combat *models.Combat, mover *models.Combatant, path [][2]int) int {
+        cost := 0
+        for _, pos := range path {
+                if terrainAt(combat.Battlefield, pos) == "difficult" {
+                        cost += 10
+                } else {
+                        cost += 5
+                }
+        }
+        if dragsAtHalfSpeed(combat, mover) {
+                cost *= 2
+        }
+        return cost
+}
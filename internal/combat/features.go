@@ -0,0 +1,485 @@
+package combat
+
+import (
+        "errors"
+        "fmt"
+        "strings"
+
+        "dnd-combat/internal/models"
+)
+
+// classFeature is a class feature that changes how a character fights. A feature is either
+// used as a "use_feature" action, like Rage, or changes the character's weapon attacks, like
+// Sneak Attack, so a new feature only needs an entry in classFeatures.
+type classFeature struct {
+        Index       string
+        Name        string
+        Class       string              // Class that gains the feature, e.g. "fighter"
+        Level       int                 // Class level the feature is gained at
+        Uses        func(level int) int // Uses between rests, 0 for unlimited; nil if the feature isn't limited
+        Recovery    string              // Rest that restores the uses, models.ShortRest or models.LongRest
+        OncePerTurn bool
+        Initiative  bool // Gives advantage on initiative rolls, like Feral Instinct
+
+        // Features used as an action: the action economy resource they take, any checks
+        // beyond having the feature and a use left, and what they do
+        Resource string
+        Validate func(s *Service, combat *models.Combat, action *models.CombatAction, actor *models.Combatant) error
+        Activate func(s *Service, combat *models.Combat, action *models.CombatAction, actor *models.Combatant, level int) (*models.ActionResult, error)
+
+        // Features that change weapon attacks
+        Attacks        func(level int) int // Attacks made with the Attack action, like Extra Attack
+        AttackOption   string              // Key of the attack's extra data that turns the feature on, e.g. "smite_slot"
+        ValidateAttack func(s *Service, action *models.CombatAction, actor *models.Combatant, attack *weaponAttack) error
+        OnHit          func(s *Service, hit *weaponHit, level int) string // Adds to a hit's damage, returning "" when it doesn't apply
+}
+
+// weaponHit is a weapon attack that hit, before its damage is rolled
+type weaponHit struct {
+        combat   *models.Combat
+        action   *models.CombatAction
+        actor    *models.Combatant
+        target   *models.Combatant
+        attack   *weaponAttack
+        mode     rollMode
+        critical bool
+        damage   []models.DamageInfo
+}
+
+// classFeatures are the class features characters use in combat. Features that add to a
+// hit's damage are applied in this order.
+var classFeatures = []*classFeature{
+        {Index: "extra-attack", Name: "Extra Attack", Class: "fighter", Level: 5, Attacks: fighterAttacks},
+        {Index: "extra-attack", Name: "Extra Attack", Class: "barbarian", Level: 5, Attacks: twoAttacks},
+        {Index: "extra-attack", Name: "Extra Attack", Class: "monk", Level: 5, Attacks: twoAttacks},
+        {Index: "extra-attack", Name: "Extra Attack", Class: "paladin", Level: 5, Attacks: twoAttacks},
+        {Index: "extra-attack", Name: "Extra Attack", Class: "ranger", Level: 5, Attacks: twoAttacks},
+        {
+                Index: "rage", Name: "Rage", Class: "barbarian", Level: 1,
+                Uses: rageUses, Recovery: models.LongRest,
+                Resource: resourceBonusAction, Validate: validateRage, Activate: startRage,
+                OnHit: rageDamageBonus,
+        },
+        {
+                Index: "second-wind", Name: "Second Wind", Class: "fighter", Level: 1,
+                Uses: oneUse, Recovery: models.ShortRest,
+                Resource: resourceBonusAction, Activate: useSecondWind,
+        },
+        {
+                Index: "action-surge", Name: "Action Surge", Class: "fighter", Level: 2,
+                Uses: actionSurgeUses, Recovery: models.ShortRest, OncePerTurn: true,
+                Resource: resourceFree, Activate: useActionSurge,
+        },
+        {Index: "feral-instinct", Name: "Feral Instinct", Class: "barbarian", Level: 7, Initiative: true},
+        {
+                Index: "sneak-attack", Name: "Sneak Attack", Class: "rogue", Level: 1,
+                OncePerTurn: true, OnHit: sneakAttack,
+        },
+        {
+                Index: "divine-smite", Name: "Divine Smite", Class: "paladin", Level: 2,
+                AttackOption: "smite_slot", ValidateAttack: validateDivineSmite, OnHit: divineSmite,
+        },
+}
+
+// hasClassFeature checks if a character's class and level give them a feature
+func hasClassFeature(char *models.Character, feature *classFeature) bool {
+        return strings.EqualFold(char.Class, feature.Class) && char.Level >= feature.Level
+}
+
+// lookupClassFeature finds a class feature by index, preferring the version of the
+// character's class for features several classes gain, like Extra Attack
+func lookupClassFeature(char *models.Character, index string) (*classFeature, error) {
+        index = strings.ReplaceAll(strings.ToLower(strings.TrimSpace(index)), " ", "-")
+        var found *classFeature
+        for _, feature := range classFeatures {
+                if feature.Index != index {
+                        continue
+                }
+                if char != nil && hasClassFeature(char, feature) {
+                        return feature, nil
+                }
+                if found == nil {
+                        found = feature
+                }
+        }
+        if found == nil {
+                return nil, fmt.Errorf("unknown class feature '%s'", index)
+        }
+        return found, nil
+}
+
+// actionFeature returns the class feature a "use_feature" action uses, from the "feature"
+// of its extra data
+func actionFeature(action *models.CombatAction, actor *models.Combatant) (*classFeature, error) {
+        index, _ := action.ExtraData["feature"].(string)
+        if index == "" {
+                return nil, errors.New("using a class feature requires extra_data.feature")
+        }
+        char, _ := actor.Stats.(*models.Character)
+        return lookupClassFeature(char, index)
+}
+
+// featureUse returns a character's uses of a limited feature, adding them if the character
+// hasn't tracked the feature yet
+func featureUse(char *models.Character, feature *classFeature) *models.FeatureUse {
+        if use := char.GetFeatureUse(feature.Index); use != nil {
+                return use
+        }
+        char.FeatureUses = append(char.FeatureUses, models.FeatureUse{
+                Index:    feature.Index,
+                Name:     feature.Name,
+                Max:      feature.Uses(char.Level),
+                Recovery: feature.Recovery,
+        })
+        return &char.FeatureUses[len(char.FeatureUses)-1]
+}
+
+// prepareFeatures sets the uses of a character's limited class features from their class
+// and level, keeping track of the uses they have already spent
+func (s *Service) prepareFeatures(char *models.Character) {
+        uses := []models.FeatureUse{}
+        for _, feature := range classFeatures {
+                if feature.Uses == nil || !hasClassFeature(char, feature) {
+                        continue
+                }
+                use := models.FeatureUse{
+                        Index:    feature.Index,
+                        Name:     feature.Name,
+                        Max:      feature.Uses(char.Level),
+                        Recovery: feature.Recovery,
+                }
+                if existing := char.GetFeatureUse(feature.Index); existing != nil {
+                        use.Used = existing.Used
+                        if use.Max > 0 && use.Used > use.Max {
+                                use.Used = use.Max
+                        }
+                }
+                uses = append(uses, use)
+        }
+        char.FeatureUses = uses
+}
+
+// checkFeature verifies that a combatant has a class feature and can still use it
+func checkFeature(actor *models.Combatant, feature *classFeature) error {
+        char, ok := actor.Stats.(*models.Character)
+        if !ok || !hasClassFeature(char, feature) {
+                return fmt.Errorf("%s doesn't have %s", actor.Name, feature.Name)
+        }
+        if feature.OncePerTurn && containsString(actor.Economy.FeaturesUsed, feature.Index) {
+                return fmt.Errorf("%s has already been used this turn", feature.Name)
+        }
+        if feature.Uses != nil {
+                if use := featureUse(char, feature); use.Max > 0 && use.Used >= use.Max {
+                        return fmt.Errorf("no uses of %s left until a %s", feature.Name, strings.ReplaceAll(feature.Recovery, "_", " "))
+                }
+        }
+        return nil
+}
+
+// spendFeature uses up a use of a limited feature and marks a feature that can only be
+// used once per turn as used
+func spendFeature(actor *models.Combatant, feature *classFeature) {
+        if char, ok := actor.Stats.(*models.Character); ok && feature.Uses != nil {
+                featureUse(char, feature).Used++
+        }
+        if feature.OncePerTurn {
+                actor.Economy.FeaturesUsed = append(actor.Economy.FeaturesUsed, feature.Index)
+        }
+}
+
+// validateFeature checks that a character can use the class feature of a "use_feature" action
+func (s *Service) validateFeature(combat *models.Combat, action *models.CombatAction, actor *models.Combatant) error {
+        feature, err := actionFeature(action, actor)
+        if err != nil {
+                return err
+        }
+        if feature.Activate == nil {
+                return fmt.Errorf("%s isn't used as an action", feature.Name)
+        }
+        if err := checkFeature(actor, feature); err != nil {
+                return err
+        }
+        if feature.Validate != nil {
+                return feature.Validate(s, combat, action, actor)
+        }
+        return nil
+}
+
+// processFeature handles using a class feature
+func (s *Service) processFeature(combat *models.Combat, action *models.CombatAction, actor *models.Combatant) (*models.ActionResult, error) {
+        feature, err := actionFeature(action, actor)
+        if err != nil {
+                return nil, err
+        }
+
+        char := actor.Stats.(*models.Character)
+        result, err := feature.Activate(s, combat, action, actor, char.Level)
+        if err != nil {
+                return nil, err
+        }
+
+        spendFeature(actor, feature)
+        return result, nil
+}
+
+// validateAttackFeatures checks the class features an attack's extra data turns on, like
+// Divine Smite, which the attacker must have and be able to use
+func (s *Service) validateAttackFeatures(action *models.CombatAction, actor *models.Combatant, attack *weaponAttack) error {
+        for _, feature := range classFeatures {
+                if feature.AttackOption == "" || action.ExtraData[feature.AttackOption] == nil {
+                        continue
+                }
+                if err := checkFeature(actor, feature); err != nil {
+                        return err
+                }
+                if feature.ValidateAttack != nil {
+                        if err := feature.ValidateAttack(s, action, actor, attack); err != nil {
+                                return err
+                        }
+                }
+        }
+        return nil
+}
+
+// applyHitFeatures lets a character's class features add to the damage of a weapon hit
+// and returns descriptions of the features that did
+func (s *Service) applyHitFeatures(hit *weaponHit) string {
+        char, ok := hit.actor.Stats.(*models.Character)
+        if !ok {
+                return ""
+        }
+
+        var descriptions []string
+        for _, feature := range classFeatures {
+                if feature.OnHit == nil || !hasClassFeature(char, feature) {
+                        continue
+                }
+                if feature.AttackOption != "" && hit.action.ExtraData[feature.AttackOption] == nil {
+                        continue
+                }
+                if feature.OncePerTurn && containsString(hit.actor.Economy.FeaturesUsed, feature.Index) {
+                        continue
+                }
+                if description := feature.OnHit(s, hit, char.Level); description != "" {
+                        spendFeature(hit.actor, feature)
+                        descriptions = append(descriptions, description)
+                }
+        }
+        return strings.Join(descriptions, " ")
+}
+
+// attacksPerAction returns how many attacks a combatant makes when they take the Attack action
+func attacksPerAction(actor *models.Combatant) int {
+        attacks := 1
+        char, ok := actor.Stats.(*models.Character)
+        if !ok {
+                return attacks
+        }
+        for _, feature := range classFeatures {
+                if feature.Attacks != nil && hasClassFeature(char, feature) {
+                        attacks = max(attacks, feature.Attacks(char.Level))
+                }
+        }
+        return attacks
+}
+
+// extraDataInt reads a whole number from an action's extra data, which JSON decodes as a float
+func extraDataInt(action *models.CombatAction, key string) (int, bool) {
+        switch value := action.ExtraData[key].(type) {
+        case float64:
+                return int(value), value == float64(int(value))
+        case int:
+                return value, true
+        }
+        return 0, false
+}
+
+// oneUse is the uses of a feature that can be used once between rests
+func oneUse(level int) int {
+        return 1
+}
+
+// twoAttacks is Extra Attack for classes other than the fighter
+func twoAttacks(level int) int {
+        return 2
+}
+
+// fighterAttacks is the fighter's Extra Attack, which gains attacks at 11th and 20th level
+func fighterAttacks(level int) int {
+        switch {
+        case level >= 20:
+                return 4
+        case level >= 11:
+                return 3
+        }
+        return 2
+}
+
+// rageUses returns how many times a barbarian can rage between long rests, unlimited at 20th level
+func rageUses(level int) int {
+        switch {
+        case level >= 20:
+                return 0
+        case level >= 17:
+                return 6
+        case level >= 12:
+                return 5
+        case level >= 6:
+                return 4
+        case level >= 3:
+                return 3
+        }
+        return 2
+}
+
+// rageDamage returns the bonus a raging barbarian adds to Strength melee weapon damage
+func rageDamage(level int) int {
+        switch {
+        case level >= 16:
+                return 4
+        case level >= 9:
+                return 3
+        }
+        return 2
+}
+
+// isRaging checks if a combatant is raging. Falling unconscious ends a rage.
+func isRaging(combatant *models.Combatant) bool {
+        return combatant.HasCondition("raging") && !hasCondition(combatant, "unconscious")
+}
+
+// validateRage checks that a barbarian isn't already raging or wearing heavy armor
+func validateRage(s *Service, combat *models.Combat, action *models.CombatAction, actor *models.Combatant) error {
+        if isRaging(actor) {
+                return errors.New("actor is already raging")
+        }
+        char := actor.Stats.(*models.Character)
+        if armor := char.WornArmor(); armor != nil && armor.Category == "heavy" {
+                return errors.New("actor can't rage while wearing heavy armor")
+        }
+        return nil
+}
+
+// startRage makes a barbarian rage for 1 minute. While raging they add their rage damage to
+// Strength melee weapon attacks, resist bludgeoning, piercing and slashing damage, have
+// advantage on Strength saving throws and can't cast spells.
+func startRage(s *Service, combat *models.Combat, action *models.CombatAction, actor *models.Combatant, level int) (*models.ActionResult, error) {
+        // Feral Instinct lets a surprised barbarian act once they rage
+        actor.RemoveCondition("surprised")
+        s.addCondition(actor, models.Condition{Name: "raging", SourceID: actor.ID, Duration: 10, StartOfTurn: true})
+        return &models.ActionResult{
+                Success:      true,
+                TargetEffect: "raging",
+                Description: fmt.Sprintf("%s flies into a rage! They deal %d extra damage with Strength melee attacks and resist bludgeoning, piercing and slashing damage.",
+                        actor.Name, rageDamage(level)),
+        }, nil
+}
+
+// rageDamageBonus adds a raging barbarian's rage damage to a Strength melee weapon hit
+func rageDamageBonus(s *Service, hit *weaponHit, level int) string {
+        if !isRaging(hit.actor) || hit.attack.ranged || hit.attack.ability != "str" || len(hit.damage) == 0 {
+                return ""
+        }
+        bonus := rageDamage(level)
+        hit.damage[0].Bonus += bonus
+        return fmt.Sprintf("Rage adds %d damage.", bonus)
+}
+
+// useSecondWind heals a fighter for 1d10 + their fighter level
+func useSecondWind(s *Service, combat *models.Combat, action *models.CombatAction, actor *models.Combatant, level int) (*models.ActionResult, error) {
+        s.diceRoller.Label("second wind", actor.ID)
+        healing := s.diceRoller.Roll(1, 10) + level
+        s.diceRoller.AddModifier(level)
+        healed := s.applyHealing(actor, healing)
+        return &models.ActionResult{
+                Success:     true,
+                Healing:     healed,
+                Description: fmt.Sprintf("%s uses Second Wind and regains %d hit points.", actor.Name, healed),
+        }, nil
+}
+
+// actionSurgeUses returns how many times a fighter can use Action Surge between short rests
+func actionSurgeUses(level int) int {
+        if level >= 17 {
+                return 2
+        }
+        return 1
+}
+
+// useActionSurge gives a fighter another action this turn
+func useActionSurge(s *Service, combat *models.Combat, action *models.CombatAction, actor *models.Combatant, level int) (*models.ActionResult, error) {
+        actor.Economy.Actions++
+        return &models.ActionResult{
+                Success:     true,
+                Description: fmt.Sprintf("%s uses Action Surge and can take another action this turn.", actor.Name),
+        }, nil
+}
+
+// sneakAttack adds a rogue's Sneak Attack dice to a hit with a finesse or ranged weapon when
+// they have advantage, or when another enemy of the target is within 5 feet of it and they
+// don't have disadvantage
+func sneakAttack(s *Service, hit *weaponHit, level int) string {
+        weapon := hit.attack.weapon
+        if weapon == nil || !(weapon.HasProperty("finesse") || weapon.IsRanged()) {
+                return ""
+        }
+        advantage, disadvantage := hit.mode.resolve()
+        if disadvantage || (!advantage && !flanked(hit.combat, hit.actor, hit.target)) {
+                return ""
+        }
+
+        dice := (level + 1) / 2
+        hit.damage = append(hit.damage, models.DamageInfo{DiceCount: dice, DiceValue: 6, Type: weapon.Damage.Type})
+        return fmt.Sprintf("Sneak Attack adds %dd6 damage.", dice)
+}
+
+// flanked checks if an enemy of a target other than the attacker is within 5 feet of it
+// and able to act
+func flanked(combat *models.Combat, attacker, target *models.Combatant) bool {
+        for i := range combat.Participants {
+                other := &combat.Participants[i]
+                if other.ID != attacker.ID && other.HP > 0 && isHostile(other, target) && !isIncapacitated(other) &&
+                        distanceFeet(other.Position, target.Position) <= 5 {
+                        return true
+                }
+        }
+        return false
+}
+
+// validateDivineSmite checks that a paladin smites with a melee weapon and a spell slot
+// they have left
+func validateDivineSmite(s *Service, action *models.CombatAction, actor *models.Combatant, attack *weaponAttack) error {
+        slotLevel, ok := extraDataInt(action, "smite_slot")
+        if !ok || slotLevel < 1 || slotLevel > 9 {
+                return errors.New("smite_slot must be a spell slot level from 1 to 9")
+        }
+        if attack.ranged {
+                return errors.New("Divine Smite needs a melee weapon attack")
+        }
+        char := actor.Stats.(*models.Character)
+        if slot := char.GetSpellSlot(slotLevel); slot == nil || slot.Used >= slot.Max {
+                return fmt.Errorf("no level %d spell slots left", slotLevel)
+        }
+        return nil
+}
+
+// divineSmite spends a paladin's spell slot on a melee weapon hit for 2d8 radiant damage,
+// plus 1d8 for each slot level above 1st to a maximum of 5d8, and 1d8 more against undead
+// and fiends
+func divineSmite(s *Service, hit *weaponHit, level int) string {
+        slotLevel, _ := extraDataInt(hit.action, "smite_slot")
+        char := hit.actor.Stats.(*models.Character)
+        slot := char.GetSpellSlot(slotLevel)
+        if hit.attack.ranged || slot == nil || slot.Used >= slot.Max {
+                return ""
+        }
+        slot.Used++
+
+        dice := min(1+slotLevel, 5)
+        if monster, ok := hit.target.Stats.(*models.Monster); ok &&
+                (strings.EqualFold(monster.Type, "undead") || strings.EqualFold(monster.Type, "fiend")) {
+                dice++
+        }
+        hit.damage = append(hit.damage, models.DamageInfo{DiceCount: dice, DiceValue: 8, Type: "radiant"})
+        return fmt.Sprintf("Divine Smite spends a level %d spell slot for %dd8 radiant damage.", slotLevel, dice)
+}
@@ -0,0 +1,319 @@
+package combat
+
+import (
+        "errors"
+        "fmt"
+        "strings"
+
+        "dnd-combat/internal/models"
+)
+
+// sizeRanks orders creature sizes from smallest to largest
+var sizeRanks = map[string]int{
+        "tiny":       0,
+        "small":      1,
+        "medium":     2,
+        "large":      3,
+        "huge":       4,
+        "gargantuan": 5,
+}
+
+// sizeRank returns where a combatant's size falls in sizeRanks. Characters created before
+// races came from the SRD are Small or Medium by their race.
+func sizeRank(combatant *models.Combatant) int {
+        switch stats := combatant.Stats.(type) {
+        case *models.Monster:
+                if rank, ok := sizeRanks[strings.ToLower(stats.Size)]; ok {
+                        return rank
+                }
+        case *models.Character:
+                if isSmall(combatant) {
+                        return sizeRanks["small"]
+                }
+        }
+        return sizeRanks["medium"]
+}
+
+// takesAttack checks if an action is made as one of the attacks of the Attack action, which
+// Extra Attack lets a combatant split between attacks, grapples and shoves
+func takesAttack(action *models.CombatAction) bool {
+        switch action.Type {
+        case "attack", "grapple", "shove":
+                return true
+        }
+        return false
+}
+
+// validateGrappleOrShove checks that a grapple or shove targets a creature within reach that
+// is no more than one size larger than the actor
+func (s *Service) validateGrappleOrShove(combat *models.Combat, action *models.CombatAction, actor *models.Combatant) error {
+        if len(action.TargetIDs) == 0 {
+                return fmt.Errorf("%s requires a target", action.Type)
+        }
+        target := s.getCombatant(combat, action.TargetIDs[0])
+        if target.ID == actor.ID {
+                return fmt.Errorf("actor can't %s themselves", action.Type)
+        }
+        if target.HP <= 0 && target.Type == "monster" {
+                return errors.New("target is dead")
+        }
+
+        if distance, reach := distanceFeet(actor.Position, target.Position), s.reachFeet(actor); distance > reach {
+                return fmt.Errorf("target is out of reach (distance: %d ft, reach: %d ft)", distance, reach)
+        }
+        if sizeRank(target) > sizeRank(actor)+1 {
+                return fmt.Errorf("%s is too large to %s", target.Name, action.Type)
+        }
+
+        if action.Type == "grapple" {
+                for _, condition := range target.Conditions {
+                        if condition.Name == "grappled" && condition.SourceID == actor.ID {
+                                return errors.New("actor is already grappling the target")
+                        }
+                }
+        }
+        if action.Type == "shove" {
+                switch shoveEffect(action) {
+                case "prone", "push":
+                default:
+                        return fmt.Errorf("unknown shove '%s', expected prone or push", shoveEffect(action))
+                }
+        }
+        return nil
+}
+
+// contestedCheck rolls a contest between an actor's check with one skill and a defender's
+// check with the better of the skills they can choose from. The actor only wins by beating
+// the defender's total. It returns whether the actor won and a description of the rolls.
+func (s *Service) contestedCheck(actor *models.Combatant, actorSkill string, defender *models.Combatant, defenderSkills ...string) (bool, string) {
+        actorTotal := s.rollSkillCheck(actor, actorSkill)
+
+        defenderSkill := defenderSkills[0]
+        for _, skill := range defenderSkills[1:] {
+                if skillBonus(defender, skill) > skillBonus(defender, defenderSkill) {
+                        defenderSkill = skill
+                }
+        }
+        defenderTotal := s.rollSkillCheck(defender, defenderSkill)
+
+        return actorTotal > defenderTotal, fmt.Sprintf("(%s %d vs %s %d)",
+                skillName(actorSkill), actorTotal, skillName(defenderSkill), defenderTotal)
+}
+
+// rollSkillCheck rolls a combatant's check with a skill, using up the Help they were given
+func (s *Service) rollSkillCheck(combatant *models.Combatant, skill string) int {
+        bonus := skillBonus(combatant, skill)
+        s.diceRoller.Label(skill+" check", combatant.ID)
+        total := s.rollD20(checkRollMode(combatant)) + bonus
+        s.diceRoller.AddModifier(bonus)
+        combatant.RemoveCondition("helped")
+        return total
+}
+
+// skillName returns a skill index like "athletics" for descriptions
+func skillName(skill string) string {
+        return strings.ToUpper(skill[:1]) + skill[1:]
+}
+
+// processGrapple handles a grapple: a Strength (Athletics) check contested by the target's
+// Strength (Athletics) or Dexterity (Acrobatics) check. A grappled target's speed is 0 and
+// it is dragged along when the grappler moves.
+func (s *Service) processGrapple(combat *models.Combat, action *models.CombatAction, actor *models.Combatant) (*models.ActionResult, error) {
+        target := s.getCombatant(combat, action.TargetIDs[0])
+
+        won, contest := s.contestedCheck(actor, "athletics", target, "athletics", "acrobatics")
+        if !won {
+                return &models.ActionResult{
+                        Description: fmt.Sprintf("%s tries to grapple %s but fails %s", actor.Name, target.Name, contest),
+                }, nil
+        }
+
+        effect := s.addCondition(target, models.Condition{Name: "grappled", SourceID: actor.ID})
+        return &models.ActionResult{
+                Success:      target.HasCondition("grappled"),
+                TargetEffect: "grappled",
+                Description:  fmt.Sprintf("%s grapples %s %s. %s", actor.Name, target.Name, contest, effect),
+        }, nil
+}
+
+// processShove handles a shove: a Strength (Athletics) check contested by the target's
+// Strength (Athletics) or Dexterity (Acrobatics) check that knocks the target prone or, when
+// the "shove" of the action's extra data is "push", pushes it 5 feet away
+func (s *Service) processShove(combat *models.Combat, action *models.CombatAction, actor *models.Combatant) (*models.ActionResult, error) {
+        target := s.getCombatant(combat, action.TargetIDs[0])
+
+        won, contest := s.contestedCheck(actor, "athletics", target, "athletics", "acrobatics")
+        if !won {
+                return &models.ActionResult{
+                        Description: fmt.Sprintf("%s tries to shove %s but fails %s", actor.Name, target.Name, contest),
+                }, nil
+        }
+
+        result := &models.ActionResult{Success: true}
+        if shoveEffect(action) == "prone" {
+                result.TargetEffect = "prone"
+                result.Description = fmt.Sprintf("%s shoves %s %s. %s", actor.Name, target.Name, contest,
+                        s.addCondition(target, models.Condition{Name: "prone", SourceID: actor.ID}))
+                return result, nil
+        }
+
+        // Push the target one square straight away from the actor
+        pos := [2]int{
+                target.Position[0] + sign(target.Position[0]-actor.Position[0]),
+                target.Position[1] + sign(target.Position[1]-actor.Position[1]),
+        }
+        if !s.isFree(combat, pos, target) {
+                result.Success = false
+                result.Description = fmt.Sprintf("%s shoves %s %s, but there is nowhere to push them", actor.Name, target.Name, contest)
+                return result, nil
+        }
+        target.Position = pos
+        result.Description = fmt.Sprintf("%s shoves %s %s, pushing them 5 feet to [%d,%d]", actor.Name, target.Name, contest, pos[0], pos[1])
+        return result, nil
+}
+
+// shoveEffect returns what a shove does, from the "shove" of its extra data: "prone", the
+// default, or "push"
+func shoveEffect(action *models.CombatAction) string {
+        if effect, _ := action.ExtraData["shove"].(string); effect != "" {
+                return effect
+        }
+        return "prone"
+}
+
+// validateEscapeGrapple checks that the actor is grappled
+func (s *Service) validateEscapeGrapple(combat *models.Combat, action *models.CombatAction, actor *models.Combatant) error {
+        if !actor.HasCondition("grappled") {
+                return errors.New("actor isn't grappled")
+        }
+        return nil
+}
+
+// processEscapeGrapple handles an attempt to escape a grapple: the better of the actor's
+// Strength (Athletics) and Dexterity (Acrobatics) checks contested by the grappler's
+// Strength (Athletics) check. An actor who escapes can use their movement this turn.
+func (s *Service) processEscapeGrapple(combat *models.Combat, action *models.CombatAction, actor *models.Combatant) (*models.ActionResult, error) {
+        condition := actor.GetCondition("grappled")
+        grappler := s.getCombatant(combat, condition.SourceID)
+        if grappler == nil {
+                actor.RemoveCondition("grappled")
+                return &models.ActionResult{Success: true, Description: fmt.Sprintf("%s slips free", actor.Name)}, nil
+        }
+
+        skill := "athletics"
+        if skillBonus(actor, "acrobatics") > skillBonus(actor, skill) {
+                skill = "acrobatics"
+        }
+        won, contest := s.contestedCheck(actor, skill, grappler, "athletics")
+        if !won {
+                return &models.ActionResult{
+                        Description: fmt.Sprintf("%s struggles against %s's grapple but can't escape %s", actor.Name, grappler.Name, contest),
+                }, nil
+        }
+
+        releaseGrapple(actor, grappler.ID)
+        if !actor.HasCondition("grappled") {
+                speed := effectiveSpeed(actor, s.combatantSpeed(actor))
+                actor.Economy.MovementLeft += speed - actor.Economy.Speed
+                actor.Economy.Speed = speed
+        }
+        return &models.ActionResult{
+                Success:     true,
+                Description: fmt.Sprintf("%s escapes %s's grapple %s", actor.Name, grappler.Name, contest),
+        }, nil
+}
+
+// releaseGrapple ends a grappler's grapple on a combatant
+func releaseGrapple(combatant *models.Combatant, grapplerID string) {
+        remaining := combatant.Conditions[:0]
+        for _, condition := range combatant.Conditions {
+                if condition.Name != "grappled" || condition.SourceID != grapplerID {
+                        remaining = append(remaining, condition)
+                }
+        }
+        combatant.Conditions = remaining
+}
+
+// grappledBy returns the combatants a grappler is grappling
+func grappledBy(combat *models.Combat, grappler *models.Combatant) []*models.Combatant {
+        var grappled []*models.Combatant
+        for i := range combat.Participants {
+                other := &combat.Participants[i]
+                for _, condition := range other.Conditions {
+                        if condition.Name == "grappled" && condition.SourceID == grappler.ID {
+                                grappled = append(grappled, other)
+                                break
+                        }
+                }
+        }
+        return grappled
+}
+
+// releaseBrokenGrapples ends the grapples of grapplers who are incapacitated, dropped or
+// gone, and of targets pushed or moved out of their grappler's reach
+func (s *Service) releaseBrokenGrapples(combat *models.Combat) {
+        for i := range combat.Participants {
+                grappled := &combat.Participants[i]
+                var broken []string
+                for _, condition := range grappled.Conditions {
+                        if condition.Name != "grappled" {
+                                continue
+                        }
+                        grappler := s.getCombatant(combat, condition.SourceID)
+                        if grappler == nil || grappler.HP <= 0 || isIncapacitated(grappler) ||
+                                distanceFeet(grappler.Position, grappled.Position) > s.reachFeet(grappler) {
+                                broken = append(broken, condition.SourceID)
+                        }
+                }
+                for _, grapplerID := range broken {
+                        releaseGrapple(grappled, grapplerID)
+                }
+        }
+}
+
+// dragsAtHalfSpeed checks if a combatant is dragging a grappled creature that isn't two or
+// more sizes smaller, which makes their movement cost double
+func dragsAtHalfSpeed(combat *models.Combat, combatant *models.Combatant) bool {
+        for _, grappled := range grappledBy(combat, combatant) {
+                if sizeRank(grappled) > sizeRank(combatant)-2 {
+                        return true
+                }
+        }
+        return false
+}
+
+// dragGrappled moves the creatures a grappler is grappling along behind them after the
+// grappler steps off from a square
+func dragGrappled(combat *models.Combat, grappler *models.Combatant, from [2]int) {
+        for _, grappled := range grappledBy(combat, grappler) {
+                grappled.Position, from = from, grappled.Position
+        }
+}
+
+// isFree checks if a square is on the battlefield, not blocked and not taken by another
+// combatant than the one moving into it
+func (s *Service) isFree(combat *models.Combat, pos [2]int, mover *models.Combatant) bool {
+        if pos[0] < 0 || pos[0] >= combat.Battlefield.Width || pos[1] < 0 || pos[1] >= combat.Battlefield.Height {
+                return false
+        }
+        if blocksMovement(combat.Battlefield, pos) {
+                return false
+        }
+        for _, other := range combat.Participants {
+                if other.ID != mover.ID && other.Position == pos {
+                        return false
+                }
+        }
+        return true
+}
+
+// sign returns -1, 0 or 1 by the sign of x
+func sign(x int) int {
+        switch {
+        case x < 0:
+                return -1
+        case x > 0:
+                return 1
+        }
+        return 0
+}
@@ -1,7 +1,10 @@
 package combat
 
 import (
+        "errors"
+        "log"
         "net/http"
+        "time"
 
         "github.com/gin-gonic/gin"
 
@@ -15,6 +18,9 @@ type Handler struct {
         characterSvc CharacterService
         srdClient    SRDClient
         wsHub        *websocket.Hub
+        
+        // Pause between the broadcast steps of automated monster turns
+        monsterStepDelay time.Duration
 }
 
 // CharacterService defines the interface for character operations
@@ -28,23 +34,31 @@ type CharacterService interface {
 type SRDClient interface {
         GetMonster(index string) (*models.Monster, error)
         GetSpell(index string) (*models.Spell, error)
+        GetSpellSlots(class string, level int) ([]models.SpellSlot, error)
+        GetWeapon(index string) (*models.Weapon, error)
+        GetClassProficiencies(class string) ([]string, error)
 }
 
 // NewHandler creates a new combat handler
-func NewHandler(service *Service, characterSvc CharacterService, srdClient SRDClient, wsHub *websocket.Hub) *Handler {
+func NewHandler(service *Service, characterSvc CharacterService, srdClient SRDClient, wsHub *websocket.Hub, monsterStepDelay time.Duration) *Handler {
         return &Handler{
-                service:      service,
-                characterSvc: characterSvc,
-                srdClient:    srdClient,
-                wsHub:        wsHub,
+                service:          service,
+                characterSvc:     characterSvc,
+                srdClient:        srdClient,
+                wsHub:            wsHub,
+                monsterStepDelay: monsterStepDelay,
         }
 }
 
 // InitiateCombatRequest represents the request body for starting a combat
 type InitiateCombatRequest struct {
-        ParticipantIDs []string `json:"participants" binding:"required"`
-        MonsterIDs     []string `json:"monster_ids"`
-        Environment    string   `json:"environment"`
+        ParticipantIDs []string                          `json:"participants" binding:"required"`
+        MonsterIDs     []string                          `json:"monster_ids"`
+        Environment    string                            `json:"environment"`
+        LairActions    map[string][]models.MonsterAction `json:"lair_actions"` // Keyed by monster index
+        AutoMonsters   bool                              `json:"auto_monsters"` // Play monster turns automatically
+        Surprised      []string                          `json:"surprised"`     // Surprised combatants, see InitiativeSetup
+        Initiative     map[string]int                    `json:"initiative"`    // Initiative rolled with physical dice, see InitiativeSetup
 }
 
 // InitiateCombat starts a new combat encounter
@@ -92,19 +106,55 @@ func (h *Handler) InitiateCombat(c *gin.Context) {
                 monsters = append(monsters, monster)
         }
 
+        // The SRD has no lair actions, so the DM gives them for the monster fought in its lair
+        for monsterID, lairActions := range req.LairActions {
+                found := false
+                for _, monster := range monsters {
+                        if monster.Index == monsterID {
+                                monster.LairActions = lairActions
+                                found = true
+                                break
+                        }
+                }
+                if !found {
+                        c.JSON(http.StatusBadRequest, gin.H{
+                                "error": "Lair actions given for a monster that isn't in the combat",
+                                "monster_id": monsterID,
+                        })
+                        return
+                }
+        }
+
         // Create combat session
-        combat, err := h.service.CreateCombat(characters, monsters, req.Environment, userID.(string))
+        setup := InitiativeSetup{Surprised: req.Surprised, Entered: req.Initiative}
+        combat, err := h.service.CreateCombat(characters, monsters, req.Environment, userID.(string), setup)
+        if errors.Is(err, ErrUnknownCombatant) {
+                c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid initiative setup", "details": err.Error()})
+                return
+        }
         if err != nil {
                 c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create combat session"})
                 return
         }
 
+        if req.AutoMonsters {
+                if err := h.service.SetAutomation(combat, &req.AutoMonsters, nil); err != nil {
+                        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create combat session"})
+                        return
+                }
+        }
+
         // Broadcast combat state to websocket clients
         h.wsHub.BroadcastToRoom(combat.ID, websocket.Message{
                 Type: "combat_initiated",
                 Data: combat,
         })
 
+        // A monster may have won initiative
+        if h.service.IsAutomatedTurn(combat) {
+                go h.playMonsterTurns(combat.ID)
+        }
+
         c.JSON(http.StatusCreated, combat)
 }
 
@@ -144,14 +194,58 @@ func (h *Handler) GetCombat(c *gin.Context) {
         c.JSON(http.StatusOK, combat)
 }
 
+// GetRolls returns the roll ledger of a combat, so players can check every die once the
+// combat is over and the dice seed is revealed
+func (h *Handler) GetRolls(c *gin.Context) {
+        id := c.Param("id")
+        if id == "" {
+                c.JSON(http.StatusBadRequest, gin.H{"error": "Combat ID is required"})
+                return
+        }
+
+        // Get user ID from context (set by auth middleware)
+        userID, exists := c.Get("userID")
+        if !exists {
+                c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+                return
+        }
+
+        // Get combat session
+        combat, err := h.service.GetCombat(id)
+        if err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve combat session"})
+                return
+        }
+
+        if combat == nil {
+                c.JSON(http.StatusNotFound, gin.H{"error": "Combat session not found"})
+                return
+        }
+
+        if !h.service.IsUserInCombat(combat, userID.(string)) {
+                c.JSON(http.StatusForbidden, gin.H{"error": "You don't have access to this combat session"})
+                return
+        }
+
+        ledger, err := h.service.GetRollLedger(combat)
+        if err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve roll ledger", "details": err.Error()})
+                return
+        }
+
+        c.JSON(http.StatusOK, ledger)
+}
+
 // CombatActionRequest represents the request body for a combat action
 type CombatActionRequest struct {
         ActionType   string                 `json:"action_type" binding:"required"`
         ActorID      string                 `json:"actor_id" binding:"required"`
         TargetIDs    []string               `json:"target_ids"`
         SpellID      string                 `json:"spell_id"`
+        SlotLevel    int                    `json:"slot_level"`
         WeaponName   string                 `json:"weapon_name"`
         MovementPath [][2]int               `json:"movement_path"`
+        Area         *models.AreaTemplate   `json:"area"`
         ExtraData    map[string]interface{} `json:"extra_data"`
 }
 
@@ -176,6 +270,12 @@ func (h *Handler) PerformAction(c *gin.Context) {
                 return
         }
 
+        unlock, ok := h.lockCombat(c)
+        if !ok {
+                return
+        }
+        defer unlock()
+
         // Get combat session
         combat, err := h.service.GetCombat(id)
         if err != nil {
@@ -188,8 +288,14 @@ func (h *Handler) PerformAction(c *gin.Context) {
                 return
         }
 
-        // Check if it's the actor's turn
-        if !h.service.IsActorsTurn(combat, req.ActorID) {
+        // Nobody else acts while the monsters' turns play out
+        if h.service.IsPlayingMonsterTurns(id) {
+                c.JSON(http.StatusConflict, gin.H{"error": "Monster turns are being played automatically"})
+                return
+        }
+
+        // Check if it's the actor's turn. Legendary actions are taken on other creatures' turns.
+        if req.ActionType != "legendary_action" && !h.service.IsActorsTurn(combat, req.ActorID) {
                 c.JSON(http.StatusBadRequest, gin.H{"error": "It's not this actor's turn"})
                 return
         }
@@ -207,8 +313,10 @@ func (h *Handler) PerformAction(c *gin.Context) {
                 ActorID:      req.ActorID,
                 TargetIDs:    req.TargetIDs,
                 SpellID:      req.SpellID,
+                SlotLevel:    req.SlotLevel,
                 WeaponName:   req.WeaponName,
                 MovementPath: req.MovementPath,
+                Area:         req.Area,
                 ExtraData:    req.ExtraData,
         })
 
@@ -224,6 +332,12 @@ func (h *Handler) PerformAction(c *gin.Context) {
                 return
         }
 
+        // Spell slots used in the fight carry over to the characters once it ends
+        if err := h.saveCharacterResources(combat); err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save character spell slots"})
+                return
+        }
+
         // Broadcast updated combat state to websocket clients
         h.wsHub.BroadcastToRoom(combat.ID, websocket.Message{
                 Type: "combat_updated",
@@ -268,6 +382,12 @@ func (h *Handler) EndTurn(c *gin.Context) {
                 return
         }
 
+        unlock, ok := h.lockCombat(c)
+        if !ok {
+                return
+        }
+        defer unlock()
+
         // Get combat session
         combat, err := h.service.GetCombat(id)
         if err != nil {
@@ -280,6 +400,18 @@ func (h *Handler) EndTurn(c *gin.Context) {
                 return
         }
 
+        // Nobody else acts while the monsters' turns play out
+        if h.service.IsPlayingMonsterTurns(id) {
+                c.JSON(http.StatusConflict, gin.H{"error": "Monster turns are being played automatically"})
+                return
+        }
+
+        // Turns don't pass while the combat is paused or over
+        if combat.Status != "active" {
+                c.JSON(http.StatusBadRequest, gin.H{"error": "Combat is not active"})
+                return
+        }
+
         // Check if it's the actor's turn
         if !h.service.IsActorsTurn(combat, req.ActorID) {
                 c.JSON(http.StatusBadRequest, gin.H{"error": "It's not this actor's turn"})
@@ -293,7 +425,8 @@ func (h *Handler) EndTurn(c *gin.Context) {
         }
 
         // End the turn
-        if err := h.service.EndTurn(combat); err != nil {
+        events, err := h.service.EndTurn(combat)
+        if err != nil {
                 c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to end turn"})
                 return
         }
@@ -305,23 +438,54 @@ func (h *Handler) EndTurn(c *gin.Context) {
                 return
         }
 
+        // Spell slots used in the fight carry over to the characters once it ends
+        if err := h.saveCharacterResources(combat); err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save character spell slots"})
+                return
+        }
+
         // Broadcast updated combat state to websocket clients
         h.wsHub.BroadcastToRoom(combat.ID, websocket.Message{
                 Type: "combat_updated",
                 Data: combat,
         })
 
+        // Also broadcast anything that happened at the start of the new turn
+        if len(events) > 0 {
+                h.wsHub.BroadcastToRoom(combat.ID, websocket.Message{
+                        Type: "turn_events",
+                        Data: events,
+                })
+        }
+
+        // Play the turns of automated monsters that are next in the initiative order
+        if h.service.IsAutomatedTurn(combat) {
+                go h.playMonsterTurns(combat.ID)
+        }
+
         c.JSON(http.StatusOK, combat)
 }
 
-// WebSocketHandler handles websocket connections for a specific combat
-func (h *Handler) WebSocketHandler(c *gin.Context) {
+// AutomationRequest turns automated monster turns on or off
+type AutomationRequest struct {
+        AutoMonsters *bool           `json:"auto_monsters"` // Every monster in the combat
+        Monsters     map[string]bool `json:"monsters"`      // Individual monsters by combatant ID
+}
+
+// SetAutomation lets the DM have the server play monster turns
+func (h *Handler) SetAutomation(c *gin.Context) {
         id := c.Param("id")
         if id == "" {
                 c.JSON(http.StatusBadRequest, gin.H{"error": "Combat ID is required"})
                 return
         }
 
+        var req AutomationRequest
+        if err := c.ShouldBindJSON(&req); err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+                return
+        }
+
         // Get user ID from context (set by auth middleware)
         userID, exists := c.Get("userID")
         if !exists {
@@ -329,6 +493,12 @@ func (h *Handler) WebSocketHandler(c *gin.Context) {
                 return
         }
 
+        unlock, ok := h.lockCombat(c)
+        if !ok {
+                return
+        }
+        defer unlock()
+
         // Get combat session
         combat, err := h.service.GetCombat(id)
         if err != nil {
@@ -341,12 +511,797 @@ func (h *Handler) WebSocketHandler(c *gin.Context) {
                 return
         }
 
-        // Check if user is involved in the combat
-        if !h.service.IsUserInCombat(combat, userID.(string)) {
-                c.JSON(http.StatusForbidden, gin.H{"error": "You don't have access to this combat session"})
+        // Only the DM decides who plays the monsters
+        if combat.DMUserID != userID.(string) {
+                c.JSON(http.StatusForbidden, gin.H{"error": "Only the DM can change monster automation"})
                 return
         }
 
-        // Upgrade connection to websocket
-        h.wsHub.ServeWs(c.Writer, c.Request, id, userID.(string))
+        if err := h.service.SetAutomation(combat, req.AutoMonsters, req.Monsters); err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to change monster automation", "details": err.Error()})
+                return
+        }
+
+        // Broadcast updated combat state to websocket clients
+        h.wsHub.BroadcastToRoom(combat.ID, websocket.Message{
+                Type: "combat_updated",
+                Data: combat,
+        })
+
+        // Take over at once if a monster that is now automated is taking its turn
+        if h.service.IsAutomatedTurn(combat) {
+                go h.playMonsterTurns(combat.ID)
+        }
+
+        c.JSON(http.StatusOK, combat)
+}
+
+// EnterInitiativeRequest is an initiative total rolled with physical dice
+type EnterInitiativeRequest struct {
+        ActorID    string `json:"actor_id" binding:"required"`
+        Initiative *int   `json:"initiative" binding:"required"`
+}
+
+// EnterInitiative lets a player enter the initiative they rolled for a combatant they
+// control, for the DM to approve. Initiative the DM enters takes effect at once.
+func (h *Handler) EnterInitiative(c *gin.Context) {
+        var req EnterInitiativeRequest
+        if err := c.ShouldBindJSON(&req); err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+                return
+        }
+
+        unlock, ok := h.lockCombat(c)
+        if !ok {
+                return
+        }
+        defer unlock()
+
+        combat, userID, ok := h.combatForUser(c)
+        if !ok {
+                return
+        }
+
+        isDM := combat.DMUserID == userID
+        if !isDM && !h.service.UserControlsActor(combat, userID, req.ActorID) {
+                c.JSON(http.StatusForbidden, gin.H{"error": "You don't control this actor"})
+                return
+        }
+
+        if h.service.IsPlayingMonsterTurns(combat.ID) {
+                c.JSON(http.StatusConflict, gin.H{"error": "Monster turns are being played automatically"})
+                return
+        }
+
+        if err := h.service.EnterInitiative(combat, req.ActorID, *req.Initiative, isDM); err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to enter initiative", "details": err.Error()})
+                return
+        }
+
+        h.wsHub.BroadcastToRoom(combat.ID, websocket.Message{
+                Type: "combat_updated",
+                Data: combat,
+        })
+
+        c.JSON(http.StatusOK, combat)
+}
+
+// InitiativeReviewRequest approves or rejects the initiative a player entered
+type InitiativeReviewRequest struct {
+        Approve bool `json:"approve"`
+}
+
+// ReviewInitiative lets the DM approve or reject the initiative a player entered
+func (h *Handler) ReviewInitiative(c *gin.Context) {
+        var req InitiativeReviewRequest
+        if err := c.ShouldBindJSON(&req); err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+                return
+        }
+
+        unlock, ok := h.lockCombat(c)
+        if !ok {
+                return
+        }
+        defer unlock()
+
+        combat, userID, ok := h.combatForUser(c)
+        if !ok {
+                return
+        }
+
+        if combat.DMUserID != userID {
+                c.JSON(http.StatusForbidden, gin.H{"error": "Only the DM can approve initiative"})
+                return
+        }
+
+        if h.service.IsPlayingMonsterTurns(combat.ID) {
+                c.JSON(http.StatusConflict, gin.H{"error": "Monster turns are being played automatically"})
+                return
+        }
+
+        if err := h.service.ReviewInitiative(combat, c.Param("actor_id"), req.Approve); err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to review initiative", "details": err.Error()})
+                return
+        }
+
+        h.wsHub.BroadcastToRoom(combat.ID, websocket.Message{
+                Type: "combat_updated",
+                Data: combat,
+        })
+
+        c.JSON(http.StatusOK, combat)
+}
+
+// ReorderInitiativeRequest is a new initiative order
+type ReorderInitiativeRequest struct {
+        Order []string `json:"order" binding:"required"` // Every combatant's ID, from first to last
+}
+
+// ReorderInitiative lets the DM change the initiative order in the middle of a combat
+func (h *Handler) ReorderInitiative(c *gin.Context) {
+        var req ReorderInitiativeRequest
+        if err := c.ShouldBindJSON(&req); err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+                return
+        }
+
+        unlock, ok := h.lockCombat(c)
+        if !ok {
+                return
+        }
+        defer unlock()
+
+        combat, userID, ok := h.combatForUser(c)
+        if !ok {
+                return
+        }
+
+        if combat.DMUserID != userID {
+                c.JSON(http.StatusForbidden, gin.H{"error": "Only the DM can reorder initiative"})
+                return
+        }
+
+        if h.service.IsPlayingMonsterTurns(combat.ID) {
+                c.JSON(http.StatusConflict, gin.H{"error": "Monster turns are being played automatically"})
+                return
+        }
+
+        if err := h.service.ReorderInitiative(combat, req.Order); err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to reorder initiative", "details": err.Error()})
+                return
+        }
+
+        h.wsHub.BroadcastToRoom(combat.ID, websocket.Message{
+                Type: "combat_updated",
+                Data: combat,
+        })
+
+        c.JSON(http.StatusOK, combat)
+}
+
+// AddCombatantRequest is a character or monster joining a running combat. Exactly one of
+// character_id, monster_id and monster is given.
+type AddCombatantRequest struct {
+        CharacterID string          `json:"character_id"`
+        MonsterID   string          `json:"monster_id"` // SRD monster index
+        Monster     *models.Monster `json:"monster"`    // A custom monster made up by the DM
+        Position    *[2]int         `json:"position"`   // The first free square on their side otherwise
+        Initiative  *int            `json:"initiative"` // Initiative rolled with physical dice, rolled by the server otherwise
+}
+
+// AddCombatant lets the DM bring a character or monster into a running combat
+func (h *Handler) AddCombatant(c *gin.Context) {
+        var req AddCombatantRequest
+        if err := c.ShouldBindJSON(&req); err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+                return
+        }
+
+        unlock, ok := h.lockCombat(c)
+        if !ok {
+                return
+        }
+        defer unlock()
+
+        combat, userID, ok := h.combatForUser(c)
+        if !ok {
+                return
+        }
+
+        if combat.DMUserID != userID {
+                c.JSON(http.StatusForbidden, gin.H{"error": "Only the DM can add combatants"})
+                return
+        }
+
+        if h.service.IsPlayingMonsterTurns(combat.ID) {
+                c.JSON(http.StatusConflict, gin.H{"error": "Monster turns are being played automatically"})
+                return
+        }
+
+        arrival := Arrival{Position: req.Position, Initiative: req.Initiative}
+        switch {
+        case req.CharacterID != "":
+                character, err := h.characterSvc.GetByID(req.CharacterID)
+                if err != nil {
+                        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve character"})
+                        return
+                }
+                if character == nil {
+                        c.JSON(http.StatusNotFound, gin.H{"error": "Character not found"})
+                        return
+                }
+                // Like at the start of the combat, the DM can bring in their own characters,
+                // and those of players who are already in the fight
+                if !h.service.IsUserInCombat(combat, character.UserID) {
+                        c.JSON(http.StatusForbidden, gin.H{"error": "You don't have permission to use this character"})
+                        return
+                }
+                arrival.Character = character
+        case req.MonsterID != "":
+                monster, err := h.srdClient.GetMonster(req.MonsterID)
+                if err != nil {
+                        c.JSON(http.StatusInternalServerError, gin.H{
+                                "error": "Failed to fetch monster data",
+                                "details": err.Error(),
+                                "monster_id": req.MonsterID,
+                        })
+                        return
+                }
+                arrival.Monster = monster
+        case req.Monster != nil:
+                arrival.Monster = req.Monster
+                arrival.Custom = true
+        }
+
+        combatant, event, err := h.service.AddCombatant(combat, arrival)
+        if err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to add combatant", "details": err.Error()})
+                return
+        }
+
+        h.wsHub.BroadcastToRoom(combat.ID, websocket.Message{
+                Type: "combatant_added",
+                Data: gin.H{"combatant": combatant, "event": event},
+        })
+        h.wsHub.BroadcastToRoom(combat.ID, websocket.Message{
+                Type: "combat_updated",
+                Data: combat,
+        })
+
+        c.JSON(http.StatusCreated, combat)
+}
+
+// RemoveCombatant lets the DM take a combatant out of a running combat
+func (h *Handler) RemoveCombatant(c *gin.Context) {
+        unlock, ok := h.lockCombat(c)
+        if !ok {
+                return
+        }
+        defer unlock()
+
+        combat, userID, ok := h.combatForUser(c)
+        if !ok {
+                return
+        }
+
+        if combat.DMUserID != userID {
+                c.JSON(http.StatusForbidden, gin.H{"error": "Only the DM can remove combatants"})
+                return
+        }
+
+        h.removeCombatant(c, combat, false)
+}
+
+// FleeCombat lets a combatant flee the battle, leaving the combat. A player's combatant
+// flees on their own turn; the DM can have anyone flee at any time.
+func (h *Handler) FleeCombat(c *gin.Context) {
+        unlock, ok := h.lockCombat(c)
+        if !ok {
+                return
+        }
+        defer unlock()
+
+        combat, userID, ok := h.combatForUser(c)
+        if !ok {
+                return
+        }
+
+        actorID := c.Param("combatant_id")
+        if combat.DMUserID != userID {
+                if !h.service.UserControlsActor(combat, userID, actorID) {
+                        c.JSON(http.StatusForbidden, gin.H{"error": "You don't control this actor"})
+                        return
+                }
+                if !h.service.IsActorsTurn(combat, actorID) {
+                        c.JSON(http.StatusBadRequest, gin.H{"error": "It's not this actor's turn"})
+                        return
+                }
+        }
+
+        h.removeCombatant(c, combat, true)
+}
+
+// removeCombatant takes the combatant of the request's path out of the combat, saving a
+// character's hit points and resources back to them, and broadcasts the change
+func (h *Handler) removeCombatant(c *gin.Context, combat *models.Combat, fled bool) {
+        if h.service.IsPlayingMonsterTurns(combat.ID) {
+                c.JSON(http.StatusConflict, gin.H{"error": "Monster turns are being played automatically"})
+                return
+        }
+
+        removed, events, err := h.service.RemoveCombatant(combat, c.Param("combatant_id"), fled)
+        if errors.Is(err, ErrUnknownCombatant) {
+                c.JSON(http.StatusNotFound, gin.H{"error": "Combatant not found", "details": err.Error()})
+                return
+        }
+        if err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to remove combatant", "details": err.Error()})
+                return
+        }
+
+        // A character leaving the fight keeps what they have left, like the rest do once it ends
+        if err := h.saveCharacter(removed); err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save character spell slots"})
+                return
+        }
+        if err := h.saveCharacterResources(combat); err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save character spell slots"})
+                return
+        }
+
+        h.wsHub.BroadcastToRoom(combat.ID, websocket.Message{
+                Type: "combatant_removed",
+                Data: gin.H{"combatant": removed, "fled": fled},
+        })
+        h.wsHub.BroadcastToRoom(combat.ID, websocket.Message{
+                Type: "combat_updated",
+                Data: combat,
+        })
+        h.wsHub.BroadcastToRoom(combat.ID, websocket.Message{
+                Type: "turn_events",
+                Data: events,
+        })
+
+        // It may have been their turn, passing it on to an automated monster
+        if h.service.IsAutomatedTurn(combat) {
+                go h.playMonsterTurns(combat.ID)
+        }
+
+        c.JSON(http.StatusOK, combat)
+}
+
+// DelayTurnRequest puts off the current turn to a lower initiative count
+type DelayTurnRequest struct {
+        ActorID    string `json:"actor_id" binding:"required"`
+        Initiative *int   `json:"initiative" binding:"required"` // Initiative count to take the turn on
+}
+
+// DelayTurn lets the combatant whose turn it is delay it to a later initiative count
+func (h *Handler) DelayTurn(c *gin.Context) {
+        var req DelayTurnRequest
+        if err := c.ShouldBindJSON(&req); err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+                return
+        }
+
+        unlock, ok := h.lockCombat(c)
+        if !ok {
+                return
+        }
+        defer unlock()
+
+        combat, userID, ok := h.combatForUser(c)
+        if !ok {
+                return
+        }
+
+        if h.service.IsPlayingMonsterTurns(combat.ID) {
+                c.JSON(http.StatusConflict, gin.H{"error": "Monster turns are being played automatically"})
+                return
+        }
+
+        if !h.service.UserControlsActor(combat, userID, req.ActorID) {
+                c.JSON(http.StatusForbidden, gin.H{"error": "You don't control this actor"})
+                return
+        }
+
+        events, err := h.service.DelayTurn(combat, req.ActorID, *req.Initiative)
+        if err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to delay turn", "details": err.Error()})
+                return
+        }
+
+        h.wsHub.BroadcastToRoom(combat.ID, websocket.Message{
+                Type: "turn_delayed",
+                Data: gin.H{"actor_id": req.ActorID, "initiative": *req.Initiative},
+        })
+        h.wsHub.BroadcastToRoom(combat.ID, websocket.Message{
+                Type: "combat_updated",
+                Data: combat,
+        })
+        h.wsHub.BroadcastToRoom(combat.ID, websocket.Message{
+                Type: "turn_events",
+                Data: events,
+        })
+
+        // Play the turns of automated monsters that are next in the initiative order
+        if h.service.IsAutomatedTurn(combat) {
+                go h.playMonsterTurns(combat.ID)
+        }
+
+        c.JSON(http.StatusOK, combat)
+}
+
+// OverrideCombatantRequest is a change the DM makes to a combatant by fiat. Fields left out
+// are unchanged.
+type OverrideCombatantRequest struct {
+        HP               *int               `json:"hp"`
+        HPChange         *int               `json:"hp_change"`   // Damage when negative, healing when positive
+        DamageType       string             `json:"damage_type"` // Type of the damage of a negative hp_change
+        TempHP           *int               `json:"temp_hp"`
+        AC               *int               `json:"ac"`
+        Position         *[2]int            `json:"position"`
+        AddConditions    []models.Condition `json:"add_conditions"`
+        RemoveConditions []string           `json:"remove_conditions"`
+}
+
+// OverrideCombatant lets the DM set or adjust a combatant's hit points, armor class and
+// conditions, or teleport them
+func (h *Handler) OverrideCombatant(c *gin.Context) {
+        var req OverrideCombatantRequest
+        if err := c.ShouldBindJSON(&req); err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+                return
+        }
+
+        unlock, ok := h.lockCombat(c)
+        if !ok {
+                return
+        }
+        defer unlock()
+
+        combat, ok := h.overrideCombat(c)
+        if !ok {
+                return
+        }
+
+        event, err := h.service.OverrideCombatant(combat, c.Param("combatant_id"), Override{
+                HP:               req.HP,
+                HPChange:         req.HPChange,
+                DamageType:       req.DamageType,
+                TempHP:           req.TempHP,
+                AC:               req.AC,
+                Position:         req.Position,
+                AddConditions:    req.AddConditions,
+                RemoveConditions: req.RemoveConditions,
+        })
+        if errors.Is(err, ErrUnknownCombatant) {
+                c.JSON(http.StatusNotFound, gin.H{"error": "Combatant not found", "details": err.Error()})
+                return
+        }
+        if err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to override combatant", "details": err.Error()})
+                return
+        }
+
+        h.broadcastOverride(c, combat, []*models.CombatAction{event})
+}
+
+// OverrideTurnRequest moves the turn forward or back
+type OverrideTurnRequest struct {
+        Action string `json:"action" binding:"required,oneof=skip rewind"`
+}
+
+// OverrideTurn lets the DM skip the current turn or rewind to the previous one
+func (h *Handler) OverrideTurn(c *gin.Context) {
+        var req OverrideTurnRequest
+        if err := c.ShouldBindJSON(&req); err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+                return
+        }
+
+        unlock, ok := h.lockCombat(c)
+        if !ok {
+                return
+        }
+        defer unlock()
+
+        combat, ok := h.overrideCombat(c)
+        if !ok {
+                return
+        }
+
+        events, err := h.service.OverrideTurn(combat, req.Action == "rewind")
+        if err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to override turn", "details": err.Error()})
+                return
+        }
+
+        h.broadcastOverride(c, combat, events)
+}
+
+// OverrideStatusRequest pauses, resumes or ends a combat
+type OverrideStatusRequest struct {
+        Status string `json:"status" binding:"required,oneof=active paused ended fled"`
+}
+
+// OverrideStatus lets the DM pause, resume or end a combat
+func (h *Handler) OverrideStatus(c *gin.Context) {
+        var req OverrideStatusRequest
+        if err := c.ShouldBindJSON(&req); err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+                return
+        }
+
+        unlock, ok := h.lockCombat(c)
+        if !ok {
+                return
+        }
+        defer unlock()
+
+        combat, ok := h.overrideCombat(c)
+        if !ok {
+                return
+        }
+
+        event, err := h.service.OverrideStatus(combat, req.Status)
+        if err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to override combat status", "details": err.Error()})
+                return
+        }
+
+        h.broadcastOverride(c, combat, []*models.CombatAction{event})
+}
+
+// overrideCombat retrieves the combat of the request's path for an override, which only
+// its DM can make. When the override can't be made it writes the error response and
+// returns false.
+func (h *Handler) overrideCombat(c *gin.Context) (*models.Combat, bool) {
+        combat, userID, ok := h.combatForUser(c)
+        if !ok {
+                return nil, false
+        }
+
+        if combat.DMUserID != userID {
+                c.JSON(http.StatusForbidden, gin.H{"error": "Only the DM can override the combat"})
+                return nil, false
+        }
+
+        if h.service.IsPlayingMonsterTurns(combat.ID) {
+                c.JSON(http.StatusConflict, gin.H{"error": "Monster turns are being played automatically"})
+                return nil, false
+        }
+
+        return combat, true
+}
+
+// broadcastOverride sends the log entries of a DM override and the changed combat to the
+// combat's websocket clients and responds with the combat
+func (h *Handler) broadcastOverride(c *gin.Context, combat *models.Combat, events []*models.CombatAction) {
+        // Characters keep what they have left if the override ended the combat
+        if err := h.saveCharacterResources(combat); err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save character spell slots"})
+                return
+        }
+
+        h.wsHub.BroadcastToRoom(combat.ID, websocket.Message{
+                Type: "dm_override",
+                Data: events,
+        })
+        h.wsHub.BroadcastToRoom(combat.ID, websocket.Message{
+                Type: "combat_updated",
+                Data: combat,
+        })
+
+        // The turn may now be an automated monster's, or the combat resumed on one
+        if h.service.IsAutomatedTurn(combat) {
+                go h.playMonsterTurns(combat.ID)
+        }
+
+        c.JSON(http.StatusOK, combat)
+}
+
+// lockCombat keeps others from changing the combat of the request's path while the request
+// loads, changes and saves it. When the combat is busy it writes the error response and
+// returns false; otherwise the caller defers the returned unlock.
+func (h *Handler) lockCombat(c *gin.Context) (func(), bool) {
+        unlock, err := h.service.LockCombat(c.Param("id"))
+        if err != nil {
+                c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+                return nil, false
+        }
+        return unlock, true
+}
+
+// combatForUser retrieves the combat of the request's path for the authenticated user, who
+// must be in it. When the combat can't be retrieved it writes the error response and
+// returns false.
+func (h *Handler) combatForUser(c *gin.Context) (*models.Combat, string, bool) {
+        id := c.Param("id")
+        if id == "" {
+                c.JSON(http.StatusBadRequest, gin.H{"error": "Combat ID is required"})
+                return nil, "", false
+        }
+
+        // Get user ID from context (set by auth middleware)
+        userID, exists := c.Get("userID")
+        if !exists {
+                c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+                return nil, "", false
+        }
+
+        // Get combat session
+        combat, err := h.service.GetCombat(id)
+        if err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve combat session"})
+                return nil, "", false
+        }
+
+        if combat == nil {
+                c.JSON(http.StatusNotFound, gin.H{"error": "Combat session not found"})
+                return nil, "", false
+        }
+
+        if !h.service.IsUserInCombat(combat, userID.(string)) {
+                c.JSON(http.StatusForbidden, gin.H{"error": "You don't have access to this combat session"})
+                return nil, "", false
+        }
+
+        return combat, userID.(string), true
+}
+
+// playMonsterTurns plays automated monster turns in the background, broadcasting every
+// step like a manual action or end of turn and pausing between steps so players can follow
+func (h *Handler) playMonsterTurns(combatID string) {
+        err := h.service.PlayMonsterTurns(combatID, func(step MonsterStep) {
+                h.wsHub.BroadcastToRoom(combatID, websocket.Message{
+                        Type: "combat_updated",
+                        Data: step.Combat,
+                })
+
+                if step.Result != nil {
+                        h.wsHub.BroadcastToRoom(combatID, websocket.Message{
+                                Type: "action_result",
+                                Data: step.Result,
+                        })
+                }
+
+                if len(step.TurnEvents) > 0 {
+                        h.wsHub.BroadcastToRoom(combatID, websocket.Message{
+                                Type: "turn_events",
+                                Data: step.TurnEvents,
+                        })
+                }
+
+                // Spell slots used in the fight carry over to the characters once it ends
+                if err := h.saveCharacterResources(step.Combat); err != nil {
+                        log.Printf("Failed to save character spell slots for combat %s: %v", combatID, err)
+                }
+
+                time.Sleep(h.monsterStepDelay)
+        })
+        if err != nil {
+                log.Printf("Failed to play monster turns for combat %s: %v", combatID, err)
+        }
+}
+
+// RespondToReaction answers a pending reaction prompt sent over the websocket
+func (h *Handler) RespondToReaction(c *gin.Context) {
+        id := c.Param("id")
+        reactionID := c.Param("reaction_id")
+        if id == "" || reactionID == "" {
+                c.JSON(http.StatusBadRequest, gin.H{"error": "Combat ID and reaction ID are required"})
+                return
+        }
+
+        var req ReactionResponse
+        if err := c.ShouldBindJSON(&req); err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+                return
+        }
+
+        // Get user ID from context (set by auth middleware)
+        userID, exists := c.Get("userID")
+        if !exists {
+                c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+                return
+        }
+
+        // Hand the answer to the action that is waiting on it
+        if err := h.service.AnswerReaction(id, reactionID, userID.(string), req); err != nil {
+                switch {
+                case errors.Is(err, ErrNotReactionController):
+                        c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+                case errors.Is(err, ErrReactionNotFound):
+                        c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+                default:
+                        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to answer reaction"})
+                }
+                return
+        }
+
+        c.JSON(http.StatusOK, gin.H{
+                "reaction_id": reactionID,
+                "accepted":    req.Accept,
+        })
+}
+
+// WebSocketHandler handles websocket connections for a specific combat
+func (h *Handler) WebSocketHandler(c *gin.Context) {
+        id := c.Param("id")
+        if id == "" {
+                c.JSON(http.StatusBadRequest, gin.H{"error": "Combat ID is required"})
+                return
+        }
+
+        // Get user ID from context (set by auth middleware)
+        userID, exists := c.Get("userID")
+        if !exists {
+                c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+                return
+        }
+
+        // Get combat session
+        combat, err := h.service.GetCombat(id)
+        if err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve combat session"})
+                return
+        }
+
+        if combat == nil {
+                c.JSON(http.StatusNotFound, gin.H{"error": "Combat session not found"})
+                return
+        }
+
+        // Check if user is involved in the combat
+        if !h.service.IsUserInCombat(combat, userID.(string)) {
+                c.JSON(http.StatusForbidden, gin.H{"error": "You don't have access to this combat session"})
+                return
+        }
+
+        // Upgrade connection to websocket
+        h.wsHub.ServeWs(c.Writer, c.Request, id, userID.(string))
+}
+
+// saveCharacterResources writes the hit points, exhaustion, spell slots and items characters
+// have left back to them once a combat is over
+func (h *Handler) saveCharacterResources(combat *models.Combat) error {
+        if !combat.IsOver() {
+                return nil
+        }
+
+        for i := range combat.Participants {
+                if err := h.saveCharacter(&combat.Participants[i]); err != nil {
+                        return err
+                }
+        }
+
+        return nil
+}
+
+// saveCharacter writes the hit points, exhaustion, spell slots and items a character has
+// left back to them. Monsters have nothing to save.
+func (h *Handler) saveCharacter(participant *models.Combatant) error {
+        stats, ok := participant.Stats.(*models.Character)
+        if !ok {
+                return nil
+        }
+
+        character, err := h.characterSvc.GetByID(participant.CharacterID)
+        if err != nil {
+                return err
+        }
+        if character == nil {
+                return nil
+        }
+
+        character.HitPoints = participant.HP
+        character.MaxHPReduction = participant.MaxHPReduction
+        character.Exhaustion = exhaustionLevel(participant)
+        character.SpellSlots = stats.SpellSlots
+        character.FeatureUses = stats.FeatureUses
+        character.Inventory = stats.Inventory
+        character.Equipped = stats.Equipped
+        return h.characterSvc.Update(character)
 }
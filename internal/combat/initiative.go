@@ -0,0 +1,463 @@
+package combat
+
+import (
+        "errors"
+        "fmt"
+        "sort"
+        "strings"
+
+        "dnd-combat/internal/models"
+)
+
+// ErrUnknownCombatant is returned when a combat is set up with surprise or initiative for
+// a combatant who isn't in it
+var ErrUnknownCombatant = errors.New("combatant isn't in the combat")
+
+// InitiativeSetup is what the DM decides about initiative as a combat starts. Combatants are
+// given by character ID, by monster combatant ID like "monster_goblin_0" (the monster's
+// index and its place in the list of monsters), or by monster index for every monster of
+// that kind.
+type InitiativeSetup struct {
+        Surprised []string       // Combatants who are surprised
+        Entered   map[string]int // Initiative totals rolled with physical dice, rolled by the server otherwise
+}
+
+// setupID checks if an ID of an InitiativeSetup refers to a combatant
+func setupID(id string, combatant *models.Combatant) bool {
+        return id == combatant.ID || (combatant.Type == "monster" && id == combatant.MonsterID)
+}
+
+// checkSetup verifies that the combatants of an InitiativeSetup are in the combat
+func checkSetup(setup InitiativeSetup, participants []*models.Combatant) error {
+        ids := append([]string(nil), setup.Surprised...)
+        for id := range setup.Entered {
+                ids = append(ids, id)
+        }
+        for _, id := range ids {
+                found := false
+                for _, participant := range participants {
+                        if setupID(id, participant) {
+                                found = true
+                                break
+                        }
+                }
+                if !found {
+                        return fmt.Errorf("%w: '%s'", ErrUnknownCombatant, id)
+                }
+        }
+        return nil
+}
+
+// initiativeModifier returns what a combatant adds to initiative rolls: their Dexterity
+// modifier, plus 5 for the Alert feat
+func initiativeModifier(combatant *models.Combatant) int {
+        modifier := abilityModifier(combatant, "dex")
+        if char, ok := combatant.Stats.(*models.Character); ok && char.HasFeat("alert") {
+                modifier += 5
+        }
+        return modifier
+}
+
+// initiativeRollMode collects the advantage and disadvantage on an initiative roll, which is
+// a Dexterity check, along with class features like Feral Instinct
+func initiativeRollMode(combatant *models.Combatant) rollMode {
+        mode := checkRollMode(combatant)
+        if char, ok := combatant.Stats.(*models.Character); ok {
+                for _, feature := range classFeatures {
+                        if feature.Initiative && hasClassFeature(char, feature) {
+                                mode.addAdvantage("%s has %s", combatant.Name, feature.Name)
+                        }
+                }
+        }
+        return mode
+}
+
+// dexterityScore returns a combatant's Dexterity score
+func dexterityScore(combatant *models.Combatant) int {
+        switch stats := combatant.Stats.(type) {
+        case *models.Character:
+                return stats.Dexterity
+        case *models.Monster:
+                return stats.Dexterity
+        }
+        return 10
+}
+
+// rollInitiative rolls initiative for every participant, or takes the total entered for
+// them, and returns the initiative order. Participants are put in the same order.
+func (s *Service) rollInitiative(participants []*models.Combatant, entered map[string]int) []models.InitiativeItem {
+        initiative := make([]models.InitiativeItem, 0, len(participants))
+        for _, participant := range participants {
+                var total *int
+                if value, ok := enteredInitiative(entered, participant); ok {
+                        total = &value
+                }
+                initiative = append(initiative, s.newInitiativeItem(participant, total))
+        }
+
+        s.sortInitiative(initiative)
+
+        places := make(map[string]int, len(initiative))
+        for i, item := range initiative {
+                places[item.ID] = i
+        }
+        sort.SliceStable(participants, func(i, j int) bool {
+                return places[participants[i].ID] < places[participants[j].ID]
+        })
+        return initiative
+}
+
+// newInitiativeItem rolls a combatant's initiative, unless a total rolled with physical
+// dice is given, and returns their initiative entry
+func (s *Service) newInitiativeItem(participant *models.Combatant, entered *int) models.InitiativeItem {
+        if entered != nil {
+                participant.Initiative = *entered
+        } else {
+                // Roll initiative: 1d20 + DEX modifier
+                modifier := initiativeModifier(participant)
+                s.diceRoller.Label("initiative", participant.ID)
+                participant.Initiative = s.rollD20(initiativeRollMode(participant)) + modifier
+                s.diceRoller.AddModifier(modifier)
+        }
+
+        return models.InitiativeItem{
+                ID:          participant.ID,
+                Name:        participant.Name,
+                Initiative:  participant.Initiative,
+                Dexterity:   dexterityScore(participant),
+                IsCharacter: participant.Type == "character",
+        }
+}
+
+// enteredInitiative returns the initiative total entered for a participant, if any
+func enteredInitiative(entered map[string]int, participant *models.Combatant) (int, bool) {
+        if total, ok := entered[participant.ID]; ok {
+                return total, true
+        }
+        if participant.Type == "monster" {
+                total, ok := entered[participant.MonsterID]
+                return total, ok
+        }
+        return 0, false
+}
+
+// sortInitiative sorts an initiative order from highest to lowest. Combatants tied on
+// initiative go in order of Dexterity score, and those still tied roll off unless they
+// already have. A lair loses ties on initiative count 20.
+func (s *Service) sortInitiative(initiative []models.InitiativeItem) {
+        var keys [][2]int
+        tied := make(map[[2]int][]*models.InitiativeItem)
+        for i := range initiative {
+                item := &initiative[i]
+                if item.Lair {
+                        continue
+                }
+                key := [2]int{item.Initiative, item.Dexterity}
+                if _, ok := tied[key]; !ok {
+                        keys = append(keys, key)
+                }
+                tied[key] = append(tied[key], item)
+        }
+
+        for _, key := range keys {
+                group := tied[key]
+                if len(group) < 2 || rolledOff(group) {
+                        continue
+                }
+                for place, item := range s.rollOff(group) {
+                        item.TieBreak = len(group) - place
+                }
+        }
+
+        sort.SliceStable(initiative, func(i, j int) bool {
+                return initiativeBefore(initiative[i], initiative[j])
+        })
+}
+
+// initiativeBefore checks if an initiative entry goes before another
+func initiativeBefore(a, b models.InitiativeItem) bool {
+        switch {
+        case a.Initiative != b.Initiative:
+                return a.Initiative > b.Initiative
+        case a.Lair != b.Lair:
+                return b.Lair
+        case a.Dexterity != b.Dexterity:
+                return a.Dexterity > b.Dexterity
+        }
+        return a.TieBreak > b.TieBreak
+}
+
+// rolledOff checks if a group of tied combatants already has an order from a roll-off
+func rolledOff(group []*models.InitiativeItem) bool {
+        seen := make(map[int]bool, len(group))
+        for _, item := range group {
+                if item.TieBreak == 0 || seen[item.TieBreak] {
+                        return false
+                }
+                seen[item.TieBreak] = true
+        }
+        return true
+}
+
+// rollOff settles a tie by having each tied combatant roll a d20, rolling again among those
+// who tie on the roll. It returns the combatants from the winner down.
+func (s *Service) rollOff(tied []*models.InitiativeItem) []*models.InitiativeItem {
+        if len(tied) < 2 {
+                return tied
+        }
+
+        byRoll := make(map[int][]*models.InitiativeItem)
+        for _, item := range tied {
+                s.diceRoller.Label("initiative roll-off", item.ID)
+                roll := s.diceRoller.Roll(1, 20)
+                byRoll[roll] = append(byRoll[roll], item)
+        }
+
+        ordered := make([]*models.InitiativeItem, 0, len(tied))
+        for roll := 20; roll >= 1; roll-- {
+                ordered = append(ordered, s.rollOff(byRoll[roll])...)
+        }
+        return ordered
+}
+
+// keepTurn makes a change to a combat's initiative order, keeping the turn with the
+// combatant whose turn it is
+func keepTurn(combat *models.Combat, change func()) {
+        var current models.InitiativeItem
+        if item := currentInitiative(combat); item != nil {
+                current = *item
+        }
+
+        change()
+
+        for i, item := range combat.Initiative {
+                if item.ID == current.ID && item.Lair == current.Lair {
+                        combat.CurrentTurnIndex = i
+                        return
+                }
+        }
+}
+
+// initiativeEntry returns the initiative entry of a combatant's own turn
+func initiativeEntry(combat *models.Combat, combatantID string) *models.InitiativeItem {
+        for i := range combat.Initiative {
+                if item := &combat.Initiative[i]; item.ID == combatantID && !item.Lair {
+                        return item
+                }
+        }
+        return nil
+}
+
+// applySurprise makes the participants surprised that the DM says are, except characters
+// with the Alert feat, and returns their names. A surprised combatant loses their first
+// turn and can't react until it's over.
+func applySurprise(participants []*models.Combatant, surprised []string) []string {
+        var names []string
+        for _, participant := range participants {
+                for _, id := range surprised {
+                        if !setupID(id, participant) {
+                                continue
+                        }
+                        if char, ok := participant.Stats.(*models.Character); ok && char.HasFeat("alert") {
+                                break
+                        }
+                        participant.Conditions = append(participant.Conditions, models.Condition{
+                                Name:      "surprised",
+                                Duration:  1,
+                                EndOfTurn: true,
+                        })
+                        names = append(names, participant.Name)
+                        break
+                }
+        }
+        return names
+}
+
+// hasFeralInstinct checks if a combatant is a barbarian who can act while surprised by
+// entering their rage first
+func hasFeralInstinct(combatant *models.Combatant) bool {
+        char, ok := combatant.Stats.(*models.Character)
+        if !ok || isIncapacitated(combatant) {
+                return false
+        }
+        feature, err := lookupClassFeature(char, "feral-instinct")
+        return err == nil && hasClassFeature(char, feature)
+}
+
+// validateSurprise checks that a surprised actor can take an action. Only a lair acts for a
+// surprised creature, and a barbarian with Feral Instinct must enter their rage first.
+func validateSurprise(action *models.CombatAction, actor *models.Combatant) error {
+        if !actor.HasCondition("surprised") || action.Type == "lair_action" {
+                return nil
+        }
+        if feature, _ := action.ExtraData["feature"].(string); action.Type == "use_feature" &&
+                strings.EqualFold(feature, "rage") && hasFeralInstinct(actor) {
+                return nil
+        }
+        return errors.New("actor is surprised and can't act until their first turn is over")
+}
+
+// skipSurprisedTurns ends the turns surprised combatants lose as soon as they start, until
+// it's the turn of a combatant who can act, and returns what happened on them
+func (s *Service) skipSurprisedTurns(combat *models.Combat) []*models.CombatAction {
+        var events []*models.CombatAction
+        for {
+                item := currentInitiative(combat)
+                if item == nil || item.Lair {
+                        return events
+                }
+                actor := s.getCombatant(combat, item.ID)
+                if actor == nil || !actor.HasCondition("surprised") {
+                        return events
+                }
+                if hasFeralInstinct(actor) {
+                        return append(events, s.turnEvent(combat, actor, "surprised",
+                                fmt.Sprintf("%s is surprised, but Feral Instinct lets them act if they rage first.", actor.Name)))
+                }
+
+                events = append(events, s.turnEvent(combat, actor, "surprised",
+                        fmt.Sprintf("%s is surprised and loses their turn.", actor.Name)))
+                events = append(events, s.nextTurn(combat)...)
+        }
+}
+
+// EnterInitiative records an initiative total rolled with physical dice for a combatant.
+// The DM's entries take effect at once, re-sorting the initiative order; a player's entry
+// waits for the DM to approve it.
+func (s *Service) EnterInitiative(combat *models.Combat, combatantID string, total int, byDM bool) error {
+        if combat.Status != "active" {
+                return errors.New("combat is not active")
+        }
+        s, err := s.forCombat(combat)
+        if err != nil {
+                return err
+        }
+
+        item := initiativeEntry(combat, combatantID)
+        if item == nil {
+                return errors.New("combatant isn't in the initiative order")
+        }
+
+        if !byDM {
+                item.Proposed = &total
+                return s.repo.Update(combat)
+        }
+        return s.setInitiative(combat, combatantID, total, "The DM sets")
+}
+
+// ReviewInitiative approves or rejects the initiative a player entered for a combatant
+func (s *Service) ReviewInitiative(combat *models.Combat, combatantID string, approve bool) error {
+        if combat.Status != "active" {
+                return errors.New("combat is not active")
+        }
+        s, err := s.forCombat(combat)
+        if err != nil {
+                return err
+        }
+
+        item := initiativeEntry(combat, combatantID)
+        if item == nil {
+                return errors.New("combatant isn't in the initiative order")
+        }
+        if item.Proposed == nil {
+                return errors.New("no initiative was entered for this combatant")
+        }
+
+        total := *item.Proposed
+        item.Proposed = nil
+        if !approve {
+                return s.repo.Update(combat)
+        }
+        return s.setInitiative(combat, combatantID, total, "The DM approves")
+}
+
+// setInitiative changes a combatant's initiative and re-sorts the initiative order, which
+// undoes any reordering by the DM, and logs the change
+func (s *Service) setInitiative(combat *models.Combat, combatantID string, total int, verb string) error {
+        keepTurn(combat, func() {
+                item := initiativeEntry(combat, combatantID)
+                item.Initiative = total
+                item.TieBreak = 0
+                item.Proposed = nil
+                if combatant := s.getCombatant(combat, combatantID); combatant != nil {
+                        combatant.Initiative = total
+                }
+                s.sortInitiative(combat.Initiative)
+        })
+
+        combat.RollCount = s.diceRoller.Count()
+        if err := s.repo.Update(combat); err != nil {
+                return err
+        }
+        item := initiativeEntry(combat, combatantID)
+        return s.saveTurnEvents(combat, []*models.CombatAction{{
+                ActorID:           combatantID,
+                Type:              "initiative",
+                ResultDescription: fmt.Sprintf("%s an initiative of %d for %s.", verb, total, item.Name),
+                Rolls:             s.diceRoller.TakeRecords(),
+        }})
+}
+
+// ReorderInitiative lets the DM put the combatants in a new initiative order, given by
+// combatant ID. A lair's turn keeps its place, and the combatant whose turn it is keeps it.
+func (s *Service) ReorderInitiative(combat *models.Combat, order []string) error {
+        if combat.Status != "active" {
+                return errors.New("combat is not active")
+        }
+        
+        var turns []models.InitiativeItem
+        lairIndex := -1
+        for i, item := range combat.Initiative {
+                if item.Lair {
+                        lairIndex = i
+                        continue
+                }
+                turns = append(turns, item)
+        }
+        if len(order) != len(turns) {
+                return fmt.Errorf("the order must list all %d combatants once", len(turns))
+        }
+
+        reordered := make([]models.InitiativeItem, 0, len(combat.Initiative))
+        for _, id := range order {
+                found := false
+                for _, item := range turns {
+                        if item.ID == id {
+                                reordered = append(reordered, item)
+                                found = true
+                                break
+                        }
+                }
+                if !found {
+                        return fmt.Errorf("combatant '%s' isn't in the initiative order", id)
+                }
+                for _, item := range reordered[:len(reordered)-1] {
+                        if item.ID == id {
+                                return fmt.Errorf("combatant '%s' is listed more than once", id)
+                        }
+                }
+        }
+        if lairIndex >= 0 {
+                lair := combat.Initiative[lairIndex]
+                reordered = append(reordered[:lairIndex], append([]models.InitiativeItem{lair}, reordered[lairIndex:]...)...)
+        }
+
+        keepTurn(combat, func() {
+                combat.Initiative = reordered
+        })
+
+        if err := s.repo.Update(combat); err != nil {
+                return err
+        }
+        names := make([]string, 0, len(reordered))
+        for _, item := range reordered {
+                if !item.Lair {
+                        names = append(names, item.Name)
+                }
+        }
+        return s.repo.SaveAction(&models.CombatAction{
+                CombatID:          combat.ID,
+                Type:              "initiative",
+                ResultDescription: "The DM reorders initiative: " + strings.Join(names, ", ") + ".",
+        })
+}
@@ -0,0 +1,143 @@
+package combat
+
+import (
+        "encoding/hex"
+        "errors"
+        "fmt"
+        "sort"
+
+        "dnd-combat/internal/models"
+        "dnd-combat/pkg/dnd5e"
+)
+
+// RollLedger lists every die rolled in a combat with the action it was rolled for.
+// The seed commitment is published when the combat starts; the seed itself is only
+// revealed once the combat is over, when the whole ledger can be verified against it.
+type RollLedger struct {
+        CombatID       string                 `json:"combat_id"`
+        SeedCommitment string                 `json:"seed_commitment"`
+        Seed           string                 `json:"seed,omitempty"`
+        RollCount      int                    `json:"roll_count"`
+        Verified       *bool                  `json:"verified,omitempty"`
+        VerifyError    string                 `json:"verify_error,omitempty"`
+        Actions        []*models.CombatAction `json:"actions"`
+}
+
+// GetRollLedger returns the roll ledger of a combat, verified if the seed has been revealed
+func (s *Service) GetRollLedger(combat *models.Combat) (*RollLedger, error) {
+        actions, err := s.repo.GetActionsByCombatID(combat.ID)
+        if err != nil {
+                return nil, err
+        }
+
+        ledger := &RollLedger{
+                CombatID:       combat.ID,
+                SeedCommitment: combat.SeedCommitment,
+                Seed:           combat.Seed,
+                RollCount:      combat.RollCount,
+                Actions:        []*models.CombatAction{},
+        }
+        for _, action := range actions {
+                if len(action.Rolls) > 0 {
+                        ledger.Actions = append(ledger.Actions, action)
+                }
+        }
+
+        // Actions are listed in the order their dice were rolled
+        sort.SliceStable(ledger.Actions, func(i, j int) bool {
+                return firstDieIndex(ledger.Actions[i]) < firstDieIndex(ledger.Actions[j])
+        })
+
+        if ledger.Seed != "" {
+                verified := true
+                if err := verifyLedger(ledger); err != nil {
+                        verified = false
+                        ledger.VerifyError = err.Error()
+                }
+                ledger.Verified = &verified
+        }
+
+        return ledger, nil
+}
+
+// verifyLedger checks that the revealed seed matches its commitment, that every die was
+// rolled from the seed, and that no die is missing from the ledger or appears twice
+func verifyLedger(ledger *RollLedger) error {
+        seed, err := hex.DecodeString(ledger.Seed)
+        if err != nil {
+                return errors.New("the revealed seed is not valid hex")
+        }
+        if dnd5e.SeedCommitment(seed) != ledger.SeedCommitment {
+                return errors.New("the revealed seed does not match the seed commitment")
+        }
+
+        seen := make(map[int]bool, ledger.RollCount)
+        for _, action := range ledger.Actions {
+                if err := dnd5e.VerifyRolls(seed, action.Rolls); err != nil {
+                        return err
+                }
+                for _, record := range action.Rolls {
+                        for _, die := range record.Dice {
+                                if seen[die.Index] {
+                                        return fmt.Errorf("die %d appears more than once in the ledger", die.Index)
+                                }
+                                seen[die.Index] = true
+                        }
+                }
+        }
+
+        for index := 0; index < ledger.RollCount; index++ {
+                if !seen[index] {
+                        return fmt.Errorf("die %d is missing from the ledger", index)
+                }
+        }
+        return nil
+}
+
+// firstDieIndex returns the position of an action's first die in the combat's sequence of dice
+func firstDieIndex(action *models.CombatAction) int {
+        for _, record := range action.Rolls {
+                if len(record.Dice) > 0 {
+                        return record.Dice[0].Index
+                }
+        }
+        return -1
+}
+
+// RollDice rolls a dice expression in a combat outside of any action, like an ability
+// check the DM asks for, and logs it with its dice in the roll ledger
+func (s *Service) RollDice(combat *models.Combat, expression *dnd5e.DiceExpression, actorID, purpose string) (*dnd5e.DiceRollResult, error) {
+        // Once the seed is revealed every later die could be predicted
+        if combat.Status != "active" {
+                return nil, errors.New("combat is over")
+        }
+
+        s, err := s.forCombat(combat)
+        if err != nil {
+                return nil, err
+        }
+
+        if purpose == "" {
+                purpose = "dice roll"
+        }
+        s.diceRoller.Label(purpose, actorID)
+        result := s.diceRoller.RollExpression(expression)
+
+        roll := &models.CombatAction{
+                CombatID:          combat.ID,
+                ActorID:           actorID,
+                Type:              "dice_roll",
+                ResultDescription: fmt.Sprintf("Rolled %s for %s: %d", result.Expression, purpose, result.Total),
+                Rolls:             s.diceRoller.TakeRecords(),
+        }
+        if err := s.repo.SaveAction(roll); err != nil {
+                return nil, err
+        }
+
+        combat.RollCount = s.diceRoller.Count()
+        if err := s.repo.Update(combat); err != nil {
+                return nil, err
+        }
+
+        return result, nil
+}
@@ -0,0 +1,109 @@
+package combat
+
+import (
+        "errors"
+        "sync"
+
+        "dnd-combat/internal/models"
+)
+
+// ErrCombatBusy is returned when a combat can't be changed because an action in it is
+// waiting for a player to answer a reaction prompt
+var ErrCombatBusy = errors.New("combat is waiting for a reaction, try again shortly")
+
+// combatLocks serializes the changes made to each combat, so two requests can't both load
+// a combat, change it and save it, the last to save silently undoing the other's changes
+type combatLocks struct {
+        mu      sync.Mutex
+        changed *sync.Cond
+        combats map[string]*combatLock
+}
+
+// combatLock is the lock of one combat
+type combatLock struct {
+        held    bool
+        waiting bool // The holder is waiting for an answer to a reaction prompt
+        users   int  // The holder and callers waiting for the lock, so unused locks can be dropped
+}
+
+// newCombatLocks creates an empty set of combat locks
+func newCombatLocks() *combatLocks {
+        locks := &combatLocks{combats: make(map[string]*combatLock)}
+        locks.changed = sync.NewCond(&locks.mu)
+        return locks
+}
+
+// lock waits until no one else holds a combat's lock and takes it. Unless waitForReactions
+// is set, it fails with ErrCombatBusy rather than wait out a reaction prompt, which can take
+// up to the reaction timeout.
+func (l *combatLocks) lock(combatID string, waitForReactions bool) error {
+        l.mu.Lock()
+        defer l.mu.Unlock()
+
+        lock := l.combats[combatID]
+        if lock == nil {
+                lock = &combatLock{}
+                l.combats[combatID] = lock
+        }
+        lock.users++
+
+        for lock.held {
+                if lock.waiting && !waitForReactions {
+                        l.release(combatID, lock)
+                        return ErrCombatBusy
+                }
+                l.changed.Wait()
+        }
+        lock.held = true
+        return nil
+}
+
+// unlock gives up a combat's lock
+func (l *combatLocks) unlock(combatID string) {
+        l.mu.Lock()
+        defer l.mu.Unlock()
+
+        lock := l.combats[combatID]
+        lock.held = false
+        l.release(combatID, lock)
+        l.changed.Broadcast()
+}
+
+// release drops a caller's use of a combat's lock, and the lock once nobody uses it
+func (l *combatLocks) release(combatID string, lock *combatLock) {
+        lock.users--
+        if lock.users == 0 {
+                delete(l.combats, combatID)
+        }
+}
+
+// setWaiting marks whether the holder of a combat's lock is waiting for a reaction, turning
+// away the callers waiting for the lock in the meantime
+func (l *combatLocks) setWaiting(combatID string, waiting bool) {
+        l.mu.Lock()
+        defer l.mu.Unlock()
+
+        if lock := l.combats[combatID]; lock != nil {
+                lock.waiting = waiting
+                l.changed.Broadcast()
+        }
+}
+
+// LockCombat keeps everyone else from changing a combat until the returned unlock is
+// called. Callers take it before loading the combat and give it up once the changed combat
+// is saved. While an action in the combat waits for a reaction it fails with ErrCombatBusy.
+func (s *Service) LockCombat(combatID string) (unlock func(), err error) {
+        if err := s.locks.lock(combatID, false); err != nil {
+                return nil, err
+        }
+        return func() { s.locks.unlock(combatID) }, nil
+}
+
+// askReaction prompts a user to take a reaction in a combat whose lock the caller holds,
+// and waits for their answer. The combat stays locked, so nothing can change under the
+// action waiting on the answer, but others trying to change it are turned away at once.
+func (s *Service) askReaction(combat *models.Combat, userID string, prompt ReactionPrompt) ReactionResponse {
+        s.locks.setWaiting(combat.ID, true)
+        defer s.locks.setWaiting(combat.ID, false)
+        return s.reactions.Ask(userID, prompt)
+}
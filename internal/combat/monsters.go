@@ -0,0 +1,474 @@
+package combat
+
+import (
+        "errors"
+        "fmt"
+        "strings"
+
+        "dnd-combat/internal/models"
+)
+
+// Limits on how often monster actions can be used, as named by the SRD
+const (
+        usageRechargeOnRoll    = "recharge on roll"
+        usagePerDay            = "per day"
+        usageRechargeAfterRest = "recharge after rest"
+)
+
+// monsterActionKinds names the kind of monster action each action type uses
+var monsterActionKinds = map[string]string{
+        "attack":           "action",
+        "legendary_action": "legendary action",
+        "lair_action":      "lair action",
+}
+
+// monsterActions returns the legendary actions, lair actions or regular actions a monster
+// can take with an action type
+func monsterActions(monster *models.Monster, actionType string) []models.MonsterAction {
+        switch actionType {
+        case "legendary_action":
+                return monster.LegendaryActions
+        case "lair_action":
+                return monster.LairActions
+        }
+        return monster.Actions
+}
+
+// findMonsterAction looks up one of a monster's actions of an action type by name
+func findMonsterAction(actor *models.Combatant, actionType, name string) *models.MonsterAction {
+        monster, ok := actor.Stats.(*models.Monster)
+        if !ok {
+                return nil
+        }
+
+        actions := monsterActions(monster, actionType)
+        for i := range actions {
+                if strings.EqualFold(actions[i].Name, name) {
+                        return &actions[i]
+                }
+        }
+        return nil
+}
+
+// monsterDamageDice returns every type of damage a monster action deals
+func monsterDamageDice(monsterAction *models.MonsterAction) []models.DamageInfo {
+        var dice []models.DamageInfo
+        for _, info := range append([]models.DamageInfo{monsterAction.Damage}, monsterAction.ExtraDamage...) {
+                if info.DiceCount > 0 || info.Bonus > 0 {
+                        dice = append(dice, info)
+                }
+        }
+        return dice
+}
+
+// isSaveAction reports whether a monster action, like a breath weapon, forces a saving throw
+// instead of making an attack roll
+func isSaveAction(monsterAction *models.MonsterAction) bool {
+        return monsterAction.SaveDC > 0 && monsterAction.AttackBonus == 0
+}
+
+// isAttackAction reports whether a monster action makes an attack roll
+func isAttackAction(monsterAction *models.MonsterAction) bool {
+        return monsterAction.AttackBonus != 0 || len(monsterDamageDice(monsterAction)) > 0
+}
+
+// expandMultiattack lists the attacks a Multiattack action makes, in order
+func expandMultiattack(monsterAction *models.MonsterAction) []string {
+        var attacks []string
+        for _, entry := range monsterAction.Multiattack {
+                for i := 0; i < entry.Count; i++ {
+                        attacks = append(attacks, entry.ActionName)
+                }
+        }
+        return attacks
+}
+
+// multiattackTarget returns the target of the i-th attack of a Multiattack. Attacks go to
+// the targets in order, and any attacks left over go to the last target.
+func multiattackTarget(action *models.CombatAction, i int) string {
+        if i >= len(action.TargetIDs) {
+                i = len(action.TargetIDs) - 1
+        }
+        return action.TargetIDs[i]
+}
+
+// monsterArea returns the area of effect a monster action is taken with, with the shape and
+// size of the action's area. Areas that can't be placed away from the monster, like the
+// cones and lines of breath weapons, start at the monster. It returns nil when the action
+// has no area.
+func monsterArea(action *models.CombatAction, actor *models.Combatant, monsterAction *models.MonsterAction) *models.AreaTemplate {
+        if action.Area == nil {
+                return nil
+        }
+
+        area := *action.Area
+        area.Direction = strings.ToLower(area.Direction)
+        if aoe := monsterAction.Area; aoe != nil {
+                area.Shape = aoe.Type
+                area.Size = aoe.Size
+        }
+        if monsterAction.AreaRange == 0 {
+                area.Origin = actor.Position
+        }
+        return &area
+}
+
+// validateMonsterArea checks the area a monster action is taken with against the action's
+// area, which must be placed within the action's area range
+func validateMonsterArea(combat *models.Combat, action *models.CombatAction, actor *models.Combatant, monsterAction *models.MonsterAction) error {
+        if monsterAction.Area == nil {
+                return fmt.Errorf("%s has no area of effect, give its targets", monsterAction.Name)
+        }
+        if err := checkAreaOfEffect(monsterAction.Name, action.Area, monsterAction.Area); err != nil {
+                return err
+        }
+        area := monsterArea(action, actor, monsterAction)
+        return validateArea(combat, actor, monsterAction.Name, area, monsterAction.AreaRange, true)
+}
+
+// initialActionUses returns the uses a monster starts a combat with for each of its
+// limited actions
+func initialActionUses(monster *models.Monster) map[string]int {
+        uses := make(map[string]int)
+        for _, actions := range [][]models.MonsterAction{monster.Actions, monster.LegendaryActions, monster.LairActions} {
+                for _, monsterAction := range actions {
+                        if monsterAction.Usage != nil {
+                                uses[monsterAction.Name] = maxUses(monsterAction.Usage)
+                        }
+                }
+        }
+
+        if len(uses) == 0 {
+                return nil
+        }
+        return uses
+}
+
+// maxUses returns how many times a limited action can be used before it recharges
+func maxUses(usage *models.ActionUsage) int {
+        if usage.Type == usagePerDay && usage.Times > 0 {
+                return usage.Times
+        }
+        return 1
+}
+
+// usesLeft returns how many more times a combatant can use a monster action.
+// Actions without a limit always have a use left.
+func usesLeft(actor *models.Combatant, monsterAction *models.MonsterAction) int {
+        if monsterAction.Usage == nil {
+                return 1
+        }
+        if uses, ok := actor.ActionUses[monsterAction.Name]; ok {
+                return uses
+        }
+        return maxUses(monsterAction.Usage)
+}
+
+// spendActionUse uses up one use of a limited monster action
+func spendActionUse(actor *models.Combatant, monsterAction *models.MonsterAction) {
+        if monsterAction.Usage == nil {
+                return
+        }
+        if actor.ActionUses == nil {
+                actor.ActionUses = make(map[string]int)
+        }
+        actor.ActionUses[monsterAction.Name] = usesLeft(actor, monsterAction) - 1
+}
+
+// rollRecharges rolls to recharge each spent recharge action of a monster at the start of its turn
+func (s *Service) rollRecharges(actor *models.Combatant) []string {
+        monster, ok := actor.Stats.(*models.Monster)
+        if !ok {
+                return nil
+        }
+
+        var descriptions []string
+        for _, actions := range [][]models.MonsterAction{monster.Actions, monster.LegendaryActions} {
+                for i := range actions {
+                        monsterAction := &actions[i]
+                        if monsterAction.Usage == nil || monsterAction.Usage.Type != usageRechargeOnRoll || usesLeft(actor, monsterAction) > 0 {
+                                continue
+                        }
+
+                        dice := monsterAction.Usage.Dice
+                        if dice == "" {
+                                dice = "1d6"
+                        }
+
+                        s.diceRoller.Label(monsterAction.Name+" recharge", actor.ID)
+                        roll := s.diceRoller.RollDamage(dice)
+                        if roll < monsterAction.Usage.MinValue {
+                                descriptions = append(descriptions, fmt.Sprintf("%s's %s doesn't recharge (rolled %d, needs %d)",
+                                        actor.Name, monsterAction.Name, roll, monsterAction.Usage.MinValue))
+                                continue
+                        }
+
+                        actor.ActionUses[monsterAction.Name] = 1
+                        descriptions = append(descriptions, fmt.Sprintf("%s's %s recharges (rolled %d)",
+                                actor.Name, monsterAction.Name, roll))
+                }
+        }
+        return descriptions
+}
+
+// legendaryActionCost returns the legendary actions a legendary action costs
+func legendaryActionCost(actor *models.Combatant, action *models.CombatAction) int {
+        if monsterAction := findMonsterAction(actor, action.Type, action.WeaponName); monsterAction != nil && monsterAction.Cost > 1 {
+                return monsterAction.Cost
+        }
+        return 1
+}
+
+// addLairInitiative adds a turn on initiative count 20 for the lair of the first monster
+// with lair actions. The lair loses initiative ties.
+func addLairInitiative(initiative []models.InitiativeItem, participants []*models.Combatant) []models.InitiativeItem {
+        for _, participant := range participants {
+                monster, ok := participant.Stats.(*models.Monster)
+                if !ok || len(monster.LairActions) == 0 {
+                        continue
+                }
+
+                lair := models.InitiativeItem{
+                        ID:         participant.ID,
+                        Name:       participant.Name + "'s lair",
+                        Initiative: 20,
+                        Lair:       true,
+                }
+
+                position := len(initiative)
+                for i, item := range initiative {
+                        if item.Initiative < 20 {
+                                position = i
+                                break
+                        }
+                }
+
+                initiative = append(initiative, models.InitiativeItem{})
+                copy(initiative[position+1:], initiative[position:])
+                initiative[position] = lair
+                return initiative
+        }
+        return initiative
+}
+
+// currentInitiative returns the initiative entry whose turn it is
+func currentInitiative(combat *models.Combat) *models.InitiativeItem {
+        if combat.CurrentTurnIndex < 0 || combat.CurrentTurnIndex >= len(combat.Initiative) {
+                return nil
+        }
+        return &combat.Initiative[combat.CurrentTurnIndex]
+}
+
+// validateTurn checks that an actor may act now. Legendary actions are taken on other
+// creatures' turns, and on initiative count 20 only the lair acts.
+func (s *Service) validateTurn(combat *models.Combat, action *models.CombatAction) error {
+        item := currentInitiative(combat)
+
+        switch {
+        case action.Type == "legendary_action":
+                if item != nil && item.ID == action.ActorID && !item.Lair {
+                        return errors.New("legendary actions can only be taken at the end of another creature's turn")
+                }
+        case item != nil && item.Lair:
+                if action.Type != "lair_action" || item.ID != action.ActorID {
+                        return errors.New("only the lair can act on initiative count 20")
+                }
+        case action.Type == "lair_action":
+                return errors.New("lair actions can only be taken on initiative count 20")
+        case !s.IsActorsTurn(combat, action.ActorID):
+                return errors.New("it's not this actor's turn")
+        }
+
+        return nil
+}
+
+// validateMonsterAction checks an action, legendary action or lair action of a monster
+func (s *Service) validateMonsterAction(combat *models.Combat, action *models.CombatAction, actor *models.Combatant) error {
+        kind := monsterActionKinds[action.Type]
+        if action.WeaponName == "" {
+                return fmt.Errorf("a %s requires the name of the action in weapon_name", kind)
+        }
+
+        monsterAction := findMonsterAction(actor, action.Type, action.WeaponName)
+        if monsterAction == nil {
+                return fmt.Errorf("%s has no %s named '%s'", actor.Name, kind, action.WeaponName)
+        }
+
+        if usesLeft(actor, monsterAction) <= 0 {
+                if monsterAction.Usage.Type == usageRechargeOnRoll {
+                        return fmt.Errorf("%s hasn't recharged", monsterAction.Name)
+                }
+                return fmt.Errorf("%s has no uses left", monsterAction.Name)
+        }
+
+        switch {
+        case len(monsterAction.Multiattack) > 0:
+                if len(action.TargetIDs) == 0 {
+                        return errors.New("multiattack requires a target")
+                }
+                for i, name := range expandMultiattack(monsterAction) {
+                        if findMonsterAction(actor, "attack", name) == nil {
+                                return fmt.Errorf("%s has no action named '%s'", actor.Name, name)
+                        }
+                        attack := &models.CombatAction{
+                                Type:       "attack",
+                                WeaponName: name,
+                                TargetIDs:  []string{multiattackTarget(action, i)},
+                        }
+                        if err := s.validateAttack(combat, attack, actor); err != nil {
+                                return err
+                        }
+                }
+
+        case isSaveAction(monsterAction):
+                // Targets can only be picked by hand for actions whose area isn't known
+                if action.Area != nil {
+                        return validateMonsterArea(combat, action, actor, monsterAction)
+                }
+                if monsterAction.Area != nil {
+                        return fmt.Errorf("%s has a %d-foot %s, give its area instead of targets",
+                                monsterAction.Name, monsterAction.Area.Size, monsterAction.Area.Type)
+                }
+                if len(action.TargetIDs) == 0 {
+                        return fmt.Errorf("%s requires targets or an area", monsterAction.Name)
+                }
+
+        case isAttackAction(monsterAction):
+                return s.validateAttack(combat, action, actor)
+        }
+
+        return nil
+}
+
+// processMonsterAction resolves an action, legendary action or lair action of a monster:
+// a Multiattack, an action that forces saving throws, an attack, or an action whose
+// effects are only described
+func (s *Service) processMonsterAction(combat *models.Combat, action *models.CombatAction, actor *models.Combatant) (*models.ActionResult, error) {
+        monsterAction := findMonsterAction(actor, action.Type, action.WeaponName)
+
+        var result *models.ActionResult
+        var err error
+        switch {
+        case len(monsterAction.Multiattack) > 0:
+                result, err = s.processMultiattack(combat, action, actor, monsterAction)
+        case isSaveAction(monsterAction):
+                result, err = s.processSaveAction(combat, action, actor, monsterAction)
+        case isAttackAction(monsterAction):
+                result, err = s.processAttack(combat, action, actor)
+        default:
+                result = &models.ActionResult{
+                        Success:     true,
+                        Description: fmt.Sprintf("%s uses %s. %s", actor.Name, monsterAction.Name, monsterAction.Description),
+                }
+        }
+        if err != nil {
+                return nil, err
+        }
+
+        spendActionUse(actor, monsterAction)
+
+        switch action.Type {
+        case "legendary_action":
+                result.Description = fmt.Sprintf("%s takes a legendary action. %s", actor.Name, result.Description)
+        case "lair_action":
+                result.Description = fmt.Sprintf("%s's lair acts. %s", actor.Name, result.Description)
+        }
+        return result, nil
+}
+
+// processMultiattack makes each of the attacks of a Multiattack action as one action
+func (s *Service) processMultiattack(combat *models.Combat, action *models.CombatAction, actor *models.Combatant, monsterAction *models.MonsterAction) (*models.ActionResult, error) {
+        result := &models.ActionResult{}
+        descriptions := []string{fmt.Sprintf("%s uses %s.", actor.Name, monsterAction.Name)}
+
+        for i, name := range expandMultiattack(monsterAction) {
+                target := s.getCombatant(combat, multiattackTarget(action, i))
+
+                // Attacks aimed at a creature that has already fallen are lost
+                if target.DeathSaves.Dead || (target.HP <= 0 && target.Type != "character") {
+                        descriptions = append(descriptions, fmt.Sprintf("%s's %s has no target, %s is down.", actor.Name, name, target.Name))
+                        continue
+                }
+
+                attack := *action
+                attack.Type = "attack"
+                attack.WeaponName = name
+                attack.TargetIDs = []string{target.ID}
+
+                attackResult, err := s.processAttack(combat, &attack, actor)
+                if err != nil {
+                        return nil, err
+                }
+
+                result.Success = result.Success || attackResult.Success
+                result.Damage += attackResult.Damage
+                result.RawDamage += attackResult.RawDamage
+                if result.DamageType == "" {
+                        result.DamageType = attackResult.DamageType
+                }
+                result.Advantage = append(result.Advantage, attackResult.Advantage...)
+                result.Disadvantage = append(result.Disadvantage, attackResult.Disadvantage...)
+                result.Reactions = append(result.Reactions, attackResult.Reactions...)
+                descriptions = append(descriptions, attackResult.Description)
+        }
+
+        result.Description = strings.Join(descriptions, " ")
+        return result, nil
+}
+
+// processSaveAction resolves a monster action, like a breath weapon, that makes every target
+// roll a saving throw. The damage is rolled once, and a successful save halves or negates it.
+func (s *Service) processSaveAction(combat *models.Combat, action *models.CombatAction, actor *models.Combatant, monsterAction *models.MonsterAction) (*models.ActionResult, error) {
+        result := &models.ActionResult{Success: true}
+
+        var targets []*models.Combatant
+        if area := monsterArea(action, actor, monsterAction); area != nil {
+                result.AffectedCells = areaCells(combat.Battlefield, area)
+                for _, target := range s.combatantsInArea(combat, result.AffectedCells) {
+                        if target.ID != actor.ID {
+                                targets = append(targets, target)
+                        }
+                }
+        } else {
+                for _, targetID := range action.TargetIDs {
+                        targets = append(targets, s.getCombatant(combat, targetID))
+                }
+        }
+
+        s.diceRoller.Label(monsterAction.Name+" damage", actor.ID)
+        damage := s.rollDamage(monsterDamageDice(monsterAction), false)
+        descriptions := []string{fmt.Sprintf("%s uses %s!", actor.Name, monsterAction.Name)}
+        if len(targets) == 0 {
+                descriptions = append(descriptions, "No one is caught in it.")
+        }
+
+        for _, target := range targets {
+                saved, description := s.rollSavingThrow(target, monsterAction.SaveAbility, monsterAction.SaveDC)
+
+                parts := make([]models.TypedDamage, 0, len(damage))
+                for _, part := range damage {
+                        if saved {
+                                if monsterAction.SaveSuccess != "half" {
+                                        continue
+                                }
+                                part.Amount /= 2
+                        }
+                        parts = append(parts, part)
+                }
+
+                if totalDamage(parts) == 0 {
+                        descriptions = append(descriptions, description+".")
+                        continue
+                }
+
+                dealt, damageDescription := s.applyDamage(combat, target, false, parts...)
+                result.RawDamage += totalDamage(parts)
+                result.Damage += dealt
+                result.DamageType = parts[0].Type
+                descriptions = append(descriptions, fmt.Sprintf("%s and takes %s damage. %s",
+                        description, describeDamage(parts), damageDescription))
+        }
+
+        result.Description = strings.Join(descriptions, " ")
+        return result, nil
+}
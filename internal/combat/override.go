@@ -0,0 +1,283 @@
+package combat
+
+import (
+        "errors"
+        "fmt"
+        "strings"
+
+        "dnd-combat/internal/models"
+)
+
+// overrideStatuses are the statuses the DM can give a combat. A paused combat can be made
+// active again; the others end it.
+var overrideStatuses = map[string]bool{
+        "active": true,
+        "paused": true,
+        "ended":  true,
+        "fled":   true,
+}
+
+// Override is a change the DM makes to a combatant by fiat, to fix a mistake or apply
+// something the rules engine doesn't know about. Fields left out are unchanged.
+type Override struct {
+        HP               *int               // Hit points to set, up to the hit point maximum
+        HPChange         *int               // Damage when negative, healing when positive
+        DamageType       string             // Type of the damage of a negative HPChange, for resistances
+        TempHP           *int               // Temporary hit points to set
+        AC               *int               // Armor class to set
+        Position         *[2]int            // Square to teleport to
+        AddConditions    []models.Condition // Conditions to apply
+        RemoveConditions []string           // Names of conditions to remove
+}
+
+// checkOverride verifies that the DM can still make overrides in a combat
+func checkOverride(combat *models.Combat) error {
+        if combat.IsOver() {
+                return errors.New("combat is over")
+        }
+        return nil
+}
+
+// OverrideCombatant changes a combatant's hit points, armor class, conditions or position
+// by the DM's fiat. Damage goes through temporary hit points, resistances and concentration
+// like any other damage. It returns the log entry of the override.
+func (s *Service) OverrideCombatant(combat *models.Combat, combatantID string, override Override) (*models.CombatAction, error) {
+        if err := checkOverride(combat); err != nil {
+                return nil, err
+        }
+        s, err := s.forCombat(combat)
+        if err != nil {
+                return nil, err
+        }
+
+        target := s.getCombatant(combat, combatantID)
+        if target == nil {
+                return nil, fmt.Errorf("%w: '%s'", ErrUnknownCombatant, combatantID)
+        }
+
+        // Check everything before changing anything
+        if override.HP != nil && *override.HP < 0 {
+                return nil, errors.New("hit points can't be negative")
+        }
+        if override.TempHP != nil && *override.TempHP < 0 {
+                return nil, errors.New("temporary hit points can't be negative")
+        }
+        if override.AC != nil && *override.AC <= 0 {
+                return nil, errors.New("armor class must be positive")
+        }
+        if override.Position != nil && !s.isFree(combat, *override.Position, target) {
+                return nil, errors.New("the position is off the battlefield, blocked or taken")
+        }
+        // A defeated monster is off the battlefield, so bringing it back needs a square for it
+        revives := (override.HP != nil && *override.HP > 0) || (override.HPChange != nil && *override.HPChange > 0)
+        if target.Type == "monster" && target.HP <= 0 && revives && override.Position == nil {
+                return nil, fmt.Errorf("%s is off the battlefield, so it needs a position to come back at", target.Name)
+        }
+        for _, condition := range override.AddConditions {
+                if strings.TrimSpace(condition.Name) == "" {
+                        return nil, errors.New("conditions need a name")
+                }
+        }
+        for _, name := range override.RemoveConditions {
+                if !target.HasCondition(strings.ToLower(strings.TrimSpace(name))) {
+                        return nil, fmt.Errorf("%s isn't %s", target.Name, name)
+                }
+        }
+
+        var changes []string
+        if override.HP != nil {
+                changes = append(changes, setHP(target, *override.HP))
+        }
+        if override.HPChange != nil {
+                changes = append(changes, s.changeHP(combat, target, *override.HPChange, override.DamageType))
+        }
+        if override.TempHP != nil {
+                changes = append(changes, fmt.Sprintf("%s's temporary hit points are set from %d to %d.",
+                        target.Name, target.TempHP, *override.TempHP))
+                target.TempHP = *override.TempHP
+        }
+        if override.AC != nil {
+                changes = append(changes, fmt.Sprintf("%s's AC is set from %d to %d.", target.Name, target.AC, *override.AC))
+                target.AC = *override.AC
+        }
+        if override.Position != nil {
+                changes = append(changes, fmt.Sprintf("%s is moved from %v to %v.", target.Name, target.Position, *override.Position))
+                target.Position = *override.Position
+        }
+        for _, condition := range override.AddConditions {
+                condition.Name = strings.ToLower(strings.TrimSpace(condition.Name))
+                changes = append(changes, s.addCondition(target, condition)+".")
+        }
+        for _, name := range override.RemoveConditions {
+                name = strings.ToLower(strings.TrimSpace(name))
+                target.RemoveCondition(name)
+                changes = append(changes, fmt.Sprintf("%s is no longer %s.", target.Name, name))
+        }
+        if len(changes) == 0 {
+                return nil, errors.New("the override changes nothing")
+        }
+
+        // Dropping the last monster or character to 0 HP ends the combat, as it would in play
+        if err := s.applyActionResult(combat, nil); err != nil {
+                return nil, err
+        }
+
+        return s.saveOverride(combat, combatantID, "combatant", strings.Join(changes, " "))
+}
+
+// setHP sets a combatant's hit points, up to their hit point maximum, and returns a
+// description of the change. A character set to 0 HP falls unconscious and is dying.
+func setHP(target *models.Combatant, hp int) string {
+        hp = min(hp, hitPointMaximum(target))
+        description := fmt.Sprintf("%s's HP is set from %d to %d.", target.Name, target.HP, hp)
+
+        wasDown := target.HP == 0
+        target.HP = hp
+        if target.Type != "character" {
+                return description
+        }
+        switch {
+        case hp > 0 && wasDown:
+                reviveCombatant(target)
+        case hp == 0 && !wasDown:
+                target.DeathSaves = models.DeathSaves{}
+                if !target.HasCondition("unconscious") {
+                        target.Conditions = append(target.Conditions, models.Condition{Name: "unconscious"})
+                }
+                description += fmt.Sprintf(" %s falls unconscious and is dying!", target.Name)
+        }
+        return description
+}
+
+// changeHP deals damage of a type to a combatant, or heals them, by the DM's fiat and
+// returns a description of the result
+func (s *Service) changeHP(combat *models.Combat, target *models.Combatant, change int, damageType string) string {
+        if change >= 0 {
+                healed := s.applyHealing(target, change)
+                return fmt.Sprintf("%s regains %d hit points. %s", target.Name, healed, hpStatus(target))
+        }
+
+        part := models.TypedDamage{Type: strings.ToLower(damageType), Amount: -change}
+        damage, description := s.applyDamage(combat, target, false, part)
+        return fmt.Sprintf("%s takes %d damage. %s", target.Name, damage, description)
+}
+
+// OverrideTurn moves the turn by the DM's fiat. Skipping ends the current turn and starts
+// the next one as if it had ended normally. Rewinding gives the previous combatant their
+// turn again with a fresh action economy, without undoing anything that happened since.
+// It returns the log entry of the override followed by what happened as the new turn started.
+func (s *Service) OverrideTurn(combat *models.Combat, rewind bool) ([]*models.CombatAction, error) {
+        if err := checkOverride(combat); err != nil {
+                return nil, err
+        }
+        s, err := s.forCombat(combat)
+        if err != nil {
+                return nil, err
+        }
+
+        from := currentInitiative(combat)
+        if from == nil {
+                return nil, errors.New("there is no turn to move")
+        }
+        name := from.Name
+
+        var events []*models.CombatAction
+        if rewind {
+                if combat.CurrentTurnIndex == 0 && combat.RoundNumber <= 1 {
+                        return nil, errors.New("it's already the first turn of the combat")
+                }
+                combat.CurrentTurnIndex--
+                if combat.CurrentTurnIndex < 0 {
+                        combat.CurrentTurnIndex = len(combat.Initiative) - 1
+                        combat.RoundNumber--
+                }
+
+                item := currentInitiative(combat)
+                if actor := s.getCombatant(combat, item.ID); actor != nil {
+                        if item.Lair {
+                                actor.Economy.LairActions = 1
+                        } else {
+                                s.resetEconomy(actor)
+                        }
+                }
+        } else {
+                events = append(s.nextTurn(combat), s.skipSurprisedTurns(combat)...)
+        }
+
+        // A failed death save at the start of the new turn can end the combat
+        if err := s.applyActionResult(combat, nil); err != nil {
+                return nil, err
+        }
+
+        to := currentInitiative(combat)
+        description := fmt.Sprintf("%s's turn is skipped; it's now %s's turn in round %d.", name, to.Name, combat.RoundNumber)
+        if rewind {
+                description = fmt.Sprintf("The turn goes back from %s to %s in round %d.", name, to.Name, combat.RoundNumber)
+        }
+
+        // The override goes in the log before what happened on the new turn
+        event, err := s.saveOverride(combat, "", "turn", description, events...)
+        if err != nil {
+                return nil, err
+        }
+        return append([]*models.CombatAction{event}, events...), nil
+}
+
+// OverrideStatus pauses, resumes or ends a combat by the DM's fiat. A combat that is ended
+// or that the party fled is over, and its dice seed is revealed. It returns the log entry of
+// the override.
+func (s *Service) OverrideStatus(combat *models.Combat, status string) (*models.CombatAction, error) {
+        if err := checkOverride(combat); err != nil {
+                return nil, err
+        }
+        if !overrideStatuses[status] {
+                return nil, fmt.Errorf("status must be \"active\", \"paused\", \"ended\" or \"fled\", not %q", status)
+        }
+        if status == combat.Status {
+                return nil, fmt.Errorf("combat is already %s", status)
+        }
+        s, err := s.forCombat(combat)
+        if err != nil {
+                return nil, err
+        }
+
+        var description string
+        switch status {
+        case "active":
+                description = "The combat resumes."
+        case "paused":
+                description = "The combat is paused."
+        case "ended":
+                description = "The combat ends."
+        case "fled":
+                description = "The combat ends as the party flees."
+        }
+
+        combat.Status = status
+        if combat.IsOver() {
+                combat.Seed = combat.DiceSeed // The combat is over, so its dice can be verified
+        }
+
+        return s.saveOverride(combat, "", "status", description)
+}
+
+// saveOverride saves a combat changed by the DM and records the override in its log, with
+// the kind of override in its extra data, followed by any events the override caused
+func (s *Service) saveOverride(combat *models.Combat, combatantID, kind, description string, after ...*models.CombatAction) (*models.CombatAction, error) {
+        event := &models.CombatAction{
+                ActorID:           combatantID,
+                Type:              "override",
+                ExtraData:         map[string]interface{}{"override": kind},
+                ResultDescription: "DM override: " + description,
+                Rolls:             s.diceRoller.TakeRecords(),
+        }
+
+        combat.RollCount = s.diceRoller.Count()
+        if err := s.repo.Update(combat); err != nil {
+                return nil, err
+        }
+        if err := s.saveTurnEvents(combat, append([]*models.CombatAction{event}, after...)); err != nil {
+                return nil, err
+        }
+        return event, nil
+}
@@ -0,0 +1,563 @@
+package combat
+
+import (
+        "crypto/rand"
+        "encoding/hex"
+        "errors"
+        "fmt"
+        "sync"
+        "time"
+
+        "dnd-combat/internal/models"
+        "dnd-combat/pkg/websocket"
+)
+
+// Reaction kinds
+const (
+        reactionOpportunityAttack = "opportunity_attack"
+        reactionShield            = "shield"
+        reactionReadied           = "readied_action"
+)
+
+// Triggers of readied actions
+const (
+        triggerMoves       = "moves"
+        triggerEntersReach = "enters_reach"
+        triggerAttacks     = "attacks"
+        triggerCastsSpell  = "casts_spell"
+)
+
+var (
+        // ErrReactionNotFound is returned when a reaction prompt is unknown or has expired
+        ErrReactionNotFound = errors.New("reaction not found or already resolved")
+        // ErrNotReactionController is returned when someone other than the prompted user answers
+        ErrNotReactionController = errors.New("you don't control the reacting combatant")
+)
+
+// ReactionPrompt describes a reaction a combatant may take in response to a trigger
+type ReactionPrompt struct {
+        ID          string    `json:"reaction_id"`
+        CombatID    string    `json:"combat_id"`
+        Kind        string    `json:"kind"` // "opportunity_attack", "shield" or "readied_action"
+        ReactorID   string    `json:"reactor_id"`
+        ReactorName string    `json:"reactor_name"`
+        TriggerID   string    `json:"trigger_actor_id"`
+        Description string    `json:"description"`
+        ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// ReactionResponse is a controller's answer to a reaction prompt
+type ReactionResponse struct {
+        Accept     bool   `json:"accept"`
+        WeaponName string `json:"weapon_name,omitempty"`
+}
+
+// pendingReaction is a prompt waiting for its controller's answer
+type pendingReaction struct {
+        prompt ReactionPrompt
+        userID string
+        answer chan ReactionResponse
+}
+
+// ReactionBroker sends reaction prompts to controllers and waits for their answers
+type ReactionBroker struct {
+        hub     *websocket.Hub
+        timeout time.Duration
+        pending map[string]*pendingReaction
+        mu      sync.Mutex
+}
+
+// NewReactionBroker creates a broker that waits up to timeout for each answer
+func NewReactionBroker(hub *websocket.Hub, timeout time.Duration) *ReactionBroker {
+        return &ReactionBroker{
+                hub:     hub,
+                timeout: timeout,
+                pending: make(map[string]*pendingReaction),
+        }
+}
+
+// Ask prompts a user to take a reaction and blocks until they answer or the prompt expires.
+// Users who are not connected, or a zero timeout, decline automatically.
+func (b *ReactionBroker) Ask(userID string, prompt ReactionPrompt) ReactionResponse {
+        if b == nil || b.timeout <= 0 || b.hub == nil || !b.hub.IsUserConnected(userID) {
+                return ReactionResponse{Accept: false}
+        }
+
+        prompt.ID = newReactionID()
+        prompt.ExpiresAt = time.Now().Add(b.timeout)
+
+        pending := &pendingReaction{
+                prompt: prompt,
+                userID: userID,
+                answer: make(chan ReactionResponse, 1),
+        }
+
+        b.mu.Lock()
+        b.pending[prompt.ID] = pending
+        b.mu.Unlock()
+
+        defer func() {
+                b.mu.Lock()
+                delete(b.pending, prompt.ID)
+                b.mu.Unlock()
+        }()
+
+        b.hub.SendToUser(userID, websocket.Message{
+                Type: "reaction_available",
+                Data: prompt,
+        })
+
+        timer := time.NewTimer(b.timeout)
+        defer timer.Stop()
+
+        select {
+        case response := <-pending.answer:
+                return response
+        case <-timer.C:
+                b.hub.SendToUser(userID, websocket.Message{
+                        Type: "reaction_expired",
+                        Data: prompt,
+                })
+                return ReactionResponse{Accept: false}
+        }
+}
+
+// Answer delivers a controller's response to a pending reaction prompt
+func (b *ReactionBroker) Answer(combatID, reactionID, userID string, response ReactionResponse) error {
+        b.mu.Lock()
+        pending, ok := b.pending[reactionID]
+        b.mu.Unlock()
+
+        if !ok || pending.prompt.CombatID != combatID {
+                return ErrReactionNotFound
+        }
+
+        if pending.userID != userID {
+                return ErrNotReactionController
+        }
+
+        select {
+        case pending.answer <- response:
+                return nil
+        default:
+                return ErrReactionNotFound
+        }
+}
+
+// newReactionID generates a random identifier for a reaction prompt
+func newReactionID() string {
+        buf := make([]byte, 8)
+        if _, err := rand.Read(buf); err != nil {
+                return time.Now().Format("20060102150405.000000000")
+        }
+        return hex.EncodeToString(buf)
+}
+
+// AnswerReaction passes a controller's answer to a pending reaction prompt
+func (s *Service) AnswerReaction(combatID, reactionID, userID string, response ReactionResponse) error {
+        return s.reactions.Answer(combatID, reactionID, userID, response)
+}
+
+// resolveOpportunityAttacks offers an opportunity attack to every hostile combatant whose
+// reach the mover leaves by stepping to next, and resolves the ones that are accepted
+func (s *Service) resolveOpportunityAttacks(combat *models.Combat, mover *models.Combatant, next [2]int) ([]*models.ActionResult, error) {
+        // Disengaging prevents opportunity attacks
+        if mover.HasCondition("disengage") {
+                return nil, nil
+        }
+
+        var results []*models.ActionResult
+        for i := range combat.Participants {
+                reactor := &combat.Participants[i]
+                if reactor.ID == mover.ID || !isHostile(reactor, mover) || !canReact(reactor) {
+                        continue
+                }
+
+                // A creature charmed by the mover can't attack them
+                reach := s.reachFeet(reactor)
+                if distanceFeet(reactor.Position, mover.Position) > reach || distanceFeet(reactor.Position, next) <= reach ||
+                        charmedBy(reactor, mover) {
+                        continue
+                }
+
+                response := s.askReaction(combat, reactor.UserID, ReactionPrompt{
+                        CombatID:    combat.ID,
+                        Kind:        reactionOpportunityAttack,
+                        ReactorID:   reactor.ID,
+                        ReactorName: reactor.Name,
+                        TriggerID:   mover.ID,
+                        Description: fmt.Sprintf("%s is leaving %s's reach. Make an opportunity attack?", mover.Name, reactor.Name),
+                })
+                if !response.Accept {
+                        continue
+                }
+
+                weaponName := response.WeaponName
+                if weaponName == "" {
+                        weaponName = s.defaultMeleeWeapon(reactor)
+                }
+
+                attack := &models.CombatAction{
+                        CombatID:   combat.ID,
+                        ActorID:    reactor.ID,
+                        Type:       "attack",
+                        TargetIDs:  []string{mover.ID},
+                        WeaponName: weaponName,
+                        ExtraData:  map[string]interface{}{"reaction": reactionOpportunityAttack},
+                }
+
+                // The attack is checked like any other, and one that isn't allowed isn't made
+                if err := s.validateAttack(combat, attack, reactor); err != nil {
+                        results = append(results, &models.ActionResult{
+                                Description: fmt.Sprintf("%s can't make an opportunity attack: %v", reactor.Name, err),
+                        })
+                        continue
+                }
+
+                reactor.Economy.Reactions--
+
+                result, err := s.processAttack(combat, attack, reactor)
+                if err != nil {
+                        return nil, err
+                }
+                result.Description = "Opportunity attack: " + result.Description
+
+                attack.ResultDescription = result.Description
+                attack.Rolls = s.diceRoller.TakeRecords()
+                if err := s.repo.SaveAction(attack); err != nil {
+                        return nil, err
+                }
+
+                results = append(results, result)
+
+                // A mover who drops can't keep walking
+                if mover.HP <= 0 {
+                        break
+                }
+        }
+
+        return results, nil
+}
+
+// offerShield lets a target who knows Shield cast it as a reaction to being hit.
+// It returns the description of the cast, or "" if the target didn't cast it.
+func (s *Service) offerShield(combat *models.Combat, attacker, target *models.Combatant) (string, error) {
+        char, ok := target.Stats.(*models.Character)
+        if !ok || !containsString(char.Spells, "shield") || !canReact(target) {
+                return "", nil
+        }
+
+        response := s.askReaction(combat, target.UserID, ReactionPrompt{
+                CombatID:    combat.ID,
+                Kind:        reactionShield,
+                ReactorID:   target.ID,
+                ReactorName: target.Name,
+                TriggerID:   attacker.ID,
+                Description: fmt.Sprintf("%s is about to hit %s. Cast Shield for +5 AC?", attacker.Name, target.Name),
+        })
+        if !response.Accept {
+                return "", nil
+        }
+
+        target.Economy.Reactions--
+        // Shield lasts until the start of the caster's next turn
+        s.addCondition(target, models.Condition{
+                Name:        "shield",
+                SourceID:    target.ID,
+                SourceSpell: "shield",
+                Duration:    1,
+                StartOfTurn: true,
+                ACBonus:     5,
+        })
+
+        description := fmt.Sprintf("%s casts Shield as a reaction, raising their AC to %d!", target.Name, armorClass(target))
+
+        cast := &models.CombatAction{
+                CombatID:          combat.ID,
+                ActorID:           target.ID,
+                Type:              "cast_spell",
+                TargetIDs:         []string{target.ID},
+                SpellID:           "shield",
+                ExtraData:         map[string]interface{}{"reaction": reactionShield},
+                ResultDescription: description,
+        }
+        if err := s.repo.SaveAction(cast); err != nil {
+                return "", err
+        }
+
+        return description, nil
+}
+
+// readiedAction reads the action a Ready action holds: the "trigger" and "trigger_id" of its
+// extra data, and its weapon, spell and targets for the action in "action", which is
+// "attack" by default or "cast_spell" when a spell is given
+func readiedAction(action *models.CombatAction) *models.ReadiedAction {
+        trigger, _ := action.ExtraData["trigger"].(string)
+        triggerID, _ := action.ExtraData["trigger_id"].(string)
+        actionType, _ := action.ExtraData["action"].(string)
+        if actionType == "" {
+                actionType = "attack"
+                if action.SpellID != "" {
+                        actionType = "cast_spell"
+                }
+        }
+
+        return &models.ReadiedAction{
+                Trigger:    trigger,
+                TriggerID:  triggerID,
+                Type:       actionType,
+                TargetIDs:  action.TargetIDs,
+                WeaponName: action.WeaponName,
+                SpellID:    action.SpellID,
+                SlotLevel:  action.SlotLevel,
+        }
+}
+
+// validateReady checks the trigger of a Ready action and the attack or spell it holds
+func (s *Service) validateReady(combat *models.Combat, action *models.CombatAction, actor *models.Combatant) error {
+        readied := readiedAction(action)
+        switch readied.Trigger {
+        case triggerMoves, triggerEntersReach, triggerAttacks, triggerCastsSpell:
+        default:
+                return fmt.Errorf("unknown trigger '%s', expected moves, enters_reach, attacks or casts_spell", readied.Trigger)
+        }
+        if readied.TriggerID != "" && s.getCombatant(combat, readied.TriggerID) == nil {
+                return errors.New("trigger creature not found in combat")
+        }
+
+        switch readied.Type {
+        case "attack":
+                if readied.WeaponName == "" {
+                        return errors.New("a readied attack requires a weapon")
+                }
+                if actor.Type == "monster" {
+                        if findMonsterAction(actor, "attack", readied.WeaponName) == nil {
+                                return fmt.Errorf("%s has no action named '%s'", actor.Name, readied.WeaponName)
+                        }
+                } else if _, err := s.lookupWeapon(readied.WeaponName); err != nil {
+                        return err
+                }
+        case "cast_spell":
+                if readied.SpellID == "" {
+                        return errors.New("a readied spell requires a spell")
+                }
+                if char, ok := actor.Stats.(*models.Character); ok && !containsString(char.Spells, readied.SpellID) {
+                        return errors.New("character doesn't know this spell")
+                }
+        default:
+                return fmt.Errorf("only an attack or a spell can be readied, got '%s'", readied.Type)
+        }
+        return nil
+}
+
+// processReady handles a Ready action, holding an attack or spell until its trigger happens
+func (s *Service) processReady(combat *models.Combat, action *models.CombatAction, actor *models.Combatant) (*models.ActionResult, error) {
+        readied := readiedAction(action)
+        actor.Readied = readied
+
+        what := "an attack with " + readied.WeaponName
+        if readied.Type == "cast_spell" {
+                what = readied.SpellID
+        }
+        return &models.ActionResult{
+                Success:     true,
+                Description: fmt.Sprintf("%s readies %s for when %s", actor.Name, what, s.describeTrigger(combat, readied)),
+        }, nil
+}
+
+// describeTrigger describes the trigger of a readied action, like "Goblin comes within reach"
+func (s *Service) describeTrigger(combat *models.Combat, readied *models.ReadiedAction) string {
+        who := "a hostile creature"
+        if trigger := s.getCombatant(combat, readied.TriggerID); trigger != nil {
+                who = trigger.Name
+        }
+        switch readied.Trigger {
+        case triggerEntersReach:
+                return who + " comes within reach"
+        case triggerAttacks:
+                return who + " attacks"
+        case triggerCastsSpell:
+                return who + " casts a spell"
+        }
+        return who + " moves"
+}
+
+// readiedTrigger returns the trigger an action sets off for readied actions once it is
+// resolved, or "" for actions that don't. Movement sets them off one step at a time.
+func readiedTrigger(action *models.CombatAction) string {
+        switch action.Type {
+        case "attack", "offhand_attack":
+                return triggerAttacks
+        case "cast_spell":
+                return triggerCastsSpell
+        }
+        return ""
+}
+
+// isTriggeredBy checks if a creature setting off a trigger is what a readied action waits
+// for. A creature entering reach is one that moves from outside the reactor's reach to inside it.
+func (s *Service) isTriggeredBy(readied *models.ReadiedAction, reactor, trigger *models.Combatant, event string, from [2]int) bool {
+        if readied.TriggerID != "" {
+                if readied.TriggerID != trigger.ID {
+                        return false
+                }
+        } else if !isHostile(reactor, trigger) {
+                return false
+        }
+
+        if readied.Trigger == triggerEntersReach {
+                reach := s.reachFeet(reactor)
+                return event == triggerMoves && distanceFeet(reactor.Position, from) > reach &&
+                        distanceFeet(reactor.Position, trigger.Position) <= reach
+        }
+        return readied.Trigger == event
+}
+
+// resolveReadiedActions offers their readied action to every combatant waiting for what a
+// creature just did, and takes the ones that are accepted. from is where a moving creature
+// stepped from. A declined readied action is kept until the start of the reactor's turn.
+func (s *Service) resolveReadiedActions(combat *models.Combat, trigger *models.Combatant, event string, from [2]int) ([]*models.ActionResult, error) {
+        var results []*models.ActionResult
+        for i := range combat.Participants {
+                reactor := &combat.Participants[i]
+                readied := reactor.Readied
+                if readied == nil || reactor.ID == trigger.ID || !canReact(reactor) ||
+                        !s.isTriggeredBy(readied, reactor, trigger, event, from) {
+                        continue
+                }
+
+                response := s.askReaction(combat, reactor.UserID, ReactionPrompt{
+                        CombatID:    combat.ID,
+                        Kind:        reactionReadied,
+                        ReactorID:   reactor.ID,
+                        ReactorName: reactor.Name,
+                        TriggerID:   trigger.ID,
+                        Description: fmt.Sprintf("The trigger of %s's readied action happened: %s. Take it?",
+                                reactor.Name, s.describeTrigger(combat, &models.ReadiedAction{Trigger: readied.Trigger, TriggerID: trigger.ID})),
+                })
+                if !response.Accept {
+                        continue
+                }
+
+                reactor.Economy.Reactions--
+                reactor.Readied = nil
+
+                targetIDs := readied.TargetIDs
+                if len(targetIDs) == 0 {
+                        targetIDs = []string{trigger.ID}
+                }
+                action := &models.CombatAction{
+                        CombatID:   combat.ID,
+                        ActorID:    reactor.ID,
+                        Type:       readied.Type,
+                        TargetIDs:  targetIDs,
+                        WeaponName: readied.WeaponName,
+                        SpellID:    readied.SpellID,
+                        SlotLevel:  readied.SlotLevel,
+                        ExtraData:  map[string]interface{}{"reaction": reactionReadied},
+                }
+
+                result, err := s.takeReadiedAction(combat, action, reactor)
+                if err != nil {
+                        return nil, err
+                }
+                result.Description = "Readied action: " + result.Description
+
+                action.ResultDescription = result.Description
+                action.Rolls = s.diceRoller.TakeRecords()
+                if err := s.repo.SaveAction(action); err != nil {
+                        return nil, err
+                }
+
+                results = append(results, result)
+
+                // Nothing is left to react to once the trigger creature drops
+                if trigger.HP <= 0 {
+                        break
+                }
+        }
+
+        return results, nil
+}
+
+// takeReadiedAction checks and takes the attack or spell a combatant readied. An action that
+// is no longer possible, like an attack on a target out of range, is wasted.
+func (s *Service) takeReadiedAction(combat *models.Combat, action *models.CombatAction, reactor *models.Combatant) (*models.ActionResult, error) {
+        var err error
+        switch {
+        case action.Type == "cast_spell":
+                err = s.validateSpellCast(combat, action, reactor)
+        case reactor.Type == "monster":
+                err = s.validateMonsterAction(combat, action, reactor)
+        default:
+                err = s.validateAttack(combat, action, reactor)
+        }
+        if err != nil {
+                return &models.ActionResult{Description: fmt.Sprintf("%s can't take their readied action: %v", reactor.Name, err)}, nil
+        }
+
+        switch {
+        case action.Type == "cast_spell":
+                return s.processSpellCast(combat, action, reactor)
+        case reactor.Type == "monster":
+                return s.processMonsterAction(combat, action, reactor)
+        }
+        return s.processAttack(combat, action, reactor)
+}
+
+// canReact checks if a combatant is able to take a reaction right now
+func canReact(combatant *models.Combatant) bool {
+        return combatant.HP > 0 && combatant.Economy.Reactions > 0 && !isIncapacitated(combatant)
+}
+
+// isHostile checks if two combatants are on opposing sides
+func isHostile(a, b *models.Combatant) bool {
+        return a.Type != b.Type
+}
+
+// reachFeet returns how far a combatant's melee attacks reach
+func (s *Service) reachFeet(combatant *models.Combatant) int {
+        reach := 5
+        switch stats := combatant.Stats.(type) {
+        case *models.Monster:
+                for _, action := range stats.Actions {
+                        if action.AttackBonus > 0 && action.Reach > reach {
+                                reach = action.Reach
+                        }
+                }
+        case *models.Character:
+                if weapon, err := s.lookupWeapon(s.defaultMeleeWeapon(combatant)); err == nil {
+                        reach = weaponReach(weapon)
+                }
+        }
+        return reach
+}
+
+// defaultMeleeWeapon picks the melee weapon a combatant uses when none is specified
+func (s *Service) defaultMeleeWeapon(combatant *models.Combatant) string {
+        switch stats := combatant.Stats.(type) {
+        case *models.Monster:
+                for _, action := range stats.Actions {
+                        if action.AttackBonus > 0 && (action.Reach > 0 || action.Range == 0) {
+                                return action.Name
+                        }
+                }
+        case *models.Character:
+                // The weapon in hand, then any other melee weapon they carry
+                candidates := []string{stats.Equipped.MainHand}
+                for _, item := range stats.Inventory {
+                        if item.Category == "weapon" {
+                                candidates = append(candidates, item.Index)
+                        }
+                }
+                for _, index := range candidates {
+                        if index == "" {
+                                continue
+                        }
+                        if weapon, err := s.lookupWeapon(index); err == nil && !weapon.IsRanged() {
+                                return index
+                        }
+                }
+        }
+        return "unarmed strike"
+}
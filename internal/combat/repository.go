@@ -45,12 +45,12 @@ func (r *Repository) Create(combat *models.Combat) error {
                 INSERT INTO combats (
                         dm_user_id, current_turn_index, round_number, status, 
                         initiative_json, participants_json, battlefield_json, environment,
-                        created_at, updated_at
+                        auto_monsters, dice_seed, seed_commitment, roll_count, created_at, updated_at
                 )
                 VALUES (
                         ?, ?, ?, ?, 
                         ?, ?, ?, ?,
-                        CURRENT_TIMESTAMP, CURRENT_TIMESTAMP
+                        ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP
                 )
                 RETURNING id
         `
@@ -65,6 +65,10 @@ func (r *Repository) Create(combat *models.Combat) error {
                 string(participantsJSON),
                 string(battlefieldJSON),
                 combat.Environment,
+                combat.AutoMonsters,
+                combat.DiceSeed,
+                combat.SeedCommitment,
+                combat.RollCount,
         ).Scan(&combat.ID)
 
         return err
@@ -76,7 +80,7 @@ func (r *Repository) GetByID(id string) (*models.Combat, error) {
                 SELECT 
                         id, dm_user_id, current_turn_index, round_number, status, 
                         initiative_json, participants_json, battlefield_json, environment,
-                        created_at, updated_at
+                        auto_monsters, dice_seed, seed_commitment, roll_count, created_at, updated_at
                 FROM combats
                 WHERE id = ?
                 LIMIT 1
@@ -95,6 +99,10 @@ func (r *Repository) GetByID(id string) (*models.Combat, error) {
                 &participantsJSON,
                 &battlefieldJSON,
                 &combat.Environment,
+                &combat.AutoMonsters,
+                &combat.DiceSeed,
+                &combat.SeedCommitment,
+                &combat.RollCount,
                 &combat.CreatedAt,
                 &combat.UpdatedAt,
         )
@@ -127,6 +135,11 @@ func (r *Repository) GetByID(id string) (*models.Combat, error) {
                 }
         }
 
+        // The dice seed is revealed once the combat is over
+        if combat.IsOver() {
+                combat.Seed = combat.DiceSeed
+        }
+
         return combat, nil
 }
 
@@ -159,6 +172,10 @@ func (r *Repository) Update(combat *models.Combat) error {
                         initiative_json = ?,
                         participants_json = ?,
                         battlefield_json = ?,
+                        auto_monsters = ?,
+                        dice_seed = ?,
+                        seed_commitment = ?,
+                        roll_count = ?,
                         updated_at = CURRENT_TIMESTAMP
                 WHERE id = ?
         `
@@ -171,6 +188,10 @@ func (r *Repository) Update(combat *models.Combat) error {
                 string(initiativeJSON),
                 string(participantsJSON),
                 string(battlefieldJSON),
+                combat.AutoMonsters,
+                combat.DiceSeed,
+                combat.SeedCommitment,
+                combat.RollCount,
                 combat.ID,
         )
 
@@ -225,16 +246,27 @@ func (r *Repository) SaveAction(action *models.CombatAction) error {
                 targetIDsJSON.Valid = true
         }
 
+        // Convert the roll ledger to JSON if any dice were rolled
+        var rollsJSON sql.NullString
+        if len(action.Rolls) > 0 {
+                rolls, err := json.Marshal(action.Rolls)
+                if err != nil {
+                        return err
+                }
+                rollsJSON.String = string(rolls)
+                rollsJSON.Valid = true
+        }
+
         query := `
                 INSERT INTO combat_actions (
                         combat_id, actor_id, type, target_ids_json, 
-                        spell_id, weapon_name, movement_path_json, extra_data_json,
-                        result_description, created_at
+                        spell_id, slot_level, weapon_name, movement_path_json, extra_data_json,
+                        rolls_json, result_description, created_at
                 )
                 VALUES (
                         ?, ?, ?, ?, 
-                        ?, ?, ?, ?,
-                        ?, CURRENT_TIMESTAMP
+                        ?, ?, ?, ?, ?,
+                        ?, ?, CURRENT_TIMESTAMP
                 )
                 RETURNING id
         `
@@ -248,6 +280,11 @@ func (r *Repository) SaveAction(action *models.CombatAction) error {
                 weaponName.String = action.WeaponName
                 weaponName.Valid = true
         }
+        var slotLevel sql.NullInt64
+        if action.SlotLevel > 0 {
+                slotLevel.Int64 = int64(action.SlotLevel)
+                slotLevel.Valid = true
+        }
 
         return r.db.QueryRow(
                 query,
@@ -256,9 +293,11 @@ func (r *Repository) SaveAction(action *models.CombatAction) error {
                 action.Type,
                 targetIDsJSON,
                 spellID,
+                slotLevel,
                 weaponName,
                 movementPathJSON,
                 extraDataJSON,
+                rollsJSON,
                 action.ResultDescription,
         ).Scan(&action.ID)
 }
@@ -268,8 +307,8 @@ func (r *Repository) GetActionsByCombatID(combatID string) ([]*models.CombatActi
         query := `
                 SELECT 
                         id, combat_id, actor_id, type, target_ids_json, 
-                        spell_id, weapon_name, movement_path_json, extra_data_json,
-                        result_description, created_at
+                        spell_id, slot_level, weapon_name, movement_path_json, extra_data_json,
+                        rolls_json, result_description, created_at
                 FROM combat_actions
                 WHERE combat_id = ?
                 ORDER BY created_at
@@ -285,8 +324,9 @@ func (r *Repository) GetActionsByCombatID(combatID string) ([]*models.CombatActi
 
         for rows.Next() {
                 action := &models.CombatAction{}
-                var targetIDsJSON, movementPathJSON, extraDataJSON sql.NullString
+                var targetIDsJSON, movementPathJSON, extraDataJSON, rollsJSON sql.NullString
                 var spellID, weaponName sql.NullString
+                var slotLevel sql.NullInt64
 
                 err := rows.Scan(
                         &action.ID,
@@ -295,9 +335,11 @@ func (r *Repository) GetActionsByCombatID(combatID string) ([]*models.CombatActi
                         &action.Type,
                         &targetIDsJSON,
                         &spellID,
+                        &slotLevel,
                         &weaponName,
                         &movementPathJSON,
                         &extraDataJSON,
+                        &rollsJSON,
                         &action.ResultDescription,
                         &action.CreatedAt,
                 )
@@ -329,10 +371,21 @@ func (r *Repository) GetActionsByCombatID(combatID string) ([]*models.CombatActi
                         action.ExtraData = extraData
                 }
 
+                // Parse roll ledger JSON
+                if rollsJSON.Valid {
+                        if err := json.Unmarshal([]byte(rollsJSON.String), &action.Rolls); err != nil {
+                                return nil, err
+                        }
+                }
+
                 if spellID.Valid {
                         action.SpellID = spellID.String
                 }
 
+                if slotLevel.Valid {
+                        action.SlotLevel = int(slotLevel.Int64)
+                }
+
                 if weaponName.Valid {
                         action.WeaponName = weaponName.String
                 }
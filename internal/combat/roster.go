@@ -0,0 +1,434 @@
+package combat
+
+import (
+        "errors"
+        "fmt"
+        "strings"
+
+        "dnd-combat/internal/models"
+        "dnd-combat/pkg/dnd5e"
+)
+
+// Arrival is a combatant joining a combat that is already running. Exactly one of
+// Character and Monster is set.
+type Arrival struct {
+        Character  *models.Character
+        Monster    *models.Monster // An SRD monster, or a custom one made up by the DM
+        Custom     bool            // The DM made up the monster, see prepareCustomMonster
+        Position   *[2]int         // Where they arrive, the first free square on their side otherwise
+        Initiative *int            // Initiative rolled with physical dice, rolled by the server otherwise
+}
+
+// characterCombatant creates the combatant of a character, with the spell slots, feature
+// uses and lasting conditions they bring into the combat
+func (s *Service) characterCombatant(char *models.Character) (*models.Combatant, error) {
+        // Spellcasters start with the spell slots of their class and level
+        if err := s.prepareSpellSlots(char); err != nil {
+                return nil, err
+        }
+
+        // Limited class features like Rage keep the uses spent since the last rest
+        s.prepareFeatures(char)
+
+        // Exhaustion and a reduced hit point maximum last until a long rest
+        conditions := []models.Condition{}
+        if char.Exhaustion > 0 {
+                conditions = append(conditions, models.Condition{Name: "exhaustion", Level: char.Exhaustion})
+        }
+
+        return &models.Combatant{
+                ID:             char.ID,
+                Type:           "character",
+                Name:           char.Name,
+                HP:             char.HitPoints,
+                MaxHP:          char.MaxHitPoints,
+                MaxHPReduction: char.MaxHPReduction,
+                AC:             char.ArmorClass,
+                Position:       [2]int{0, 0}, // Default position, will be updated later
+                UserID:         char.UserID,
+                CharacterID:    char.ID,
+                Stats:          char, // Store character data for reference
+                Conditions:     conditions,
+        }, nil
+}
+
+// monsterCombatant creates the combatant of a monster with the given combatant ID, rolling
+// its hit points
+func (s *Service) monsterCombatant(monster *models.Monster, id string, dmUserID string) *models.Combatant {
+        s.diceRoller.Label("hit points", id)
+        hp := s.diceRoller.RollHitPoints(monster.HitDice)
+
+        return &models.Combatant{
+                ID:         id,
+                Type:       "monster",
+                Name:       monster.Name,
+                HP:         hp,
+                MaxHP:      hp,
+                AC:         monster.ArmorClass,
+                Position:   [2]int{0, 0}, // Default position, will be updated later
+                UserID:     dmUserID,     // DM controls all monsters
+                MonsterID:  monster.Index,
+                Stats:      monster, // Store monster data for reference
+                Conditions: []models.Condition{},
+                ActionUses: initialActionUses(monster),
+        }
+}
+
+// prepareCustomMonster checks a monster the DM made up and fills in what they left out:
+// an index from its name, ability scores of 10, Medium size and a walking speed of 30 feet
+func prepareCustomMonster(monster *models.Monster) error {
+        if strings.TrimSpace(monster.Name) == "" {
+                return errors.New("a custom monster needs a name")
+        }
+        if monster.ArmorClass <= 0 {
+                return errors.New("a custom monster needs an armor class")
+        }
+        if _, err := dnd5e.ParseDice(monster.HitDice); err != nil {
+                return fmt.Errorf("a custom monster needs hit dice like \"2d8+2\": %w", err)
+        }
+
+        if monster.Index == "" {
+                monster.Index = strings.Join(strings.Fields(strings.ToLower(monster.Name)), "-")
+        }
+        if monster.Size == "" {
+                monster.Size = "Medium"
+        }
+        if monster.Speed.Walk == 0 {
+                monster.Speed.Walk = models.BaseSpeed
+        }
+
+        scores := []*int{&monster.Strength, &monster.Dexterity, &monster.Constitution,
+                &monster.Intelligence, &monster.Wisdom, &monster.Charisma}
+        for _, score := range scores {
+                if *score == 0 {
+                        *score = 10
+                }
+        }
+        monster.StrengthMod = models.GetAbilityModifier(monster.Strength)
+        monster.DexterityMod = models.GetAbilityModifier(monster.Dexterity)
+        monster.ConMod = models.GetAbilityModifier(monster.Constitution)
+        monster.IntMod = models.GetAbilityModifier(monster.Intelligence)
+        monster.WisdomMod = models.GetAbilityModifier(monster.Wisdom)
+        monster.CharismaMod = models.GetAbilityModifier(monster.Charisma)
+        return nil
+}
+
+// AddCombatant brings a character or monster into a running combat. Their initiative is
+// rolled, or taken as entered, and they join the initiative order where it belongs without
+// changing whose turn it is. It returns the new combatant and the log entry of their arrival.
+func (s *Service) AddCombatant(combat *models.Combat, arrival Arrival) (*models.Combatant, *models.CombatAction, error) {
+        if combat.Status != "active" {
+                return nil, nil, errors.New("combat is not active")
+        }
+        if (arrival.Character == nil) == (arrival.Monster == nil) {
+                return nil, nil, errors.New("give either a character or a monster to add")
+        }
+        s, err := s.forCombat(combat)
+        if err != nil {
+                return nil, nil, err
+        }
+
+        var arriving *models.Combatant
+        if char := arrival.Character; char != nil {
+                if s.getCombatant(combat, char.ID) != nil {
+                        return nil, nil, fmt.Errorf("%s is already in the combat", char.Name)
+                }
+                if arriving, err = s.characterCombatant(char); err != nil {
+                        return nil, nil, err
+                }
+        } else {
+                monster := arrival.Monster
+                if arrival.Custom {
+                        if err := prepareCustomMonster(monster); err != nil {
+                                return nil, nil, err
+                        }
+                }
+                arriving = s.monsterCombatant(monster, s.newMonsterID(combat, monster), combat.DMUserID)
+        }
+
+        if arrival.Position != nil {
+                if !s.isFree(combat, *arrival.Position, arriving) {
+                        return nil, nil, errors.New("the arrival position is off the battlefield, blocked or taken")
+                }
+                arriving.Position = *arrival.Position
+        } else {
+                position, ok := s.arrivalPosition(combat, arriving)
+                if !ok {
+                        return nil, nil, errors.New("there is no free square left on the battlefield")
+                }
+                arriving.Position = position
+        }
+
+        item := s.newInitiativeItem(arriving, arrival.Initiative)
+        s.resetEconomy(arriving)
+        combat.Participants = append(combat.Participants, *arriving)
+        arriving = &combat.Participants[len(combat.Participants)-1]
+
+        keepTurn(combat, func() {
+                s.insertInitiative(combat, item)
+                if !hasLairTurn(combat) {
+                        combat.Initiative = addLairInitiative(combat.Initiative, []*models.Combatant{arriving})
+                }
+        })
+
+        event := &models.CombatAction{
+                ActorID:           arriving.ID,
+                Type:              "combatant_added",
+                ResultDescription: fmt.Sprintf("%s joins the combat with an initiative of %d.", arriving.Name, arriving.Initiative),
+                Rolls:             s.diceRoller.TakeRecords(),
+        }
+
+        combat.RollCount = s.diceRoller.Count()
+        if err := s.repo.Update(combat); err != nil {
+                return nil, nil, err
+        }
+        if err := s.saveTurnEvents(combat, []*models.CombatAction{event}); err != nil {
+                return nil, nil, err
+        }
+        return arriving, event, nil
+}
+
+// newMonsterID returns the first combatant ID for a monster of its kind that isn't taken
+func (s *Service) newMonsterID(combat *models.Combat, monster *models.Monster) string {
+        for n := 0; ; n++ {
+                id := fmt.Sprintf("monster_%s_%d", monster.Index, n)
+                if s.getCombatant(combat, id) == nil {
+                        return id
+                }
+        }
+}
+
+// arrivalPosition finds the free square closest to a combatant's side of the battlefield:
+// the left for characters and the right for monsters
+func (s *Service) arrivalPosition(combat *models.Combat, arriving *models.Combatant) ([2]int, bool) {
+        width := combat.Battlefield.Width
+        for column := 0; column < width; column++ {
+                x := column
+                if arriving.Type == "monster" {
+                        x = width - 1 - column
+                }
+                for y := 0; y < combat.Battlefield.Height; y++ {
+                        if s.isFree(combat, [2]int{x, y}, arriving) {
+                                return [2]int{x, y}, true
+                        }
+                }
+        }
+        return [2]int{}, false
+}
+
+// insertInitiative puts a new entry in the initiative order before the first entry it
+// goes before. Combatants it ties with roll off again to settle their order.
+func (s *Service) insertInitiative(combat *models.Combat, item models.InitiativeItem) {
+        group := []models.InitiativeItem{item}
+        remaining := make([]models.InitiativeItem, 0, len(combat.Initiative)+1)
+        for _, other := range combat.Initiative {
+                if !other.Lair && other.Initiative == item.Initiative && other.Dexterity == item.Dexterity {
+                        group = append(group, other)
+                        continue
+                }
+                remaining = append(remaining, other)
+        }
+
+        if len(group) > 1 {
+                tied := make([]*models.InitiativeItem, len(group))
+                for i := range group {
+                        tied[i] = &group[i]
+                }
+                ordered := make([]models.InitiativeItem, 0, len(group))
+                for place, tiedItem := range s.rollOff(tied) {
+                        tiedItem.TieBreak = len(group) - place
+                        ordered = append(ordered, *tiedItem)
+                }
+                group = ordered
+        }
+
+        for _, entry := range group {
+                position := len(remaining)
+                for i, other := range remaining {
+                        if initiativeBefore(entry, other) {
+                                position = i
+                                break
+                        }
+                }
+                remaining = append(remaining, models.InitiativeItem{})
+                copy(remaining[position+1:], remaining[position:])
+                remaining[position] = entry
+        }
+        combat.Initiative = remaining
+}
+
+// hasLairTurn checks if a combat's initiative order has a lair's turn
+func hasLairTurn(combat *models.Combat) bool {
+        for _, item := range combat.Initiative {
+                if item.Lair {
+                        return true
+                }
+        }
+        return false
+}
+
+// hasCharacters checks if any characters are left in a combat, whatever their hit points
+func hasCharacters(combat *models.Combat) bool {
+        for _, participant := range combat.Participants {
+                if participant.Type == "character" {
+                        return true
+                }
+        }
+        return false
+}
+
+// RemoveCombatant takes a combatant out of a running combat, because they flee or the DM
+// removes them. Their concentration ends and their turns leave the initiative order; if it
+// was their turn, the next combatant's starts. The combat is fled when the last character
+// flees. It returns the removed combatant and what happened.
+func (s *Service) RemoveCombatant(combat *models.Combat, combatantID string, fled bool) (*models.Combatant, []*models.CombatAction, error) {
+        if combat.Status != "active" {
+                return nil, nil, errors.New("combat is not active")
+        }
+        s, err := s.forCombat(combat)
+        if err != nil {
+                return nil, nil, err
+        }
+
+        leaving := s.getCombatant(combat, combatantID)
+        if leaving == nil {
+                return nil, nil, fmt.Errorf("%w: '%s'", ErrUnknownCombatant, combatantID)
+        }
+
+        var description string
+        if fled {
+                description = fmt.Sprintf("%s flees the battle.", leaving.Name)
+        } else {
+                description = fmt.Sprintf("The DM removes %s from the combat.", leaving.Name)
+        }
+        description += s.endConcentration(combat, leaving, "")
+
+        // Take their turns out of the initiative order, keeping the turn where it is
+        current := combat.CurrentTurnIndex
+        wasTheirTurn := false
+        initiative := make([]models.InitiativeItem, 0, len(combat.Initiative))
+        for i, item := range combat.Initiative {
+                if item.ID != combatantID {
+                        initiative = append(initiative, item)
+                        continue
+                }
+                if i < current {
+                        combat.CurrentTurnIndex--
+                } else if i == current {
+                        wasTheirTurn = true
+                }
+        }
+        combat.Initiative = initiative
+
+        removed := *leaving
+        participants := make([]models.Combatant, 0, len(combat.Participants))
+        for _, participant := range combat.Participants {
+                if participant.ID != combatantID {
+                        participants = append(participants, participant)
+                }
+        }
+        combat.Participants = participants
+
+        events := []*models.CombatAction{{
+                ActorID:           combatantID,
+                Type:              "combatant_removed",
+                ResultDescription: description,
+        }}
+
+        // The turn passes to whoever came after them
+        if wasTheirTurn {
+                if combat.CurrentTurnIndex >= len(combat.Initiative) {
+                        combat.CurrentTurnIndex = 0
+                        combat.RoundNumber++
+                }
+                events = append(events, s.startTurn(combat)...)
+                events = append(events, s.skipSurprisedTurns(combat)...)
+        }
+
+        // With the last monster or character gone the combat is over. A party whose last
+        // character flees has fled rather than been defeated.
+        if err := s.applyActionResult(combat, nil); err != nil {
+                return nil, nil, err
+        }
+        if fled && combat.Status == "defeat" && !hasCharacters(combat) {
+                combat.Status = "fled"
+        }
+
+        combat.RollCount = s.diceRoller.Count()
+        if err := s.repo.Update(combat); err != nil {
+                return nil, nil, err
+        }
+        if err := s.saveTurnEvents(combat, events); err != nil {
+                return nil, nil, err
+        }
+        return &removed, events, nil
+}
+
+// DelayTurn puts off the turn of the combatant whose turn it is to a lower initiative
+// count, before they do anything on it. They take the rest of their turn, and their turns
+// in later rounds, at that count. It returns what happened as the next turn starts.
+func (s *Service) DelayTurn(combat *models.Combat, actorID string, count int) ([]*models.CombatAction, error) {
+        if combat.Status != "active" {
+                return nil, errors.New("combat is not active")
+        }
+        s, err := s.forCombat(combat)
+        if err != nil {
+                return nil, err
+        }
+
+        item := currentInitiative(combat)
+        if item == nil || item.Lair || item.ID != actorID {
+                return nil, errors.New("only the combatant whose turn it is can delay it")
+        }
+        actor := s.getCombatant(combat, actorID)
+        if actor == nil {
+                return nil, fmt.Errorf("%w: '%s'", ErrUnknownCombatant, actorID)
+        }
+        if actor.Economy.Actions < 1 || actor.Economy.BonusActions < 1 ||
+                actor.Economy.MovementLeft < actor.Economy.Speed {
+                return nil, errors.New("a turn can only be delayed before acting or moving on it")
+        }
+        if count >= item.Initiative {
+                return nil, fmt.Errorf("a turn can only be delayed to an initiative count below %d", item.Initiative)
+        }
+
+        // The delayed turn goes after everyone who acts on a higher count this round
+        delayed := *item
+        delayed.Initiative = count
+        delayed.TieBreak = 0
+        delayed.Delayed = true
+        index := combat.CurrentTurnIndex
+        position := len(combat.Initiative)
+        for i := index + 1; i < len(combat.Initiative); i++ {
+                if combat.Initiative[i].Initiative < count {
+                        position = i
+                        break
+                }
+        }
+        if position == index+1 {
+                return nil, errors.New("nobody acts before that initiative count this round")
+        }
+
+        initiative := make([]models.InitiativeItem, 0, len(combat.Initiative))
+        initiative = append(initiative, combat.Initiative[:index]...)
+        initiative = append(initiative, combat.Initiative[index+1:position]...)
+        initiative = append(initiative, delayed)
+        initiative = append(initiative, combat.Initiative[position:]...)
+        combat.Initiative = initiative
+        actor.Initiative = count
+
+        // The turn passes on without ending, so nothing that ends with it ends yet
+        events := []*models.CombatAction{s.turnEvent(combat, actor, "delay",
+                fmt.Sprintf("%s delays their turn to initiative count %d.", actor.Name, count))}
+        events = append(events, s.startTurn(combat)...)
+        events = append(events, s.skipSurprisedTurns(combat)...)
+
+        combat.RollCount = s.diceRoller.Count()
+        if err := s.repo.Update(combat); err != nil {
+                return nil, err
+        }
+        if err := s.saveTurnEvents(combat, events); err != nil {
+                return nil, err
+        }
+        return events, nil
+}
@@ -1,8 +1,11 @@
 package combat
 
 import (
+        "encoding/hex"
         "errors"
         "fmt"
+        "strings"
+        "sync"
         "time"
 
         "dnd-combat/internal/models"
@@ -11,66 +14,92 @@ import (
 
 // Service handles combat business logic
 type Service struct {
-        repo        *Repository
+        repo      *Repository
+        srdClient SRDClient
+        reactions *ReactionBroker
+        brain     MonsterBrain
+        autoPlay  *autoPlayTracker
+        locks     *combatLocks
+        
+        // Dice of the combat being worked on, set by forCombat
         diceRoller  *dnd5e.DiceRoller
         combatRules *dnd5e.CombatRules
 }
 
+// autoPlayTracker tracks the combats whose monster turns are being played automatically
+type autoPlayTracker struct {
+        mu      sync.Mutex
+        combats map[string]bool
+}
+
 // NewService creates a new combat service
-func NewService(repo *Repository, diceRoller *dnd5e.DiceRoller, combatRules *dnd5e.CombatRules) *Service {
+func NewService(repo *Repository, srdClient SRDClient, reactions *ReactionBroker, brain MonsterBrain) *Service {
         return &Service{
-                repo:        repo,
-                diceRoller:  diceRoller,
-                combatRules: combatRules,
+                repo:      repo,
+                srdClient: srdClient,
+                reactions: reactions,
+                brain:     brain,
+                autoPlay:  &autoPlayTracker{combats: make(map[string]bool)},
+                locks:     newCombatLocks(),
+        }
+}
+
+// forCombat returns a copy of the service that rolls a combat's dice, continuing the
+// combat's seeded sequence of dice. Combats without a seed are given one. Callers hold the
+// combat's lock from loading it until it's saved with the new roll count, so no two
+// rollers continue the sequence from the same die.
+func (s *Service) forCombat(combat *models.Combat) (*Service, error) {
+        seed, err := hex.DecodeString(combat.DiceSeed)
+        if err != nil || len(seed) == 0 {
+                if seed, err = dnd5e.NewRollSeed(); err != nil {
+                        return nil, fmt.Errorf("failed to generate dice seed: %w", err)
+                }
+                combat.DiceSeed = hex.EncodeToString(seed)
+                combat.SeedCommitment = dnd5e.SeedCommitment(seed)
+                combat.RollCount = 0
         }
+        
+        scoped := *s
+        scoped.diceRoller = dnd5e.NewSeededDiceRoller(seed, combat.RollCount)
+        scoped.combatRules = dnd5e.NewCombatRules(scoped.diceRoller)
+        return &scoped, nil
 }
 
-// CreateCombat initializes a new combat session
-func (s *Service) CreateCombat(characters []*models.Character, monsters []*models.Monster, environment string, dmUserID string) (*models.Combat, error) {
+// CreateCombat initializes a new combat session, with the surprise and initiative totals
+// the DM gives in setup
+func (s *Service) CreateCombat(characters []*models.Character, monsters []*models.Monster, environment string, dmUserID string, setup InitiativeSetup) (*models.Combat, error) {
+        // Commit to the seed every die of the combat is rolled from before rolling any
+        combat := &models.Combat{}
+        s, err := s.forCombat(combat)
+        if err != nil {
+                return nil, err
+        }
+        
         // Create participants from characters and monsters
         participants := make([]*models.Combatant, 0, len(characters)+len(monsters))
         
         // Add characters as combatants
         for _, char := range characters {
-                participants = append(participants, &models.Combatant{
-                        ID:          char.ID,
-                        Type:        "character",
-                        Name:        char.Name,
-                        Initiative:  0, // Will be calculated later
-                        HP:          char.HitPoints,
-                        MaxHP:       char.MaxHitPoints,
-                        AC:          char.ArmorClass,
-                        Position:    [2]int{0, 0}, // Default position, will be updated later
-                        UserID:      char.UserID,
-                        CharacterID: char.ID,
-                        Stats:       char, // Store character data for reference
-                        Conditions:  []string{},
-                })
+                participant, err := s.characterCombatant(char)
+                if err != nil {
+                        return nil, err
+                }
+                participants = append(participants, participant)
         }
         
         // Add monsters as combatants
         for i, monster := range monsters {
-                monsterID := fmt.Sprintf("monster_%s_%d", monster.Index, i)
-                hp := s.diceRoller.RollHitPoints(monster.HitDice)
-                
-                participants = append(participants, &models.Combatant{
-                        ID:          monsterID,
-                        Type:        "monster",
-                        Name:        monster.Name,
-                        Initiative:  0, // Will be calculated later
-                        HP:          hp,
-                        MaxHP:       hp,
-                        AC:          monster.ArmorClass,
-                        Position:    [2]int{0, 0}, // Default position, will be updated later
-                        UserID:      dmUserID,    // DM controls all monsters
-                        MonsterID:   monster.Index,
-                        Stats:       monster, // Store monster data for reference
-                        Conditions:  []string{},
-                })
+                participants = append(participants, s.monsterCombatant(monster, fmt.Sprintf("monster_%s_%d", monster.Index, i), dmUserID))
+        }
+        
+        if err := checkSetup(setup, participants); err != nil {
+                return nil, err
         }
         
         // Roll initiative for all participants
-        initiative := s.rollInitiative(participants)
+        initiative := s.rollInitiative(participants, setup.Entered)
+        initiative = addLairInitiative(initiative, participants)
+        surprised := applySurprise(participants, setup.Surprised)
         
         // Create combat session
         // Convert []*models.Combatant to []models.Combatant
@@ -82,27 +111,61 @@ func (s *Service) CreateCombat(characters []*models.Character, monsters []*model
         // Get battlefield
         battlefield := s.createBattlefield(environment, participants)
         
-        combat := &models.Combat{
-                DMUserID:         dmUserID,
-                Initiative:       initiative,
-                Participants:     combatantSlice,
-                CurrentTurnIndex: 0,
-                RoundNumber:      1,
-                Status:           "active",
-                Environment:      environment,
-                Battlefield:      *battlefield,
-                CreatedAt:        time.Now(),
-                UpdatedAt:        time.Now(),
-        }
+        combat.DMUserID = dmUserID
+        combat.Initiative = initiative
+        combat.Participants = combatantSlice
+        combat.CurrentTurnIndex = 0
+        combat.RoundNumber = 1
+        combat.Status = "active"
+        combat.Environment = environment
+        combat.Battlefield = *battlefield
+        combat.CreatedAt = time.Now()
+        combat.UpdatedAt = time.Now()
+        combat.RollCount = s.diceRoller.Count()
         
         // Position participants on the battlefield
         s.positionParticipants(combat)
         
+        // Everyone starts with a full budget so they can react before their first turn,
+        // except surprised combatants
+        for i := range combat.Participants {
+                s.resetEconomy(&combat.Participants[i])
+                if combat.Participants[i].HasCondition("surprised") {
+                        combat.Participants[i].Economy.Reactions = 0
+                }
+        }
+        
         // Save to database
         if err := s.repo.Create(combat); err != nil {
                 return nil, err
         }
         
+        // Log the hit point and initiative rolls now that the combat has an ID to log against
+        description := "Combat begins: hit points and initiative are rolled."
+        if len(surprised) > 0 {
+                description += fmt.Sprintf(" Surprised: %s.", strings.Join(surprised, ", "))
+        }
+        if err := s.saveTurnEvents(combat, []*models.CombatAction{{
+                Type:              "initiative",
+                ResultDescription: description,
+                Rolls:             s.diceRoller.TakeRecords(),
+        }}); err != nil {
+                return nil, err
+        }
+        
+        // Start the first combatant's turn, skipping those who are surprised
+        events := s.startTurn(combat)
+        events = append(events, s.skipSurprisedTurns(combat)...)
+        if len(events) > 0 {
+                combat.RollCount = s.diceRoller.Count()
+                if err := s.repo.Update(combat); err != nil {
+                        return nil, err
+                }
+                if err := s.saveTurnEvents(combat, events); err != nil {
+                        return nil, err
+                }
+        }
+        
         return combat, nil
 }
 
@@ -160,6 +223,11 @@ func (s *Service) UserControlsActor(combat *models.Combat, userID string, actorI
 
 // ExecuteAction processes a combat action and returns the result
 func (s *Service) ExecuteAction(combat *models.Combat, action *models.CombatAction) (*models.ActionResult, error) {
+        s, err := s.forCombat(combat)
+        if err != nil {
+                return nil, err
+        }
+        
         // Validate the action
         if err := s.validateAction(combat, action); err != nil {
                 return nil, err
@@ -173,11 +241,16 @@ func (s *Service) ExecuteAction(combat *models.Combat, action *models.CombatActi
         
         // Process different action types
         var result *models.ActionResult
-        var err error
         
         switch action.Type {
         case "attack":
-                result, err = s.processAttack(combat, action, actor)
+                if actor.Type == "monster" {
+                        result, err = s.processMonsterAction(combat, action, actor)
+                } else {
+                        result, err = s.processAttack(combat, action, actor)
+                }
+        case "legendary_action", "lair_action":
+                result, err = s.processMonsterAction(combat, action, actor)
         case "cast_spell":
                 result, err = s.processSpellCast(combat, action, actor)
         case "move":
@@ -194,6 +267,20 @@ func (s *Service) ExecuteAction(combat *models.Combat, action *models.CombatActi
                 result, err = s.processDash(combat, action, actor)
         case "use_item":
                 result, err = s.processItemUse(combat, action, actor)
+        case "stabilize":
+                result, err = s.processStabilize(combat, action, actor)
+        case "use_feature":
+                result, err = s.processFeature(combat, action, actor)
+        case "offhand_attack":
+                result, err = s.processAttack(combat, action, actor)
+        case "grapple":
+                result, err = s.processGrapple(combat, action, actor)
+        case "shove":
+                result, err = s.processShove(combat, action, actor)
+        case "escape_grapple":
+                result, err = s.processEscapeGrapple(combat, action, actor)
+        case "ready":
+                result, err = s.processReady(combat, action, actor)
         default:
                 return nil, fmt.Errorf("unknown action type: %s", action.Type)
         }
@@ -202,18 +289,37 @@ func (s *Service) ExecuteAction(combat *models.Combat, action *models.CombatActi
                 return nil, err
         }
         
+        // Readied actions the action triggers are taken once it's resolved, logged after
+        // the dice rolled for the action itself
+        action.Rolls = s.diceRoller.TakeRecords()
+        if trigger := readiedTrigger(action); trigger != "" {
+                reactions, err := s.resolveReadiedActions(combat, actor, trigger, actor.Position)
+                if err != nil {
+                        return nil, err
+                }
+                result.Reactions = append(result.Reactions, reactions...)
+                for _, reaction := range reactions {
+                        result.Description += " " + reaction.Description
+                }
+        }
+        
+        // Use up the action, bonus action, reaction or movement the action needed
+        s.spendEconomy(combat, action, actor)
+        
         // Update combat with the action result
         if err := s.applyActionResult(combat, result); err != nil {
                 return nil, err
         }
         
-        // Save action to database
+        // Save action to database with the dice it rolled
         action.ResultDescription = result.Description
+        action.Rolls = append(action.Rolls, s.diceRoller.TakeRecords()...)
         if err := s.repo.SaveAction(action); err != nil {
                 return nil, err
         }
         
         // Update combat session in database
+        combat.RollCount = s.diceRoller.Count()
         if err := s.repo.Update(combat); err != nil {
                 return nil, err
         }
@@ -221,73 +327,40 @@ func (s *Service) ExecuteAction(combat *models.Combat, action *models.CombatActi
         return result, nil
 }
 
-// EndTurn advances to the next participant's turn
-func (s *Service) EndTurn(combat *models.Combat) error {
-        // Move to the next participant in initiative order
-        combat.CurrentTurnIndex++
-        
-        // If we've gone through everyone, start a new round
-        if combat.CurrentTurnIndex >= len(combat.Initiative) {
-                combat.CurrentTurnIndex = 0
-                combat.RoundNumber++
-                
-                // Process end-of-round effects (like saving throws against conditions)
-                s.processEndOfRound(combat)
+// EndTurn advances to the next participant's turn and returns the events that
+// happened at the end of the old turn and the start of the new one, such as
+// saving throws against conditions and death saving throws
+func (s *Service) EndTurn(combat *models.Combat) ([]*models.CombatAction, error) {
+        s, err := s.forCombat(combat)
+        if err != nil {
+                return nil, err
+        }
+        
+        // Move to the next participant in initiative order. Surprised combatants lose
+        // their first turn.
+        events := s.nextTurn(combat)
+        events = append(events, s.skipSurprisedTurns(combat)...)
+        
+        // A failed death save can end the combat
+        if err := s.applyActionResult(combat, nil); err != nil {
+                return nil, err
         }
         
         // Update in database
-        return s.repo.Update(combat)
-}
-
-// Helper methods
-
-// rollInitiative calculates initiative order for all participants
-func (s *Service) rollInitiative(participants []*models.Combatant) []models.InitiativeItem {
-        // Calculate initiative scores
-        for _, participant := range participants {
-                // Get the dexterity modifier
-                var dexMod int
-                if participant.Type == "character" {
-                        // For characters, calculate from dexterity
-                        char := participant.Stats.(*models.Character)
-                        dexMod = (char.Dexterity - 10) / 2
-                } else if participant.Type == "monster" {
-                        // For monsters, use their initiative modifier
-                        monster := participant.Stats.(*models.Monster)
-                        dexMod = monster.DexterityMod
-                }
-                
-                // Roll initiative: 1d20 + DEX modifier
-                initiative := s.diceRoller.Roll(1, 20) + dexMod
-                participant.Initiative = initiative
-        }
-        
-        // Sort participants by initiative (descending)
-        // Using a simple bubble sort for clarity
-        n := len(participants)
-        for i := 0; i < n-1; i++ {
-                for j := 0; j < n-i-1; j++ {
-                        if participants[j].Initiative < participants[j+1].Initiative {
-                                participants[j], participants[j+1] = participants[j+1], participants[j]
-                        }
-                }
+        combat.RollCount = s.diceRoller.Count()
+        if err := s.repo.Update(combat); err != nil {
+                return nil, err
         }
         
-        // Create the initiative order list
-        initiativeItems := make([]models.InitiativeItem, len(participants))
-        for i, participant := range participants {
-                initiativeItems[i] = models.InitiativeItem{
-                        ID:        participant.ID,
-                        Name:      participant.Name,
-                        Initiative: participant.Initiative,
-                        Dexterity: 0, // This is a placeholder, should be filled from the character/monster stats
-                        IsCharacter: participant.Type == "character",
-                }
+        if err := s.saveTurnEvents(combat, events); err != nil {
+                return nil, err
         }
         
-        return initiativeItems
+        return events, nil
 }
 
+// Helper methods
+
 // createBattlefield initializes the combat battlefield
 func (s *Service) createBattlefield(environment string, participants []*models.Combatant) *models.Battlefield {
         // Create a standard 10x10 grid battlefield
@@ -424,9 +497,9 @@ func (s *Service) validateAction(combat *models.Combat, action *models.CombatAct
                 return errors.New("combat is not active")
         }
         
-        // Check if it's the actor's turn
-        if !s.IsActorsTurn(combat, action.ActorID) {
-                return errors.New("it's not this actor's turn")
+        // Check if it's the actor's turn, or the turn they can take legendary or lair actions on
+        if err := s.validateTurn(combat, action); err != nil {
+                return err
         }
         
         // Actor must exist in combat
@@ -440,6 +513,16 @@ func (s *Service) validateAction(combat *models.Combat, action *models.CombatAct
                 return errors.New("actor is unconscious or dead")
         }
         
+        // Incapacitated actors (paralyzed, stunned, ...) can't take actions
+        if isIncapacitated(actor) && actionResource(action, actor) != resourceMovement {
+                return errors.New("actor is incapacitated and can't take actions")
+        }
+        
+        // Surprised actors can't act until their first turn is over
+        if err := validateSurprise(action, actor); err != nil {
+                return err
+        }
+        
         // Validate targets if provided
         if len(action.TargetIDs) > 0 {
                 for _, targetID := range action.TargetIDs {
@@ -450,14 +533,36 @@ func (s *Service) validateAction(combat *models.Combat, action *models.CombatAct
                 }
         }
         
+        // Actor must have the action, bonus action, reaction or movement left
+        if err := s.checkEconomy(combat, action, actor); err != nil {
+                return err
+        }
+        
         // Validate action-specific requirements
         switch action.Type {
         case "attack":
+                if actor.Type == "monster" {
+                        return s.validateMonsterAction(combat, action, actor)
+                }
                 return s.validateAttack(combat, action, actor)
+        case "legendary_action", "lair_action":
+                return s.validateMonsterAction(combat, action, actor)
         case "cast_spell":
                 return s.validateSpellCast(combat, action, actor)
         case "move":
                 return s.validateMovement(combat, action, actor)
+        case "stabilize":
+                return s.validateStabilize(combat, action, actor)
+        case "use_feature":
+                return s.validateFeature(combat, action, actor)
+        case "offhand_attack":
+                return s.validateOffHandAttack(combat, action, actor)
+        case "grapple", "shove":
+                return s.validateGrappleOrShove(combat, action, actor)
+        case "escape_grapple":
+                return s.validateEscapeGrapple(combat, action, actor)
+        case "ready":
+                return s.validateReady(combat, action, actor)
         }
         
         return nil
@@ -478,37 +583,27 @@ func (s *Service) validateAttack(combat *models.Combat, action *models.CombatAct
         // Check if target is in range
         target := s.getCombatant(combat, action.TargetIDs[0])
         
-        // Get weapon range
-        var weaponRange int
-        if actor.Type == "character" {
-                // For characters, check equipment
-                // Simple implementation - would need to be expanded to check actual equipment
-                weaponRange = 5 // Melee range by default
-                if action.WeaponName == "longbow" || action.WeaponName == "shortbow" {
-                        weaponRange = 80 // Ranged weapons
-                }
-        } else {
-                // For monsters, use action range from stats
-                monster := actor.Stats.(*models.Monster)
-                // Simple implementation - check monster actions
-                weaponRange = 5 // Melee range by default
-                for _, monsterAction := range monster.Actions {
-                        if monsterAction.Name == action.WeaponName {
-                                if monsterAction.Range > 0 {
-                                        weaponRange = monsterAction.Range
-                                }
-                                break
-                        }
+        // A charmed creature can't attack its charmer
+        for _, condition := range actor.Conditions {
+                if condition.Name == "charmed" && condition.SourceID == target.ID {
+                        return errors.New("actor is charmed by the target and can't attack them")
                 }
         }
         
-        // Calculate distance
-        distance := calculateDistance(actor.Position, target.Position)
-        if distance > weaponRange {
-                return fmt.Errorf("target is out of range (distance: %d, range: %d)", distance, weaponRange)
+        // The weapon must be known and wielded properly
+        attack, err := s.resolveAttack(actor, target, action)
+        if err != nil {
+                return err
         }
         
-        return nil
+        // Attacks at long range are allowed with disadvantage
+        distance := distanceFeet(actor.Position, target.Position)
+        if distance > attack.longRange {
+                return fmt.Errorf("target is out of range (distance: %d ft, range: %d ft)", distance, attack.longRange)
+        }
+        
+        // Class features the attack turns on, like Divine Smite, must be usable
+        return s.validateAttackFeatures(action, actor, attack)
 }
 
 // validateSpellCast checks if a spell casting action is valid
@@ -518,6 +613,11 @@ func (s *Service) validateSpellCast(combat *models.Combat, action *models.Combat
                 return errors.New("spell casting requires a spell")
         }
         
+        // Raging barbarians can't cast spells
+        if isRaging(actor) {
+                return errors.New("actor can't cast spells while raging")
+        }
+        
         // Character-specific validation
         if actor.Type == "character" {
                 char := actor.Stats.(*models.Character)
@@ -535,13 +635,26 @@ func (s *Service) validateSpellCast(combat *models.Combat, action *models.Combat
                         return errors.New("character doesn't know this spell")
                 }
                 
-                // Would need more validation for spell slots, components, etc.
+                // Would need more validation for components, etc.
+        }
+        
+        // Shield is only cast as a reaction to being hit
+        if action.SpellID == "shield" {
+                return errors.New("shield can only be cast as a reaction to being hit")
         }
         
-        // Target validation depends on the spell
-        // Would need to lookup spell targeting requirements from SRD
+        // Target validation depends on the spell's SRD range
+        spell, err := s.srdClient.GetSpell(action.SpellID)
+        if err != nil {
+                return fmt.Errorf("failed to look up spell '%s': %w", action.SpellID, err)
+        }
         
-        return nil
+        // The caster needs a spell slot of the chosen level
+        if err := s.validateSpellSlot(action, actor, spell); err != nil {
+                return err
+        }
+        
+        return s.validateSpellTargets(combat, action, actor, spell)
 }
 
 // validateMovement checks if a movement action is valid
@@ -551,22 +664,16 @@ func (s *Service) validateMovement(combat *models.Combat, action *models.CombatA
                 return errors.New("movement requires a path")
         }
         
-        // Calculate movement speed
-        var speed int
-        if actor.Type == "character" {
-                // Simple implementation - base speed
-                speed = 30
-        } else {
-                monster := actor.Stats.(*models.Monster)
-                speed = monster.Speed.Walk
-        }
-        
-        // Convert to grid movement (assuming 5ft per grid)
-        maxSquares := speed / 5
+        // Path length against remaining movement is checked by checkEconomy
         
-        // Check if path is too long
-        if len(action.MovementPath) > maxSquares {
-                return fmt.Errorf("movement path exceeds speed (max: %d squares)", maxSquares)
+        // Frightened creatures can't willingly move closer to the source of their fear
+        var fearSources []*models.Combatant
+        for _, condition := range actor.Conditions {
+                if condition.Name == "frightened" && condition.SourceID != "" {
+                        if source := s.getCombatant(combat, condition.SourceID); source != nil {
+                                fearSources = append(fearSources, source)
+                        }
+                }
         }
         
         // Check for valid path
@@ -579,11 +686,7 @@ func (s *Service) validateMovement(combat *models.Combat, action *models.CombatA
                 }
                 
                 // Check if position is blocked
-                posKey := fmt.Sprintf("%d,%d", pos[0], pos[1])
-                if combat.Battlefield.Grid[posKey] == "wall" || 
-                   combat.Battlefield.Grid[posKey] == "tree" ||
-                   combat.Battlefield.Grid[posKey] == "rock" ||
-                   combat.Battlefield.Obstacles[posKey] {
+                if blocksMovement(combat.Battlefield, pos) {
                         return errors.New("movement path is blocked by an obstacle")
                 }
                 
@@ -601,12 +704,28 @@ func (s *Service) validateMovement(combat *models.Combat, action *models.CombatA
                         return errors.New("invalid movement: can only move to adjacent squares")
                 }
                 
+                for _, source := range fearSources {
+                        if distanceFeet(pos, source.Position) < distanceFeet(currentPos, source.Position) {
+                                return fmt.Errorf("actor is frightened of %s and can't move closer", source.Name)
+                        }
+                }
+                
                 currentPos = pos
         }
         
         return nil
 }
 
+// blocksMovement reports whether a cell holds a wall, tree, rock or other obstacle
+func blocksMovement(battlefield models.Battlefield, pos [2]int) bool {
+        posKey := fmt.Sprintf("%d,%d", pos[0], pos[1])
+        switch battlefield.Grid[posKey] {
+        case "wall", "tree", "rock":
+                return true
+        }
+        return battlefield.Obstacles[posKey]
+}
+
 // processAttack handles an attack action
 func (s *Service) processAttack(combat *models.Combat, action *models.CombatAction, actor *models.Combatant) (*models.ActionResult, error) {
         target := s.getCombatant(combat, action.TargetIDs[0])
@@ -617,180 +736,127 @@ func (s *Service) processAttack(combat *models.Combat, action *models.CombatActi
                 Description: "",
         }
         
-        // Roll to hit
-        var attackBonus int
-        var damage int
-        var damageType string
-        
-        if actor.Type == "character" {
-                char := actor.Stats.(*models.Character)
-                
-                // Calculate attack bonus based on weapon and stats
-                // Simple implementation
-                if action.WeaponName == "longbow" || action.WeaponName == "shortbow" {
-                        // Ranged weapons use DEX
-                        attackBonus = (char.Dexterity - 10) / 2
-                } else {
-                        // Melee weapons use STR by default
-                        attackBonus = (char.Strength - 10) / 2
-                }
-                
-                // Add proficiency bonus based on level
-                profBonus := 2
-                if char.Level >= 5 {
-                        profBonus = 3
-                }
-                if char.Level >= 9 {
-                        profBonus = 4
-                }
-                if char.Level >= 13 {
-                        profBonus = 5
-                }
-                if char.Level >= 17 {
-                        profBonus = 6
-                }
-                
-                attackBonus += profBonus
-                
-                // Determine damage based on weapon
-                switch action.WeaponName {
-                case "longsword":
-                        damage = s.diceRoller.Roll(1, 8) + (char.Strength - 10) / 2
-                        damageType = "slashing"
-                case "longbow":
-                        damage = s.diceRoller.Roll(1, 8) + (char.Dexterity - 10) / 2
-                        damageType = "piercing"
-                case "dagger":
-                        damage = s.diceRoller.Roll(1, 4) + (char.Strength - 10) / 2
-                        damageType = "piercing"
-                default:
-                        damage = s.diceRoller.Roll(1, 6) + (char.Strength - 10) / 2
-                        damageType = "bludgeoning"
-                }
-        } else {
-                // Monster attack
-                monster := actor.Stats.(*models.Monster)
-                
-                // Find the action for this weapon
-                for _, monsterAction := range monster.Actions {
-                        if monsterAction.Name == action.WeaponName {
-                                attackBonus = monsterAction.AttackBonus
-                                
-                                // Parse damage (simplified)
-                                damage = s.diceRoller.Roll(monsterAction.Damage.DiceCount, monsterAction.Damage.DiceValue) + monsterAction.Damage.Bonus
-                                damageType = monsterAction.Damage.Type
-                                break
-                        }
+        // Attack bonus, damage and range come from the weapon catalog or the monster's action
+        attack, err := s.resolveAttack(actor, target, action)
+        if err != nil {
+                return nil, err
+        }
+        attackBonus := attack.attackBonus
+        damageDice := attack.damage
+        reducesMaxHP := attack.reducesMaxHP
+        
+        // Attacking with a light melee weapon on their turn allows an off-hand attack with
+        // another one, so the weapon in their main hand is remembered
+        if action.Type == "attack" && action.ExtraData["reaction"] == nil && attack.weapon != nil &&
+                attack.weapon.HasProperty("light") && !attack.weapon.IsRanged() {
+                actor.Economy.OffHandAttack = true
+                if actor.Economy.MainHandWeapon == "" {
+                        actor.Economy.MainHandWeapon = attack.weapon.Index
                 }
         }
         
+        // Collect every source of advantage and disadvantage
+        mode, autoCritical := s.attackRollMode(combat, actor, target, attack.ranged, attack.normalRange)
+        if attack.heavy && isSmall(actor) {
+                mode.addDisadvantage("%s is too small to wield a heavy weapon", actor.Name)
+        }
+        mode.record(result)
+        
         // Roll attack
-        attackRoll := s.diceRoller.Roll(1, 20)
+        s.diceRoller.Label("attack roll", actor.ID)
+        attackRoll := s.rollD20(mode)
+        s.diceRoller.AddModifier(attackBonus)
         totalAttack := attackRoll + attackBonus
         
+        // Attacking gives away a hidden attacker's position, and Help is used up
+        actor.RemoveCondition("hidden")
+        actor.RemoveCondition("helped")
+        targetAC := armorClass(target)
+        
         // Check for critical hit or miss
-        isCritical := attackRoll == 20
+        isCritical := attackRoll == 20 || (autoCritical && attackRoll != 1 && totalAttack >= targetAC)
         isCritMiss := attackRoll == 1
         
         if isCritMiss {
-                result.Description = fmt.Sprintf("%s critically misses their attack with %s against %s!", 
-                        actor.Name, action.WeaponName, target.Name)
+                result.Description = fmt.Sprintf("%s critically misses their attack with %s against %s!%s", 
+                        actor.Name, action.WeaponName, target.Name, mode.describe())
                 return result, nil
         }
         
-        if isCritical {
-                // Double damage dice on critical hit
-                if actor.Type == "character" {
-                        // Roll extra damage dice based on weapon
-                        switch action.WeaponName {
-                        case "longsword":
-                                damage += s.diceRoller.Roll(1, 8)
-                        case "longbow":
-                                damage += s.diceRoller.Roll(1, 8)
-                        case "dagger":
-                                damage += s.diceRoller.Roll(1, 4)
-                        default:
-                                damage += s.diceRoller.Roll(1, 6)
-                        }
-                } else {
-                        // Monster critical hit
-                        monster := actor.Stats.(*models.Monster)
-                        
-                        for _, monsterAction := range monster.Actions {
-                                if monsterAction.Name == action.WeaponName {
-                                        damage += s.diceRoller.Roll(monsterAction.Damage.DiceCount, monsterAction.Damage.DiceValue)
-                                        break
-                                }
-                        }
+        // Check if attack hits
+        hits := isCritical || totalAttack >= targetAC
+        
+        // The target may answer a hit that Shield would turn into a miss
+        shieldDescription := ""
+        if hits && attackRoll != 20 && totalAttack < targetAC+5 {
+                var err error
+                shieldDescription, err = s.offerShield(combat, actor, target)
+                if err != nil {
+                        return nil, err
+                }
+                if shieldDescription != "" {
+                        targetAC = armorClass(target)
+                        hits = totalAttack >= targetAC
                 }
         }
         
-        // Check if attack hits
-        hits := isCritical || totalAttack >= target.AC
-        
         if !hits {
-                result.Description = fmt.Sprintf("%s attacks %s with %s but misses! (Rolled %d + %d = %d vs AC %d)", 
-                        actor.Name, target.Name, action.WeaponName, attackRoll, attackBonus, totalAttack, target.AC)
+                result.Description = fmt.Sprintf("%s attacks %s with %s but misses! (Rolled %d + %d = %d vs AC %d)%s", 
+                        actor.Name, target.Name, action.WeaponName, attackRoll, attackBonus, totalAttack, targetAC, mode.describe())
+                if shieldDescription != "" {
+                        result.Description = shieldDescription + " " + result.Description
+                }
                 return result, nil
         }
         
-        // Apply damage
-        result.Success = true
-        result.Damage = damage
-        
-        // Update target HP
-        newHP := target.HP - damage
-        if newHP < 0 {
-                newHP = 0
-        }
-        target.HP = newHP
-        
-        // Check if target is defeated
-        if target.HP == 0 {
-                if target.Type == "character" {
-                        // Characters are unconscious at 0 HP
-                        target.Conditions = append(target.Conditions, "unconscious")
-                        hitDescription := "hits"
-                        if isCritical {
-                                hitDescription = "critically hits"
-                        }
-                        result.Description = fmt.Sprintf("%s %s %s with %s for %d %s damage! %s falls unconscious!", 
-                                actor.Name, 
-                                hitDescription,
-                                target.Name, 
-                                action.WeaponName, 
-                                damage,
-                                damageType,
-                                target.Name)
-                } else {
-                        // Monsters are dead at 0 HP
-                        hitDescription := "hits"
-                        if isCritical {
-                                hitDescription = "critically hits"
-                        }
-                        result.Description = fmt.Sprintf("%s %s %s with %s for %d %s damage! %s is defeated!", 
-                                actor.Name, 
-                                hitDescription,
-                                target.Name, 
-                                action.WeaponName, 
-                                damage,
-                                damageType,
-                                target.Name)
-                }
-        } else {
-                hitDescription := "hits"
-                if isCritical {
-                        hitDescription = "critically hits"
+        // Class features like Sneak Attack and Divine Smite add to a character's damage
+        featureDescription := ""
+        if actor.Type == "character" {
+                hit := &weaponHit{
+                        combat:   combat,
+                        action:   action,
+                        actor:    actor,
+                        target:   target,
+                        attack:   attack,
+                        mode:     mode,
+                        critical: isCritical,
+                        damage:   append([]models.DamageInfo(nil), damageDice...),
                 }
-                result.Description = fmt.Sprintf("%s %s %s with %s for %d %s damage! (HP: %d/%d)", 
-                        actor.Name, 
-                        hitDescription,
-                        target.Name, 
-                        action.WeaponName, 
-                        damage,
-                        damageType,
-                        target.HP,
-                        target.MaxHP)
+                featureDescription = s.applyHitFeatures(hit)
+                damageDice = hit.damage
+        }
+        
+        // Apply damage, doubling the damage dice on a critical hit
+        s.diceRoller.Label("damage", actor.ID)
+        damage := s.rollDamage(damageDice, isCritical)
+        dealt, damageDescription := s.applyDamage(combat, target, isCritical, damage...)
+        if reducesMaxHP && dealt > 0 {
+                damageDescription += " " + reduceMaxHP(target, dealt)
+        }
+        result.Success = true
+        result.RawDamage = totalDamage(damage)
+        result.Damage = dealt
+        if len(damage) > 0 {
+                result.DamageType = damage[0].Type
+        }
+        
+        hitDescription := "hits"
+        if isCritical {
+                hitDescription = "critically hits"
+        }
+        result.Description = fmt.Sprintf("%s %s %s with %s for %s damage!%s %s", 
+                actor.Name, 
+                hitDescription,
+                target.Name, 
+                action.WeaponName, 
+                describeDamage(damage),
+                mode.describe(),
+                damageDescription)
+        if featureDescription != "" {
+                result.Description = featureDescription + " " + result.Description
+        }
+        if shieldDescription != "" {
+                result.Description = shieldDescription + " " + result.Description
         }
         
         return result, nil
@@ -798,130 +864,93 @@ func (s *Service) processAttack(combat *models.Combat, action *models.CombatActi
 
 // processSpellCast handles a spell casting action
 func (s *Service) processSpellCast(combat *models.Combat, action *models.CombatAction, actor *models.Combatant) (*models.ActionResult, error) {
-        result := &models.ActionResult{
-                Success:     true,
-                Damage:      0,
-                Description: "",
+        // Spells are resolved from their SRD data unless they have a bespoke handler
+        spell, err := s.srdClient.GetSpell(action.SpellID)
+        if err != nil {
+                return nil, fmt.Errorf("failed to look up spell '%s': %w", action.SpellID, err)
         }
         
-        // This would typically involve looking up the spell in the SRD API
-        // For simplicity, we'll implement a few basic spells
+        // Concentrating on a new spell ends the previous one before its effects are applied,
+        // but the previous one only gives way to the new one once it has been cast
+        stopped, restore := "", func() {}
+        if spell.Concentration {
+                stopped, restore = s.stopConcentration(combat, actor)
+        }
         
-        switch action.SpellID {
-        case "cure-wounds":
-                // Healing spell
-                if len(action.TargetIDs) == 0 {
-                        return nil, errors.New("cure wounds requires a target")
-                }
-                
-                target := s.getCombatant(combat, action.TargetIDs[0])
-                
-                // Calculate healing amount
-                var spellcastingMod int
-                if actor.Type == "character" {
-                        char := actor.Stats.(*models.Character)
-                        // Use the appropriate spellcasting ability modifier
-                        // This is a simplification - different classes use different abilities
-                        spellcastingMod = (char.Wisdom - 10) / 2
-                } else {
-                        spellcastingMod = 3 // Default for monsters
-                }
-                
-                // 1d8 + spellcasting modifier
-                healing := s.diceRoller.Roll(1, 8) + spellcastingMod
-                
-                // Apply healing
-                newHP := target.HP + healing
-                if newHP > target.MaxHP {
-                        newHP = target.MaxHP
-                }
-                
-                oldHP := target.HP
-                target.HP = newHP
-                
-                // Remove unconscious condition if healed from 0 HP
-                if oldHP == 0 && newHP > 0 {
-                        for i, condition := range target.Conditions {
-                                if condition == "unconscious" {
-                                        // Remove the condition
-                                        target.Conditions = append(target.Conditions[:i], target.Conditions[i+1:]...)
-                                        break
-                                }
-                        }
-                }
-                
-                result.Description = fmt.Sprintf("%s casts Cure Wounds on %s, healing %d damage! (HP: %d/%d)",
-                        actor.Name, target.Name, healing, target.HP, target.MaxHP)
-                
-        case "magic-missile":
-                // Damage spell that always hits
-                if len(action.TargetIDs) == 0 {
-                        return nil, errors.New("magic missile requires a target")
-                }
-                
-                target := s.getCombatant(combat, action.TargetIDs[0])
-                
-                // 3 missiles, each dealing 1d4+1 force damage
-                totalDamage := 0
-                for i := 0; i < 3; i++ {
-                        damage := s.diceRoller.Roll(1, 4) + 1
-                        totalDamage += damage
-                }
-                
-                // Apply damage
-                newHP := target.HP - totalDamage
-                if newHP < 0 {
-                        newHP = 0
-                }
-                target.HP = newHP
-                
-                result.Damage = totalDamage
-                
-                // Check if target is defeated
-                if target.HP == 0 {
-                        if target.Type == "character" {
-                                // Characters are unconscious at 0 HP
-                                target.Conditions = append(target.Conditions, "unconscious")
-                                result.Description = fmt.Sprintf("%s casts Magic Missile at %s, dealing %d force damage! %s falls unconscious!", 
-                                        actor.Name, target.Name, totalDamage, target.Name)
-                        } else {
-                                // Monsters are dead at 0 HP
-                                result.Description = fmt.Sprintf("%s casts Magic Missile at %s, dealing %d force damage! %s is defeated!", 
-                                        actor.Name, target.Name, totalDamage, target.Name)
-                        }
-                } else {
-                        result.Description = fmt.Sprintf("%s casts Magic Missile at %s, dealing %d force damage! (HP: %d/%d)", 
-                                actor.Name, target.Name, totalDamage, target.HP, target.MaxHP)
-                }
-                
-        case "shield":
-                // Defensive spell
-                result.Description = fmt.Sprintf("%s casts Shield, granting +5 AC until the start of their next turn!",
-                        actor.Name)
-                
-                // Add temporary condition
-                actor.Conditions = append(actor.Conditions, "shield")
-                actor.AC += 5 // Temporary AC boost
-                
-        default:
-                return nil, fmt.Errorf("spell '%s' not implemented", action.SpellID)
+        var result *models.ActionResult
+        if handler, ok := spellHandlers[spell.Index]; ok {
+                result, err = handler(s, combat, action, actor, spell)
+        } else {
+                result, err = s.resolveSpell(combat, action, actor, spell)
+        }
+        if err != nil {
+                restore()
+                return nil, err
         }
         
+        if spell.Concentration {
+                startConcentration(actor, spell, spellDurationTurns(spell.Duration))
+        }
+        if stopped != "" {
+                result.Description = stopped + " " + result.Description
+        }
+        
+        s.spendSpellSlot(action, actor, spell)
         return result, nil
 }
 
 // processMovement handles a movement action
 func (s *Service) processMovement(combat *models.Combat, action *models.CombatAction, actor *models.Combatant) (*models.ActionResult, error) {
-        // Update actor position to the end of the path
+        // Walk the path one square at a time so leaving a hostile's reach can provoke
         if len(action.MovementPath) > 0 {
                 oldPos := actor.Position
-                actor.Position = action.MovementPath[len(action.MovementPath)-1]
+                result := &models.ActionResult{Success: true}
                 
-                return &models.ActionResult{
-                        Success:     true,
-                        Description: fmt.Sprintf("%s moves from [%d,%d] to [%d,%d]", 
-                                actor.Name, oldPos[0], oldPos[1], actor.Position[0], actor.Position[1]),
-                }, nil
+                // Standing up from prone costs half the actor's speed
+                standDescription := ""
+                if cost := standUpCost(actor); cost > 0 {
+                        actor.Economy.MovementLeft -= cost
+                        actor.RemoveCondition("prone")
+                        standDescription = fmt.Sprintf("%s stands up. ", actor.Name)
+                }
+                
+                for i, pos := range action.MovementPath {
+                        reactions, err := s.resolveOpportunityAttacks(combat, actor, pos)
+                        if err != nil {
+                                return nil, err
+                        }
+                        result.Reactions = append(result.Reactions, reactions...)
+                        
+                        // Movement stops if an opportunity attack drops the mover
+                        if actor.HP <= 0 {
+                                action.MovementPath = action.MovementPath[:i]
+                                break
+                        }
+                        
+                        // Grappled creatures are dragged along
+                        from := actor.Position
+                        actor.Position = pos
+                        dragGrappled(combat, actor, from)
+                        
+                        // Readied actions waiting for the actor to move or come close
+                        reactions, err = s.resolveReadiedActions(combat, actor, triggerMoves, from)
+                        if err != nil {
+                                return nil, err
+                        }
+                        result.Reactions = append(result.Reactions, reactions...)
+                        if actor.HP <= 0 {
+                                action.MovementPath = action.MovementPath[:i+1]
+                                break
+                        }
+                }
+                
+                result.Description = standDescription + fmt.Sprintf("%s moves from [%d,%d] to [%d,%d]", 
+                        actor.Name, oldPos[0], oldPos[1], actor.Position[0], actor.Position[1])
+                for _, reaction := range result.Reactions {
+                        result.Description += " " + reaction.Description
+                }
+                
+                return result, nil
         }
         
         return &models.ActionResult{
@@ -932,8 +961,13 @@ func (s *Service) processMovement(combat *models.Combat, action *models.CombatAc
 
 // processDodge handles a dodge action
 func (s *Service) processDodge(combat *models.Combat, action *models.CombatAction, actor *models.Combatant) (*models.ActionResult, error) {
-        // Add dodge condition
-        actor.Conditions = append(actor.Conditions, "dodge")
+        // Dodging lasts until the start of the actor's next turn
+        s.addCondition(actor, models.Condition{
+                Name:        "dodge",
+                SourceID:    actor.ID,
+                Duration:    1,
+                StartOfTurn: true,
+        })
         
         return &models.ActionResult{
                 Success:     true,
@@ -950,7 +984,12 @@ func (s *Service) processHelp(combat *models.Combat, action *models.CombatAction
         target := s.getCombatant(combat, action.TargetIDs[0])
         
         // Add help condition to the target
-        target.Conditions = append(target.Conditions, "helped")
+        s.addCondition(target, models.Condition{
+                Name:      "helped",
+                SourceID:  actor.ID,
+                Duration:  1,
+                EndOfTurn: true,
+        })
         
         return &models.ActionResult{
                 Success:     true,
@@ -961,31 +1000,45 @@ func (s *Service) processHelp(combat *models.Combat, action *models.CombatAction
 
 // processHide handles a hide action
 func (s *Service) processHide(combat *models.Combat, action *models.CombatAction, actor *models.Combatant) (*models.ActionResult, error) {
-        // Roll stealth check
-        var stealthMod int
-        if actor.Type == "character" {
-                char := actor.Stats.(*models.Character)
-                stealthMod = (char.Dexterity - 10) / 2
-        } else {
-                monster := actor.Stats.(*models.Monster)
-                stealthMod = monster.DexterityMod
-        }
+        // Roll a Stealth check
+        stealthMod := skillBonus(actor, "stealth")
         
-        stealthRoll := s.diceRoller.Roll(1, 20) + stealthMod
+        mode := checkRollMode(actor)
+        if char, ok := actor.Stats.(*models.Character); ok {
+                if armor := char.WornArmor(); armor != nil && armor.StealthDisadvantage {
+                        mode.addDisadvantage("%s's armor is noisy", actor.Name)
+                }
+        }
+        s.diceRoller.Label("stealth check", actor.ID)
+        stealthRoll := s.rollD20(mode) + stealthMod
+        s.diceRoller.AddModifier(stealthMod)
+        actor.RemoveCondition("helped")
         
         // Add hidden condition with the stealth value
-        actor.Conditions = append(actor.Conditions, fmt.Sprintf("hidden:%d", stealthRoll))
+        s.addCondition(actor, models.Condition{
+                Name:     "hidden",
+                SourceID: actor.ID,
+                Value:    stealthRoll,
+        })
         
-        return &models.ActionResult{
+        result := &models.ActionResult{
                 Success:     true,
-                Description: fmt.Sprintf("%s attempts to hide, rolling a %d for Stealth", actor.Name, stealthRoll),
-        }, nil
+                Description: fmt.Sprintf("%s attempts to hide, rolling a %d for Stealth%s", actor.Name, stealthRoll, mode.describe()),
+        }
+        mode.record(result)
+        
+        return result, nil
 }
 
 // processDisengage handles a disengage action
 func (s *Service) processDisengage(combat *models.Combat, action *models.CombatAction, actor *models.Combatant) (*models.ActionResult, error) {
-        // Add disengage condition
-        actor.Conditions = append(actor.Conditions, "disengage")
+        // Disengaging lasts for the rest of the actor's turn
+        s.addCondition(actor, models.Condition{
+                Name:      "disengage",
+                SourceID:  actor.ID,
+                Duration:  1,
+                EndOfTurn: true,
+        })
         
         return &models.ActionResult{
                 Success:     true,
@@ -995,12 +1048,13 @@ func (s *Service) processDisengage(combat *models.Combat, action *models.CombatA
 
 // processDash handles a dash action
 func (s *Service) processDash(combat *models.Combat, action *models.CombatAction, actor *models.Combatant) (*models.ActionResult, error) {
-        // Add dash condition
-        actor.Conditions = append(actor.Conditions, "dash")
+        // Dashing grants extra movement equal to the actor's speed for this turn
+        actor.Economy.MovementLeft += actor.Economy.Speed
         
         return &models.ActionResult{
                 Success:     true,
-                Description: fmt.Sprintf("%s takes the Dash action, doubling their movement speed until the end of their turn", actor.Name),
+                Description: fmt.Sprintf("%s takes the Dash action, gaining %d ft of movement (%d ft left this turn)",
+                        actor.Name, actor.Economy.Speed, actor.Economy.MovementLeft),
         }, nil
 }
 
@@ -1012,30 +1066,44 @@ func (s *Service) processItemUse(combat *models.Combat, action *models.CombatAct
                 return nil, errors.New("item name not provided or invalid")
         }
         
+        // Items come out of the character's inventory
+        index := equipmentIndex(itemName)
+        if alias, ok := itemAliases[index]; ok {
+                index = alias
+        }
+        char, ok := actor.Stats.(*models.Character)
+        if !ok || char.GetItem(index) == nil {
+                return nil, fmt.Errorf("%s has no %s", actor.Name, itemName)
+        }
+        
         var result *models.ActionResult
         
         // Process different item types
-        switch itemName {
-        case "healing-potion":
+        switch index {
+        case "potion-of-healing":
                 // Heal 2d4+2 hit points
+                s.diceRoller.Label("healing potion", actor.ID)
                 healing := s.diceRoller.Roll(2, 4) + 2
+                s.diceRoller.AddModifier(2)
                 
                 // Apply healing
-                newHP := actor.HP + healing
-                if newHP > actor.MaxHP {
-                        newHP = actor.MaxHP
-                }
-                actor.HP = newHP
+                s.applyHealing(actor, healing)
                 
                 result = &models.ActionResult{
                         Success:     true,
-                        Description: fmt.Sprintf("%s drinks a Healing Potion, recovering %d hit points! (HP: %d/%d)",
-                                actor.Name, healing, actor.HP, actor.MaxHP),
+                        Healing:     healing,
+                        Description: fmt.Sprintf("%s drinks a Healing Potion, recovering %d hit points! %s",
+                                actor.Name, healing, hpStatus(actor)),
                 }
                 
-        case "antitoxin":
+        case "antitoxin-vial":
                 // Gain advantage on saving throws against poison
-                actor.Conditions = append(actor.Conditions, "antitoxin")
+                s.addCondition(actor, models.Condition{
+                        Name:      "antitoxin",
+                        SourceID:  actor.ID,
+                        Duration:  600, // 1 hour in 6-second turns
+                        EndOfTurn: true,
+                })
                 
                 result = &models.ActionResult{
                         Success:     true,
@@ -1047,25 +1115,37 @@ func (s *Service) processItemUse(combat *models.Combat, action *models.CombatAct
                 return nil, fmt.Errorf("item '%s' not implemented", itemName)
         }
         
+        char.RemoveItem(index, 1)
         return result, nil
 }
 
+// itemAliases maps the item names use_item accepted before inventories to SRD equipment indexes
+var itemAliases = map[string]string{
+        "healing-potion": "potion-of-healing",
+        "antitoxin":      "antitoxin-vial",
+}
+
 // applyActionResult updates the combat state based on action results
 func (s *Service) applyActionResult(combat *models.Combat, result *models.ActionResult) error {
+        // Grapples end when the grappler can't hold on or the target is out of reach
+        s.releaseBrokenGrapples(combat)
+        
         // Check if any participants are defeated
         allMonstersDead := true
         allPlayersDead := true
         
-        for _, participant := range combat.Participants {
-                if participant.HP <= 0 {
-                        if participant.Type == "monster" {
+        for i := range combat.Participants {
+                participant := &combat.Participants[i]
+                if participant.Type == "monster" {
+                        if participant.HP <= 0 {
                                 // Remove dead monsters from the battlefield
                                 participant.Position = [2]int{-1, -1}
-                        }
-                } else {
-                        if participant.Type == "monster" {
+                        } else {
                                 allMonstersDead = false
-                        } else if participant.Type == "character" {
+                        }
+                } else if participant.Type == "character" {
+                        // The party only loses once every character is dead or dying
+                        if participant.HP > 0 || participant.DeathSaves.Stable {
                                 allPlayersDead = false
                         }
                 }
@@ -1074,47 +1154,19 @@ func (s *Service) applyActionResult(combat *models.Combat, result *models.Action
         // Check for combat end conditions
         if allMonstersDead {
                 combat.Status = "victory"
+                combat.Seed = combat.DiceSeed // The combat is over, so its dice can be verified
                 return nil
         }
         
         if allPlayersDead {
                 combat.Status = "defeat"
+                combat.Seed = combat.DiceSeed
                 return nil
         }
         
         return nil
 }
 
-// processEndOfRound handles end-of-round effects
-func (s *Service) processEndOfRound(combat *models.Combat) {
-        for _, participant := range combat.Participants {
-                // Process ongoing conditions
-                newConditions := []string{}
-                
-                for _, condition := range participant.Conditions {
-                        // Check for temporary conditions that should expire
-                        switch condition {
-                        case "shield", "dodge", "disengage", "dash", "helped":
-                                // These conditions expire at the end of the round
-                                continue
-                        default:
-                                // Keep other conditions
-                                newConditions = append(newConditions, condition)
-                        }
-                }
-                
-                // Update conditions
-                participant.Conditions = newConditions
-                
-                // Reset AC if shield condition was removed
-                if containsString(participant.Conditions, "shield") && !containsString(newConditions, "shield") {
-                        participant.AC -= 5 // Remove Shield spell AC bonus
-                }
-                
-                // Process ongoing damage (not implemented in this simple version)
-        }
-}
-
 // Helper functions
 
 // calculateDistance calculates the distance between two positions on the grid
@@ -1123,6 +1175,17 @@ func calculateDistance(pos1, pos2 [2]int) int {
         return abs(pos1[0]-pos2[0]) + abs(pos1[1]-pos2[1])
 }
 
+// distanceFeet calculates the distance in feet between two grid positions,
+// counting diagonal squares as 5 feet
+func distanceFeet(pos1, pos2 [2]int) int {
+        dx := abs(pos1[0] - pos2[0])
+        dy := abs(pos1[1] - pos2[1])
+        if dx > dy {
+                return dx * 5
+        }
+        return dy * 5
+}
+
 // abs returns the absolute value of an integer
 func abs(x int) int {
         if x < 0 {
@@ -0,0 +1,114 @@
+package combat
+
+import (
+        "fmt"
+        "strings"
+
+        "dnd-combat/internal/models"
+)
+
+// spellcastingAbilities maps each spellcasting class to the ability it casts spells with
+var spellcastingAbilities = map[string]string{
+        "bard":     "cha",
+        "cleric":   "wis",
+        "druid":    "wis",
+        "paladin":  "cha",
+        "ranger":   "wis",
+        "sorcerer": "cha",
+        "warlock":  "cha",
+        "wizard":   "int",
+}
+
+// spellcastingAbility returns the ability a character casts spells with, or "" for
+// characters without a spellcasting class
+func spellcastingAbility(char *models.Character) string {
+        return spellcastingAbilities[strings.ToLower(char.Class)]
+}
+
+// spellcastingModifier returns the ability modifier a combatant casts spells with
+func (s *Service) spellcastingModifier(actor *models.Combatant) int {
+        if char, ok := actor.Stats.(*models.Character); ok {
+                if ability := spellcastingAbility(char); ability != "" {
+                        return abilityModifier(actor, ability)
+                }
+                return 0
+        }
+        return 3 // Default for monsters
+}
+
+// spellSaveDC returns the DC of saving throws against a combatant's spells
+func (s *Service) spellSaveDC(actor *models.Combatant) int {
+        return 8 + proficiencyBonus(actor) + s.spellcastingModifier(actor)
+}
+
+// spellAttackBonus returns the bonus a combatant adds to spell attack rolls
+func (s *Service) spellAttackBonus(actor *models.Combatant) int {
+        return proficiencyBonus(actor) + s.spellcastingModifier(actor)
+}
+
+// prepareSpellSlots sets a character's spell slots from the SRD table for their class and
+// level, keeping track of the slots they have already used
+func (s *Service) prepareSpellSlots(char *models.Character) error {
+        if spellcastingAbility(char) == "" {
+                return nil
+        }
+
+        slots, err := s.srdClient.GetSpellSlots(char.Class, char.Level)
+        if err != nil {
+                return fmt.Errorf("failed to look up spell slots for %s: %w", char.Name, err)
+        }
+
+        for i := range slots {
+                if existing := char.GetSpellSlot(slots[i].Level); existing != nil {
+                        slots[i].Used = existing.Used
+                        if slots[i].Used > slots[i].Max {
+                                slots[i].Used = slots[i].Max
+                        }
+                }
+        }
+
+        char.SpellSlots = slots
+        return nil
+}
+
+// castingSlotLevel returns the slot level a spell is cast at, which is the spell's own
+// level unless the caster upcasts it
+func castingSlotLevel(action *models.CombatAction, spell *models.Spell) int {
+        if spell.Level == 0 || action.SlotLevel == 0 {
+                return spell.Level
+        }
+        return action.SlotLevel
+}
+
+// validateSpellSlot checks that a character has a spell slot to cast a spell at the chosen level
+func (s *Service) validateSpellSlot(action *models.CombatAction, actor *models.Combatant, spell *models.Spell) error {
+        char, ok := actor.Stats.(*models.Character)
+        if !ok || spell.Level == 0 {
+                // Monsters don't track slots and cantrips don't use them
+                return nil
+        }
+
+        slotLevel := castingSlotLevel(action, spell)
+        if slotLevel < spell.Level || slotLevel > 9 {
+                return fmt.Errorf("%s must be cast with a spell slot between level %d and 9", spell.Name, spell.Level)
+        }
+
+        slot := char.GetSpellSlot(slotLevel)
+        if slot == nil || slot.Used >= slot.Max {
+                return fmt.Errorf("no level %d spell slots left", slotLevel)
+        }
+
+        return nil
+}
+
+// spendSpellSlot uses up the spell slot a character cast a spell with
+func (s *Service) spendSpellSlot(action *models.CombatAction, actor *models.Combatant, spell *models.Spell) {
+        char, ok := actor.Stats.(*models.Character)
+        if !ok || spell.Level == 0 {
+                return
+        }
+
+        if slot := char.GetSpellSlot(castingSlotLevel(action, spell)); slot != nil {
+                slot.Used++
+        }
+}
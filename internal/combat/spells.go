@@ -0,0 +1,402 @@
+package combat
+
+import (
+        "errors"
+        "fmt"
+        "sort"
+        "strconv"
+        "strings"
+
+        "dnd-combat/internal/models"
+)
+
+// spellHandler resolves a spell that needs bespoke code instead of the generic spell engine
+type spellHandler func(s *Service, combat *models.Combat, action *models.CombatAction, actor *models.Combatant, spell *models.Spell) (*models.ActionResult, error)
+
+// spellHandlers override the generic spell engine for specific spells, keyed by SRD index
+var spellHandlers = map[string]spellHandler{
+        "magic-missile":   castMagicMissile,
+        "spare-the-dying": castSpareTheDying,
+}
+
+// validateSpellTargets checks a spell's targets against its range
+func (s *Service) validateSpellTargets(combat *models.Combat, action *models.CombatAction, actor *models.Combatant, spell *models.Spell) error {
+        // Area spells affect whoever is inside the area instead of explicit targets
+        if area := spellArea(action, actor, spell); area != nil {
+                return validateSpellArea(combat, action, actor, spell, area)
+        }
+
+        rangeFeet, limited := spellRangeFeet(spell.Range)
+
+        // Spells with a range of Self affect the caster or an area around them
+        if limited && rangeFeet == 0 {
+                return nil
+        }
+
+        if len(action.TargetIDs) == 0 {
+                return fmt.Errorf("%s requires a target", spell.Name)
+        }
+
+        if !limited {
+                return nil
+        }
+
+        for _, targetID := range action.TargetIDs {
+                target := s.getCombatant(combat, targetID)
+                if distance := distanceFeet(actor.Position, target.Position); distance > rangeFeet {
+                        return fmt.Errorf("%s is out of range of %s (distance: %d ft, range: %d ft)",
+                                target.Name, spell.Name, distance, rangeFeet)
+                }
+        }
+
+        return nil
+}
+
+// resolveSpell casts a spell generically from its SRD data: spell attacks, saving throws,
+// damage, healing, conditions and buffs. Area spells affect every combatant in the area.
+func (s *Service) resolveSpell(combat *models.Combat, action *models.CombatAction, actor *models.Combatant, spell *models.Spell) (*models.ActionResult, error) {
+        result := &models.ActionResult{Success: true}
+
+        var targets []*models.Combatant
+        if area := spellArea(action, actor, spell); area != nil {
+                result.AffectedCells = areaCells(combat.Battlefield, area)
+                targets = s.combatantsInArea(combat, result.AffectedCells)
+
+                // The area of a concentration spell lingers on the battlefield
+                if spell.Concentration {
+                        addSpellZone(combat, actor, spell, result.AffectedCells)
+                }
+
+                switch {
+                case !affectsCreatures(spell):
+                        result.Description = fmt.Sprintf("%s casts %s, filling a %d-foot %s.",
+                                actor.Name, spell.Name, area.Size, area.Shape)
+                        return result, nil
+                case len(targets) == 0:
+                        result.Description = fmt.Sprintf("%s casts %s, but no one is caught in the %d-foot %s.",
+                                actor.Name, spell.Name, area.Size, area.Shape)
+                        return result, nil
+                }
+        } else {
+                targets = s.spellTargets(combat, action, actor)
+                if len(targets) == 0 {
+                        return nil, fmt.Errorf("%s requires a target", spell.Name)
+                }
+        }
+
+        slotLevel := castingSlotLevel(action, spell)
+        spellMod := s.spellcastingModifier(actor)
+        saveDC := s.spellSaveDC(actor)
+        attackBonus := s.spellAttackBonus(actor)
+        duration := spellDurationTurns(spell.Duration)
+
+        var descriptions []string
+
+        for _, target := range targets {
+                switch {
+                case len(spell.HealAtSlotLevel) > 0:
+                        // Healing spells add the spellcasting modifier where the SRD says "MOD"
+                        healingDice := strings.ReplaceAll(spellDice(spell.HealAtSlotLevel, slotLevel), "MOD", strconv.Itoa(spellMod))
+                        s.diceRoller.Label(spell.Name+" healing", actor.ID)
+                        cast := s.combatRules.CastHealingSpell(actor.Name, target.Name, spell.Name, healingDice, 0)
+                        s.applyHealing(target, cast.Healing)
+                        result.Healing += cast.Healing
+                        descriptions = append(descriptions, fmt.Sprintf("%s %s", cast.Description, hpStatus(target)))
+
+                case len(spell.TempHPAtSlotLevel) > 0:
+                        s.diceRoller.Label(spell.Name+" temporary hit points", actor.ID)
+                        tempHP := s.diceRoller.RollDamage(strings.ReplaceAll(spellDice(spell.TempHPAtSlotLevel, slotLevel), "MOD", strconv.Itoa(spellMod)))
+                        descriptions = append(descriptions, fmt.Sprintf("%s casts %s on %s. %s",
+                                actor.Name, spell.Name, target.Name, grantTempHP(target, tempHP)))
+
+                case spell.AttackType != "":
+                        descriptions = append(descriptions, s.resolveSpellAttack(combat, actor, target, spell, slotLevel, attackBonus, duration, result))
+
+                case len(spell.DamageAtSlotLevel) > 0 || len(spell.DamageAtCharacterLevel) > 0:
+                        dc := 0
+                        if spell.SaveAbility != "" {
+                                dc = saveDC
+                        }
+                        s.diceRoller.Label(spell.Name+" damage", actor.ID)
+                        cast := s.combatRules.CastDamageSpell(actor.Name, target.Name, spell.Name, s.spellDamageDice(actor, spell, slotLevel),
+                                spell.DamageType, dc, s.saveModifiers(target, spell.SaveAbility), spell.SaveSuccess == "half")
+                        dealt, damageDescription := s.applyDamage(combat, target, false, spellDamage(spell, cast.Damage))
+                        result.RawDamage += cast.Damage
+                        result.Damage += dealt
+                        result.DamageType = spell.DamageType
+
+                        description := cast.Description + " " + damageDescription
+                        if !cast.SavePassed {
+                                description += s.applySpellConditions(actor, target, spell, dc, duration)
+                        }
+                        descriptions = append(descriptions, description)
+
+                case spell.SaveAbility != "":
+                        // Spells that only apply conditions on a failed save
+                        saved, description := s.rollSavingThrow(target, spell.SaveAbility, saveDC)
+                        if !saved {
+                                description += "." + s.applySpellConditions(actor, target, spell, saveDC, duration)
+                        } else {
+                                description += fmt.Sprintf(" and resists %s.", spell.Name)
+                        }
+                        descriptions = append(descriptions, description)
+
+                default:
+                        descriptions = append(descriptions, s.applySpellBuff(actor, target, spell, duration))
+                }
+        }
+
+        result.Description = strings.Join(descriptions, " ")
+        return result, nil
+}
+
+// resolveSpellAttack makes a melee or ranged spell attack against a target and returns its description
+func (s *Service) resolveSpellAttack(combat *models.Combat, actor, target *models.Combatant, spell *models.Spell, slotLevel, attackBonus, duration int, result *models.ActionResult) string {
+        rangeFeet, _ := spellRangeFeet(spell.Range)
+        mode, autoCritical := s.attackRollMode(combat, actor, target, spell.AttackType == "ranged", rangeFeet)
+        mode.record(result)
+
+        s.diceRoller.Label(spell.Name+" attack roll", actor.ID)
+        attackRoll := s.rollD20(mode)
+        s.diceRoller.AddModifier(attackBonus)
+        totalAttack := attackRoll + attackBonus
+        targetAC := armorClass(target)
+        actor.RemoveCondition("hidden")
+        actor.RemoveCondition("helped")
+
+        if attackRoll == 1 || (attackRoll != 20 && totalAttack < targetAC) {
+                return fmt.Sprintf("%s casts %s at %s but misses! (Rolled %d + %d = %d vs AC %d)%s",
+                        actor.Name, spell.Name, target.Name, attackRoll, attackBonus, totalAttack, targetAC, mode.describe())
+        }
+
+        isCritical := attackRoll == 20 || autoCritical
+        description := fmt.Sprintf("%s's %s hits %s! (Rolled %d + %d = %d vs AC %d)%s",
+                actor.Name, spell.Name, target.Name, attackRoll, attackBonus, totalAttack, targetAC, mode.describe())
+
+        if dice := s.spellDamageDice(actor, spell, slotLevel); dice != "" {
+                // Critical hits roll the damage dice twice
+                if isCritical {
+                        dice = dice + " + " + dice
+                }
+                s.diceRoller.Label(spell.Name+" damage", actor.ID)
+                cast := s.combatRules.CastDamageSpell(actor.Name, target.Name, spell.Name, dice, spell.DamageType, 0, s.saveModifiers(target, ""), false)
+                dealt, damageDescription := s.applyDamage(combat, target, isCritical, spellDamage(spell, cast.Damage))
+                result.RawDamage += cast.Damage
+                result.Damage += dealt
+                result.DamageType = spell.DamageType
+                description += fmt.Sprintf(" %d %s damage. %s", cast.Damage, spell.DamageType, damageDescription)
+        }
+
+        return description + s.applySpellConditions(actor, target, spell, 0, duration)
+}
+
+// applySpellConditions applies the conditions a spell inflicts on a target. Conditions from
+// spells with a saving throw can be saved against again at the end of each of the target's turns.
+func (s *Service) applySpellConditions(actor, target *models.Combatant, spell *models.Spell, saveDC, duration int) string {
+        description := ""
+        for _, name := range spell.Conditions {
+                condition := models.Condition{
+                        Name:        name,
+                        SourceID:    actor.ID,
+                        SourceSpell: spell.Index,
+                        Duration:    duration,
+                        EndOfTurn:   true,
+                }
+                if saveDC > 0 {
+                        condition.SaveDC = saveDC
+                        condition.SaveAbility = spell.SaveAbility
+                }
+                description += " " + s.addCondition(target, condition) + "."
+        }
+        return description
+}
+
+// applySpellBuff applies a spell without attack, save, damage or healing to a willing target
+func (s *Service) applySpellBuff(actor, target *models.Combatant, spell *models.Spell, duration int) string {
+        // Spells such as Invisibility apply SRD conditions
+        if len(spell.Conditions) > 0 {
+                return fmt.Sprintf("%s casts %s on %s.%s", actor.Name, spell.Name, target.Name,
+                        s.applySpellConditions(actor, target, spell, 0, duration))
+        }
+
+        effect := "the effects of " + spell.Name
+        if spell.ACBonus > 0 {
+                effect = fmt.Sprintf("+%d AC", spell.ACBonus)
+        }
+
+        s.addCondition(target, models.Condition{
+                Name:        spell.Index,
+                SourceID:    actor.ID,
+                SourceSpell: spell.Index,
+                Duration:    duration,
+                StartOfTurn: true,
+                ACBonus:     spell.ACBonus,
+        })
+
+        return s.combatRules.CastBuffSpell(actor.Name, target.Name, spell.Name, effect, duration).Description
+}
+
+// spellDamage returns damage dealt by a spell, which is always magical
+func spellDamage(spell *models.Spell, amount int) models.TypedDamage {
+        return models.TypedDamage{Type: spell.DamageType, Amount: amount, Magical: true}
+}
+
+// affectsCreatures reports whether a spell does anything to the creatures it targets, as
+// opposed to only shaping the battlefield
+func affectsCreatures(spell *models.Spell) bool {
+        return len(spell.HealAtSlotLevel) > 0 || len(spell.TempHPAtSlotLevel) > 0 || spell.AttackType != "" || spell.SaveAbility != "" ||
+                len(spell.DamageAtSlotLevel) > 0 || len(spell.DamageAtCharacterLevel) > 0 ||
+                len(spell.Conditions) > 0 || spell.ACBonus > 0
+}
+
+// spellTargets returns the combatants a spell is cast on, defaulting to the caster
+func (s *Service) spellTargets(combat *models.Combat, action *models.CombatAction, actor *models.Combatant) []*models.Combatant {
+        if len(action.TargetIDs) == 0 {
+                return []*models.Combatant{actor}
+        }
+
+        targets := make([]*models.Combatant, 0, len(action.TargetIDs))
+        for _, targetID := range action.TargetIDs {
+                if target := s.getCombatant(combat, targetID); target != nil {
+                        targets = append(targets, target)
+                }
+        }
+        return targets
+}
+
+// spellDamageDice returns the damage dice of a spell cast at a slot level, scaling
+// cantrips with the caster's character level
+func (s *Service) spellDamageDice(actor *models.Combatant, spell *models.Spell, slotLevel int) string {
+        if len(spell.DamageAtCharacterLevel) > 0 {
+                level := 1
+                if char, ok := actor.Stats.(*models.Character); ok {
+                        level = char.Level
+                }
+                return spellDice(spell.DamageAtCharacterLevel, level)
+        }
+        return spellDice(spell.DamageAtSlotLevel, slotLevel)
+}
+
+// spellDice picks the entry of an SRD level table for a level: the highest level
+// that doesn't exceed it, or the lowest level in the table
+func spellDice(table map[int]string, level int) string {
+        if len(table) == 0 {
+                return ""
+        }
+
+        levels := make([]int, 0, len(table))
+        for l := range table {
+                levels = append(levels, l)
+        }
+        sort.Ints(levels)
+
+        dice := table[levels[0]]
+        for _, l := range levels {
+                if l <= level {
+                        dice = table[l]
+                }
+        }
+        return dice
+}
+
+// spellRangeFeet converts an SRD range such as "60 feet", "Touch" or "Self" to feet.
+// limited is false for ranges like "Sight" or "Unlimited".
+func spellRangeFeet(spellRange string) (feet int, limited bool) {
+        spellRange = strings.ToLower(strings.TrimSpace(spellRange))
+
+        switch {
+        case spellRange == "touch":
+                return 5, true
+        case strings.HasPrefix(spellRange, "self"):
+                return 0, true
+        }
+
+        var value int
+        var unit string
+        if _, err := fmt.Sscanf(spellRange, "%d %s", &value, &unit); err != nil {
+                return 0, false
+        }
+        if strings.HasPrefix(unit, "mile") {
+                return value * 5280, true
+        }
+        return value, true
+}
+
+// spellDurationTurns converts an SRD duration such as "1 minute" or "Up to 1 hour" to
+// rounds. Instantaneous and open-ended durations return 0, lasting until removed.
+func spellDurationTurns(duration string) int {
+        duration = strings.ToLower(strings.TrimSpace(duration))
+        if i := strings.Index(duration, "up to "); i >= 0 {
+                duration = duration[i+len("up to "):]
+        }
+
+        var value int
+        var unit string
+        if _, err := fmt.Sscanf(duration, "%d %s", &value, &unit); err != nil {
+                return 0
+        }
+
+        switch {
+        case strings.HasPrefix(unit, "round"):
+                return value
+        case strings.HasPrefix(unit, "minute"):
+                return value * 10
+        case strings.HasPrefix(unit, "hour"):
+                return value * 600
+        case strings.HasPrefix(unit, "day"):
+                return value * 14400
+        }
+        return 0
+}
+
+// castMagicMissile fires three darts that always hit, each dealing 1d4+1 force damage,
+// plus one more dart for each slot level above 1st
+func castMagicMissile(s *Service, combat *models.Combat, action *models.CombatAction, actor *models.Combatant, spell *models.Spell) (*models.ActionResult, error) {
+        if len(action.TargetIDs) == 0 {
+                return nil, errors.New("magic missile requires a target")
+        }
+
+        target := s.getCombatant(combat, action.TargetIDs[0])
+
+        darts := 3 + castingSlotLevel(action, spell) - 1
+        rolled := 0
+        s.diceRoller.Label(spell.Name+" damage", actor.ID)
+        for i := 0; i < darts; i++ {
+                rolled += s.diceRoller.Roll(1, 4) + 1
+                s.diceRoller.AddModifier(1)
+        }
+
+        dealt, damageDescription := s.applyDamage(combat, target, false,
+                models.TypedDamage{Type: "force", Amount: rolled, Magical: true})
+        return &models.ActionResult{
+                Success:    true,
+                Damage:     dealt,
+                RawDamage:  rolled,
+                DamageType: "force",
+                Description: fmt.Sprintf("%s casts Magic Missile at %s, %d darts dealing %d force damage! %s",
+                        actor.Name, target.Name, darts, rolled, damageDescription),
+        }, nil
+}
+
+// castSpareTheDying stabilizes a dying creature by touch
+func castSpareTheDying(s *Service, combat *models.Combat, action *models.CombatAction, actor *models.Combatant, spell *models.Spell) (*models.ActionResult, error) {
+        if len(action.TargetIDs) == 0 {
+                return nil, errors.New("spare the dying requires a target")
+        }
+
+        target := s.getCombatant(combat, action.TargetIDs[0])
+        if !isDying(target) {
+                return nil, errors.New("spare the dying requires a dying target")
+        }
+        if distanceFeet(actor.Position, target.Position) > 5 {
+                return nil, errors.New("spare the dying requires touching the target")
+        }
+
+        stabilize(target)
+        return &models.ActionResult{
+                Success:      true,
+                TargetEffect: "stable",
+                Description: fmt.Sprintf("%s casts Spare the Dying on %s, who is now stable!",
+                        actor.Name, target.Name),
+        }, nil
+}
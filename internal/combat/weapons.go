@@ -0,0 +1,259 @@
+package combat
+
+import (
+        "errors"
+        "fmt"
+        "strings"
+
+        "dnd-combat/internal/models"
+)
+
+// unarmedStrike isn't in the SRD equipment catalog; it deals 1 + STR bludgeoning damage
+var unarmedStrike = models.Weapon{
+        Index:       "unarmed-strike",
+        Name:        "Unarmed Strike",
+        Category:    "simple",
+        WeaponRange: "melee",
+        Damage:      models.DamageInfo{Bonus: 1, Type: "bludgeoning"},
+        NormalRange: 5,
+}
+
+// weaponAttack is how an attack with a weapon or a monster action is made against a target
+type weaponAttack struct {
+        attackBonus  int
+        damage       []models.DamageInfo
+        ranged       bool // A ranged attack, which includes throwing a melee weapon
+        normalRange  int  // Reach of a melee attack, or normal range of a ranged one
+        longRange    int  // Furthest the attack can hit, with disadvantage beyond normalRange
+        reducesMaxHP bool
+        heavy        bool
+        weapon       *models.Weapon // Weapon a character attacks with, nil for monsters
+        ability      string         // Ability a character's weapon attack uses
+}
+
+// equipmentIndex turns an item name like "Potion of Healing" or "longsword" into an SRD equipment index
+func equipmentIndex(name string) string {
+        return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(name)), " ", "-")
+}
+
+// lookupWeapon finds a weapon in the SRD equipment catalog
+func (s *Service) lookupWeapon(name string) (*models.Weapon, error) {
+        index := equipmentIndex(name)
+        if index == unarmedStrike.Index {
+                weapon := unarmedStrike
+                return &weapon, nil
+        }
+
+        weapon, err := s.srdClient.GetWeapon(index)
+        if err != nil {
+                return nil, fmt.Errorf("failed to look up weapon '%s': %w", name, err)
+        }
+        return weapon, nil
+}
+
+// resolveAttack works out the attack bonus, damage and range of an attack against a target
+func (s *Service) resolveAttack(actor, target *models.Combatant, action *models.CombatAction) (*weaponAttack, error) {
+        distance := distanceFeet(actor.Position, target.Position)
+
+        if actor.Type != "character" {
+                return resolveMonsterAttack(actor, action, distance), nil
+        }
+
+        char := actor.Stats.(*models.Character)
+        weapon, err := s.lookupWeapon(action.WeaponName)
+        if err != nil {
+                return nil, err
+        }
+        twoHanded, err := attackGrip(action, weapon)
+        if err != nil {
+                return nil, err
+        }
+
+        attack := &weaponAttack{heavy: weapon.HasProperty("heavy"), weapon: weapon, ability: weaponAbility(actor, weapon)}
+        reach := weaponReach(weapon)
+        switch {
+        case weapon.IsRanged():
+                attack.ranged, attack.normalRange, attack.longRange = true, weapon.NormalRange, weapon.LongRange
+        case weapon.HasProperty("thrown") && distance > reach:
+                attack.ranged, attack.normalRange, attack.longRange = true, weapon.ThrowNormal, weapon.ThrowLong
+        default:
+                attack.normalRange, attack.longRange = reach, reach
+        }
+        if attack.longRange < attack.normalRange {
+                attack.longRange = attack.normalRange
+        }
+
+        ability := abilityModifier(actor, attack.ability)
+        attack.attackBonus = ability
+        proficient, err := s.proficientWith(char, weapon)
+        if err != nil {
+                return nil, err
+        }
+        if proficient {
+                attack.attackBonus += proficiencyBonus(actor)
+        }
+
+        damage := weapon.Damage
+        if twoHanded && weapon.HasProperty("versatile") && weapon.TwoHandedDamage != nil {
+                damage = *weapon.TwoHandedDamage
+        }
+        // Off-hand attacks don't add a positive ability modifier to the damage
+        if action.Type != "offhand_attack" || ability < 0 {
+                damage.Bonus += ability
+        }
+        attack.damage = []models.DamageInfo{damage}
+
+        return attack, nil
+}
+
+// resolveMonsterAttack works out an attack with a monster action. Actions that can be made
+// in melee or at range, like a thrown javelin, are ranged when the target is out of reach.
+func resolveMonsterAttack(actor *models.Combatant, action *models.CombatAction, distance int) *weaponAttack {
+        attack := &weaponAttack{normalRange: 5, longRange: 5}
+        monsterAction := findMonsterAction(actor, action.Type, action.WeaponName)
+        if monsterAction == nil {
+                return attack
+        }
+
+        attack.attackBonus = monsterAction.AttackBonus
+        attack.damage = monsterDamageDice(monsterAction)
+        attack.reducesMaxHP = monsterAction.ReducesMaxHP
+
+        reach := monsterAction.Reach
+        if reach == 0 && monsterAction.Range == 0 {
+                reach = 5
+        }
+        if monsterAction.Range > 0 && (reach == 0 || distance > reach) {
+                attack.ranged, attack.normalRange, attack.longRange = true, monsterAction.Range, monsterAction.LongRange
+                if attack.longRange < attack.normalRange {
+                        attack.longRange = attack.normalRange
+                }
+                return attack
+        }
+        attack.normalRange, attack.longRange = reach, reach
+        return attack
+}
+
+// weaponReach returns how far a melee weapon reaches in feet
+func weaponReach(weapon *models.Weapon) int {
+        if weapon.HasProperty("reach") {
+                return 10
+        }
+        return 5
+}
+
+// weaponAbility returns the ability used for attacks with a weapon: DEX for ranged weapons,
+// the better of STR and DEX for finesse weapons and STR for everything else, including
+// thrown melee weapons
+func weaponAbility(actor *models.Combatant, weapon *models.Weapon) string {
+        switch {
+        case weapon.HasProperty("finesse"):
+                if abilityModifier(actor, "dex") > abilityModifier(actor, "str") {
+                        return "dex"
+                }
+                return "str"
+        case weapon.IsRanged():
+                return "dex"
+        }
+        return "str"
+}
+
+// validateOffHandAttack checks that a character who attacked with a light melee weapon this
+// turn attacks with a different light melee weapon in their other hand, or a second one of
+// the same kind. The weapon defaults to the one in their off hand.
+func (s *Service) validateOffHandAttack(combat *models.Combat, action *models.CombatAction, actor *models.Combatant) error {
+        char, ok := actor.Stats.(*models.Character)
+        if !ok {
+                return errors.New("only characters make off-hand attacks")
+        }
+        if !actor.Economy.OffHandAttack {
+                return errors.New("an off-hand attack needs an attack with a light melee weapon this turn")
+        }
+
+        if action.WeaponName == "" {
+                action.WeaponName = char.Equipped.OffHand
+        }
+        if action.WeaponName == "" {
+                return errors.New("off-hand attack requires a weapon")
+        }
+        weapon, err := s.lookupWeapon(action.WeaponName)
+        if err != nil {
+                return err
+        }
+        if !weapon.HasProperty("light") || weapon.IsRanged() {
+                return fmt.Errorf("%s isn't a light melee weapon", weapon.Name)
+        }
+        if weapon.Index == actor.Economy.MainHandWeapon {
+                if item := char.GetItem(weapon.Index); item == nil || item.Quantity < 2 {
+                        return fmt.Errorf("%s already attacked with their %s, so the off-hand attack needs another weapon",
+                                actor.Name, weapon.Name)
+                }
+        }
+
+        return s.validateAttack(combat, action, actor)
+}
+
+// attackGrip returns whether a weapon is wielded in two hands, from the "grip" of an attack's
+// extra data: "one_handed" or "two_handed". Two-handed weapons are always wielded in two hands.
+func attackGrip(action *models.CombatAction, weapon *models.Weapon) (bool, error) {
+        grip, _ := action.ExtraData["grip"].(string)
+        switch grip {
+        case "":
+                return weapon.HasProperty("two-handed"), nil
+        case "one_handed":
+                if weapon.HasProperty("two-handed") {
+                        return false, fmt.Errorf("%s must be wielded with two hands", weapon.Name)
+                }
+                return false, nil
+        case "two_handed":
+                return true, nil
+        }
+        return false, fmt.Errorf("unknown grip '%s', expected one_handed or two_handed", grip)
+}
+
+// proficientWith checks if a character's class is proficient with a weapon, either with its
+// whole category like "martial-weapons" or with the weapon itself like "longswords"
+func (s *Service) proficientWith(char *models.Character, weapon *models.Weapon) (bool, error) {
+        if weapon.Index == unarmedStrike.Index {
+                return true, nil
+        }
+
+        proficiencies, err := s.srdClient.GetClassProficiencies(char.Class)
+        if err != nil {
+                return false, fmt.Errorf("failed to look up proficiencies for %s: %w", char.Name, err)
+        }
+
+        for _, proficiency := range proficiencies {
+                if proficiency == weapon.Category+"-weapons" || proficiency == weaponProficiencyIndex(weapon.Index) {
+                        return true, nil
+                }
+        }
+        return false, nil
+}
+
+// weaponProficiencyIndex returns the SRD proficiency index of a single weapon, which is the
+// plural of its equipment index: "longsword" becomes "longswords" and "crossbow-light"
+// becomes "crossbows-light"
+func weaponProficiencyIndex(index string) string {
+        if name, kind, found := strings.Cut(index, "-"); found && name == "crossbow" {
+                return name + "s-" + kind
+        }
+        return index + "s"
+}
+
+// isSmall checks if a character's race is Small, which gives disadvantage with heavy weapons.
+// Characters created before races came from the SRD have no size and go by their race.
+func isSmall(combatant *models.Combatant) bool {
+        char, ok := combatant.Stats.(*models.Character)
+        if !ok {
+                return false
+        }
+        if char.Size != "" {
+                return char.Size == "Small"
+        }
+        switch strings.ToLower(char.Race) {
+        case "halfling", "gnome":
+                return true
+        }
+        return false
+}
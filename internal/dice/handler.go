@@ -0,0 +1,171 @@
+package dice
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"dnd-combat/internal/combat"
+	"dnd-combat/internal/game"
+	"dnd-combat/pkg/dnd5e"
+	"dnd-combat/pkg/websocket"
+)
+
+// Handler handles dice rolling HTTP requests
+type Handler struct {
+	combatService *combat.Service
+	gameService   *game.Service
+	wsHub         *websocket.Hub
+}
+
+// NewHandler creates a new dice handler
+func NewHandler(combatService *combat.Service, gameService *game.Service, wsHub *websocket.Hub) *Handler {
+	return &Handler{
+		combatService: combatService,
+		gameService:   gameService,
+		wsHub:         wsHub,
+	}
+}
+
+// RollRequest represents the request body for a dice roll
+type RollRequest struct {
+	Expression string `json:"expression" binding:"required,max=200"`
+	Purpose    string `json:"purpose" binding:"max=100"`
+	CombatID   string `json:"combat_id"` // Combat room to broadcast the roll to
+	GameID     string `json:"game_id"`   // Game room to broadcast the roll to
+	ActorID    string `json:"actor_id"`  // Combatant the roll is made for
+}
+
+// RollResponse is a dice roll and who made it, as returned and broadcast as a dice_roll message
+type RollResponse struct {
+	UserID   string                `json:"user_id"`
+	ActorID  string                `json:"actor_id,omitempty"`
+	Purpose  string                `json:"purpose,omitempty"`
+	CombatID string                `json:"combat_id,omitempty"`
+	GameID   string                `json:"game_id,omitempty"`
+	Result   *dnd5e.DiceRollResult `json:"result"`
+}
+
+// Roll rolls a dice expression, optionally broadcasting it to a combat or game room.
+// Rolls made in a combat use the combat's dice and go in its roll ledger.
+func (h *Handler) Roll(c *gin.Context) {
+	var req RollRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+
+	// Get the user ID from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	if req.CombatID != "" && req.GameID != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Roll into either a combat or a game, not both"})
+		return
+	}
+	if req.ActorID != "" && req.CombatID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "An actor can only be given for a roll in a combat"})
+		return
+	}
+
+	expression, err := dnd5e.ParseDice(req.Expression)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid dice expression", "details": err.Error()})
+		return
+	}
+
+	response := &RollResponse{
+		UserID:   userID.(string),
+		ActorID:  req.ActorID,
+		Purpose:  req.Purpose,
+		CombatID: req.CombatID,
+		GameID:   req.GameID,
+	}
+
+	switch {
+	case req.CombatID != "":
+		var ok bool
+		if response.Result, ok = h.rollInCombat(c, req, userID.(string), expression); !ok {
+			return
+		}
+
+	case req.GameID != "":
+		game, err := h.gameService.GetByID(req.GameID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve game session"})
+			return
+		}
+		if game == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Game session not found"})
+			return
+		}
+		if !h.gameService.IsUserInGame(game, userID.(string)) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You don't have access to this game session"})
+			return
+		}
+		response.Result = dnd5e.NewDiceRoller().RollExpression(expression)
+
+	default:
+		response.Result = dnd5e.NewDiceRoller().RollExpression(expression)
+	}
+
+	// Share the roll with everyone in the room
+	if room := req.CombatID + req.GameID; room != "" {
+		h.wsHub.BroadcastToRoom(room, websocket.Message{
+			Type: "dice_roll",
+			Data: response,
+		})
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// rollInCombat rolls an expression with a combat's dice. When the roll can't be made it
+// writes the error response and returns false.
+func (h *Handler) rollInCombat(c *gin.Context, req RollRequest, userID string, expression *dnd5e.DiceExpression) (*dnd5e.DiceRollResult, bool) {
+	// The roll continues the combat's dice, so nothing else may change the combat meanwhile
+	unlock, err := h.combatService.LockCombat(req.CombatID)
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	defer unlock()
+
+	session, err := h.combatService.GetCombat(req.CombatID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve combat session"})
+		return nil, false
+	}
+	if session == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Combat session not found"})
+		return nil, false
+	}
+	if !h.combatService.IsUserInCombat(session, userID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You don't have access to this combat session"})
+		return nil, false
+	}
+	if req.ActorID != "" && !h.combatService.UserControlsActor(session, userID, req.ActorID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You don't control this actor"})
+		return nil, false
+	}
+	if h.combatService.IsPlayingMonsterTurns(session.ID) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Monster turns are being played automatically"})
+		return nil, false
+	}
+
+	// Rolls not made for a combatant are logged against the user who made them
+	actorID := req.ActorID
+	if actorID == "" {
+		actorID = userID
+	}
+
+	result, err := h.combatService.RollDice(session, expression, actorID, req.Purpose)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to roll dice", "details": err.Error()})
+		return nil, false
+	}
+	return result, true
+}
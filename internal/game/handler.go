@@ -6,17 +6,20 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"dnd-combat/internal/models"
+	"dnd-combat/pkg/websocket"
 )
 
 // Handler handles game-related HTTP requests
 type Handler struct {
 	service *Service
+	wsHub   *websocket.Hub
 }
 
 // NewHandler creates a new game handler
-func NewHandler(service *Service) *Handler {
+func NewHandler(service *Service, wsHub *websocket.Hub) *Handler {
 	return &Handler{
 		service: service,
+		wsHub:   wsHub,
 	}
 }
 
@@ -163,6 +166,42 @@ func (h *Handler) Update(c *gin.Context) {
 	c.JSON(http.StatusOK, game)
 }
 
+// WebSocketHandler handles websocket connections for a game's room, where things shared
+// with the whole table like dice rolls are broadcast
+func (h *Handler) WebSocketHandler(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Game ID is required"})
+		return
+	}
+
+	// Get the user ID from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	game, err := h.service.GetByID(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve game session"})
+		return
+	}
+
+	if game == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Game session not found"})
+		return
+	}
+
+	if !h.service.IsUserInGame(game, userID.(string)) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You don't have access to this game session"})
+		return
+	}
+
+	// Upgrade connection to websocket
+	h.wsHub.ServeWs(c.Writer, c.Request, id, userID.(string))
+}
+
 // Helper function to check if a slice contains a string
 func contains(slice []string, item string) bool {
 	for _, s := range slice {
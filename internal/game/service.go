@@ -35,3 +35,8 @@ func (s *Service) GetByUserID(userID string) ([]*models.Game, error) {
 func (s *Service) Update(game *models.Game) error {
 	return s.repo.Update(game)
 }
+
+// IsUserInGame checks if a user is the DM or a player of a game
+func (s *Service) IsUserInGame(game *models.Game, userID string) bool {
+	return game.DMUserID == userID || contains(game.PlayerIDs, userID)
+}
@@ -1,30 +1,261 @@
 package models
 
 import (
+	"strings"
 	"time"
 )
 
 // Character represents a D&D character
 type Character struct {
-	ID           string    `json:"id"`
-	UserID       string    `json:"user_id"`
-	Name         string    `json:"name"`
-	Race         string    `json:"race"`
-	Class        string    `json:"class"`
-	Level        int       `json:"level"`
-	Strength     int       `json:"strength"`
-	Dexterity    int       `json:"dexterity"`
-	Constitution int       `json:"constitution"`
-	Intelligence int       `json:"intelligence"`
-	Wisdom       int       `json:"wisdom"`
-	Charisma     int       `json:"charisma"`
-	HitPoints    int       `json:"hit_points"`
-	MaxHitPoints int       `json:"max_hit_points"`
-	ArmorClass   int       `json:"armor_class"`
-	Equipment    []string  `json:"equipment"`
-	Spells       []string  `json:"spells"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID             string          `json:"id"`
+	UserID         string          `json:"user_id"`
+	Name           string          `json:"name"`
+	Race           string          `json:"race"`
+	Subrace        string          `json:"subrace,omitempty"`
+	Size           string          `json:"size"`       // Size of the character's race, "Small" or "Medium"
+	RaceSpeed      int             `json:"race_speed"` // Walking speed of the character's race in feet
+	Class          string          `json:"class"`
+	Level          int             `json:"level"`
+	Strength       int             `json:"strength"`
+	Dexterity      int             `json:"dexterity"`
+	Constitution   int             `json:"constitution"`
+	Intelligence   int             `json:"intelligence"`
+	Wisdom         int             `json:"wisdom"`
+	Charisma       int             `json:"charisma"`
+	HitPoints      int             `json:"hit_points"`
+	MaxHitPoints   int             `json:"max_hit_points"`
+	MaxHPReduction int             `json:"max_hp_reduction"` // Reduction of the hit point maximum until a long rest
+	HitDiceUsed    int             `json:"hit_dice_used"`    // Hit dice spent on short rests, out of one per level
+	Exhaustion     int             `json:"exhaustion"`       // Exhaustion level
+	ArmorClass     int             `json:"armor_class"`      // Derived from the equipped armor and shield
+	Inventory      []InventoryItem `json:"inventory"`
+	Equipped       EquippedSlots   `json:"equipped"`
+	Spells         []string        `json:"spells"`
+	SavingThrows   []string        `json:"saving_throws"` // Abilities the character's class is proficient in saving throws of
+	Skills         []string        `json:"skills"`        // SRD skill indexes the character is proficient in
+	Expertise      []string        `json:"expertise"`     // Skills the character adds twice their proficiency bonus to
+	Feats          []string        `json:"feats"`         // Feat indexes like "alert"
+	SpellSlots     []SpellSlot     `json:"spell_slots"`
+	FeatureUses    []FeatureUse    `json:"feature_uses"` // Uses of class features limited per rest, like Rage
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+
+	// Derived from the level, inventory and Strength by DeriveStats
+	ProficiencyBonus int     `json:"proficiency_bonus"`
+	Speed            int     `json:"speed"`
+	CarryingCapacity int     `json:"carrying_capacity"`
+	CarriedWeight    float64 `json:"carried_weight"`
+	Encumbrance      string  `json:"encumbrance"` // "unencumbered", "encumbered", "heavily_encumbered" or "over_capacity"
+}
+
+// InventoryItem is a stack of one item a character carries, from the SRD equipment catalog
+type InventoryItem struct {
+	Index    string  `json:"index"` // SRD equipment index like "longsword" or "potion-of-healing"
+	Name     string  `json:"name"`
+	Category string  `json:"category,omitempty"` // SRD equipment category like "weapon" or "armor"
+	Quantity int     `json:"quantity"`
+	Weight   float64 `json:"weight"` // Weight of one item in pounds
+	Equipped bool    `json:"equipped"`
+	Attuned  bool    `json:"attuned"`
+	Armor    *Armor  `json:"armor,omitempty"` // Armor stats of armor and shields
+}
+
+// EquippedSlots holds the indexes of the inventory items a character wields and wears
+type EquippedSlots struct {
+	MainHand string `json:"main_hand,omitempty"`
+	OffHand  string `json:"off_hand,omitempty"`
+	Armor    string `json:"armor,omitempty"`
+	Shield   string `json:"shield,omitempty"`
+}
+
+// Encumbrance levels, from the variant encumbrance rules
+const (
+	Unencumbered      = "unencumbered"
+	Encumbered        = "encumbered"
+	HeavilyEncumbered = "heavily_encumbered"
+	OverCapacity      = "over_capacity"
+)
+
+// BaseSpeed is the walking speed in feet of characters whose race doesn't give one
+const BaseSpeed = 30
+
+// MaxAttunedItems is how many magic items a character can be attuned to at once
+const MaxAttunedItems = 3
+
+// SpellSlot tracks a character's spell slots of one spell level
+type SpellSlot struct {
+	Level int `json:"level"`
+	Max   int `json:"max"`
+	Used  int `json:"used"`
+}
+
+// MaxAbilityScore is the highest an ability score can be raised to
+const MaxAbilityScore = 20
+
+// AbilityRoll is a set of ability scores rolled on the server for a user's next character,
+// each the highest three of 4d6. A character created from it can arrange the scores in
+// any order, and the set can only be used once.
+type AbilityRoll struct {
+	ID        string       `json:"id"`
+	UserID    string       `json:"user_id"`
+	Scores    []int        `json:"scores"`
+	Rolls     []RollRecord `json:"rolls"`
+	Used      bool         `json:"used"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+// Rests that restore limited uses of class features
+const (
+	ShortRest = "short_rest"
+	LongRest  = "long_rest"
+)
+
+// FeatureUse tracks the uses of a class feature a character can only use a few times
+// before resting, like a fighter's Action Surge
+type FeatureUse struct {
+	Index    string `json:"index"` // e.g. "action-surge"
+	Name     string `json:"name"`
+	Max      int    `json:"max"`
+	Used     int    `json:"used"`
+	Recovery string `json:"recovery"` // ShortRest or LongRest
+}
+
+// GetFeatureUse returns the character's uses of a class feature, or nil if its uses aren't limited
+func (c *Character) GetFeatureUse(index string) *FeatureUse {
+	for i := range c.FeatureUses {
+		if c.FeatureUses[i].Index == index {
+			return &c.FeatureUses[i]
+		}
+	}
+	return nil
+}
+
+// GetSpellSlot returns the character's spell slots of a level, or nil if they have none
+func (c *Character) GetSpellSlot(level int) *SpellSlot {
+	for i := range c.SpellSlots {
+		if c.SpellSlots[i].Level == level {
+			return &c.SpellSlots[i]
+		}
+	}
+	return nil
+}
+
+// GetItem returns the character's stack of an item, or nil if they don't carry it
+func (c *Character) GetItem(index string) *InventoryItem {
+	for i := range c.Inventory {
+		if c.Inventory[i].Index == index {
+			return &c.Inventory[i]
+		}
+	}
+	return nil
+}
+
+// RemoveItem takes a number of an item out of the inventory, unequipping it once none are left
+func (c *Character) RemoveItem(index string, quantity int) bool {
+	item := c.GetItem(index)
+	if item == nil || item.Quantity < quantity {
+		return false
+	}
+
+	item.Quantity -= quantity
+	if item.Quantity == 0 {
+		for i := range c.Inventory {
+			if c.Inventory[i].Index == index {
+				c.Inventory = append(c.Inventory[:i], c.Inventory[i+1:]...)
+				break
+			}
+		}
+		for _, slot := range []*string{&c.Equipped.MainHand, &c.Equipped.OffHand, &c.Equipped.Armor, &c.Equipped.Shield} {
+			if *slot == index {
+				*slot = ""
+			}
+		}
+	}
+
+	c.DeriveStats()
+	return true
+}
+
+// WornArmor returns the armor the character wears, or nil if they wear none
+func (c *Character) WornArmor() *Armor {
+	return c.slotArmor(c.Equipped.Armor)
+}
+
+// HeldShield returns the shield the character holds, or nil if they hold none
+func (c *Character) HeldShield() *Armor {
+	return c.slotArmor(c.Equipped.Shield)
+}
+
+// slotArmor returns the armor stats of the item in a slot
+func (c *Character) slotArmor(index string) *Armor {
+	if index == "" {
+		return nil
+	}
+	if item := c.GetItem(index); item != nil {
+		return item.Armor
+	}
+	return nil
+}
+
+// DeriveStats works out the proficiency bonus, armor class, speed, carried weight and
+// encumbrance from the character's level, equipment and ability scores
+func (c *Character) DeriveStats() {
+	c.ProficiencyBonus = GetProficiencyBonus(c.Level)
+
+	// Unarmored characters have an AC of 10 + DEX
+	dexterity := GetAbilityModifier(c.Dexterity)
+	c.ArmorClass = 10 + dexterity
+	if armor := c.WornArmor(); armor != nil {
+		c.ArmorClass = armor.BaseAC
+		if armor.DexBonus {
+			if armor.MaxDexBonus > 0 && dexterity > armor.MaxDexBonus {
+				dexterity = armor.MaxDexBonus
+			}
+			c.ArmorClass += dexterity
+		}
+	}
+	if shield := c.HeldShield(); shield != nil {
+		c.ArmorClass += shield.BaseAC
+	}
+
+	c.CarriedWeight = 0
+	for _, item := range c.Inventory {
+		c.CarriedWeight += item.Weight * float64(item.Quantity)
+	}
+
+	// Carrying capacity is 15 times Strength; carrying more than 5 and 10 times Strength
+	// encumbers and heavily encumbers the character
+	c.CarryingCapacity = 15 * c.Strength
+	c.Speed = BaseSpeed
+	if c.RaceSpeed > 0 {
+		c.Speed = c.RaceSpeed
+	}
+	switch {
+	case c.CarriedWeight > float64(c.CarryingCapacity):
+		c.Encumbrance = OverCapacity
+		c.Speed = 5
+	case c.CarriedWeight > float64(10*c.Strength):
+		c.Encumbrance = HeavilyEncumbered
+		c.Speed -= 20
+	case c.CarriedWeight > float64(5*c.Strength):
+		c.Encumbrance = Encumbered
+		c.Speed -= 10
+	default:
+		c.Encumbrance = Unencumbered
+	}
+
+	// Heavy armor slows a character without the Strength to wear it
+	if armor := c.WornArmor(); armor != nil && c.Strength < armor.StrengthMinimum && c.Encumbrance != OverCapacity {
+		c.Speed -= 10
+	}
+	if c.Speed < 0 {
+		c.Speed = 0
+	}
+}
+
+// HitDiceLeft returns how many hit dice the character can still spend
+func (c *Character) HitDiceLeft() int {
+	return c.Level - c.HitDiceUsed
 }
 
 // GetAbilityModifier calculates the ability modifier for a given ability score
@@ -36,3 +267,13 @@ func GetAbilityModifier(score int) int {
 func GetProficiencyBonus(level int) int {
 	return ((level - 1) / 4) + 2
 }
+
+// HasFeat checks if a character has a feat, given by index like "alert"
+func (c *Character) HasFeat(index string) bool {
+	for _, feat := range c.Feats {
+		if strings.EqualFold(strings.ReplaceAll(strings.TrimSpace(feat), " ", "-"), index) {
+			return true
+		}
+	}
+	return false
+}
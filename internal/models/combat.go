@@ -1,6 +1,7 @@
 package models
 
 import (
+        "encoding/json"
         "time"
 )
 
@@ -29,6 +30,16 @@ type Monster struct {
         Actions      []MonsterAction `json:"actions"`
         ChallengeRating float64   `json:"challenge_rating"`
         XP           int          `json:"xp"`
+        DamageVulnerabilities []string `json:"damage_vulnerabilities,omitempty"`
+        DamageResistances     []string `json:"damage_resistances,omitempty"` // e.g. "fire" or "bludgeoning, piercing, and slashing from nonmagical attacks"
+        DamageImmunities      []string `json:"damage_immunities,omitempty"`
+        ConditionImmunities   []string `json:"condition_immunities,omitempty"` // SRD condition indexes, e.g. "poisoned"
+        LegendaryActions      []MonsterAction `json:"legendary_actions,omitempty"`
+        LegendaryActionCount  int      `json:"legendary_action_count,omitempty"` // Legendary actions per round
+        SpecialAbilities      []MonsterAction `json:"special_abilities,omitempty"`
+        LairActions           []MonsterAction `json:"lair_actions,omitempty"` // Taken on initiative count 20
+        SavingThrows          map[string]int  `json:"saving_throws,omitempty"` // Saving throw bonus by ability index, for proficient saves
+        Skills                map[string]int  `json:"skills,omitempty"`        // Check bonus by SRD skill index, for proficient skills
 }
 
 // MonsterSpeed represents monster movement speeds
@@ -48,13 +59,46 @@ type DamageInfo struct {
         Type      string `json:"type"`
 }
 
+// TypedDamage is an amount of damage of a single type dealt to a combatant
+type TypedDamage struct {
+        Type    string `json:"type"`
+        Amount  int    `json:"amount"`
+        Magical bool   `json:"magical,omitempty"` // Dealt by a spell or magic weapon
+}
+
 // MonsterAction represents an action a monster can take
 type MonsterAction struct {
         Name        string     `json:"name"`
         Description string     `json:"description"`
         AttackBonus int        `json:"attack_bonus"`
-        Range       int        `json:"range,omitempty"`
+        Reach       int        `json:"reach,omitempty"`      // Reach of a melee attack in feet
+        Range       int        `json:"range,omitempty"`      // Normal range of a ranged attack in feet
+        LongRange   int        `json:"long_range,omitempty"` // Long range, attacked at with disadvantage
         Damage      DamageInfo `json:"damage,omitempty"`
+        ExtraDamage []DamageInfo `json:"extra_damage,omitempty"` // Damage of other types dealt on the same hit
+        ReducesMaxHP bool       `json:"reduces_max_hp,omitempty"` // Damage also reduces the target's hit point maximum, like Life Drain
+        Multiattack []MultiattackEntry `json:"multiattack,omitempty"` // Attacks made by a Multiattack action
+        Usage       *ActionUsage `json:"usage,omitempty"`        // Recharge or per-day limit
+        SaveDC      int        `json:"save_dc,omitempty"`        // Saving throw DC of actions like breath weapons
+        SaveAbility string     `json:"save_ability,omitempty"`
+        SaveSuccess string     `json:"save_success,omitempty"`   // "half" or "none" damage on a successful save
+        Area        *AreaOfEffect `json:"area,omitempty"`        // Area of a saving throw action, like a breath weapon's cone
+        AreaRange   int        `json:"area_range,omitempty"`     // How far away the area can be placed, 0 when it starts at or surrounds the monster
+        Cost        int        `json:"cost,omitempty"`           // Legendary actions it costs
+}
+
+// MultiattackEntry is one of the attacks a Multiattack action makes
+type MultiattackEntry struct {
+        ActionName string `json:"action_name"`
+        Count      int    `json:"count"`
+}
+
+// ActionUsage limits how often a monster action can be used
+type ActionUsage struct {
+        Type     string `json:"type"`                // "recharge on roll", "per day" or "recharge after rest"
+        Dice     string `json:"dice,omitempty"`      // Recharge roll, e.g. "1d6"
+        MinValue int    `json:"min_value,omitempty"` // Lowest recharge roll that recharges the action
+        Times    int    `json:"times,omitempty"`     // Uses per day
 }
 
 // Spell represents a D&D spell
@@ -70,6 +114,35 @@ type Spell struct {
         Description string   `json:"description"`
         HigherLevel string   `json:"higher_level,omitempty"`
         Classes     []string `json:"classes"`
+        
+        // Structured data used to resolve the spell in combat
+        Concentration          bool           `json:"concentration"`
+        AttackType             string         `json:"attack_type,omitempty"`               // "melee" or "ranged" spell attack
+        SaveAbility            string         `json:"save_ability,omitempty"`              // Saving throw ability, e.g. "dex"
+        SaveSuccess            string         `json:"save_success,omitempty"`              // "half" or "none" damage on a successful save
+        DamageType             string         `json:"damage_type,omitempty"`
+        DamageAtSlotLevel      map[int]string `json:"damage_at_slot_level,omitempty"`      // Damage dice by spell slot level
+        DamageAtCharacterLevel map[int]string `json:"damage_at_character_level,omitempty"` // Cantrip damage dice by character level
+        HealAtSlotLevel        map[int]string `json:"heal_at_slot_level,omitempty"`        // Healing dice by slot level, "MOD" is the spellcasting modifier
+        AreaOfEffect           *AreaOfEffect  `json:"area_of_effect,omitempty"`
+        Conditions             []string       `json:"conditions,omitempty"` // Conditions applied on a hit or failed save
+        ACBonus                int            `json:"ac_bonus,omitempty"`   // AC bonus granted by buff spells
+        TempHPAtSlotLevel      map[int]string `json:"temp_hp_at_slot_level,omitempty"` // Temporary hit points by slot level, "MOD" is the spellcasting modifier
+        Terrain                string         `json:"terrain,omitempty"`    // Terrain the spell's area becomes, e.g. "difficult"
+}
+
+// AreaOfEffect represents the shape and size in feet of the area of a spell or monster action
+type AreaOfEffect struct {
+        Type string `json:"type"` // sphere, cone, cube, cylinder or line
+        Size int    `json:"size"`
+}
+
+// AreaTemplate places an area of effect on the battlefield grid
+type AreaTemplate struct {
+        Shape     string `json:"shape"`               // sphere, cone, cube, cylinder or line
+        Origin    [2]int `json:"origin"`              // Cell the area starts from
+        Direction string `json:"direction,omitempty"` // Compass direction of cones, lines and cubes, e.g. "ne"
+        Size      int    `json:"size,omitempty"`      // Radius, length or side in feet
 }
 
 // Combat represents a D&D combat encounter
@@ -78,22 +151,37 @@ type Combat struct {
         DMUserID        string           `json:"dm_user_id"`
         CurrentTurnIndex int             `json:"current_turn_index"` // Renamed from CurrentTurnIdx for consistency
         RoundNumber     int              `json:"round_number"`
-        Status          string           `json:"status"`
+        Status          string           `json:"status"` // "active", "paused", or once it's over "victory", "defeat", "ended" or "fled"
         Initiative      []InitiativeItem `json:"initiative"`
         Participants    []Combatant      `json:"participants"`
         Battlefield     Battlefield      `json:"battlefield"`
         Environment     string           `json:"environment"`
+        AutoMonsters    bool             `json:"auto_monsters"` // Every monster's turns are played automatically
+        SeedCommitment  string           `json:"seed_commitment"` // SHA-256 of the dice seed, published when the combat starts
+        Seed            string           `json:"seed,omitempty"`  // Dice seed, revealed once the combat is over
+        DiceSeed        string           `json:"-"`               // Dice seed, kept secret while the combat is active
+        RollCount       int              `json:"roll_count"`      // Dice rolled so far
         CreatedAt       time.Time        `json:"created_at"`
         UpdatedAt       time.Time        `json:"updated_at"`
 }
 
+// IsOver checks if a combat has ended, so it can't go on and its dice seed can be revealed.
+// A paused combat isn't over.
+func (c *Combat) IsOver() bool {
+        return c.Status != "active" && c.Status != "paused"
+}
+
 // InitiativeItem represents a participant's initiative order
 type InitiativeItem struct {
         ID          string `json:"id"`
         Name        string `json:"name"`
         Initiative  int    `json:"initiative"`
-        Dexterity   int    `json:"dexterity"`
+        Dexterity   int    `json:"dexterity"`           // Dexterity score, which breaks initiative ties
+        TieBreak    int    `json:"tie_break,omitempty"` // Place won in a roll-off among combatants still tied, highest first
         IsCharacter bool   `json:"is_character"`
+        Lair        bool   `json:"lair,omitempty"` // Initiative count 20 turn of the lair of the combatant with this ID
+        Proposed    *int   `json:"proposed_initiative,omitempty"` // Initiative a player entered, waiting for the DM's approval
+        Delayed     bool   `json:"delayed,omitempty"`             // The combatant delayed their turn to this initiative count
 }
 
 // Combatant represents a participant in combat
@@ -106,13 +194,88 @@ type Combatant struct {
         Type         string      `json:"type"` // "character" or "monster"
         HP           int         `json:"hp"`
         MaxHP        int         `json:"max_hp"`
+        TempHP       int         `json:"temp_hp"` // Temporary hit points, lost before HP
+        MaxHPReduction int       `json:"max_hp_reduction,omitempty"` // Reduction of the hit point maximum until a long rest
         AC           int         `json:"ac"`
         Initiative   int         `json:"initiative"`
         Position     [2]int      `json:"position"`
-        Conditions   []string    `json:"conditions"`
+        Conditions   []Condition `json:"conditions"`
+        Economy      ActionEconomy `json:"economy"` // Resources left on the current turn
+        DeathSaves   DeathSaves  `json:"death_saves"`
+        Concentration *Concentration `json:"concentration,omitempty"` // Spell the combatant is concentrating on
+        Readied      *ReadiedAction `json:"readied,omitempty"` // Action held until its trigger happens
+        ActionUses   map[string]int `json:"action_uses,omitempty"` // Uses left of limited monster actions, 1 while a recharge action is charged
+        AutoPlay     bool        `json:"auto_play,omitempty"` // This monster's turns are played automatically
         Stats        interface{} `json:"stats,omitempty"` // Character or Monster
 }
 
+// UnmarshalJSON restores Stats as a *Character or *Monster based on the combatant type,
+// so combatants loaded from participants_json behave like freshly created ones
+func (c *Combatant) UnmarshalJSON(data []byte) error {
+        type combatantAlias Combatant
+        aux := struct {
+                *combatantAlias
+                Stats json.RawMessage `json:"stats,omitempty"`
+        }{
+                combatantAlias: (*combatantAlias)(c),
+        }
+
+        if err := json.Unmarshal(data, &aux); err != nil {
+                return err
+        }
+
+        c.Stats = nil
+        if len(aux.Stats) == 0 || string(aux.Stats) == "null" {
+                return nil
+        }
+
+        switch c.Type {
+        case "character":
+                var character Character
+                if err := json.Unmarshal(aux.Stats, &character); err != nil {
+                        return err
+                }
+                c.Stats = &character
+        case "monster":
+                var monster Monster
+                if err := json.Unmarshal(aux.Stats, &monster); err != nil {
+                        return err
+                }
+                c.Stats = &monster
+        default:
+                var stats interface{}
+                if err := json.Unmarshal(aux.Stats, &stats); err != nil {
+                        return err
+                }
+                c.Stats = stats
+        }
+
+        return nil
+}
+
+// DeathSaves tracks a character's death saving throws while at 0 HP
+type DeathSaves struct {
+        Successes int  `json:"successes"`
+        Failures  int  `json:"failures"`
+        Stable    bool `json:"stable"`
+        Dead      bool `json:"dead"`
+}
+
+// ActionEconomy tracks the resources a combatant has left on their turn
+type ActionEconomy struct {
+        Actions      int `json:"actions"`
+        BonusActions int `json:"bonus_actions"`
+        Reactions    int `json:"reactions"`
+        MovementLeft int `json:"movement_left"` // In feet
+        Speed        int `json:"speed"`         // Base walking speed in feet
+        LegendaryActions int `json:"legendary_actions,omitempty"` // Legendary actions left this round
+        LairActions  int `json:"lair_actions,omitempty"`   // Lair actions left on initiative count 20
+        AttacksLeft  int `json:"attacks_left,omitempty"`   // Attacks left from an Attack action with Extra Attack
+        FeaturesUsed []string `json:"features_used,omitempty"` // Class features used this turn that work once per turn, like Sneak Attack
+        OffHandAttack bool    `json:"off_hand_attack,omitempty"` // Attacked with a light melee weapon this turn, allowing an off-hand attack
+        MainHandWeapon string `json:"main_hand_weapon,omitempty"` // SRD index of the light melee weapon that allowed the off-hand attack
+}
+
 // Battlefield represents the combat area
 type Battlefield struct {
         Width     int                `json:"width"`
@@ -120,6 +283,34 @@ type Battlefield struct {
         Grid      map[string]string  `json:"grid"` // Map of "x,y" to content
         Terrain   map[string]string  `json:"terrain"`
         Obstacles map[string]bool    `json:"obstacles"`
+        Zones     []Zone             `json:"zones,omitempty"` // Lingering spell areas
+}
+
+// Zone is an area of the battlefield affected by a lingering spell
+type Zone struct {
+        SourceID    string   `json:"source_id"`    // Combatant who created the zone
+        SourceSpell string   `json:"source_spell"` // SRD index of the spell that created it
+        Cells       [][2]int `json:"cells"`
+        Terrain     string   `json:"terrain,omitempty"` // e.g. "difficult"
+}
+
+// Concentration tracks the spell a combatant is concentrating on
+type Concentration struct {
+        SpellID   string `json:"spell_id"`
+        SpellName string `json:"spell_name"`
+        Duration  int    `json:"duration"` // Turns remaining, 0 = until broken
+}
+
+// ReadiedAction is an action a combatant holds with the Ready action and takes as a reaction
+// when its trigger happens, until the start of their next turn
+type ReadiedAction struct {
+        Trigger    string   `json:"trigger"`              // "moves", "enters_reach", "attacks" or "casts_spell"
+        TriggerID  string   `json:"trigger_id,omitempty"` // Creature whose action triggers it, any hostile creature if empty
+        Type       string   `json:"type"`                 // Action taken: "attack" or "cast_spell"
+        TargetIDs  []string `json:"target_ids,omitempty"` // Targets of the action, the triggering creature if empty
+        WeaponName string   `json:"weapon_name,omitempty"`
+        SpellID    string   `json:"spell_id,omitempty"`
+        SlotLevel  int      `json:"slot_level,omitempty"`
 }
 
 // CombatAction represents an action taken in combat
@@ -130,20 +321,47 @@ type CombatAction struct {
         Type             string                 `json:"type"` // "attack", "cast", "move", etc.
         TargetIDs        []string               `json:"target_ids,omitempty"`
         SpellID          string                 `json:"spell_id,omitempty"`
+        SlotLevel        int                    `json:"slot_level,omitempty"` // Spell slot level used to cast the spell
         WeaponName       string                 `json:"weapon_name,omitempty"`
         MovementPath     [][2]int               `json:"movement_path,omitempty"`
+        Area             *AreaTemplate          `json:"area,omitempty"` // Area of effect of the spell
         ExtraData        map[string]interface{} `json:"extra_data,omitempty"`
         ResultDescription string                `json:"result_description,omitempty"`
+        Rolls            []RollRecord           `json:"rolls,omitempty"` // Every die rolled for the action
         CreatedAt        time.Time              `json:"created_at"`
 }
 
+// RollRecord is an entry in a combat's roll ledger: one roll of one or more dice
+type RollRecord struct {
+        Formula  string    `json:"formula"` // e.g. "1d20+5", "2d20kh1" for advantage, "2d6+3"
+        Dice     []DieRoll `json:"dice"`
+        Modifier int       `json:"modifier"` // Added to the dice that aren't dropped to make the total
+        Total    int       `json:"total"`
+        Purpose  string    `json:"purpose"` // What the roll was for, like "attack roll" or "Fire Bolt damage"
+        ActorID  string    `json:"actor_id,omitempty"` // Combatant who rolled
+}
+
+// DieRoll is a single die of a roll
+type DieRoll struct {
+        Index   int  `json:"index"` // Position in the combat's sequence of dice, used to verify the face
+        Sides   int  `json:"sides"`
+        Face    int  `json:"face"`
+        Dropped bool `json:"dropped,omitempty"` // Not counted, like a die dropped by 4d6kh3 or rerolled
+
+}
+
 // ActionResult represents the result of a combat action
 type ActionResult struct {
         Success      bool         `json:"success"`
         Description  string       `json:"description"`
-        Damage       int          `json:"damage,omitempty"`
+        Damage       int          `json:"damage,omitempty"`     // Damage dealt after resistances, immunities and vulnerabilities
+        RawDamage    int          `json:"raw_damage,omitempty"` // Damage rolled
         DamageType   string       `json:"damage_type,omitempty"`
         Healing      int          `json:"healing,omitempty"`
         TargetEffect string       `json:"target_effect,omitempty"`
         Errors       []string     `json:"errors,omitempty"`
+        Reactions    []*ActionResult `json:"reactions,omitempty"` // Reactions triggered while resolving the action
+        Advantage    []string     `json:"advantage,omitempty"`    // Sources of advantage on the action's d20 roll
+        Disadvantage []string     `json:"disadvantage,omitempty"` // Sources of disadvantage on the action's d20 roll
+        AffectedCells [][2]int    `json:"affected_cells,omitempty"` // Cells covered by an area of effect
 }
\ No newline at end of file
@@ -0,0 +1,66 @@
+package models
+
+import (
+        "encoding/json"
+        "strconv"
+        "strings"
+)
+
+// Condition is a structured effect on a combatant, either one of the SRD conditions
+// or a combat marker such as "dodge" or "hidden"
+type Condition struct {
+        Name        string `json:"name"`
+        SourceID    string `json:"source_id,omitempty"`     // Combatant that applied the condition
+        SourceSpell string `json:"source_spell,omitempty"`  // Spell that applied the condition
+        Duration    int    `json:"duration,omitempty"`      // Turns of the affected creature left, 0 lasts until removed
+        SaveDC      int    `json:"save_dc,omitempty"`       // DC of the saving throw that ends the condition
+        SaveAbility string `json:"save_ability,omitempty"`  // Ability used for that saving throw, e.g. "wis"
+        StartOfTurn bool   `json:"start_of_turn,omitempty"` // Processed at the start of the affected creature's turn
+        EndOfTurn   bool   `json:"end_of_turn,omitempty"`   // Processed at the end of the affected creature's turn
+        Level       int    `json:"level,omitempty"`         // Exhaustion level
+        Value       int    `json:"value,omitempty"`         // Condition-specific value, e.g. the Stealth total while hidden
+        ACBonus     int    `json:"ac_bonus,omitempty"`      // Bonus to AC while the condition lasts
+}
+
+// UnmarshalJSON accepts both structured conditions and the legacy plain strings
+// such as "dodge" or "hidden:14" stored by older combats
+func (c *Condition) UnmarshalJSON(data []byte) error {
+        var legacy string
+        if err := json.Unmarshal(data, &legacy); err == nil {
+                *c = Condition{Name: legacy}
+                if name, value, found := strings.Cut(legacy, ":"); found {
+                        c.Name = name
+                        c.Value, _ = strconv.Atoi(value)
+                }
+                return nil
+        }
+
+        type conditionAlias Condition
+        return json.Unmarshal(data, (*conditionAlias)(c))
+}
+
+// HasCondition checks if the combatant has a condition with the given name
+func (c *Combatant) HasCondition(name string) bool {
+        return c.GetCondition(name) != nil
+}
+
+// GetCondition returns the combatant's first condition with the given name, or nil
+func (c *Combatant) GetCondition(name string) *Condition {
+        for i := range c.Conditions {
+                if c.Conditions[i].Name == name {
+                        return &c.Conditions[i]
+                }
+        }
+        return nil
+}
+
+// RemoveCondition removes every condition with the given name
+func (c *Combatant) RemoveCondition(name string) {
+        conditions := make([]Condition, 0, len(c.Conditions))
+        for _, condition := range c.Conditions {
+                if condition.Name != name {
+                        conditions = append(conditions, condition)
+                }
+        }
+        c.Conditions = conditions
+}
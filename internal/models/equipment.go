@@ -0,0 +1,55 @@
+package models
+
+// Weapon is a weapon from the SRD equipment catalog
+type Weapon struct {
+	Index           string      `json:"index"`
+	Name            string      `json:"name"`
+	Category        string      `json:"category"`     // "simple" or "martial"
+	WeaponRange     string      `json:"weapon_range"` // "melee" or "ranged"
+	Damage          DamageInfo  `json:"damage"`
+	TwoHandedDamage *DamageInfo `json:"two_handed_damage,omitempty"` // Damage of a versatile weapon wielded with two hands
+	Properties      []string    `json:"properties"`                  // SRD property indexes like "finesse" or "two-handed"
+	NormalRange     int         `json:"normal_range"`
+	LongRange       int         `json:"long_range,omitempty"`
+	ThrowNormal     int         `json:"throw_normal,omitempty"` // Range of a thrown melee weapon
+	ThrowLong       int         `json:"throw_long,omitempty"`
+	Weight          float64     `json:"weight"`
+}
+
+// HasProperty checks if the weapon has an SRD weapon property
+func (w *Weapon) HasProperty(property string) bool {
+	for _, p := range w.Properties {
+		if p == property {
+			return true
+		}
+	}
+	return false
+}
+
+// IsRanged checks if the weapon is a ranged weapon; thrown melee weapons are not
+func (w *Weapon) IsRanged() bool {
+	return w.WeaponRange == "ranged"
+}
+
+// Armor is a suit of armor or a shield from the SRD equipment catalog
+type Armor struct {
+	Index               string  `json:"index"`
+	Name                string  `json:"name"`
+	Category            string  `json:"category"` // "light", "medium", "heavy" or "shield"
+	BaseAC              int     `json:"base_ac"`
+	DexBonus            bool    `json:"dex_bonus"`               // Whether the wearer adds their DEX modifier
+	MaxDexBonus         int     `json:"max_dex_bonus,omitempty"` // Cap on the DEX modifier, 0 for none
+	StrengthMinimum     int     `json:"strength_minimum,omitempty"`
+	StealthDisadvantage bool    `json:"stealth_disadvantage"`
+	Weight              float64 `json:"weight"`
+}
+
+// Equipment is an item from the SRD equipment catalog
+type Equipment struct {
+	Index    string  `json:"index"`
+	Name     string  `json:"name"`
+	Category string  `json:"category"` // SRD equipment category like "weapon", "armor" or "adventuring-gear"
+	Weight   float64 `json:"weight"`
+	Weapon   *Weapon `json:"weapon,omitempty"`
+	Armor    *Armor  `json:"armor,omitempty"`
+}
@@ -0,0 +1,21 @@
+package models
+
+// Race is a character race from the SRD
+type Race struct {
+	Index               string         `json:"index"`
+	Name                string         `json:"name"`
+	Speed               int            `json:"speed"`
+	Size                string         `json:"size"`                            // "Small" or "Medium"
+	AbilityBonuses      map[string]int `json:"ability_bonuses"`                 // Bonus by ability index like "dex"
+	AbilityBonusChoices int            `json:"ability_bonus_choices,omitempty"` // Extra +1 bonuses the player chooses
+	AbilityBonusOptions []string       `json:"ability_bonus_options,omitempty"` // Abilities the chosen bonuses can go to
+	Subraces            []string       `json:"subraces"`                        // SRD subrace indexes
+}
+
+// Subrace is a subrace from the SRD, like a hill dwarf
+type Subrace struct {
+	Index          string         `json:"index"`
+	Name           string         `json:"name"`
+	Race           string         `json:"race"`            // Index of the race it belongs to
+	AbilityBonuses map[string]int `json:"ability_bonuses"` // Bonus by ability index like "wis"
+}
@@ -0,0 +1,92 @@
+package models
+
+// Abilities lists the SRD ability indexes in the order of a character sheet
+var Abilities = []string{"str", "dex", "con", "int", "wis", "cha"}
+
+// SkillAbilities maps each SRD skill index to the ability its checks use
+var SkillAbilities = map[string]string{
+	"acrobatics":      "dex",
+	"animal-handling": "wis",
+	"arcana":          "int",
+	"athletics":       "str",
+	"deception":       "cha",
+	"history":         "int",
+	"insight":         "wis",
+	"intimidation":    "cha",
+	"investigation":   "int",
+	"medicine":        "wis",
+	"nature":          "int",
+	"perception":      "wis",
+	"performance":     "cha",
+	"persuasion":      "cha",
+	"religion":        "int",
+	"sleight-of-hand": "dex",
+	"stealth":         "dex",
+	"survival":        "wis",
+}
+
+// AbilityScore returns the character's score for an SRD ability index like "dex", or nil
+// for an unknown ability
+func (c *Character) AbilityScore(ability string) *int {
+	switch ability {
+	case "str":
+		return &c.Strength
+	case "dex":
+		return &c.Dexterity
+	case "con":
+		return &c.Constitution
+	case "int":
+		return &c.Intelligence
+	case "wis":
+		return &c.Wisdom
+	case "cha":
+		return &c.Charisma
+	}
+	return nil
+}
+
+// AbilityModifier returns the character's modifier for an SRD ability index like "dex"
+func (c *Character) AbilityModifier(ability string) int {
+	if score := c.AbilityScore(ability); score != nil {
+		return GetAbilityModifier(*score)
+	}
+	return 0
+}
+
+// IsProficientInSave checks if the character is proficient in saving throws of an ability
+func (c *Character) IsProficientInSave(ability string) bool {
+	return containsString(c.SavingThrows, ability)
+}
+
+// SavingThrowBonus returns the modifier the character adds to saving throws of an ability
+func (c *Character) SavingThrowBonus(ability string) int {
+	bonus := c.AbilityModifier(ability)
+	if c.IsProficientInSave(ability) {
+		bonus += GetProficiencyBonus(c.Level)
+	}
+	return bonus
+}
+
+// SkillBonus returns the modifier the character adds to checks of a skill: the skill's
+// ability modifier plus their proficiency bonus if they're proficient, or twice it with
+// expertise
+func (c *Character) SkillBonus(skill string) int {
+	bonus := c.AbilityModifier(SkillAbilities[skill])
+	switch {
+	case containsString(c.Expertise, skill):
+		bonus += 2 * GetProficiencyBonus(c.Level)
+	case containsString(c.Skills, skill):
+		bonus += GetProficiencyBonus(c.Level)
+	}
+	return bonus
+}
+
+// containsString checks if a list of indexes contains one
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
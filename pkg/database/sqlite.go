@@ -43,6 +43,11 @@ func New(dbPath string) (*DB, error) {
                 return nil, fmt.Errorf("failed to create tables: %w", err)
         }
 
+        // Add columns introduced after the tables were first created
+        if err := migrateTables(sqlDB); err != nil {
+                return nil, fmt.Errorf("failed to migrate tables: %w", err)
+        }
+
         return &DB{DB: sqlDB}, nil
 }
 
@@ -147,6 +152,93 @@ func createTables(db *sql.DB) error {
                 return fmt.Errorf("failed to create combat_actions table: %w", err)
         }
 
+        // Create ability_rolls table
+        if _, err := db.Exec(`
+                CREATE TABLE IF NOT EXISTS ability_rolls (
+                        id TEXT PRIMARY KEY DEFAULT (lower(hex(randomblob(16)))),
+                        user_id TEXT NOT NULL,
+                        scores_json TEXT NOT NULL,
+                        rolls_json TEXT NOT NULL,
+                        used INTEGER NOT NULL DEFAULT 0,
+                        created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+                        FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+                )
+        `); err != nil {
+                return fmt.Errorf("failed to create ability_rolls table: %w", err)
+        }
+
+        return nil
+}
+
+// migrateTables adds columns that older databases are missing
+func migrateTables(db *sql.DB) error {
+        columns := []struct {
+                table      string
+                column     string
+                definition string
+        }{
+                {"characters", "spell_slots_json", "TEXT NOT NULL DEFAULT '[]'"},
+                {"combat_actions", "slot_level", "INTEGER"},
+                {"combats", "auto_monsters", "INTEGER NOT NULL DEFAULT 0"},
+                {"combats", "dice_seed", "TEXT NOT NULL DEFAULT ''"},
+                {"combats", "seed_commitment", "TEXT NOT NULL DEFAULT ''"},
+                {"combats", "roll_count", "INTEGER NOT NULL DEFAULT 0"},
+                {"combat_actions", "rolls_json", "TEXT"},
+                {"characters", "equipped_json", "TEXT NOT NULL DEFAULT '{}'"},
+                {"characters", "max_hp_reduction", "INTEGER NOT NULL DEFAULT 0"},
+                {"characters", "hit_dice_used", "INTEGER NOT NULL DEFAULT 0"},
+                {"characters", "exhaustion", "INTEGER NOT NULL DEFAULT 0"},
+                {"characters", "saving_throws_json", "TEXT NOT NULL DEFAULT '[]'"},
+                {"characters", "skills_json", "TEXT NOT NULL DEFAULT '[]'"},
+                {"characters", "expertise_json", "TEXT NOT NULL DEFAULT '[]'"},
+                {"characters", "subrace", "TEXT NOT NULL DEFAULT ''"},
+                {"characters", "size", "TEXT NOT NULL DEFAULT ''"},
+                {"characters", "race_speed", "INTEGER NOT NULL DEFAULT 0"},
+                {"characters", "feature_uses_json", "TEXT NOT NULL DEFAULT '[]'"},
+                {"characters", "feats_json", "TEXT NOT NULL DEFAULT '[]'"},
+        }
+
+        for _, c := range columns {
+                if err := addColumnIfMissing(db, c.table, c.column, c.definition); err != nil {
+                        return err
+                }
+        }
+
+        return nil
+}
+
+// addColumnIfMissing adds a column to a table unless it already exists
+func addColumnIfMissing(db *sql.DB, table, column, definition string) error {
+        rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+        if err != nil {
+                return fmt.Errorf("failed to read columns of %s: %w", table, err)
+        }
+        defer rows.Close()
+
+        for rows.Next() {
+                var (
+                        cid        int
+                        name       string
+                        columnType string
+                        notNull    int
+                        dfltValue  sql.NullString
+                        primaryKey int
+                )
+                if err := rows.Scan(&cid, &name, &columnType, &notNull, &dfltValue, &primaryKey); err != nil {
+                        return fmt.Errorf("failed to read columns of %s: %w", table, err)
+                }
+                if name == column {
+                        return nil
+                }
+        }
+        if err := rows.Err(); err != nil {
+                return fmt.Errorf("failed to read columns of %s: %w", table, err)
+        }
+
+        if _, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)); err != nil {
+                return fmt.Errorf("failed to add column %s to %s: %w", column, table, err)
+        }
+
         return nil
 }
 
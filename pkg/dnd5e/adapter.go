@@ -1,6 +1,9 @@
 package dnd5e
 
 import (
+	"fmt"
+	"strings"
+
 	"dnd-combat/internal/models"
 )
 
@@ -24,20 +27,7 @@ func (a *SRDClientAdapter) GetMonster(index string) (*models.Monster, error) {
 	}
 
 	// Convert from pkg/dnd5e.Monster to models.Monster
-	actions := make([]models.MonsterAction, 0, len(monster.Actions))
-	for _, action := range monster.Actions {
-		actions = append(actions, models.MonsterAction{
-			Name:        action.Name,
-			Description: action.Description,
-			AttackBonus: action.AttackBonus,
-			Damage: models.DamageInfo{
-				DiceCount: action.Damage.DiceCount,
-				DiceValue: action.Damage.DiceValue,
-				Bonus:     action.Damage.Bonus,
-				Type:      action.Damage.Type,
-			},
-		})
-	}
+	actions := convertMonsterActions(monster.Actions)
 
 	return &models.Monster{
 		Index:        monster.Index,
@@ -69,9 +59,89 @@ func (a *SRDClientAdapter) GetMonster(index string) (*models.Monster, error) {
 		Actions:        actions,
 		ChallengeRating: monster.ChallengeRating,
 		XP:             monster.XP,
+		DamageVulnerabilities: monster.DamageVulnerabilities,
+		DamageResistances:     monster.DamageResistances,
+		DamageImmunities:      monster.DamageImmunities,
+		ConditionImmunities:   monster.ConditionImmunities,
+		LegendaryActions:      convertMonsterActions(monster.LegendaryActions),
+		LegendaryActionCount:  monster.LegendaryActionCount,
+		SpecialAbilities:      convertMonsterActions(monster.SpecialAbilities),
+		SavingThrows:          monster.SavingThrows,
+		Skills:                monster.Skills,
 	}, nil
 }
 
+// convertMonsterActions converts pkg/dnd5e.MonsterAction values to models.MonsterAction
+func convertMonsterActions(monsterActions []MonsterAction) []models.MonsterAction {
+	actions := make([]models.MonsterAction, 0, len(monsterActions))
+	for _, action := range monsterActions {
+		extraDamage := make([]models.DamageInfo, 0, len(action.ExtraDamage))
+		for _, damage := range action.ExtraDamage {
+			extraDamage = append(extraDamage, convertDamageInfo(damage))
+		}
+
+		multiattack := make([]models.MultiattackEntry, 0, len(action.Multiattack))
+		for _, entry := range action.Multiattack {
+			multiattack = append(multiattack, models.MultiattackEntry{
+				ActionName: entry.ActionName,
+				Count:      entry.Count,
+			})
+		}
+
+		var usage *models.ActionUsage
+		if action.Usage != nil {
+			usage = &models.ActionUsage{
+				Type:     action.Usage.Type,
+				Dice:     action.Usage.Dice,
+				MinValue: action.Usage.MinValue,
+				Times:    action.Usage.Times,
+			}
+		}
+
+		actions = append(actions, models.MonsterAction{
+			Name:         action.Name,
+			Description:  action.Description,
+			AttackBonus:  action.AttackBonus,
+			Reach:        action.Reach,
+			Range:        action.Range,
+			LongRange:    action.LongRange,
+			Damage:       convertDamageInfo(action.Damage),
+			ExtraDamage:  extraDamage,
+			ReducesMaxHP: action.ReducesMaxHP,
+			Multiattack:  multiattack,
+			Usage:        usage,
+			SaveDC:       action.SaveDC,
+			SaveAbility:  action.SaveAbility,
+			SaveSuccess:  action.SaveSuccess,
+			Area:         convertAreaOfEffect(action.Area),
+			AreaRange:    action.AreaRange,
+			Cost:         action.Cost,
+		})
+	}
+	return actions
+}
+
+// convertDamageInfo converts pkg/dnd5e.DamageInfo to models.DamageInfo
+func convertDamageInfo(damage DamageInfo) models.DamageInfo {
+	return models.DamageInfo{
+		DiceCount: damage.DiceCount,
+		DiceValue: damage.DiceValue,
+		Bonus:     damage.Bonus,
+		Type:      damage.Type,
+	}
+}
+
+// convertAreaOfEffect converts pkg/dnd5e.AreaOfEffect to models.AreaOfEffect
+func convertAreaOfEffect(area *AreaOfEffect) *models.AreaOfEffect {
+	if area == nil {
+		return nil
+	}
+	return &models.AreaOfEffect{
+		Type: area.Type,
+		Size: area.Size,
+	}
+}
+
 // GetSpell fetches a spell from the SRD API and converts it to models.Spell
 func (a *SRDClientAdapter) GetSpell(index string) (*models.Spell, error) {
 	spell, err := a.client.GetSpell(index)
@@ -79,17 +149,188 @@ func (a *SRDClientAdapter) GetSpell(index string) (*models.Spell, error) {
 		return nil, err
 	}
 
-	return &models.Spell{
-		Index:       spell.Index,
-		Name:        spell.Name,
-		Level:       spell.Level,
-		School:      spell.School,
-		CastingTime: spell.CastingTime,
-		Range:       spell.Range,
-		Components:  spell.Components,
-		Duration:    spell.Duration,
-		Description: spell.Description,
-		HigherLevel: spell.HigherLevel,
-		Classes:     spell.Classes,
+	result := &models.Spell{
+		Index:                  spell.Index,
+		Name:                   spell.Name,
+		Level:                  spell.Level,
+		School:                 spell.School,
+		CastingTime:            spell.CastingTime,
+		Range:                  spell.Range,
+		Components:             spell.Components,
+		Duration:               spell.Duration,
+		Description:            spell.Description,
+		HigherLevel:            spell.HigherLevel,
+		Classes:                spell.Classes,
+		Concentration:          spell.Concentration,
+		AttackType:             spell.AttackType,
+		SaveAbility:            spell.SaveAbility,
+		SaveSuccess:            spell.SaveSuccess,
+		DamageType:             spell.DamageType,
+		DamageAtSlotLevel:      spell.DamageAtSlotLevel,
+		DamageAtCharacterLevel: spell.DamageAtCharacterLevel,
+		HealAtSlotLevel:        spell.HealAtSlotLevel,
+		Conditions:             spell.Conditions,
+		ACBonus:                spell.ACBonus,
+		TempHPAtSlotLevel:      spell.TempHPAtSlotLevel,
+		Terrain:                spell.Terrain,
+		AreaOfEffect:           convertAreaOfEffect(spell.AreaOfEffect),
+	}
+
+	return result, nil
+}
+
+// GetEquipment fetches an item from the SRD equipment catalog and converts it to models.Equipment
+func (a *SRDClientAdapter) GetEquipment(index string) (*models.Equipment, error) {
+	equipment, err := a.client.GetEquipment(index)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.Equipment{
+		Index:    equipment.Index,
+		Name:     equipment.Name,
+		Category: equipment.Category,
+		Weight:   equipment.Weight,
+	}
+	if equipment.Weapon != nil {
+		result.Weapon = convertWeapon(equipment.Weapon)
+	}
+	if equipment.Armor != nil {
+		result.Armor = convertArmor(equipment.Armor)
+	}
+	return result, nil
+}
+
+// GetWeapon fetches a weapon from the SRD equipment catalog and converts it to models.Weapon
+func (a *SRDClientAdapter) GetWeapon(index string) (*models.Weapon, error) {
+	weapon, err := a.client.GetWeapon(index)
+	if err != nil {
+		return nil, err
+	}
+	return convertWeapon(weapon), nil
+}
+
+// convertWeapon converts a pkg/dnd5e.Weapon to models.Weapon
+func convertWeapon(weapon *Weapon) *models.Weapon {
+	result := &models.Weapon{
+		Index:       weapon.Index,
+		Name:        weapon.Name,
+		Category:    weapon.Category,
+		WeaponRange: weapon.WeaponRange,
+		Damage:      convertDamageInfo(weapon.Damage),
+		Properties:  weapon.Properties,
+		NormalRange: weapon.NormalRange,
+		LongRange:   weapon.LongRange,
+		ThrowNormal: weapon.ThrowNormal,
+		ThrowLong:   weapon.ThrowLong,
+		Weight:      weapon.Weight,
+	}
+	if weapon.TwoHandedDamage != nil {
+		damage := convertDamageInfo(*weapon.TwoHandedDamage)
+		result.TwoHandedDamage = &damage
+	}
+	return result
+}
+
+// GetArmor fetches armor or a shield from the SRD equipment catalog and converts it to models.Armor
+func (a *SRDClientAdapter) GetArmor(index string) (*models.Armor, error) {
+	armor, err := a.client.GetArmor(index)
+	if err != nil {
+		return nil, err
+	}
+	return convertArmor(armor), nil
+}
+
+// convertArmor converts a pkg/dnd5e.Armor to models.Armor
+func convertArmor(armor *Armor) *models.Armor {
+	return &models.Armor{
+		Index:               armor.Index,
+		Name:                armor.Name,
+		Category:            armor.Category,
+		BaseAC:              armor.BaseAC,
+		DexBonus:            armor.DexBonus,
+		MaxDexBonus:         armor.MaxDexBonus,
+		StrengthMinimum:     armor.StrengthMinimum,
+		StealthDisadvantage: armor.StealthDisadvantage,
+		Weight:              armor.Weight,
+	}
+}
+
+// GetClassProficiencies fetches the SRD indexes of a class's proficiencies, like "martial-weapons"
+func (a *SRDClientAdapter) GetClassProficiencies(class string) ([]string, error) {
+	classData, err := a.client.GetClass(strings.ToLower(class))
+	if err != nil {
+		return nil, err
+	}
+	return classData.Proficiencies, nil
+}
+
+// GetClassSavingThrows fetches the abilities a class is proficient in saving throws of, like "str"
+func (a *SRDClientAdapter) GetClassSavingThrows(class string) ([]string, error) {
+	classData, err := a.client.GetClass(strings.ToLower(class))
+	if err != nil {
+		return nil, err
+	}
+	return classData.SavingThrows, nil
+}
+
+// GetHitDie fetches the size of a class's hit die, like 10 for a fighter's d10
+func (a *SRDClientAdapter) GetHitDie(class string) (int, error) {
+	classData, err := a.client.GetClass(strings.ToLower(class))
+	if err != nil {
+		return 0, err
+	}
+	if classData.HitDie == 0 {
+		return 0, fmt.Errorf("class '%s' has no hit die", class)
+	}
+	return classData.HitDie, nil
+}
+
+// GetRace fetches a race from the SRD API and converts it to models.Race
+func (a *SRDClientAdapter) GetRace(index string) (*models.Race, error) {
+	race, err := a.client.GetRace(strings.ToLower(index))
+	if err != nil {
+		return nil, err
+	}
+	return &models.Race{
+		Index:               race.Index,
+		Name:                race.Name,
+		Speed:               race.Speed,
+		Size:                race.Size,
+		AbilityBonuses:      race.AbilityBonuses,
+		AbilityBonusChoices: race.AbilityBonusChoices,
+		AbilityBonusOptions: race.AbilityBonusOptions,
+		Subraces:            race.Subraces,
+	}, nil
+}
+
+// GetSubrace fetches a subrace from the SRD API and converts it to models.Subrace
+func (a *SRDClientAdapter) GetSubrace(index string) (*models.Subrace, error) {
+	subrace, err := a.client.GetSubrace(strings.ToLower(index))
+	if err != nil {
+		return nil, err
+	}
+	return &models.Subrace{
+		Index:          subrace.Index,
+		Name:           subrace.Name,
+		Race:           subrace.Race,
+		AbilityBonuses: subrace.AbilityBonuses,
 	}, nil
-}
\ No newline at end of file
+}
+
+// GetSpellSlots fetches the spell slots a class has at a level from the SRD API
+func (a *SRDClientAdapter) GetSpellSlots(class string, level int) ([]models.SpellSlot, error) {
+	classLevel, err := a.client.GetClassLevel(strings.ToLower(class), level)
+	if err != nil {
+		return nil, err
+	}
+
+	slots := make([]models.SpellSlot, 0, len(classLevel.SpellSlots))
+	for slotLevel := 1; slotLevel <= 9; slotLevel++ {
+		if count := classLevel.SpellSlots[slotLevel]; count > 0 {
+			slots = append(slots, models.SpellSlot{Level: slotLevel, Max: count})
+		}
+	}
+
+	return slots, nil
+}
@@ -140,11 +140,12 @@ type SpellCastResult struct {
         DamageType   string
         Healing      int
         TargetEffect string
+        SavePassed   bool
         Description  string
 }
 
 // CastDamageSpell simulates casting a damage-dealing spell
-func (c *CombatRules) CastDamageSpell(casterName, targetName, spellName string, damageDice string, damageType string, saveDC int, saveAbilityMod int, halfDamageOnSave bool) SpellCastResult {
+func (c *CombatRules) CastDamageSpell(casterName, targetName, spellName string, damageDice string, damageType string, saveDC int, save SaveModifiers, halfDamageOnSave bool) SpellCastResult {
         result := SpellCastResult{
                 Success:   true,
                 SpellName: spellName,
@@ -160,8 +161,8 @@ func (c *CombatRules) CastDamageSpell(casterName, targetName, spellName string,
         // Check if target makes a saving throw
         if saveDC > 0 {
                 // Roll saving throw
-                saveRoll := c.diceRoller.Roll(1, 20) + saveAbilityMod
-                savePassed := saveRoll >= saveDC
+                savePassed := !save.AutoFail && c.diceRoller.RollSavingThrow(save.AbilityMod, saveDC, save.HasAdvantage, save.HasDisadvantage)
+                result.SavePassed = savePassed
                 
                 if savePassed {
                         if halfDamageOnSave {
@@ -354,7 +355,7 @@ func (c *CombatRules) ValidateMovement(actorName string, startPos []int, endPos
 // Condition represents a temporary condition affecting a combatant
 type Condition struct {
         Name       string
-        Duration   int
+        Duration   int    // Turns remaining, 0 means the condition lasts until removed
         Effect     string
         SaveDC     int
         SaveType   string
@@ -362,28 +363,40 @@ type Condition struct {
         StartOfTurn bool
 }
 
+// SaveModifiers describes how a creature rolls a saving throw
+type SaveModifiers struct {
+        AbilityMod      int // Ability modifier, plus the proficiency bonus for a proficient save
+        HasAdvantage    bool
+        HasDisadvantage bool
+        AutoFail        bool
+}
+
 // ProcessCondition handles condition effects and duration
-func (c *CombatRules) ProcessCondition(condition *Condition, actorName string, abilityMod int) (bool, string) {
-        // Reduce duration
-        condition.Duration--
-        
-        // Check if condition has expired
-        if condition.Duration <= 0 {
-                return true, fmt.Sprintf("%s is no longer affected by %s", actorName, condition.Name)
+func (c *CombatRules) ProcessCondition(condition *Condition, actorName string, save SaveModifiers) (bool, string) {
+        // Reduce duration of timed conditions
+        if condition.Duration > 0 {
+                condition.Duration--
+                
+                // Check if condition has expired
+                if condition.Duration <= 0 {
+                        return true, fmt.Sprintf("%s is no longer affected by %s", actorName, condition.Name)
+                }
         }
         
         // Process saving throws if needed
         if condition.SaveDC > 0 {
                 // Roll saving throw
-                saveRoll := c.diceRoller.Roll(1, 20) + abilityMod
-                savePassed := saveRoll >= condition.SaveDC
+                savePassed := !save.AutoFail && c.diceRoller.RollSavingThrow(save.AbilityMod, condition.SaveDC, save.HasAdvantage, save.HasDisadvantage)
                 
                 if savePassed {
                         return true, fmt.Sprintf("%s succeeds on a %s saving throw and is no longer affected by %s", 
                                 actorName, condition.SaveType, condition.Name)
-                } else {
+                } else if condition.Duration > 0 {
                         return false, fmt.Sprintf("%s fails on a %s saving throw and remains affected by %s (duration: %d rounds)", 
                                 actorName, condition.SaveType, condition.Name, condition.Duration)
+                } else {
+                        return false, fmt.Sprintf("%s fails on a %s saving throw and remains affected by %s", 
+                                actorName, condition.SaveType, condition.Name)
                 }
         }
         
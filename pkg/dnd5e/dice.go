@@ -1,24 +1,216 @@
 package dnd5e
 
 import (
+        "crypto/hmac"
+        cryptorand "crypto/rand"
+        "crypto/sha256"
+        "encoding/binary"
+        "encoding/hex"
         "fmt"
-        "math/rand"
-        "regexp"
         "strconv"
         "strings"
+        "sync"
         "time"
+
+        "dnd-combat/internal/models"
 )
 
-// DiceRoller handles dice rolling operations for D&D
+// DiceRoller handles dice rolling operations for D&D. Every die is derived from a
+// secret seed and its position in the sequence of dice, so the rolls of a seeded
+// roller can be reproduced and verified once the seed is revealed. Each roll is
+// recorded in a ledger with its formula, dice, modifier and purpose.
+// A DiceRoller is safe for concurrent use.
 type DiceRoller struct {
-        rng *rand.Rand
+        mu      sync.Mutex
+        seed    []byte
+        count   int                 // Dice rolled so far
+        purpose string              // Label of the rolls being made
+        actorID string
+        records []models.RollRecord // Rolls not yet taken from the ledger
 }
 
-// NewDiceRoller creates a new dice roller with a seeded random source
+// NewDiceRoller creates a new dice roller with a random seed
 func NewDiceRoller() *DiceRoller {
+        seed, err := NewRollSeed()
+        if err != nil {
+                // crypto/rand only fails if the OS has no randomness to give
+                seed = []byte(strconv.FormatInt(time.Now().UnixNano(), 10))
+        }
+        return NewSeededDiceRoller(seed, 0)
+}
+
+// NewSeededDiceRoller creates a dice roller that continues a seed's sequence of dice
+// after the count dice already rolled
+func NewSeededDiceRoller(seed []byte, count int) *DiceRoller {
         return &DiceRoller{
-                rng: rand.New(rand.NewSource(time.Now().UnixNano())),
+                seed:  seed,
+                count: count,
+        }
+}
+
+// NewRollSeed generates a random secret seed for a dice roller
+func NewRollSeed() ([]byte, error) {
+        seed := make([]byte, 32)
+        if _, err := cryptorand.Read(seed); err != nil {
+                return nil, err
+        }
+        return seed, nil
+}
+
+// SeedCommitment returns the SHA-256 hash of a seed as hex. Publishing it before any
+// dice are rolled commits to the seed without revealing it.
+func SeedCommitment(seed []byte) string {
+        sum := sha256.Sum256(seed)
+        return hex.EncodeToString(sum[:])
+}
+
+// DieFace returns the face of the die at a position in a seed's sequence: one plus the
+// first 8 bytes of HMAC-SHA256(seed, index as a big-endian uint64) modulo the sides
+func DieFace(seed []byte, index, sides int) int {
+        var message [8]byte
+        binary.BigEndian.PutUint64(message[:], uint64(index))
+
+        mac := hmac.New(sha256.New, seed)
+        mac.Write(message[:])
+        value := binary.BigEndian.Uint64(mac.Sum(nil)[:8])
+
+        return int(value%uint64(sides)) + 1
+}
+
+// VerifyRolls checks every die of a ledger against the seed it was rolled with, and that
+// the total of every roll is its kept dice plus its modifier
+func VerifyRolls(seed []byte, records []models.RollRecord) error {
+        for _, record := range records {
+                kept := 0
+                for _, die := range record.Dice {
+                        if face := DieFace(seed, die.Index, die.Sides); face != die.Face {
+                                return fmt.Errorf("die %d of %s (%s) shows %d but the seed gives %d",
+                                        die.Index, record.Purpose, record.Formula, die.Face, face)
+                        }
+                        if !die.Dropped {
+                                kept += die.Face
+                        }
+                }
+                if kept+record.Modifier != record.Total {
+                        return fmt.Errorf("%s (%s) totals %d but its kept dice and modifier add up to %d",
+                                record.Purpose, record.Formula, record.Total, kept+record.Modifier)
+                }
+        }
+        return nil
+}
+
+// Count returns how many dice the roller has rolled
+func (d *DiceRoller) Count() int {
+        d.mu.Lock()
+        defer d.mu.Unlock()
+        return d.count
+}
+
+// Label sets the purpose and actor recorded with the rolls that follow
+func (d *DiceRoller) Label(purpose, actorID string) {
+        d.mu.Lock()
+        defer d.mu.Unlock()
+        d.purpose = purpose
+        d.actorID = actorID
+}
+
+// AddModifier adds a modifier applied by the caller to the last recorded roll
+func (d *DiceRoller) AddModifier(modifier int) {
+        d.mu.Lock()
+        defer d.mu.Unlock()
+        if len(d.records) == 0 || modifier == 0 {
+                return
+        }
+
+        record := &d.records[len(d.records)-1]
+        dice := strings.TrimSuffix(record.Formula, formatFormula("", record.Modifier))
+        record.Modifier += modifier
+        record.Total += modifier
+        record.Formula = formatFormula(dice, record.Modifier)
+}
+
+// TakeRecords returns the rolls recorded since the last call and clears them
+func (d *DiceRoller) TakeRecords() []models.RollRecord {
+        d.mu.Lock()
+        defer d.mu.Unlock()
+        records := d.records
+        d.records = nil
+        return records
+}
+
+// draw rolls dice without recording them
+func (d *DiceRoller) draw(count, sides int) []models.DieRoll {
+        d.mu.Lock()
+        defer d.mu.Unlock()
+
+        dice := make([]models.DieRoll, 0, count)
+        for i := 0; i < count; i++ {
+                dice = append(dice, models.DieRoll{
+                        Index: d.count,
+                        Sides: sides,
+                        Face:  DieFace(d.seed, d.count, sides),
+                })
+                d.count++
+        }
+        return dice
+}
+
+// record adds a roll to the ledger
+func (d *DiceRoller) record(formula string, dice []models.DieRoll, modifier, total int) {
+        d.mu.Lock()
+        defer d.mu.Unlock()
+        d.records = append(d.records, models.RollRecord{
+                Formula:  formula,
+                Dice:     dice,
+                Modifier: modifier,
+                Total:    total,
+                Purpose:  d.purpose,
+                ActorID:  d.actorID,
+        })
+}
+
+// sumFaces adds up the faces of dice
+func sumFaces(dice []models.DieRoll) int {
+        total := 0
+        for _, die := range dice {
+                total += die.Face
+        }
+        return total
+}
+
+// formatFormula appends a modifier to a dice formula, like "1d20+5" or "1d20-1"
+func formatFormula(dice string, modifier int) string {
+        switch {
+        case modifier > 0:
+                return fmt.Sprintf("%s+%d", dice, modifier)
+        case modifier < 0:
+                return fmt.Sprintf("%s%d", dice, modifier)
         }
+        return dice
+}
+
+// rollD20 rolls and records a d20 with advantage, disadvantage or neither plus a modifier
+// and returns the d20's result
+func (d *DiceRoller) rollD20(modifier int, hasAdvantage bool, hasDisadvantage bool) int {
+        if hasAdvantage == hasDisadvantage {
+                dice := d.draw(1, 20)
+                d.record(formatFormula("1d20", modifier), dice, modifier, dice[0].Face+modifier)
+                return dice[0].Face
+        }
+
+        dice := d.draw(2, 20)
+        roll, formula := max(dice[0].Face, dice[1].Face), "2d20kh1"
+        if hasDisadvantage {
+                roll, formula = min(dice[0].Face, dice[1].Face), "2d20kl1"
+        }
+        // The other die is dropped
+        if dice[0].Face == roll {
+                dice[1].Dropped = true
+        } else {
+                dice[0].Dropped = true
+        }
+        d.record(formatFormula(formula, modifier), dice, modifier, roll+modifier)
+        return roll
 }
 
 // Roll rolls a specified number of dice with the given sides
@@ -27,54 +219,31 @@ func (d *DiceRoller) Roll(count, sides int) int {
                 return 0
         }
 
-        total := 0
-        for i := 0; i < count; i++ {
-                total += d.rng.Intn(sides) + 1
-        }
+        dice := d.draw(count, sides)
+        total := sumFaces(dice)
+        d.record(fmt.Sprintf("%dd%d", count, sides), dice, 0, total)
         return total
 }
 
 // RollWithAdvantage rolls a d20 with advantage (roll twice, take the higher value)
 func (d *DiceRoller) RollWithAdvantage() int {
-        roll1 := d.Roll(1, 20)
-        roll2 := d.Roll(1, 20)
-        if roll1 > roll2 {
-                return roll1
-        }
-        return roll2
+        return d.rollD20(0, true, false)
 }
 
 // RollWithDisadvantage rolls a d20 with disadvantage (roll twice, take the lower value)
 func (d *DiceRoller) RollWithDisadvantage() int {
-        roll1 := d.Roll(1, 20)
-        roll2 := d.Roll(1, 20)
-        if roll1 < roll2 {
-                return roll1
-        }
-        return roll2
+        return d.rollD20(0, false, true)
 }
 
 // RollHitPoints calculates hit points based on a hit dice string (e.g., "3d8+4")
 func (d *DiceRoller) RollHitPoints(hitDice string) int {
-        // Parse hit dice string
-        re := regexp.MustCompile(`(\d+)d(\d+)(?:\+(\d+))?`)
-        match := re.FindStringSubmatch(hitDice)
-        
-        if len(match) < 3 {
+        expression, err := ParseDice(hitDice)
+        if err != nil {
                 // Invalid format, return default value
                 return 10
         }
         
-        count, _ := strconv.Atoi(match[1])
-        sides, _ := strconv.Atoi(match[2])
-        
-        bonus := 0
-        if len(match) > 3 && match[3] != "" {
-                bonus, _ = strconv.Atoi(match[3])
-        }
-        
-        // Roll the dice and add the bonus
-        return d.Roll(count, sides) + bonus
+        return d.RollExpression(expression).Total
 }
 
 // RollDamage calculates damage based on a damage formula (e.g., "2d6+3")
@@ -82,89 +251,39 @@ func (d *DiceRoller) RollDamage(damageFormula string) int {
         return d.parseAndRoll(damageFormula)
 }
 
-// parseAndRoll parses a dice formula and rolls it
+// parseAndRoll parses a dice formula and rolls it. Invalid formulas roll nothing.
 func (d *DiceRoller) parseAndRoll(formula string) int {
-        formula = strings.TrimSpace(formula)
-        
-        // Handle empty formula
-        if formula == "" {
+        if strings.TrimSpace(formula) == "" {
                 return 0
         }
         
-        // Split by addition
-        parts := strings.Split(formula, "+")
-        total := 0
-        
-        for _, part := range parts {
-                part = strings.TrimSpace(part)
-                
-                // Check if it's a dice roll (e.g., "2d6")
-                if strings.Contains(part, "d") {
-                        diceParts := strings.Split(part, "d")
-                        if len(diceParts) != 2 {
-                                continue
-                        }
-                        
-                        count, err := strconv.Atoi(diceParts[0])
-                        if err != nil {
-                                count = 1 // Default to 1 if not specified
-                        }
-                        
-                        sides, err := strconv.Atoi(diceParts[1])
-                        if err != nil {
-                                continue
-                        }
-                        
-                        total += d.Roll(count, sides)
-                } else {
-                        // It's a static bonus
-                        bonus, err := strconv.Atoi(part)
-                        if err != nil {
-                                continue
-                        }
-                        
-                        total += bonus
-                }
+        expression, err := ParseDice(formula)
+        if err != nil {
+                return 0
         }
         
-        return total
+        return d.RollExpression(expression).Total
 }
 
 // RollSavingThrow simulates a saving throw against a DC
 func (d *DiceRoller) RollSavingThrow(abilityMod int, dc int, hasAdvantage bool, hasDisadvantage bool) bool {
-        var roll int
-        
-        if hasAdvantage && !hasDisadvantage {
-                roll = d.RollWithAdvantage()
-        } else if hasDisadvantage && !hasAdvantage {
-                roll = d.RollWithDisadvantage()
-        } else {
-                roll = d.Roll(1, 20)
-        }
-        
-        total := roll + abilityMod
-        return total >= dc
+        return d.RollSavingThrowTotal(abilityMod, hasAdvantage, hasDisadvantage) >= dc
+}
+
+// RollSavingThrowTotal rolls a saving throw and returns its total, for saves whose DC
+// isn't known to the roller
+func (d *DiceRoller) RollSavingThrowTotal(saveMod int, hasAdvantage bool, hasDisadvantage bool) int {
+        return d.rollD20(saveMod, hasAdvantage, hasDisadvantage) + saveMod
 }
 
 // RollInitiative simulates an initiative roll
 func (d *DiceRoller) RollInitiative(dexMod int, hasAdvantage bool) int {
-        if hasAdvantage {
-                return d.RollWithAdvantage() + dexMod
-        }
-        return d.Roll(1, 20) + dexMod
+        return d.rollD20(dexMod, hasAdvantage, false) + dexMod
 }
 
 // RollAttack simulates an attack roll
 func (d *DiceRoller) RollAttack(attackBonus int, hasAdvantage bool, hasDisadvantage bool) (int, bool) {
-        var roll int
-        
-        if hasAdvantage && !hasDisadvantage {
-                roll = d.RollWithAdvantage()
-        } else if hasDisadvantage && !hasAdvantage {
-                roll = d.RollWithDisadvantage()
-        } else {
-                roll = d.Roll(1, 20)
-        }
+        roll := d.rollD20(attackBonus, hasAdvantage, hasDisadvantage)
         
         // Check for critical hit
         isCritical := roll == 20
@@ -175,22 +294,12 @@ func (d *DiceRoller) RollAttack(attackBonus int, hasAdvantage bool, hasDisadvant
 
 // RollAbilityCheck simulates an ability check
 func (d *DiceRoller) RollAbilityCheck(abilityMod int, profBonus int, isProficient bool, hasAdvantage bool, hasDisadvantage bool) int {
-        var roll int
-        
-        if hasAdvantage && !hasDisadvantage {
-                roll = d.RollWithAdvantage()
-        } else if hasDisadvantage && !hasAdvantage {
-                roll = d.RollWithDisadvantage()
-        } else {
-                roll = d.Roll(1, 20)
-        }
-        
-        total := roll + abilityMod
+        modifier := abilityMod
         if isProficient {
-                total += profBonus
+                modifier += profBonus
         }
         
-        return total
+        return d.rollD20(modifier, hasAdvantage, hasDisadvantage) + modifier
 }
 
 // FormatRollResult formats a roll result as a string
@@ -0,0 +1,820 @@
+package dnd5e
+
+import (
+        "fmt"
+        "math"
+        "sort"
+        "strconv"
+        "strings"
+
+        "dnd-combat/internal/models"
+)
+
+// Limits on dice expressions, so a single roll can't tie up the server
+const (
+        maxExpressionDice = 1000 // Dice in a whole expression
+        maxDiceSides      = 1000
+        maxExplosions     = 10 // Extra dice an exploding die can roll
+)
+
+// DiceNode is a node of a parsed dice expression. Every node can be inspected for the
+// lowest, highest and average value it can roll.
+type DiceNode interface {
+        Min() int
+        Max() int
+        Average() float64
+        String() string
+        roll(d *DiceRoller, result *DiceRollResult) int
+}
+
+// DiceExpression is a parsed dice expression like "1d8[slashing]+2d6[fire]" or "4d6kh3"
+type DiceExpression struct {
+        Root DiceNode
+}
+
+// NumberNode is a constant
+type NumberNode struct {
+        Value int
+}
+
+// DiceTermNode is a roll of a number of dice of the same size, with the modifiers
+// written after it: kh/kl keep the highest or lowest dice, dh/dl drop them, ! explodes
+// dice that roll their highest face, r rerolls matching dice once and min sets the
+// lowest value a die can count as
+type DiceTermNode struct {
+        Count      int
+        Sides      int
+        Keep       int  // Dice kept, or 0 to keep them all
+        KeepLowest bool // Keep the lowest dice instead of the highest
+        Explode    bool
+        Reroll     *DiceComparison // Dice rerolled once
+        MinFace    int             // Lowest value a die counts as, or 0
+        notation   string
+}
+
+// DiceComparison matches die faces: "=" a single face, "<" that face or lower and ">"
+// that face or higher
+type DiceComparison struct {
+        Op    string
+        Value int
+}
+
+// NegateNode negates its operand
+type NegateNode struct {
+        Operand DiceNode
+}
+
+// BinaryNode adds, subtracts or multiplies two nodes
+type BinaryNode struct {
+        Op    byte // '+', '-' or '*'
+        Left  DiceNode
+        Right DiceNode
+}
+
+// GroupNode is an expression in parentheses
+type GroupNode struct {
+        Inner DiceNode
+}
+
+// LabelNode names the value of its operand, like the damage type of a damage roll
+type LabelNode struct {
+        Operand DiceNode
+        Label   string
+}
+
+// DiceRollResult is the outcome of rolling a dice expression
+type DiceRollResult struct {
+        Expression string           `json:"expression"`
+        Total      int              `json:"total"`
+        Terms      []DiceTermResult `json:"terms"`
+        Labels     map[string]int   `json:"labels,omitempty"` // Totals of labelled terms
+        Min        int              `json:"min"`
+        Max        int              `json:"max"`
+        Average    float64          `json:"average"`
+}
+
+// DiceTermResult is the outcome of rolling one dice term of an expression
+type DiceTermResult struct {
+        Notation string           `json:"notation"`
+        Dice     []models.DieRoll `json:"dice"` // Every die rolled, with dropped and rerolled dice marked
+        Total    int              `json:"total"`
+}
+
+// ParseDice parses a dice expression. Expressions add, subtract and multiply numbers,
+// dice terms like "2d6" or "d20" and expressions in parentheses, and any of these can
+// be labelled with a name in brackets, like "1d8[slashing]".
+func ParseDice(expression string) (*DiceExpression, error) {
+        p := &diceParser{input: expression}
+        root, err := p.parseSum()
+        if err != nil {
+                return nil, err
+        }
+        p.skipSpaces()
+        if p.pos < len(p.input) {
+                return nil, p.errorf("unexpected '%c'", p.input[p.pos])
+        }
+        if p.dice > maxExpressionDice {
+                return nil, fmt.Errorf("too many dice: an expression can roll at most %d", maxExpressionDice)
+        }
+        return &DiceExpression{Root: root}, nil
+}
+
+// Min returns the lowest total the expression can roll
+func (e *DiceExpression) Min() int { return e.Root.Min() }
+
+// Max returns the highest total the expression can roll
+func (e *DiceExpression) Max() int { return e.Root.Max() }
+
+// Average returns the average total of the expression
+func (e *DiceExpression) Average() float64 { return e.Root.Average() }
+
+// String returns the expression in its normal form
+func (e *DiceExpression) String() string { return e.Root.String() }
+
+// DiceAndBonus reduces an expression of at most one plain dice term plus or minus constants,
+// like "2d6+3" or "1d8-1", to its dice and bonus. ok is false for any other expression.
+func (e *DiceExpression) DiceAndBonus() (count, sides, bonus int, ok bool) {
+        var walk func(node DiceNode, sign int) bool
+        walk = func(node DiceNode, sign int) bool {
+                switch n := node.(type) {
+                case *NumberNode:
+                        bonus += sign * n.Value
+                        return true
+                case *DiceTermNode:
+                        if count > 0 || sign < 0 || n.Keep > 0 || n.Explode || n.Reroll != nil || n.MinFace > 0 {
+                                return false
+                        }
+                        count, sides = n.Count, n.Sides
+                        return true
+                case *BinaryNode:
+                        if n.Op == '*' {
+                                return false
+                        }
+                        rightSign := sign
+                        if n.Op == '-' {
+                                rightSign = -sign
+                        }
+                        return walk(n.Left, sign) && walk(n.Right, rightSign)
+                case *GroupNode:
+                        return walk(n.Inner, sign)
+                case *LabelNode:
+                        return walk(n.Operand, sign)
+                }
+                return false
+        }
+
+        if !walk(e.Root, 1) {
+                return 0, 0, 0, false
+        }
+        return count, sides, bonus, true
+}
+
+// RollExpression rolls a parsed dice expression and records it in the ledger as one roll
+func (d *DiceRoller) RollExpression(expression *DiceExpression) *DiceRollResult {
+        result := &DiceRollResult{
+                Expression: expression.String(),
+                Terms:      []DiceTermResult{},
+                Min:        expression.Min(),
+                Max:        expression.Max(),
+                Average:    expression.Average(),
+        }
+        result.Total = expression.Root.roll(d, result)
+
+        // Whatever the kept dice don't add up to, like constants, subtracted dice, multipliers
+        // and minimum values, is recorded as the modifier
+        var dice []models.DieRoll
+        kept := 0
+        for _, term := range result.Terms {
+                dice = append(dice, term.Dice...)
+                for _, die := range term.Dice {
+                        if !die.Dropped {
+                                kept += die.Face
+                        }
+                }
+        }
+        d.record(result.Expression, dice, result.Total-kept, result.Total)
+
+        return result
+}
+
+// Min returns the constant
+func (n *NumberNode) Min() int { return n.Value }
+
+// Max returns the constant
+func (n *NumberNode) Max() int { return n.Value }
+
+// Average returns the constant
+func (n *NumberNode) Average() float64 { return float64(n.Value) }
+
+// String returns the constant
+func (n *NumberNode) String() string { return strconv.Itoa(n.Value) }
+
+func (n *NumberNode) roll(d *DiceRoller, result *DiceRollResult) int { return n.Value }
+
+// Min returns the lowest total of the kept dice
+func (n *DiceTermNode) Min() int {
+        distribution := n.dieDistribution()
+        for value, p := range distribution {
+                if p > 0 {
+                        return value * n.kept()
+                }
+        }
+        return 0
+}
+
+// Max returns the highest total of the kept dice
+func (n *DiceTermNode) Max() int {
+        return (len(n.dieDistribution()) - 1) * n.kept()
+}
+
+// Average returns the average total of the kept dice
+func (n *DiceTermNode) Average() float64 {
+        distribution := n.dieDistribution()
+        if n.Keep == 0 || n.Keep >= n.Count {
+                mean := 0.0
+                for value, p := range distribution {
+                        mean += float64(value) * p
+                }
+                return mean * float64(n.Count)
+        }
+
+        // The j-th highest die is at least v when at least j dice are, so the average of
+        // the j-th highest die is the sum over v of that probability
+        positions := make([]bool, n.Count+1)
+        for j := 1; j <= n.Keep; j++ {
+                if n.KeepLowest {
+                        positions[n.Count-j+1] = true
+                } else {
+                        positions[j] = true
+                }
+        }
+
+        total := 0.0
+        atLeast := 1.0 // Chance a die rolls at least v
+        for v := 1; v < len(distribution); v++ {
+                atLeast -= distribution[v-1]
+                tails := binomialTails(n.Count, atLeast)
+                for j := 1; j <= n.Count; j++ {
+                        if positions[j] {
+                                total += tails[j]
+                        }
+                }
+        }
+        return total
+}
+
+// String returns the dice term as it was written, like "4d6kh3"
+func (n *DiceTermNode) String() string { return n.notation }
+
+// kept returns the number of dice counted in the total
+func (n *DiceTermNode) kept() int {
+        if n.Keep > 0 && n.Keep < n.Count {
+                return n.Keep
+        }
+        return n.Count
+}
+
+// dieDistribution returns the chance of a single die counting as each value, indexed by value
+func (n *DiceTermNode) dieDistribution() []float64 {
+        sides := n.Sides
+        face := 1 / float64(sides)
+
+        // The first roll, after matching faces are rerolled once
+        first := make([]float64, sides+1)
+        rerolled := 0.0
+        for f := 1; f <= sides; f++ {
+                if n.Reroll != nil && n.Reroll.Matches(f) {
+                        rerolled += face
+                } else {
+                        first[f] = face
+                }
+        }
+        for f := 1; f <= sides; f++ {
+                first[f] += rerolled * face
+        }
+
+        distribution := first
+        if n.Explode {
+                // A die showing its highest face adds another roll, up to the limit
+                chain := uniformDistribution(sides)
+                for i := 1; i < maxExplosions; i++ {
+                        chain = explodeDistribution(uniformDistribution(sides), chain, sides)
+                }
+                distribution = explodeDistribution(first, chain, sides)
+        }
+
+        if n.MinFace > 1 {
+                if n.MinFace >= len(distribution) {
+                        grown := make([]float64, n.MinFace+1)
+                        copy(grown, distribution)
+                        distribution = grown
+                }
+                for value := 0; value < n.MinFace; value++ {
+                        distribution[n.MinFace] += distribution[value]
+                        distribution[value] = 0
+                }
+        }
+        return distribution
+}
+
+// uniformDistribution is the distribution of a plain die
+func uniformDistribution(sides int) []float64 {
+        distribution := make([]float64, sides+1)
+        for f := 1; f <= sides; f++ {
+                distribution[f] = 1 / float64(sides)
+        }
+        return distribution
+}
+
+// explodeDistribution combines a roll with the chain of dice added when it shows its highest face
+func explodeDistribution(roll, chain []float64, sides int) []float64 {
+        distribution := make([]float64, sides+len(chain))
+        copy(distribution, roll[:sides])
+        for value, p := range chain {
+                distribution[sides+value] += roll[sides] * p
+        }
+        return distribution
+}
+
+// binomialTails returns the chance that at least j of n dice succeed, for every j, when
+// each succeeds with chance p
+func binomialTails(n int, p float64) []float64 {
+        exactly := make([]float64, n+1)
+        for i := 0; i <= n; i++ {
+                exactly[i] = binomial(n, i) * math.Pow(p, float64(i)) * math.Pow(1-p, float64(n-i))
+        }
+        tails := make([]float64, n+2)
+        for j := n; j >= 0; j-- {
+                tails[j] = tails[j+1] + exactly[j]
+        }
+        return tails
+}
+
+// binomial returns n choose k
+func binomial(n, k int) float64 {
+        result := 1.0
+        for i := 1; i <= k; i++ {
+                result = result * float64(n-k+i) / float64(i)
+        }
+        return result
+}
+
+// dieResult is one die of a term, with the rolls that make it up
+type dieResult struct {
+        value int
+        rolls []int // Indexes into the term's dice
+}
+
+func (n *DiceTermNode) roll(d *DiceRoller, result *DiceRollResult) int {
+        term := DiceTermResult{Notation: n.notation}
+        dice := make([]dieResult, 0, n.Count)
+
+        for i := 0; i < n.Count; i++ {
+                die := dieResult{}
+                rolled := d.draw(1, n.Sides)[0]
+                if n.Reroll != nil && n.Reroll.Matches(rolled.Face) {
+                        rolled.Dropped = true
+                        term.Dice = append(term.Dice, rolled)
+                        rolled = d.draw(1, n.Sides)[0]
+                }
+                die.rolls = append(die.rolls, len(term.Dice))
+                term.Dice = append(term.Dice, rolled)
+                die.value = rolled.Face
+
+                // Exploding dice add another roll for every highest face
+                for explosions := 0; n.Explode && rolled.Face == n.Sides && explosions < maxExplosions; explosions++ {
+                        rolled = d.draw(1, n.Sides)[0]
+                        die.rolls = append(die.rolls, len(term.Dice))
+                        term.Dice = append(term.Dice, rolled)
+                        die.value += rolled.Face
+                }
+
+                if die.value < n.MinFace {
+                        die.value = n.MinFace
+                }
+                dice = append(dice, die)
+        }
+
+        // Drop the dice that aren't kept
+        if n.Keep > 0 && n.Keep < n.Count {
+                sort.SliceStable(dice, func(i, j int) bool {
+                        if n.KeepLowest {
+                                return dice[i].value < dice[j].value
+                        }
+                        return dice[i].value > dice[j].value
+                })
+                for _, die := range dice[n.Keep:] {
+                        for _, index := range die.rolls {
+                                term.Dice[index].Dropped = true
+                        }
+                }
+                dice = dice[:n.Keep]
+        }
+
+        for _, die := range dice {
+                term.Total += die.value
+        }
+        result.Terms = append(result.Terms, term)
+        return term.Total
+}
+
+// Matches reports whether a die face matches the comparison
+func (c *DiceComparison) Matches(face int) bool {
+        switch c.Op {
+        case "<":
+                return face <= c.Value
+        case ">":
+                return face >= c.Value
+        }
+        return face == c.Value
+}
+
+// Min returns the lowest value of the negated operand
+func (n *NegateNode) Min() int { return -n.Operand.Max() }
+
+// Max returns the highest value of the negated operand
+func (n *NegateNode) Max() int { return -n.Operand.Min() }
+
+// Average returns the average value of the negated operand
+func (n *NegateNode) Average() float64 { return -n.Operand.Average() }
+
+// String returns the negation
+func (n *NegateNode) String() string { return "-" + n.Operand.String() }
+
+func (n *NegateNode) roll(d *DiceRoller, result *DiceRollResult) int {
+        return -n.Operand.roll(d, result)
+}
+
+// Min returns the lowest value of the operation
+func (n *BinaryNode) Min() int {
+        low, _ := n.bounds()
+        return low
+}
+
+// Max returns the highest value of the operation
+func (n *BinaryNode) Max() int {
+        _, high := n.bounds()
+        return high
+}
+
+// bounds returns the lowest and highest values of the operation
+func (n *BinaryNode) bounds() (int, int) {
+        switch n.Op {
+        case '+':
+                return n.Left.Min() + n.Right.Min(), n.Left.Max() + n.Right.Max()
+        case '-':
+                return n.Left.Min() - n.Right.Max(), n.Left.Max() - n.Right.Min()
+        }
+
+        // A product is lowest and highest at the extremes of its operands
+        products := []int{
+                n.Left.Min() * n.Right.Min(),
+                n.Left.Min() * n.Right.Max(),
+                n.Left.Max() * n.Right.Min(),
+                n.Left.Max() * n.Right.Max(),
+        }
+        low, high := products[0], products[0]
+        for _, product := range products[1:] {
+                low, high = min(low, product), max(high, product)
+        }
+        return low, high
+}
+
+// Average returns the average value of the operation. The operands roll their own dice,
+// so the average of a product is the product of the averages.
+func (n *BinaryNode) Average() float64 {
+        switch n.Op {
+        case '+':
+                return n.Left.Average() + n.Right.Average()
+        case '-':
+                return n.Left.Average() - n.Right.Average()
+        }
+        return n.Left.Average() * n.Right.Average()
+}
+
+// String returns the operation
+func (n *BinaryNode) String() string {
+        return n.Left.String() + string(n.Op) + n.Right.String()
+}
+
+func (n *BinaryNode) roll(d *DiceRoller, result *DiceRollResult) int {
+        left := n.Left.roll(d, result)
+        right := n.Right.roll(d, result)
+        switch n.Op {
+        case '+':
+                return left + right
+        case '-':
+                return left - right
+        }
+        return left * right
+}
+
+// Min returns the lowest value of the expression in parentheses
+func (n *GroupNode) Min() int { return n.Inner.Min() }
+
+// Max returns the highest value of the expression in parentheses
+func (n *GroupNode) Max() int { return n.Inner.Max() }
+
+// Average returns the average value of the expression in parentheses
+func (n *GroupNode) Average() float64 { return n.Inner.Average() }
+
+// String returns the expression in parentheses
+func (n *GroupNode) String() string { return "(" + n.Inner.String() + ")" }
+
+func (n *GroupNode) roll(d *DiceRoller, result *DiceRollResult) int {
+        return n.Inner.roll(d, result)
+}
+
+// Min returns the lowest value of the labelled operand
+func (n *LabelNode) Min() int { return n.Operand.Min() }
+
+// Max returns the highest value of the labelled operand
+func (n *LabelNode) Max() int { return n.Operand.Max() }
+
+// Average returns the average value of the labelled operand
+func (n *LabelNode) Average() float64 { return n.Operand.Average() }
+
+// String returns the operand with its label
+func (n *LabelNode) String() string { return n.Operand.String() + "[" + n.Label + "]" }
+
+func (n *LabelNode) roll(d *DiceRoller, result *DiceRollResult) int {
+        value := n.Operand.roll(d, result)
+        if result.Labels == nil {
+                result.Labels = make(map[string]int)
+        }
+        result.Labels[n.Label] += value
+        return value
+}
+
+// diceParser is a recursive descent parser of dice expressions
+type diceParser struct {
+        input string
+        pos   int
+        dice  int // Dice in the expression so far
+}
+
+// errorf returns a parse error at the current position
+func (p *diceParser) errorf(format string, args ...interface{}) error {
+        return fmt.Errorf("invalid dice expression at position %d: %s", p.pos+1, fmt.Sprintf(format, args...))
+}
+
+// skipSpaces moves past whitespace
+func (p *diceParser) skipSpaces() {
+        for p.pos < len(p.input) && (p.input[p.pos] == ' ' || p.input[p.pos] == '\t') {
+                p.pos++
+        }
+}
+
+// peek returns the next character without consuming it, or 0 at the end
+func (p *diceParser) peek() byte {
+        if p.pos < len(p.input) {
+                return p.input[p.pos]
+        }
+        return 0
+}
+
+// accept consumes the given text if the input continues with it, ignoring case
+func (p *diceParser) accept(text string) bool {
+        if len(p.input)-p.pos >= len(text) && strings.EqualFold(p.input[p.pos:p.pos+len(text)], text) {
+                p.pos += len(text)
+                return true
+        }
+        return false
+}
+
+// number parses an unsigned integer, reporting whether there was one
+func (p *diceParser) number() (int, bool, error) {
+        start := p.pos
+        for p.pos < len(p.input) && p.input[p.pos] >= '0' && p.input[p.pos] <= '9' {
+                p.pos++
+        }
+        if start == p.pos {
+                return 0, false, nil
+        }
+        value, err := strconv.Atoi(p.input[start:p.pos])
+        if err != nil || value > 1000000 {
+                p.pos = start
+                return 0, false, p.errorf("number is too large")
+        }
+        return value, true, nil
+}
+
+// parseSum parses terms joined by + and -
+func (p *diceParser) parseSum() (DiceNode, error) {
+        left, err := p.parseProduct()
+        if err != nil {
+                return nil, err
+        }
+        for {
+                p.skipSpaces()
+                op := p.peek()
+                if op != '+' && op != '-' {
+                        return left, nil
+                }
+                p.pos++
+                right, err := p.parseProduct()
+                if err != nil {
+                        return nil, err
+                }
+                left = &BinaryNode{Op: op, Left: left, Right: right}
+        }
+}
+
+// parseProduct parses factors joined by *
+func (p *diceParser) parseProduct() (DiceNode, error) {
+        left, err := p.parseFactor()
+        if err != nil {
+                return nil, err
+        }
+        for {
+                p.skipSpaces()
+                if p.peek() != '*' {
+                        return left, nil
+                }
+                p.pos++
+                right, err := p.parseFactor()
+                if err != nil {
+                        return nil, err
+                }
+                left = &BinaryNode{Op: '*', Left: left, Right: right}
+        }
+}
+
+// parseFactor parses a negation, or a number, dice term or expression in parentheses
+// with an optional label
+func (p *diceParser) parseFactor() (DiceNode, error) {
+        p.skipSpaces()
+        if p.peek() == '-' {
+                p.pos++
+                operand, err := p.parseFactor()
+                if err != nil {
+                        return nil, err
+                }
+                return &NegateNode{Operand: operand}, nil
+        }
+
+        node, err := p.parsePrimary()
+        if err != nil {
+                return nil, err
+        }
+
+        p.skipSpaces()
+        if p.peek() == '[' {
+                end := strings.IndexByte(p.input[p.pos:], ']')
+                if end < 0 {
+                        return nil, p.errorf("label is missing its closing ']'")
+                }
+                label := strings.TrimSpace(p.input[p.pos+1 : p.pos+end])
+                if label == "" {
+                        return nil, p.errorf("label is empty")
+                }
+                p.pos += end + 1
+                node = &LabelNode{Operand: node, Label: label}
+        }
+        return node, nil
+}
+
+// parsePrimary parses a number, dice term or expression in parentheses
+func (p *diceParser) parsePrimary() (DiceNode, error) {
+        p.skipSpaces()
+        if p.peek() == '(' {
+                p.pos++
+                inner, err := p.parseSum()
+                if err != nil {
+                        return nil, err
+                }
+                p.skipSpaces()
+                if p.peek() != ')' {
+                        return nil, p.errorf("expected ')'")
+                }
+                p.pos++
+                return &GroupNode{Inner: inner}, nil
+        }
+
+        start := p.pos
+        count, hasCount, err := p.number()
+        if err != nil {
+                return nil, err
+        }
+        if p.peek() != 'd' && p.peek() != 'D' {
+                if !hasCount {
+                        if p.pos >= len(p.input) {
+                                return nil, p.errorf("expression ends too soon")
+                        }
+                        return nil, p.errorf("unexpected '%c'", p.peek())
+                }
+                return &NumberNode{Value: count}, nil
+        }
+        if !hasCount {
+                count = 1
+        }
+        p.pos++
+        return p.parseDiceTerm(start, count)
+}
+
+// parseDiceTerm parses the size and modifiers of a dice term whose count has been read
+func (p *diceParser) parseDiceTerm(start, count int) (DiceNode, error) {
+        node := &DiceTermNode{Count: count}
+        if p.accept("%") {
+                node.Sides = 100
+        } else {
+                sides, ok, err := p.number()
+                if err != nil {
+                        return nil, err
+                }
+                if !ok {
+                        return nil, p.errorf("dice need a number of sides")
+                }
+                node.Sides = sides
+        }
+
+        if node.Count < 1 {
+                return nil, p.errorf("roll at least one die")
+        }
+        if node.Sides < 1 || node.Sides > maxDiceSides {
+                return nil, p.errorf("dice must have between 1 and %d sides", maxDiceSides)
+        }
+        p.dice += node.Count
+
+        for {
+                switch {
+                case p.accept("kh"):
+                        if err := p.parseKeep(node, false, false); err != nil {
+                                return nil, err
+                        }
+                case p.accept("kl"):
+                        if err := p.parseKeep(node, true, false); err != nil {
+                                return nil, err
+                        }
+                case p.accept("k"):
+                        if err := p.parseKeep(node, false, false); err != nil {
+                                return nil, err
+                        }
+                case p.accept("dh"):
+                        // Dropping the highest dice keeps the lowest
+                        if err := p.parseKeep(node, true, true); err != nil {
+                                return nil, err
+                        }
+                case p.accept("dl"):
+                        if err := p.parseKeep(node, false, true); err != nil {
+                                return nil, err
+                        }
+                case p.accept("!"):
+                        if node.Sides == 1 {
+                                return nil, p.errorf("a one-sided die can't explode")
+                        }
+                        node.Explode = true
+                        p.dice += node.Count * maxExplosions
+                case p.accept("min"):
+                        value, ok, err := p.number()
+                        if err != nil {
+                                return nil, err
+                        }
+                        if !ok {
+                                return nil, p.errorf("min needs a value")
+                        }
+                        node.MinFace = value
+                case p.accept("r"):
+                        comparison := &DiceComparison{Op: "="}
+                        if p.accept("<") {
+                                comparison.Op = "<"
+                        } else if p.accept(">") {
+                                comparison.Op = ">"
+                        }
+                        value, ok, err := p.number()
+                        if err != nil {
+                                return nil, err
+                        }
+                        if !ok {
+                                return nil, p.errorf("reroll needs a face to reroll")
+                        }
+                        comparison.Value = value
+                        node.Reroll = comparison
+                        p.dice += node.Count
+                default:
+                        node.notation = strings.ToLower(p.input[start:p.pos])
+                        return node, nil
+                }
+        }
+}
+
+// parseKeep parses the number of dice kept, or dropped when drop is set, which defaults
+// to one. At least one die must be kept.
+func (p *diceParser) parseKeep(node *DiceTermNode, lowest, drop bool) error {
+        value, ok, err := p.number()
+        if err != nil {
+                return err
+        }
+        if !ok {
+                value = 1
+        }
+        if drop {
+                value = node.Count - value
+        }
+        if value < 1 || value > node.Count {
+                return p.errorf("can't keep %d of %d dice", value, node.Count)
+        }
+        node.Keep = value
+        node.KeepLowest = lowest
+        return nil
+}
@@ -5,6 +5,9 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"regexp"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
@@ -100,8 +103,7 @@ type ClassData struct {
 	Index       string   `json:"index"`
 	Name        string   `json:"name"`
 	HitDie      int      `json:"hit_die"`
-	ProfChoices []string `json:"prof_choices"`
-	Proficiencies []string `json:"proficiencies"`
+	Proficiencies []string `json:"proficiencies"` // Indexes like "simple-weapons" or "longswords"
 	SavingThrows []string `json:"saving_throws"`
 	StartingEquipment []string `json:"starting_equipment"`
 	ClassLevels  string   `json:"class_levels"`
@@ -111,28 +113,70 @@ type ClassData struct {
 	} `json:"subclasses"`
 }
 
+// ClassLevelData represents what a class has at one level from the SRD API
+type ClassLevelData struct {
+	Level         int         `json:"level"`
+	ProfBonus     int         `json:"prof_bonus"`
+	SpellSlots    map[int]int `json:"spell_slots"` // Number of slots by spell level
+	CantripsKnown int         `json:"cantrips_known"`
+}
+
 // RaceData represents a character race from the SRD API
 type RaceData struct {
-	Index          string   `json:"index"`
-	Name           string   `json:"name"`
-	Speed          int      `json:"speed"`
-	AbilityBonuses []struct {
-		AbilityScore struct {
-			Index string `json:"index"`
-			Name  string `json:"name"`
-		} `json:"ability_score"`
-		Bonus int `json:"bonus"`
-	} `json:"ability_bonuses"`
-	Alignment       string   `json:"alignment"`
-	Age             string   `json:"age"`
-	Size            string   `json:"size"`
-	SizeDescription string   `json:"size_description"`
-	Languages       []string `json:"languages"`
-	Traits          []string `json:"traits"`
-	Subraces        []struct {
-		Name  string `json:"name"`
-		URL   string `json:"url"`
-	} `json:"subraces"`
+	Index               string         `json:"index"`
+	Name                string         `json:"name"`
+	Speed               int            `json:"speed"`
+	AbilityBonuses      map[string]int `json:"ability_bonuses"`       // Bonus by ability index
+	AbilityBonusChoices int            `json:"ability_bonus_choices"` // Extra +1 bonuses the player chooses, like a half-elf's two
+	AbilityBonusOptions []string       `json:"ability_bonus_options"` // Abilities the chosen bonuses can go to
+	Alignment           string         `json:"alignment"`
+	Age                 string         `json:"age"`
+	Size                string         `json:"size"`
+	SizeDescription     string         `json:"size_description"`
+	Languages           []string       `json:"languages"` // Language indexes
+	Traits              []string       `json:"traits"`    // Trait indexes
+	Subraces            []string       `json:"subraces"`  // Subrace indexes
+}
+
+// SubraceData represents a subrace from the SRD API
+type SubraceData struct {
+	Index          string         `json:"index"`
+	Name           string         `json:"name"`
+	Race           string         `json:"race"`            // Index of the race it belongs to
+	AbilityBonuses map[string]int `json:"ability_bonuses"` // Bonus by ability index
+}
+
+// apiAbilityBonus is an ability score bonus of a race or subrace in the SRD API
+type apiAbilityBonus struct {
+	AbilityScore struct {
+		Index string `json:"index"`
+	} `json:"ability_score"`
+	Bonus int `json:"bonus"`
+}
+
+// apiReference is a reference to another SRD resource
+type apiReference struct {
+	Index string `json:"index"`
+	Name  string `json:"name"`
+	URL   string `json:"url"`
+}
+
+// abilityBonuses collects SRD ability bonuses by ability index
+func abilityBonuses(bonuses []apiAbilityBonus) map[string]int {
+	result := make(map[string]int, len(bonuses))
+	for _, bonus := range bonuses {
+		result[bonus.AbilityScore.Index] += bonus.Bonus
+	}
+	return result
+}
+
+// referenceIndexes returns the indexes of SRD references
+func referenceIndexes(references []apiReference) []string {
+	indexes := make([]string, 0, len(references))
+	for _, reference := range references {
+		indexes = append(indexes, reference.Index)
+	}
+	return indexes
 }
 
 // GetClass fetches a class from the SRD API
@@ -155,14 +199,105 @@ func (c *SRDClient) GetClass(index string) (*ClassData, error) {
 		return nil, fmt.Errorf("API returned non-OK status: %d", resp.StatusCode)
 	}
 
-	var classData ClassData
-	if err := json.NewDecoder(resp.Body).Decode(&classData); err != nil {
+	var apiResponse struct {
+		Index         string `json:"index"`
+		Name          string `json:"name"`
+		HitDie        int    `json:"hit_die"`
+		Proficiencies []struct {
+			Index string `json:"index"`
+		} `json:"proficiencies"`
+		SavingThrows []struct {
+			Index string `json:"index"`
+		} `json:"saving_throws"`
+		StartingEquipment []struct {
+			Equipment struct {
+				Index string `json:"index"`
+			} `json:"equipment"`
+		} `json:"starting_equipment"`
+		ClassLevels string `json:"class_levels"`
+		Subclasses  []struct {
+			Name string `json:"name"`
+			URL  string `json:"url"`
+		} `json:"subclasses"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&apiResponse); err != nil {
 		return nil, fmt.Errorf("error decoding class data: %w", err)
 	}
 
+	// References to other SRD resources are kept as their indexes
+	classData := &ClassData{
+		Index:       apiResponse.Index,
+		Name:        apiResponse.Name,
+		HitDie:      apiResponse.HitDie,
+		ClassLevels: apiResponse.ClassLevels,
+		Subclasses:  apiResponse.Subclasses,
+	}
+	for _, proficiency := range apiResponse.Proficiencies {
+		classData.Proficiencies = append(classData.Proficiencies, proficiency.Index)
+	}
+	for _, save := range apiResponse.SavingThrows {
+		classData.SavingThrows = append(classData.SavingThrows, save.Index)
+	}
+	for _, item := range apiResponse.StartingEquipment {
+		classData.StartingEquipment = append(classData.StartingEquipment, item.Equipment.Index)
+	}
+
+	// Store in cache
+	c.cache.Set(cacheKey, classData)
+	return classData, nil
+}
+
+// GetClassLevel fetches the features of a class at a level from the SRD API
+func (c *SRDClient) GetClassLevel(index string, level int) (*ClassLevelData, error) {
+	// Check cache first
+	cacheKey := fmt.Sprintf("class:%s:level:%d", index, level)
+	if data, found := c.cache.Get(cacheKey); found {
+		return data.(*ClassLevelData), nil
+	}
+
+	// Fetch from API
+	url := fmt.Sprintf("%s/classes/%s/levels/%d", c.baseURL, index, level)
+	resp, err := c.httpClient.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching class level data: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API returned non-OK status: %d", resp.StatusCode)
+	}
+
+	var apiResponse struct {
+		Level        int            `json:"level"`
+		ProfBonus    int            `json:"prof_bonus"`
+		Spellcasting map[string]int `json:"spellcasting"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&apiResponse); err != nil {
+		return nil, fmt.Errorf("error decoding class level data: %w", err)
+	}
+
+	classLevel := &ClassLevelData{
+		Level:      apiResponse.Level,
+		ProfBonus:  apiResponse.ProfBonus,
+		SpellSlots: make(map[int]int),
+	}
+
+	// Spell slots come as spell_slots_level_1 ... spell_slots_level_9
+	for key, value := range apiResponse.Spellcasting {
+		var slotLevel int
+		if _, err := fmt.Sscanf(key, "spell_slots_level_%d", &slotLevel); err == nil && value > 0 {
+			classLevel.SpellSlots[slotLevel] = value
+		}
+		if key == "cantrips_known" {
+			classLevel.CantripsKnown = value
+		}
+	}
+
 	// Store in cache
-	c.cache.Set(cacheKey, &classData)
-	return &classData, nil
+	c.cache.Set(cacheKey, classLevel)
+	return classLevel, nil
 }
 
 // GetRace fetches a race from the SRD API
@@ -185,14 +320,95 @@ func (c *SRDClient) GetRace(index string) (*RaceData, error) {
 		return nil, fmt.Errorf("API returned non-OK status: %d", resp.StatusCode)
 	}
 
-	var raceData RaceData
-	if err := json.NewDecoder(resp.Body).Decode(&raceData); err != nil {
+	var apiResponse struct {
+		Index               string            `json:"index"`
+		Name                string            `json:"name"`
+		Speed               int               `json:"speed"`
+		AbilityBonuses      []apiAbilityBonus `json:"ability_bonuses"`
+		AbilityBonusOptions *struct {
+			Choose int `json:"choose"`
+			From   struct {
+				Options []apiAbilityBonus `json:"options"`
+			} `json:"from"`
+		} `json:"ability_bonus_options"`
+		Alignment       string         `json:"alignment"`
+		Age             string         `json:"age"`
+		Size            string         `json:"size"`
+		SizeDescription string         `json:"size_description"`
+		Languages       []apiReference `json:"languages"`
+		Traits          []apiReference `json:"traits"`
+		Subraces        []apiReference `json:"subraces"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&apiResponse); err != nil {
 		return nil, fmt.Errorf("error decoding race data: %w", err)
 	}
 
+	// References to other SRD resources are kept as their indexes
+	raceData := &RaceData{
+		Index:           apiResponse.Index,
+		Name:            apiResponse.Name,
+		Speed:           apiResponse.Speed,
+		AbilityBonuses:  abilityBonuses(apiResponse.AbilityBonuses),
+		Alignment:       apiResponse.Alignment,
+		Age:             apiResponse.Age,
+		Size:            apiResponse.Size,
+		SizeDescription: apiResponse.SizeDescription,
+		Languages:       referenceIndexes(apiResponse.Languages),
+		Traits:          referenceIndexes(apiResponse.Traits),
+		Subraces:        referenceIndexes(apiResponse.Subraces),
+	}
+	if options := apiResponse.AbilityBonusOptions; options != nil {
+		raceData.AbilityBonusChoices = options.Choose
+		for _, option := range options.From.Options {
+			raceData.AbilityBonusOptions = append(raceData.AbilityBonusOptions, option.AbilityScore.Index)
+		}
+	}
+
 	// Store in cache
-	c.cache.Set(cacheKey, &raceData)
-	return &raceData, nil
+	c.cache.Set(cacheKey, raceData)
+	return raceData, nil
+}
+
+// GetSubrace fetches a subrace from the SRD API
+func (c *SRDClient) GetSubrace(index string) (*SubraceData, error) {
+	// Check cache first
+	cacheKey := fmt.Sprintf("subrace:%s", index)
+	if data, found := c.cache.Get(cacheKey); found {
+		return data.(*SubraceData), nil
+	}
+
+	// Fetch from API
+	url := fmt.Sprintf("%s/subraces/%s", c.baseURL, index)
+	resp, err := c.httpClient.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching subrace data: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API returned non-OK status: %d", resp.StatusCode)
+	}
+
+	var apiResponse struct {
+		Index          string            `json:"index"`
+		Name           string            `json:"name"`
+		Race           apiReference      `json:"race"`
+		AbilityBonuses []apiAbilityBonus `json:"ability_bonuses"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&apiResponse); err != nil {
+		return nil, fmt.Errorf("error decoding subrace data: %w", err)
+	}
+
+	subraceData := &SubraceData{
+		Index:          apiResponse.Index,
+		Name:           apiResponse.Name,
+		Race:           apiResponse.Race.Index,
+		AbilityBonuses: abilityBonuses(apiResponse.AbilityBonuses),
+	}
+
+	// Store in cache
+	c.cache.Set(cacheKey, subraceData)
+	return subraceData, nil
 }
 
 // GetMonster fetches a monster from the SRD API
@@ -237,19 +453,23 @@ func (c *SRDClient) GetMonster(index string) (*Monster, error) {
 		Intelligence int     `json:"intelligence"`
 		Wisdom       int     `json:"wisdom"`
 		Charisma     int     `json:"charisma"`
-		Actions      []struct {
-			Name        string `json:"name"`
-			Description string `json:"desc"`
-			AttackBonus int    `json:"attack_bonus,omitempty"`
-			Damage      []struct {
-				DamageDice string `json:"damage_dice"`
-				DamageType struct {
-					Name string `json:"name"`
-				} `json:"damage_type"`
-			} `json:"damage,omitempty"`
-		} `json:"actions"`
-		ChallengeRating float64 `json:"challenge_rating"`
-		XP              int     `json:"xp"`
+		Actions          []apiMonsterAction `json:"actions"`
+		LegendaryActions []apiMonsterAction `json:"legendary_actions"`
+		SpecialAbilities []apiMonsterAction `json:"special_abilities"`
+		ChallengeRating       float64  `json:"challenge_rating"`
+		XP                    int      `json:"xp"`
+		DamageVulnerabilities []string `json:"damage_vulnerabilities"`
+		DamageResistances     []string `json:"damage_resistances"`
+		DamageImmunities      []string `json:"damage_immunities"`
+		ConditionImmunities   []struct {
+			Index string `json:"index"`
+		} `json:"condition_immunities"`
+		Proficiencies []struct {
+			Value       int `json:"value"`
+			Proficiency struct {
+				Index string `json:"index"`
+			} `json:"proficiency"`
+		} `json:"proficiencies"`
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(&apiResponse); err != nil {
@@ -287,28 +507,63 @@ func (c *SRDClient) GetMonster(index string) (*Monster, error) {
 		Actions:      make([]MonsterAction, 0, len(apiResponse.Actions)),
 		ChallengeRating: apiResponse.ChallengeRating,
 		XP:           apiResponse.XP,
+		DamageVulnerabilities: apiResponse.DamageVulnerabilities,
+		DamageResistances:     apiResponse.DamageResistances,
+		DamageImmunities:      apiResponse.DamageImmunities,
+	}
+
+	for _, immunity := range apiResponse.ConditionImmunities {
+		monster.ConditionImmunities = append(monster.ConditionImmunities, immunity.Index)
+	}
+
+	// Proficient saves and skills are listed with their total bonus, like
+	// "saving-throw-dex" or "skill-stealth"
+	for _, proficiency := range apiResponse.Proficiencies {
+		if ability, found := strings.CutPrefix(proficiency.Proficiency.Index, "saving-throw-"); found {
+			if monster.SavingThrows == nil {
+				monster.SavingThrows = make(map[string]int)
+			}
+			monster.SavingThrows[ability] = proficiency.Value
+		} else if skill, found := strings.CutPrefix(proficiency.Proficiency.Index, "skill-"); found {
+			if monster.Skills == nil {
+				monster.Skills = make(map[string]int)
+			}
+			monster.Skills[skill] = proficiency.Value
+		}
 	}
 
 	// Process actions
 	for _, action := range apiResponse.Actions {
-		monsterAction := MonsterAction{
-			Name:        action.Name,
-			Description: action.Description,
-			AttackBonus: action.AttackBonus,
-		}
+		monster.Actions = append(monster.Actions, action.convert())
+	}
 
-		// Process damage
-		if len(action.Damage) > 0 {
-			diceCount, diceValue, bonus := parseDamageDice(action.Damage[0].DamageDice)
-			monsterAction.Damage = DamageInfo{
-				DiceCount: diceCount,
-				DiceValue: diceValue,
-				Bonus:     bonus,
-				Type:      action.Damage[0].DamageType.Name,
+	// Legendary actions that make one of the monster's attacks, like a dragon's
+	// Tail Attack, use that attack's bonus and damage
+	for _, action := range apiResponse.LegendaryActions {
+		legendaryAction := action.convert()
+		legendaryAction.Cost = legendaryActionCost(action.Name)
+		if legendaryAction.AttackBonus == 0 && legendaryAction.SaveDC == 0 {
+			for _, attack := range monster.Actions {
+				if strings.Contains(strings.ToLower(action.Description), "makes a "+strings.ToLower(attack.Name)+" attack") {
+					legendaryAction.AttackBonus = attack.AttackBonus
+					legendaryAction.Damage = attack.Damage
+					legendaryAction.ExtraDamage = attack.ExtraDamage
+					legendaryAction.Reach = attack.Reach
+					legendaryAction.Range = attack.Range
+					legendaryAction.LongRange = attack.LongRange
+					break
+				}
 			}
 		}
+		monster.LegendaryActions = append(monster.LegendaryActions, legendaryAction)
+	}
+	if len(monster.LegendaryActions) > 0 {
+		// Legendary monsters in the SRD can take 3 legendary actions a round
+		monster.LegendaryActionCount = 3
+	}
 
-		monster.Actions = append(monster.Actions, monsterAction)
+	for _, ability := range apiResponse.SpecialAbilities {
+		monster.SpecialAbilities = append(monster.SpecialAbilities, ability.convert())
 	}
 
 	// Store in cache
@@ -352,6 +607,23 @@ func (c *SRDClient) GetSpell(index string) (*Spell, error) {
 		Classes     []struct {
 			Name string `json:"name"`
 		} `json:"classes"`
+		Concentration bool   `json:"concentration"`
+		AttackType    string `json:"attack_type"`
+		DC            *struct {
+			DCType struct {
+				Index string `json:"index"`
+			} `json:"dc_type"`
+			DCSuccess string `json:"dc_success"`
+		} `json:"dc"`
+		Damage *struct {
+			DamageType struct {
+				Index string `json:"index"`
+			} `json:"damage_type"`
+			DamageAtSlotLevel      map[string]string `json:"damage_at_slot_level"`
+			DamageAtCharacterLevel map[string]string `json:"damage_at_character_level"`
+		} `json:"damage"`
+		HealAtSlotLevel map[string]string `json:"heal_at_slot_level"`
+		AreaOfEffect    *AreaOfEffect     `json:"area_of_effect"`
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(&apiResponse); err != nil {
@@ -380,11 +652,375 @@ func (c *SRDClient) GetSpell(index string) (*Spell, error) {
 		spell.Classes = append(spell.Classes, class.Name)
 	}
 
+	// Structured combat data
+	spell.Concentration = apiResponse.Concentration
+	spell.AttackType = apiResponse.AttackType
+	spell.AreaOfEffect = apiResponse.AreaOfEffect
+	spell.HealAtSlotLevel = parseLevelTable(apiResponse.HealAtSlotLevel)
+	if apiResponse.DC != nil {
+		spell.SaveAbility = apiResponse.DC.DCType.Index
+		spell.SaveSuccess = apiResponse.DC.DCSuccess
+	}
+	if apiResponse.Damage != nil {
+		spell.DamageType = apiResponse.Damage.DamageType.Index
+		spell.DamageAtSlotLevel = parseLevelTable(apiResponse.Damage.DamageAtSlotLevel)
+		spell.DamageAtCharacterLevel = parseLevelTable(apiResponse.Damage.DamageAtCharacterLevel)
+	}
+	if effects, ok := spellEffects[spell.Index]; ok {
+		spell.Conditions = effects.Conditions
+		spell.ACBonus = effects.ACBonus
+		spell.Terrain = effects.Terrain
+		spell.TempHPAtSlotLevel = effects.TempHPAtSlotLevel
+	}
+
 	// Store in cache
 	c.cache.Set(cacheKey, spell)
 	return spell, nil
 }
 
+// apiEquipment is an item in the SRD equipment payload, with the fields of weapons and armor
+type apiEquipment struct {
+	Index             string `json:"index"`
+	Name              string `json:"name"`
+	EquipmentCategory struct {
+		Index string `json:"index"`
+	} `json:"equipment_category"`
+	Weight float64 `json:"weight"`
+
+	// Weapons
+	WeaponCategory  string           `json:"weapon_category"`
+	WeaponRange     string           `json:"weapon_range"`
+	Damage          *apiWeaponDamage `json:"damage"`
+	TwoHandedDamage *apiWeaponDamage `json:"two_handed_damage"`
+	Range           struct {
+		Normal int `json:"normal"`
+		Long   int `json:"long"`
+	} `json:"range"`
+	ThrowRange struct {
+		Normal int `json:"normal"`
+		Long   int `json:"long"`
+	} `json:"throw_range"`
+	Properties []struct {
+		Index string `json:"index"`
+	} `json:"properties"`
+
+	// Armor
+	ArmorCategory string `json:"armor_category"`
+	ArmorClass    struct {
+		Base     int  `json:"base"`
+		DexBonus bool `json:"dex_bonus"`
+		MaxBonus int  `json:"max_bonus"`
+	} `json:"armor_class"`
+	StrMinimum          int  `json:"str_minimum"`
+	StealthDisadvantage bool `json:"stealth_disadvantage"`
+}
+
+// apiWeaponDamage is the damage of a weapon in the SRD equipment payload
+type apiWeaponDamage struct {
+	DamageDice string `json:"damage_dice"`
+	DamageType struct {
+		Index string `json:"index"`
+	} `json:"damage_type"`
+}
+
+// convert turns SRD weapon damage into our DamageInfo
+func (d apiWeaponDamage) convert() DamageInfo {
+	diceCount, diceValue, bonus := parseDamageDice(d.DamageDice)
+	return DamageInfo{
+		DiceCount: diceCount,
+		DiceValue: diceValue,
+		Bonus:     bonus,
+		Type:      d.DamageType.Index,
+	}
+}
+
+// getEquipment fetches an item of equipment from the SRD API
+func (c *SRDClient) getEquipment(index string) (*apiEquipment, error) {
+	// Check cache first
+	cacheKey := fmt.Sprintf("equipment:%s", index)
+	if data, found := c.cache.Get(cacheKey); found {
+		return data.(*apiEquipment), nil
+	}
+
+	// Fetch from API
+	url := fmt.Sprintf("%s/equipment/%s", c.baseURL, index)
+	resp, err := c.httpClient.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching equipment data: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API returned non-OK status: %d", resp.StatusCode)
+	}
+
+	var equipment apiEquipment
+	if err := json.NewDecoder(resp.Body).Decode(&equipment); err != nil {
+		return nil, fmt.Errorf("error decoding equipment data: %w", err)
+	}
+
+	// Store in cache
+	c.cache.Set(cacheKey, &equipment)
+	return &equipment, nil
+}
+
+// GetEquipment fetches any item from the SRD equipment catalog, with its weapon or armor stats
+func (c *SRDClient) GetEquipment(index string) (*Equipment, error) {
+	equipment, err := c.getEquipment(index)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Equipment{
+		Index:    equipment.Index,
+		Name:     equipment.Name,
+		Category: equipment.EquipmentCategory.Index,
+		Weight:   equipment.Weight,
+	}
+	switch result.Category {
+	case "weapon":
+		result.Weapon = equipment.weapon()
+	case "armor":
+		result.Armor = equipment.armor()
+	}
+	return result, nil
+}
+
+// GetWeapon fetches a weapon from the SRD equipment catalog
+func (c *SRDClient) GetWeapon(index string) (*Weapon, error) {
+	equipment, err := c.getEquipment(index)
+	if err != nil {
+		return nil, err
+	}
+	if equipment.EquipmentCategory.Index != "weapon" {
+		return nil, fmt.Errorf("'%s' is not a weapon", index)
+	}
+	return equipment.weapon(), nil
+}
+
+// GetArmor fetches armor or a shield from the SRD equipment catalog
+func (c *SRDClient) GetArmor(index string) (*Armor, error) {
+	equipment, err := c.getEquipment(index)
+	if err != nil {
+		return nil, err
+	}
+	if equipment.EquipmentCategory.Index != "armor" {
+		return nil, fmt.Errorf("'%s' is not armor", index)
+	}
+	return equipment.armor(), nil
+}
+
+// weapon converts the SRD payload of a weapon into our Weapon
+func (equipment *apiEquipment) weapon() *Weapon {
+	weapon := &Weapon{
+		Index:       equipment.Index,
+		Name:        equipment.Name,
+		Category:    strings.ToLower(equipment.WeaponCategory),
+		WeaponRange: strings.ToLower(equipment.WeaponRange),
+		NormalRange: equipment.Range.Normal,
+		LongRange:   equipment.Range.Long,
+		ThrowNormal: equipment.ThrowRange.Normal,
+		ThrowLong:   equipment.ThrowRange.Long,
+		Weight:      equipment.Weight,
+	}
+
+	// A net deals no damage
+	if equipment.Damage != nil {
+		weapon.Damage = equipment.Damage.convert()
+	}
+	if equipment.TwoHandedDamage != nil {
+		damage := equipment.TwoHandedDamage.convert()
+		weapon.TwoHandedDamage = &damage
+	}
+	for _, property := range equipment.Properties {
+		weapon.Properties = append(weapon.Properties, property.Index)
+	}
+	return weapon
+}
+
+// armor converts the SRD payload of armor or a shield into our Armor
+func (equipment *apiEquipment) armor() *Armor {
+	return &Armor{
+		Index:               equipment.Index,
+		Name:                equipment.Name,
+		Category:            strings.ToLower(equipment.ArmorCategory),
+		BaseAC:              equipment.ArmorClass.Base,
+		DexBonus:            equipment.ArmorClass.DexBonus,
+		MaxDexBonus:         equipment.ArmorClass.MaxBonus,
+		StrengthMinimum:     equipment.StrMinimum,
+		StealthDisadvantage: equipment.StealthDisadvantage,
+		Weight:              equipment.Weight,
+	}
+}
+
+// apiMonsterAction is an action, legendary action or special ability in the SRD monster payload
+type apiMonsterAction struct {
+	Name            string `json:"name"`
+	Description     string `json:"desc"`
+	AttackBonus     int    `json:"attack_bonus,omitempty"`
+	MultiattackType string `json:"multiattack_type,omitempty"`
+	Actions         []struct {
+		ActionName string          `json:"action_name"`
+		Count      json.RawMessage `json:"count"`
+	} `json:"actions,omitempty"`
+	Usage *struct {
+		Type     string `json:"type"`
+		Dice     string `json:"dice"`
+		MinValue int    `json:"min_value"`
+		Times    int    `json:"times"`
+	} `json:"usage,omitempty"`
+	DC *struct {
+		DCType struct {
+			Index string `json:"index"`
+		} `json:"dc_type"`
+		DCValue     int    `json:"dc_value"`
+		SuccessType string `json:"success_type"`
+	} `json:"dc,omitempty"`
+	Damage []struct {
+		DamageDice string `json:"damage_dice"`
+		DamageType struct {
+			Index string `json:"index"`
+			Name  string `json:"name"`
+		} `json:"damage_type"`
+	} `json:"damage,omitempty"`
+}
+
+// convert turns an SRD monster action into our MonsterAction
+func (a apiMonsterAction) convert() MonsterAction {
+	monsterAction := MonsterAction{
+		Name:        a.Name,
+		Description: a.Description,
+		AttackBonus: a.AttackBonus,
+		// Life Drain and similar attacks only describe this in prose
+		ReducesMaxHP: strings.Contains(a.Description, "hit point maximum is reduced"),
+	}
+
+	// Reach and range are only given in the attack's description
+	monsterAction.Reach, monsterAction.Range, monsterAction.LongRange = parseAttackDistances(a.Description)
+
+	// Process damage, keeping further damage types dealt on the same hit
+	for i, damage := range a.Damage {
+		if damage.DamageDice == "" {
+			continue
+		}
+		diceCount, diceValue, bonus := parseDamageDice(damage.DamageDice)
+		info := DamageInfo{
+			DiceCount: diceCount,
+			DiceValue: diceValue,
+			Bonus:     bonus,
+			Type:      damage.DamageType.Index,
+		}
+		if i == 0 {
+			monsterAction.Damage = info
+		} else {
+			monsterAction.ExtraDamage = append(monsterAction.ExtraDamage, info)
+		}
+	}
+
+	// Multiattack lists the attacks it makes; choices between options aren't modelled
+	if a.MultiattackType == "actions" {
+		for _, attack := range a.Actions {
+			count, err := strconv.Atoi(strings.Trim(string(attack.Count), `"`))
+			if err != nil || count <= 0 {
+				count = 1
+			}
+			monsterAction.Multiattack = append(monsterAction.Multiattack, MultiattackEntry{
+				ActionName: attack.ActionName,
+				Count:      count,
+			})
+		}
+	}
+
+	if a.Usage != nil {
+		monsterAction.Usage = &ActionUsage{
+			Type:     a.Usage.Type,
+			Dice:     a.Usage.Dice,
+			MinValue: a.Usage.MinValue,
+			Times:    a.Usage.Times,
+		}
+	}
+
+	if a.DC != nil {
+		monsterAction.SaveDC = a.DC.DCValue
+		monsterAction.SaveAbility = a.DC.DCType.Index
+		monsterAction.SaveSuccess = a.DC.SuccessType
+
+		// The area of an action like a breath weapon is only given in its description
+		if a.AttackBonus == 0 {
+			monsterAction.Area, monsterAction.AreaRange = parseActionArea(a.Description)
+		}
+	}
+
+	return monsterAction
+}
+
+// attackReachPattern and attackRangePattern match the distances in attack descriptions
+// like "Melee or Ranged Weapon Attack: +4 to hit, reach 5 ft. or range 20/60 ft."
+var (
+	attackReachPattern = regexp.MustCompile(`reach (\d+) ft`)
+	attackRangePattern = regexp.MustCompile(`range (\d+)(?:/(\d+))? ft`)
+)
+
+// parseAttackDistances parses the melee reach and the normal and long range of an attack
+// from its description. Distances the attack doesn't have are 0.
+func parseAttackDistances(description string) (reach, normalRange, longRange int) {
+	if match := attackReachPattern.FindStringSubmatch(description); match != nil {
+		reach, _ = strconv.Atoi(match[1])
+	}
+	if match := attackRangePattern.FindStringSubmatch(description); match != nil {
+		normalRange, _ = strconv.Atoi(match[1])
+		longRange = normalRange
+		if match[2] != "" {
+			longRange, _ = strconv.Atoi(match[2])
+		}
+	}
+	return reach, normalRange, longRange
+}
+
+// actionAreaPattern and actionWithinPattern match the areas in action descriptions like
+// "exhales fire in a 60-foot cone", "a 20-foot-radius sphere centered on a point it can see
+// within 120 feet of it" and "each creature within 10 feet of the dragon"
+var (
+	actionAreaPattern   = regexp.MustCompile(`(\d+)-foot(?:-radius)?(?:, \d+-foot-high)? (cone|line|cube|sphere|cylinder)`)
+	actionWithinPattern = regexp.MustCompile(`within (\d+) feet`)
+)
+
+// parseActionArea parses the area of effect of a monster action from its description, and
+// how far from the monster the area can be placed: 0 for cones and lines, which start at the
+// monster, and for areas given only as a distance from the monster, which surround it. The
+// area is nil when the description gives none.
+func parseActionArea(description string) (*AreaOfEffect, int) {
+	within := 0
+	if match := actionWithinPattern.FindStringSubmatch(description); match != nil {
+		within, _ = strconv.Atoi(match[1])
+	}
+
+	if match := actionAreaPattern.FindStringSubmatch(description); match != nil {
+		size, _ := strconv.Atoi(match[1])
+		area := &AreaOfEffect{Type: match[2], Size: size}
+		if area.Type == "cone" || area.Type == "line" {
+			return area, 0
+		}
+		return area, within
+	}
+
+	if within > 0 {
+		return &AreaOfEffect{Type: "sphere", Size: within}, 0
+	}
+	return nil, 0
+}
+
+// legendaryActionCost parses the cost of a legendary action from names like "Wing Attack (Costs 2 Actions)"
+func legendaryActionCost(name string) int {
+	cost := 1
+	if i := strings.Index(name, "("); i >= 0 {
+		if _, err := fmt.Sscanf(name[i:], "(Costs %d Actions)", &cost); err != nil {
+			cost = 1
+		}
+	}
+	return cost
+}
+
 // Helper functions
 
 // parseSpeed converts a speed string like "30 ft." to an integer
@@ -394,10 +1030,30 @@ func parseSpeed(speed string) int {
 	return value
 }
 
-// parseDamageDice parses a damage dice string like "2d6+2"
+// parseDamageDice parses a damage dice string like "2d6+2" or "1d8-1"
 func parseDamageDice(dice string) (count, value, bonus int) {
-	fmt.Sscanf(dice, "%dd%d+%d", &count, &value, &bonus)
-	return
+	expression, err := ParseDice(dice)
+	if err != nil {
+		return 0, 0, 0
+	}
+	count, value, bonus, _ = expression.DiceAndBonus()
+	return count, value, bonus
+}
+
+// parseLevelTable converts an SRD table keyed by level strings like "3" into a map keyed by level
+func parseLevelTable(table map[string]string) map[int]string {
+	if len(table) == 0 {
+		return nil
+	}
+
+	result := make(map[int]string, len(table))
+	for key, value := range table {
+		var level int
+		if _, err := fmt.Sscanf(key, "%d", &level); err == nil {
+			result[level] = value
+		}
+	}
+	return result
 }
 
 // combineStringSlice joins a string slice into a single string
@@ -435,6 +1091,15 @@ type Monster struct {
 	Actions      []MonsterAction `json:"actions"`
 	ChallengeRating float64     `json:"challenge_rating"`
 	XP           int            `json:"xp"`
+	DamageVulnerabilities []string `json:"damage_vulnerabilities,omitempty"`
+	DamageResistances     []string `json:"damage_resistances,omitempty"`
+	DamageImmunities      []string `json:"damage_immunities,omitempty"`
+	ConditionImmunities   []string `json:"condition_immunities,omitempty"`
+	LegendaryActions      []MonsterAction `json:"legendary_actions,omitempty"`
+	LegendaryActionCount  int      `json:"legendary_action_count,omitempty"`
+	SpecialAbilities      []MonsterAction `json:"special_abilities,omitempty"`
+	SavingThrows          map[string]int  `json:"saving_throws,omitempty"` // Bonus by ability index
+	Skills                map[string]int  `json:"skills,omitempty"`        // Bonus by skill index
 }
 
 // MonsterSpeed represents a monster's speed capabilities
@@ -451,8 +1116,34 @@ type MonsterAction struct {
 	Name        string      `json:"name"`
 	Description string      `json:"description"`
 	AttackBonus int         `json:"attack_bonus,omitempty"`
+	Reach       int         `json:"reach,omitempty"`
 	Range       int         `json:"range,omitempty"`
+	LongRange   int         `json:"long_range,omitempty"`
 	Damage      DamageInfo  `json:"damage,omitempty"`
+	ExtraDamage []DamageInfo `json:"extra_damage,omitempty"`
+	ReducesMaxHP bool       `json:"reduces_max_hp,omitempty"`
+	Multiattack []MultiattackEntry `json:"multiattack,omitempty"`
+	Usage       *ActionUsage `json:"usage,omitempty"`
+	SaveDC      int         `json:"save_dc,omitempty"`
+	SaveAbility string      `json:"save_ability,omitempty"`
+	SaveSuccess string      `json:"save_success,omitempty"`
+	Area        *AreaOfEffect `json:"area,omitempty"`
+	AreaRange   int         `json:"area_range,omitempty"`
+	Cost        int         `json:"cost,omitempty"`
+}
+
+// MultiattackEntry is one of the attacks a Multiattack action makes
+type MultiattackEntry struct {
+	ActionName string `json:"action_name"`
+	Count      int    `json:"count"`
+}
+
+// ActionUsage limits how often a monster action can be used
+type ActionUsage struct {
+	Type     string `json:"type"` // "recharge on roll", "per day" or "recharge after rest"
+	Dice     string `json:"dice,omitempty"`
+	MinValue int    `json:"min_value,omitempty"`
+	Times    int    `json:"times,omitempty"`
 }
 
 // DamageInfo represents damage dealt by an attack or spell
@@ -476,4 +1167,95 @@ type Spell struct {
 	Description string   `json:"description"`
 	HigherLevel string   `json:"higher_level,omitempty"`
 	Classes     []string `json:"classes"`
+
+	// Structured data used to resolve the spell in combat
+	Concentration          bool           `json:"concentration"`
+	AttackType             string         `json:"attack_type,omitempty"`
+	SaveAbility            string         `json:"save_ability,omitempty"`
+	SaveSuccess            string         `json:"save_success,omitempty"`
+	DamageType             string         `json:"damage_type,omitempty"`
+	DamageAtSlotLevel      map[int]string `json:"damage_at_slot_level,omitempty"`
+	DamageAtCharacterLevel map[int]string `json:"damage_at_character_level,omitempty"`
+	HealAtSlotLevel        map[int]string `json:"heal_at_slot_level,omitempty"`
+	AreaOfEffect           *AreaOfEffect  `json:"area_of_effect,omitempty"`
+	Conditions             []string       `json:"conditions,omitempty"`
+	ACBonus                int            `json:"ac_bonus,omitempty"`
+	TempHPAtSlotLevel      map[int]string `json:"temp_hp_at_slot_level,omitempty"`
+	Terrain                string         `json:"terrain,omitempty"`
+}
+
+// AreaOfEffect represents the shape and size of a spell's area
+type AreaOfEffect struct {
+	Type string `json:"type"`
+	Size int    `json:"size"`
+}
+
+// Weapon represents a weapon from the SRD equipment catalog
+type Weapon struct {
+	Index           string      `json:"index"`
+	Name            string      `json:"name"`
+	Category        string      `json:"category"`     // "simple" or "martial"
+	WeaponRange     string      `json:"weapon_range"` // "melee" or "ranged"
+	Damage          DamageInfo  `json:"damage"`
+	TwoHandedDamage *DamageInfo `json:"two_handed_damage,omitempty"`
+	Properties      []string    `json:"properties"`
+	NormalRange     int         `json:"normal_range"`
+	LongRange       int         `json:"long_range,omitempty"`
+	ThrowNormal     int         `json:"throw_normal,omitempty"`
+	ThrowLong       int         `json:"throw_long,omitempty"`
+	Weight          float64     `json:"weight"`
+}
+
+// Equipment represents any item from the SRD equipment catalog
+type Equipment struct {
+	Index    string  `json:"index"`
+	Name     string  `json:"name"`
+	Category string  `json:"category"`
+	Weight   float64 `json:"weight"`
+	Weapon   *Weapon `json:"weapon,omitempty"`
+	Armor    *Armor  `json:"armor,omitempty"`
+}
+
+// Armor represents armor or a shield from the SRD equipment catalog
+type Armor struct {
+	Index               string  `json:"index"`
+	Name                string  `json:"name"`
+	Category            string  `json:"category"` // "light", "medium", "heavy" or "shield"
+	BaseAC              int     `json:"base_ac"`
+	DexBonus            bool    `json:"dex_bonus"`
+	MaxDexBonus         int     `json:"max_dex_bonus,omitempty"`
+	StrengthMinimum     int     `json:"strength_minimum,omitempty"`
+	StealthDisadvantage bool    `json:"stealth_disadvantage"`
+	Weight              float64 `json:"weight"`
+}
+
+// spellEffects holds the effects the SRD API only describes in prose
+var spellEffects = map[string]struct {
+	Conditions        []string
+	ACBonus           int
+	Terrain           string
+	TempHPAtSlotLevel map[int]string
+}{
+	"blindness-deafness":      {Conditions: []string{"blinded"}},
+	"command":                 {Conditions: []string{"prone"}},
+	"entangle":                {Conditions: []string{"restrained"}, Terrain: "difficult"},
+	"fear":                    {Conditions: []string{"frightened"}},
+	"charm-person":            {Conditions: []string{"charmed"}},
+	"hold-monster":            {Conditions: []string{"paralyzed"}},
+	"hold-person":             {Conditions: []string{"paralyzed"}},
+	"hypnotic-pattern":        {Conditions: []string{"charmed", "incapacitated"}},
+	"tashas-hideous-laughter": {Conditions: []string{"prone", "incapacitated"}},
+	"web":                     {Conditions: []string{"restrained"}, Terrain: "difficult"},
+	"spike-growth":            {Terrain: "difficult"},
+	"flesh-to-stone":          {Conditions: []string{"restrained"}},
+	"greater-invisibility":    {Conditions: []string{"invisible"}},
+	"invisibility":            {Conditions: []string{"invisible"}},
+	"shield-of-faith":         {ACBonus: 2},
+	"false-life": {TempHPAtSlotLevel: map[int]string{
+		1: "1d4+4", 2: "1d4+9", 3: "1d4+14", 4: "1d4+19", 5: "1d4+24",
+		6: "1d4+29", 7: "1d4+34", 8: "1d4+39", 9: "1d4+44",
+	}},
+	"heroism": {TempHPAtSlotLevel: map[int]string{
+		1: "MOD", 2: "MOD", 3: "MOD", 4: "MOD", 5: "MOD", 6: "MOD", 7: "MOD", 8: "MOD", 9: "MOD",
+	}},
 }
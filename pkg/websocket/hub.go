@@ -195,6 +195,15 @@ func (h *Hub) SendToUser(userID string, message Message) {
 	client.mu.Unlock()
 }
 
+// IsUserConnected reports whether a user currently has an open connection
+func (h *Hub) IsUserConnected(userID string) bool {
+	h.usersMu.RLock()
+	defer h.usersMu.RUnlock()
+
+	_, ok := h.users[userID]
+	return ok
+}
+
 // readPump reads messages from the websocket connection and handles them
 func (c *Client) readPump() {
 	defer func() {